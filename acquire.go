@@ -0,0 +1,78 @@
+package roko
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrNotAcquired is returned by Acquire when the retrier gives up before the lock could be acquired, because it was
+// held by someone else every time it was tried
+var ErrNotAcquired = errors.New("lock not acquired")
+
+type acquireConfig struct {
+	onWaiting func(attempt int, next time.Duration)
+}
+
+type acquireOpt func(*acquireConfig)
+
+// WithOnWaiting sets a function that Acquire calls each time it finds the lock held by someone else, and is going to try
+// again. It's called with the number of the attempt that found the lock held (starting at 1), and how long Acquire will
+// wait before the next one. It's useful for logging or measuring lock contention
+func WithOnWaiting(f func(attempt int, next time.Duration)) acquireOpt {
+	return func(c *acquireConfig) {
+		c.onWaiting = f
+	}
+}
+
+// Acquire tries to acquire a contended lock or lease, retrying with r until it's acquired. try should attempt to take
+// the lock without blocking (like a TryLock), and return whether it's now held. Finding the lock held by someone else
+// isn't an error - it just means waiting and trying again - but errors returned by try are retried too, as with any
+// other retry loop. If r gives up, Acquire returns the error from the last attempt if it was an error, or
+// ErrNotAcquired if the lock was held.
+//
+// r must use jitter (see WithJitter). Without it, processes that were waiting for the same lock retry in lockstep, and
+// keep colliding with each other, so Acquire panics if r doesn't have it
+func Acquire(ctx context.Context, r *Retrier, try func(ctx context.Context) (held bool, err error), opts ...acquireOpt) error {
+	if !r.hasJitter() {
+		panic("retriers used to acquire locks must use jitter")
+	}
+
+	c := &acquireConfig{onWaiting: func(int, time.Duration) {}}
+	for _, o := range opts {
+		o(c)
+	}
+
+	return r.run(ctx, func(r *Retrier) error {
+		held, err := try(r.Context())
+		if err != nil {
+			return err
+		}
+		if held {
+			return nil
+		}
+
+		if !r.isLastAttempt() {
+			c.onWaiting(r.AttemptCount()+1, r.NextInterval())
+		}
+		return ErrNotAcquired
+	}, nil)
+}
+
+// hasJitter returns whether the retrier adds jitter to the intervals calculated by its strategy
+func (r *Retrier) hasJitter() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.jitterMode.isSet() || (r.jitter && r.jitterRange.max > r.jitterRange.min)
+}
+
+// isLastAttempt returns whether the retrier will give up if the attempt in progress fails
+func (r *Retrier) isLastAttempt() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.attemptCount++
+	defer func() { r.attemptCount-- }()
+	return r.shouldGiveUp()
+}
@@ -0,0 +1,91 @@
+package roko
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestAcquire_RetriesUntilTheLockIsHeld(t *testing.T) {
+	t.Parallel()
+
+	tries := 0
+	type waiting struct {
+		attempt int
+		next    time.Duration
+	}
+	waits := []waiting{}
+
+	r := NewRetrier(
+		WithMaxAttempts(5),
+		WithStrategy(Constant(time.Second)),
+		WithJitter(FullJitter),
+		WithSleepFunc(dummySleep),
+	)
+	err := Acquire(context.Background(), r, func(ctx context.Context) (bool, error) {
+		tries++
+		return tries == 3, nil
+	}, WithOnWaiting(func(attempt int, next time.Duration) {
+		waits = append(waits, waiting{attempt, next})
+	}))
+
+	assert.NilError(t, err)
+	assert.Equal(t, 3, tries)
+	assert.Equal(t, 2, len(waits))
+	assert.Equal(t, 1, waits[0].attempt)
+	assert.Equal(t, 2, waits[1].attempt)
+	for _, w := range waits {
+		assert.Assert(t, w.next >= 0 && w.next <= time.Second)
+	}
+}
+
+func TestAcquire_WhenTheLockIsAlwaysHeld_ReturnsErrNotAcquired(t *testing.T) {
+	t.Parallel()
+
+	waits := 0
+	r := NewRetrier(
+		WithMaxAttempts(3),
+		WithStrategy(Constant(time.Second)),
+		WithJitter(),
+		WithSleepFunc(dummySleep),
+	)
+	err := Acquire(context.Background(), r, func(ctx context.Context) (bool, error) {
+		return false, nil
+	}, WithOnWaiting(func(int, time.Duration) { waits++ }))
+
+	assert.ErrorIs(t, err, ErrNotAcquired)
+	assert.Equal(t, 3, r.AttemptCount())
+	// There's no waiting after the last attempt
+	assert.Equal(t, 2, waits)
+}
+
+func TestAcquire_RetriesErrors(t *testing.T) {
+	t.Parallel()
+
+	errConn := errors.New("connection refused")
+	r := NewRetrier(
+		WithMaxAttempts(3),
+		WithStrategy(Constant(time.Second)),
+		WithJitter(),
+		WithSleepFunc(dummySleep),
+	)
+	err := Acquire(context.Background(), r, func(ctx context.Context) (bool, error) {
+		return false, errConn
+	})
+
+	assert.ErrorIs(t, err, errConn)
+	assert.Equal(t, 3, r.AttemptCount())
+}
+
+func TestAcquire_WithoutJitter_Panics(t *testing.T) {
+	t.Parallel()
+
+	r := NewRetrier(WithMaxAttempts(3), WithStrategy(Constant(time.Second)))
+	defer func() {
+		assert.Assert(t, recover() != nil)
+	}()
+	_ = Acquire(context.Background(), r, func(ctx context.Context) (bool, error) { return true, nil })
+}
@@ -0,0 +1,104 @@
+package roko
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+)
+
+// AttemptInfo describes a single attempt made by a retrier. It's available from the context returned by r.Context()
+// during an attempt, so that code called from inside the attempt (loggers, HTTP middleware and so on) can tell which
+// attempt it's part of, without it having to be passed down explicitly
+type AttemptInfo struct {
+	// ID identifies the retry loop the attempt belongs to. It's the same for every attempt made by one call to Do or
+	// DoWithContext, and different for each call, so it can be used to tie together the logs from every attempt
+	ID string
+
+	// Attempt is the number of the attempt within its loop, starting at 1
+	Attempt int
+
+	// Name is the name of the retrier making the attempt, if it has one (see WithName)
+	Name string
+
+	// IdempotencyKey is the key to send with the operation to APIs that support idempotency keys. Like ID, it's the same
+	// for every attempt in a loop, so the server can tell that a retry is the same operation rather than a new one. It's
+	// only set for retriers using WithIdempotencyKeys
+	IdempotencyKey string
+}
+
+type attemptInfoKey struct{}
+
+// AttemptFromContext returns information about the attempt that ctx belongs to. ok is false if ctx didn't come from a
+// retrier's Context() during an attempt, or from WithAttemptInfo
+func AttemptFromContext(ctx context.Context) (info AttemptInfo, ok bool) {
+	info, ok = ctx.Value(attemptInfoKey{}).(AttemptInfo)
+	return info, ok
+}
+
+// WithAttemptInfo returns a copy of ctx carrying info, for AttemptFromContext to find. Retriers do this themselves for
+// the contexts they pass to attempts; it's for code that runs attempts some other way, and for tests of code that
+// reads the attempt info
+func WithAttemptInfo(ctx context.Context, info AttemptInfo) context.Context {
+	return context.WithValue(ctx, attemptInfoKey{}, info)
+}
+
+// newLoopInfo returns the AttemptInfo for a new retry loop using r, before its first attempt
+func newLoopInfo(r *Retrier) AttemptInfo {
+	info := AttemptInfo{ID: newCorrelationID(), Name: r.name}
+	if r.idempotencyKey != nil {
+		info.IdempotencyKey = r.idempotencyKey()
+	}
+	return info
+}
+
+// WithIdempotencyKeys gives each retry loop an idempotency key, available from AttemptInfo during each of its attempts:
+//
+//	err := r.Do(func(r *roko.Retrier) error {
+//		info, _ := roko.AttemptFromContext(r.Context())
+//		req.Header.Set("Idempotency-Key", info.IdempotencyKey)
+//		// ...
+//	})
+//
+// Keys are random UUIDs by default; a generator can be passed to make them some other way. The generator is called once
+// at the start of each loop
+func WithIdempotencyKeys(generator ...func() string) retrierOpt {
+	if len(generator) > 1 {
+		panic("WithIdempotencyKeys accepts at most one generator")
+	}
+
+	gen := newUUID
+	if len(generator) == 1 {
+		gen = generator[0]
+	}
+
+	return func(r *Retrier) {
+		r.idempotencyKey = gen
+	}
+}
+
+// newUUID returns a random (version 4) UUID
+func newUUID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		// As with newCorrelationID, this can't happen on any platform we support, but an idempotency key that's shared
+		// with other operations would be worse than a crash
+		panic(fmt.Sprintf("generating idempotency key: %v", err))
+	}
+
+	b[6] = (b[6] & 0x0f) | 0x40 // version 4
+	b[8] = (b[8] & 0x3f) | 0x80 // RFC 4122 variant
+
+	h := hex.EncodeToString(b)
+	return h[0:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:32]
+}
+
+// newCorrelationID returns a random ID for a retry loop
+func newCorrelationID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		// crypto/rand doesn't fail on any platform we support, and an ID is only a diagnostic aid anyway
+		return "unknown"
+	}
+	return hex.EncodeToString(b)
+}
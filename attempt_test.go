@@ -0,0 +1,140 @@
+package roko
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+)
+
+func TestAttemptFromContext_DuringAnAttempt(t *testing.T) {
+	t.Parallel()
+
+	infos := []AttemptInfo{}
+	err := NewRetrier(
+		WithStrategy(Constant(1*time.Second)),
+		WithMaxAttempts(3),
+		WithSleepFunc(dummySleep),
+	).Do(func(r *Retrier) error {
+		info, ok := AttemptFromContext(r.Context())
+		assert.Assert(t, ok)
+		infos = append(infos, info)
+		return errDummy
+	})
+	assert.ErrorIs(t, err, errDummy)
+
+	assert.Equal(t, 3, len(infos))
+	for i, info := range infos {
+		assert.Equal(t, i+1, info.Attempt)
+		assert.Equal(t, infos[0].ID, info.ID)
+	}
+	assert.Equal(t, 16, len(infos[0].ID))
+}
+
+func TestAttemptFromContext_EachLoopGetsItsOwnID(t *testing.T) {
+	t.Parallel()
+
+	ids := []string{}
+	for i := 0; i < 2; i++ {
+		err := NewRetrier(NoRetry()).Do(func(r *Retrier) error {
+			info, _ := AttemptFromContext(r.Context())
+			ids = append(ids, info.ID)
+			return nil
+		})
+		assert.NilError(t, err)
+	}
+
+	assert.Check(t, ids[0] != ids[1])
+}
+
+func TestAttemptFromContext_OutsideAnAttempt(t *testing.T) {
+	t.Parallel()
+
+	_, ok := AttemptFromContext(context.Background())
+	assert.Check(t, !ok)
+
+	_, ok = AttemptFromContext(NewRetrier(NoRetry()).Context())
+	assert.Check(t, !ok)
+}
+
+func TestWithIdempotencyKeys_KeyIsTheSameForEveryAttemptInALoop(t *testing.T) {
+	t.Parallel()
+
+	loops := [][]string{}
+	for i := 0; i < 2; i++ {
+		r := NewRetrier(
+			WithStrategy(Constant(1*time.Second)),
+			WithMaxAttempts(3),
+			WithSleepFunc(dummySleep),
+			WithIdempotencyKeys(),
+		)
+
+		keys := []string{}
+		_ = r.Do(func(r *Retrier) error {
+			info, _ := AttemptFromContext(r.Context())
+			keys = append(keys, info.IdempotencyKey)
+			return errDummy
+		})
+		loops = append(loops, keys)
+	}
+
+	assert.Equal(t, 3, len(loops[0]))
+	for _, key := range loops[0] {
+		assert.Equal(t, loops[0][0], key)
+	}
+	assert.Assert(t, cmp.Regexp(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`, loops[0][0]))
+	assert.Check(t, loops[0][0] != loops[1][0])
+}
+
+func TestWithIdempotencyKeys_UsesTheGenerator(t *testing.T) {
+	t.Parallel()
+
+	n := 0
+	r := NewRetrier(NoRetry(), WithIdempotencyKeys(func() string {
+		n++
+		return fmt.Sprintf("op-%d", n)
+	}))
+
+	keys := []string{}
+	for i := 0; i < 2; i++ {
+		assert.NilError(t, r.Do(func(r *Retrier) error {
+			info, _ := AttemptFromContext(r.Context())
+			keys = append(keys, info.IdempotencyKey)
+			return nil
+		}))
+	}
+
+	assert.DeepEqual(t, []string{"op-1", "op-2"}, keys)
+}
+
+func TestAttemptFromContext_WithoutIdempotencyKeys_HasNoKey(t *testing.T) {
+	t.Parallel()
+
+	assert.NilError(t, NewRetrier(NoRetry()).Do(func(r *Retrier) error {
+		info, _ := AttemptFromContext(r.Context())
+		assert.Equal(t, "", info.IdempotencyKey)
+		return nil
+	}))
+}
+
+func TestAttemptFromContext_IncludesTheRetriersName(t *testing.T) {
+	t.Parallel()
+
+	assert.NilError(t, NewRetrier(NoRetry(), WithName("upload-artifact")).Do(func(r *Retrier) error {
+		info, _ := AttemptFromContext(r.Context())
+		assert.Equal(t, "upload-artifact", info.Name)
+		return nil
+	}))
+}
+
+func TestWithAttemptInfo(t *testing.T) {
+	t.Parallel()
+
+	want := AttemptInfo{ID: "abc123", Attempt: 2, Name: "fetch"}
+	info, ok := AttemptFromContext(WithAttemptInfo(context.Background(), want))
+	assert.Assert(t, ok)
+	assert.Equal(t, want, info)
+}
@@ -0,0 +1,101 @@
+package roko
+
+import (
+	"context"
+	"time"
+)
+
+// defaultPressurePoll is how often a paused retrier checks the pressure, if Backpressure.PollInterval isn't set
+const defaultPressurePoll = time.Second
+
+// Backpressure configures WithBackpressure
+type Backpressure struct {
+	// Pressure returns the current load on whatever the retrier is retrying against - the depth of an upstream queue,
+	// local CPU use, or anything else - as a number between 0 (no load) and 1 (full). Values outside that range are
+	// clamped to it. It's called from the retry loop, so it should be quick
+	Pressure func() float64
+
+	// MaxFactor is what intervals are multiplied by at full pressure. They're stretched in proportion to the pressure
+	// below that, so at a pressure of 0.5 and a MaxFactor of 5, intervals are three times as long. Leaving it as zero
+	// means intervals aren't stretched
+	MaxFactor float64
+
+	// PauseAbove is the pressure at or above which the retrier holds off on attempts altogether, checking the pressure
+	// every PollInterval (a second by default) until it drops. Leaving it as zero means the retrier never pauses
+	PauseAbove   float64
+	PollInterval time.Duration
+}
+
+// WithBackpressure makes the retrier respond to load, stretching its intervals as pressure rises, and pausing before
+// attempts while pressure is high, so that retries don't pile onto a system that's already struggling:
+//
+//	roko.WithBackpressure(roko.Backpressure{
+//		Pressure:   func() float64 { return float64(queue.Len()) / float64(queue.Cap()) },
+//		MaxFactor:  4,   // Wait up to four times as long between attempts as the queue fills...
+//		PauseAbove: 0.9, // ...and don't make any attempts at all while it's almost full
+//	})
+//
+// The pressure is checked before every attempt, including the first, and the interval that follows an attempt is
+// stretched according to the pressure just before it. Time spent paused doesn't count towards WithMaxTotalSleep, but a
+// pause ends early if the loop's context is done. Intervals set using SetNextInterval aren't stretched
+func WithBackpressure(b Backpressure) retrierOpt {
+	if b.Pressure == nil {
+		panic("backpressure must have a pressure func")
+	}
+	if b.MaxFactor < 0 || (b.MaxFactor > 0 && b.MaxFactor < 1) {
+		panic("backpressure max factors must be at least 1")
+	}
+	if b.PauseAbove < 0 || b.PauseAbove > 1 {
+		panic("backpressure pause thresholds must be between 0 and 1")
+	}
+	if b.PollInterval < 0 {
+		panic("backpressure poll intervals must not be negative")
+	}
+
+	if b.MaxFactor == 0 {
+		b.MaxFactor = 1
+	}
+	if b.PollInterval == 0 {
+		b.PollInterval = defaultPressurePoll
+	}
+
+	return func(r *Retrier) {
+		r.backpressure = &b
+	}
+}
+
+// pressure returns the current pressure, clamped to between 0 and 1
+func (b *Backpressure) pressure() float64 {
+	p := b.Pressure()
+	switch {
+	case p > 1:
+		return 1
+	case p > 0:
+		return p
+	default:
+		return 0 // Including NaN
+	}
+}
+
+// stretch stretches an interval according to the current pressure
+func (b *Backpressure) stretch(interval time.Duration) time.Duration {
+	if b.MaxFactor == 1 {
+		return interval
+	}
+	return saturatingDuration(float64(interval)*(1+(b.MaxFactor-1)*b.pressure()), 1)
+}
+
+// waitForPressure waits until the retrier's backpressure (if it has any) is below its pause threshold, or ctx is done
+func (r *Retrier) waitForPressure(ctx context.Context) error {
+	b := r.backpressure
+	if b == nil || b.PauseAbove == 0 {
+		return nil
+	}
+
+	for b.pressure() >= b.PauseAbove {
+		if err := r.sleepOrDone(ctx, b.PollInterval); err != nil {
+			return err
+		}
+	}
+	return nil
+}
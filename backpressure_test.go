@@ -0,0 +1,126 @@
+package roko
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestWithBackpressure_StretchesIntervals(t *testing.T) {
+	t.Parallel()
+
+	pressures := []float64{0, 0.5, 1, 2, 0}
+	var slept []time.Duration
+	r := NewRetrier(
+		WithMaxAttempts(5),
+		WithStrategy(Constant(time.Second)),
+		WithBackpressure(Backpressure{
+			Pressure: func() float64 {
+				p := pressures[0]
+				pressures = pressures[1:]
+				return p
+			},
+			MaxFactor: 5,
+		}),
+		WithSleepFunc(func(d time.Duration) { slept = append(slept, d) }),
+	)
+
+	err := r.Do(func(r *Retrier) error {
+		if r.AttemptCount() < 4 {
+			return errors.New("failed")
+		}
+		return nil
+	})
+
+	assert.NilError(t, err)
+	// Pressures over 1 are treated as 1
+	assert.DeepEqual(t, []time.Duration{time.Second, 3 * time.Second, 5 * time.Second, 5 * time.Second}, slept)
+}
+
+func TestWithBackpressure_PausesWhilePressureIsHigh(t *testing.T) {
+	t.Parallel()
+
+	pressures := []float64{0.95, 0.95, 0.5, 1, 0.2}
+	var slept []time.Duration
+	attempts := 0
+	r := NewRetrier(
+		WithMaxAttempts(3),
+		WithStrategy(Constant(time.Second)),
+		WithBackpressure(Backpressure{
+			Pressure: func() float64 {
+				p := pressures[0]
+				pressures = pressures[1:]
+				return p
+			},
+			PauseAbove:   0.9,
+			PollInterval: 100 * time.Millisecond,
+		}),
+		WithSleepFunc(func(d time.Duration) { slept = append(slept, d) }),
+	)
+
+	err := r.Do(func(r *Retrier) error {
+		attempts++
+		if attempts == 1 {
+			return errors.New("failed")
+		}
+		return nil
+	})
+
+	assert.NilError(t, err)
+	assert.Equal(t, 2, attempts)
+	// Two polls before the first attempt, and one more after the retry's wait
+	assert.DeepEqual(t, []time.Duration{100 * time.Millisecond, 100 * time.Millisecond, time.Second, 100 * time.Millisecond}, slept)
+	assert.Equal(t, 0, len(pressures))
+}
+
+func TestWithBackpressure_PauseEndsWhenTheContextIsDone(t *testing.T) {
+	t.Parallel()
+
+	r := NewRetrier(
+		WithMaxAttempts(3),
+		WithStrategy(Constant(time.Second)),
+		WithBackpressure(Backpressure{Pressure: func() float64 { return 1 }, PauseAbove: 1}),
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	called := false
+	err := r.DoWithContext(ctx, func(*Retrier) error {
+		called = true
+		return nil
+	})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Assert(t, !called)
+}
+
+func TestWithBackpressure_PanicsOnBadConfig(t *testing.T) {
+	t.Parallel()
+
+	pressure := func() float64 { return 0 }
+	for _, b := range []Backpressure{
+		{},
+		{Pressure: pressure, MaxFactor: 0.5},
+		{Pressure: pressure, PauseAbove: 1.5},
+		{Pressure: pressure, PollInterval: -time.Second},
+	} {
+		func() {
+			defer func() { assert.Assert(t, recover() != nil, "%+v", b) }()
+			WithBackpressure(b)
+		}()
+	}
+}
+
+func TestWithBackpressure_Describe(t *testing.T) {
+	t.Parallel()
+
+	r := NewRetrier(
+		WithMaxAttempts(3),
+		WithStrategy(Constant(time.Second)),
+		WithBackpressure(Backpressure{Pressure: func() float64 { return 0 }, MaxFactor: 2}),
+	)
+	assert.Equal(t, "constant(1s), up to 3 attempts, with backpressure", r.Describe())
+}
@@ -0,0 +1,73 @@
+package roko
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrItemFailed is recorded against items that a RetryBatch function reports as failed without returning an error
+var ErrItemFailed = errors.New("roko: batch item failed")
+
+// BatchResult is the outcome of one of the items passed to RetryBatch
+type BatchResult[T any] struct {
+	Item     T
+	Attempts int   // The number of attempts that included the item
+	Err      error // The error from the item's last attempt, or nil if it succeeded
+}
+
+// RetryBatch retries a bulk operation that can partially fail, without redoing the work that's already succeeded. Each
+// attempt calls f with only the items that haven't succeeded yet, starting with all of them, and f returns the ones that
+// failed. If f returns an error without saying which items failed, it's treated as having failed all of them, as when
+// the request couldn't be made at all.
+//
+// RetryBatch returns the outcome of every item, in the same order as items, along with the last error if any of them
+// never succeeded
+func RetryBatch[T comparable](ctx context.Context, r *Retrier, items []T, f func(ctx context.Context, items []T) (failed []T, err error)) ([]BatchResult[T], error) {
+	results := make([]BatchResult[T], len(items))
+	pending := make([]int, len(items)) // indexes of the items that haven't succeeded yet
+	for i, item := range items {
+		results[i].Item = item
+		pending[i] = i
+	}
+
+	err := r.run(ctx, func(r *Retrier) error {
+		batch := make([]T, len(pending))
+		for j, i := range pending {
+			batch[j] = items[i]
+			results[i].Attempts++
+		}
+
+		failed, err := f(r.Context(), batch)
+		if err != nil && len(failed) == 0 {
+			failed = batch
+		}
+		if err == nil {
+			err = ErrItemFailed
+		}
+
+		// Match the failed items up with the pending ones, allowing for the same item appearing more than once
+		counts := make(map[T]int, len(failed))
+		for _, item := range failed {
+			counts[item]++
+		}
+
+		still := []int{}
+		for _, i := range pending {
+			if counts[items[i]] > 0 {
+				counts[items[i]]--
+				results[i].Err = err
+				still = append(still, i)
+			} else {
+				results[i].Err = nil
+			}
+		}
+		pending = still
+
+		if len(pending) == 0 {
+			return nil
+		}
+		return err
+	}, nil)
+
+	return results, err
+}
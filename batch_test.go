@@ -0,0 +1,102 @@
+package roko
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestRetryBatch_RetriesOnlyTheFailedItems(t *testing.T) {
+	t.Parallel()
+
+	batches := [][]string{}
+	results, err := RetryBatch(context.Background(), NewRetrier(
+		WithMaxAttempts(5),
+		WithStrategy(Constant(time.Millisecond)),
+		WithSleepFunc(dummySleep),
+	), []string{"a", "b", "c", "d"}, func(ctx context.Context, items []string) ([]string, error) {
+		batches = append(batches, items)
+		switch len(batches) {
+		case 1:
+			return []string{"b", "d"}, errDummy
+		case 2:
+			return []string{"d"}, nil
+		default:
+			return nil, nil
+		}
+	})
+
+	assert.NilError(t, err)
+	assert.DeepEqual(t, [][]string{{"a", "b", "c", "d"}, {"b", "d"}, {"d"}}, batches)
+
+	attempts := []int{}
+	for _, res := range results {
+		assert.NilError(t, res.Err)
+		attempts = append(attempts, res.Attempts)
+	}
+	assert.DeepEqual(t, []int{1, 2, 1, 3}, attempts)
+}
+
+func TestRetryBatch_WhenTheRetrierGivesUp_ReportsTheItemsThatFailed(t *testing.T) {
+	t.Parallel()
+
+	results, err := RetryBatch(context.Background(), NewRetrier(
+		WithMaxAttempts(3),
+		WithStrategy(Constant(time.Millisecond)),
+		WithSleepFunc(dummySleep),
+	), []int{1, 2, 3}, func(ctx context.Context, items []int) ([]int, error) {
+		return []int{2}, nil
+	})
+
+	assert.ErrorIs(t, err, ErrItemFailed)
+	assert.NilError(t, results[0].Err)
+	assert.ErrorIs(t, results[1].Err, ErrItemFailed)
+	assert.Equal(t, 3, results[1].Attempts)
+	assert.NilError(t, results[2].Err)
+}
+
+func TestRetryBatch_AnErrorWithoutFailedItems_FailsTheWholeBatch(t *testing.T) {
+	t.Parallel()
+
+	errConn := errors.New("connection refused")
+	calls := 0
+	results, err := RetryBatch(context.Background(), NewRetrier(
+		WithMaxAttempts(3),
+		WithStrategy(Constant(time.Millisecond)),
+		WithSleepFunc(dummySleep),
+	), []string{"a", "b"}, func(ctx context.Context, items []string) ([]string, error) {
+		calls++
+		if calls == 1 {
+			return nil, errConn
+		}
+		assert.DeepEqual(t, []string{"a", "b"}, items)
+		return nil, nil
+	})
+
+	assert.NilError(t, err)
+	assert.Equal(t, 2, calls)
+	assert.Equal(t, 2, results[0].Attempts)
+	assert.Equal(t, 2, results[1].Attempts)
+}
+
+func TestRetryBatch_WithDuplicateItems_RetriesEachCopy(t *testing.T) {
+	t.Parallel()
+
+	results, err := RetryBatch(context.Background(), NewRetrier(
+		WithMaxAttempts(2),
+		WithStrategy(Constant(time.Millisecond)),
+		WithSleepFunc(dummySleep),
+	), []string{"a", "a", "a"}, func(ctx context.Context, items []string) ([]string, error) {
+		return []string{"a"}, errDummy
+	})
+
+	assert.ErrorIs(t, err, errDummy)
+	assert.ErrorIs(t, results[0].Err, errDummy)
+	assert.Equal(t, 2, results[0].Attempts)
+	assert.NilError(t, results[1].Err)
+	assert.Equal(t, 1, results[1].Attempts)
+	assert.NilError(t, results[2].Err)
+}
@@ -0,0 +1,83 @@
+package roko
+
+import "time"
+
+// WithBlackout stops the retrier from retrying during a window every day, such as a nightly maintenance window. start
+// and end are times of day, given as the time since midnight in loc, and the window can cross midnight (when end is
+// before start). Any wait that would end inside the window is extended to the end of it, whether it was calculated by
+// the strategy or set using SetNextInterval, and the extended wait counts towards WithMaxTotalSleep. The option can be
+// given more than once to set several windows:
+//
+//	roko.WithBlackout(2*time.Hour, 2*time.Hour+30*time.Minute, time.UTC) // 02:00-02:30 UTC
+//
+// Only retries are held back - the first attempt is always made straight away. If loc is nil, the local time zone is
+// used
+func WithBlackout(start, end time.Duration, loc *time.Location) retrierOpt {
+	if start < 0 || start >= 24*time.Hour || end < 0 || end >= 24*time.Hour {
+		panic("blackout windows must start and end within a day")
+	}
+	if start == end {
+		panic("blackout windows must not be empty")
+	}
+	if loc == nil {
+		loc = time.Local
+	}
+
+	w := blackoutWindow{start: start, end: end, loc: loc}
+	return func(r *Retrier) {
+		r.blackouts = append(r.blackouts, w)
+	}
+}
+
+type blackoutWindow struct {
+	start, end time.Duration
+	loc        *time.Location
+}
+
+// endOf returns the end of the window that t falls in, or false if t isn't in the window
+func (w blackoutWindow) endOf(t time.Time) (time.Time, bool) {
+	t = t.In(w.loc)
+	y, m, d := t.Date()
+	tod := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute +
+		time.Duration(t.Second())*time.Second + time.Duration(t.Nanosecond())
+
+	// Times of day are converted using time.Date so that they're wall clock times, even on days with DST changes
+	switch {
+	case w.start < w.end && tod >= w.start && tod < w.end:
+		return time.Date(y, m, d, 0, 0, 0, int(w.end), w.loc), true
+	case w.start > w.end && tod >= w.start:
+		return time.Date(y, m, d+1, 0, 0, 0, int(w.end), w.loc), true
+	case w.start > w.end && tod < w.end:
+		return time.Date(y, m, d, 0, 0, 0, int(w.end), w.loc), true
+	default:
+		return time.Time{}, false
+	}
+}
+
+// avoidBlackouts returns the time of the first attempt at or after t that doesn't fall into any of the windows
+func avoidBlackouts(t time.Time, windows []blackoutWindow) time.Time {
+	// Windows can overlap or follow on from one another, so keep going until t is clear of all of them. Each pass that
+	// moves t moves it to the end of a window, and there are only so many of those
+	for moved := true; moved; {
+		moved = false
+		for _, w := range windows {
+			if end, ok := w.endOf(t); ok {
+				t, moved = end, true
+			}
+		}
+	}
+	return t
+}
+
+// applyBlackouts extends the retrier's next interval so that the next attempt isn't made during a blackout window
+func (r *Retrier) applyBlackouts() {
+	if len(r.blackouts) == 0 {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.now()
+	r.nextInterval = avoidBlackouts(now.Add(r.nextInterval), r.blackouts).Sub(now)
+}
@@ -0,0 +1,107 @@
+package roko
+
+import (
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestAvoidBlackouts(t *testing.T) {
+	t.Parallel()
+
+	maintenance := blackoutWindow{start: 2 * time.Hour, end: 2*time.Hour + 30*time.Minute, loc: time.UTC}
+	overnight := blackoutWindow{start: 22 * time.Hour, end: 6 * time.Hour, loc: time.UTC}
+	afterOvernight := blackoutWindow{start: 6 * time.Hour, end: 7 * time.Hour, loc: time.UTC}
+
+	day := func(hour, min int) time.Time { return time.Date(2022, 6, 15, hour, min, 0, 0, time.UTC) }
+
+	testCases := []struct {
+		name    string
+		at      time.Time
+		windows []blackoutWindow
+		want    time.Time
+	}{
+		{"before the window", day(1, 59), []blackoutWindow{maintenance}, day(1, 59)},
+		{"start of the window", day(2, 0), []blackoutWindow{maintenance}, day(2, 30)},
+		{"inside the window", day(2, 10), []blackoutWindow{maintenance}, day(2, 30)},
+		{"end of the window", day(2, 30), []blackoutWindow{maintenance}, day(2, 30)},
+		{"late in an overnight window", day(23, 0), []blackoutWindow{overnight}, day(30, 0)},
+		{"early in an overnight window", day(3, 0), []blackoutWindow{overnight}, day(6, 0)},
+		{"outside an overnight window", day(12, 0), []blackoutWindow{overnight}, day(12, 0)},
+		{"windows that follow on", day(23, 0), []blackoutWindow{afterOvernight, overnight}, day(31, 0)},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tc.want, avoidBlackouts(tc.at, tc.windows))
+		})
+	}
+}
+
+func TestAvoidBlackouts_InOtherTimeZones(t *testing.T) {
+	t.Parallel()
+
+	loc := time.FixedZone("UTC+10", 10*60*60)
+	w := blackoutWindow{start: 9 * time.Hour, end: 17 * time.Hour, loc: loc}
+
+	// 01:00 UTC is 11:00 in UTC+10, so the window ends at 07:00 UTC
+	at := time.Date(2022, 6, 15, 1, 0, 0, 0, time.UTC)
+	assert.Assert(t, avoidBlackouts(at, []blackoutWindow{w}).Equal(time.Date(2022, 6, 15, 7, 0, 0, 0, time.UTC)))
+}
+
+func TestWithBlackout_ExtendsWaitsThatEndInTheWindow(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now().UTC()
+	tod := time.Duration(now.Hour())*time.Hour + time.Duration(now.Minute())*time.Minute
+	start := tod - time.Hour
+	if start < 0 {
+		start += 24 * time.Hour
+	}
+	end := (tod + time.Hour) % (24 * time.Hour)
+
+	insomniac := newInsomniac()
+	err := NewRetrier(
+		WithMaxAttempts(2),
+		WithStrategy(Constant(time.Millisecond)),
+		WithBlackout(start, end, time.UTC),
+		WithSleepFunc(insomniac.sleep),
+	).Do(func(r *Retrier) error {
+		return errDummy
+	})
+
+	assert.ErrorIs(t, err, errDummy)
+	assert.Equal(t, 1, len(insomniac.sleepIntervals))
+	assert.Assert(t, insomniac.sleepIntervals[0] > 59*time.Minute)
+	assert.Assert(t, insomniac.sleepIntervals[0] <= 2*time.Hour)
+}
+
+func TestWithBlackout_WithEmptyWindow_Panics(t *testing.T) {
+	t.Parallel()
+
+	defer func() {
+		assert.Assert(t, recover() != nil)
+	}()
+
+	WithBlackout(time.Hour, time.Hour, time.UTC)
+}
+
+func TestWithBlackout_WithoutALocation_UsesLocalTime(t *testing.T) {
+	t.Parallel()
+
+	clock := NewSimulatedClock(time.Date(2024, time.January, 1, 2, 10, 0, 0, time.Local))
+	err := NewRetrier(
+		WithMaxAttempts(2),
+		WithStrategy(Constant(time.Millisecond)),
+		WithBlackout(2*time.Hour, 2*time.Hour+30*time.Minute, nil),
+		WithSimulation(1, clock),
+	).Do(func(r *Retrier) error {
+		return errDummy
+	})
+
+	assert.ErrorIs(t, err, errDummy)
+	assert.Assert(t, clock.Now().Equal(time.Date(2024, time.January, 1, 2, 30, 0, 0, time.Local)), "%s", clock.Now())
+}
@@ -0,0 +1,234 @@
+package roko
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrBudgetExhausted is returned by a retrier's Do and DoWithContext when its Budget ran out before the loop's first
+// attempt. Once a loop has made an attempt, running out of budget returns the last attempt's error, as running out of
+// attempts does
+var ErrBudgetExhausted = errors.New("roko: retry budget exhausted")
+
+// Budget is an allowance of attempts and time shared by the retriers in a multi-step workflow, so that the workflow as
+// a whole stays within its limits, however many steps it has and however each step is retried. Budgets form a tree:
+// each step can have a child budget with limits of its own, and an attempt is only made if every budget from the step's
+// up to the root has room for it:
+//
+//	deploy := roko.NewBudget("deploy", 20, 10*time.Minute)
+//	build := roko.NewRetrier(
+//		roko.WithMaxAttempts(10),
+//		roko.WithStrategy(roko.Exponential(2*time.Second, 0)),
+//		roko.WithBudget(deploy.Child("build", 5, 0)),
+//	)
+//	release := roko.NewRetrier(
+//		roko.TryForever(),
+//		roko.WithStrategy(roko.Constant(10*time.Second)),
+//		roko.WithBudget(deploy.Child("release", 0, 2*time.Minute)),
+//	)
+//
+// A budget's time limit runs from the first attempt made under it. Retriers give up rather than wait past the time
+// limit of any budget they draw from. Once the workflow is over, Report describes how much of each budget was used. A
+// Budget is safe to use concurrently
+type Budget struct {
+	mu *sync.Mutex // Shared by the whole tree, so that drawing from a budget and its ancestors is atomic
+
+	name        string
+	parent      *Budget
+	children    []*Budget
+	maxAttempts int
+	maxTime     time.Duration
+
+	attempts  int
+	failures  int
+	start     time.Time
+	last      time.Time
+	exhausted bool
+}
+
+// NewBudget returns a root budget that allows up to maxAttempts attempts, made over up to maxTime. Either limit can be
+// zero for no limit
+func NewBudget(name string, maxAttempts int, maxTime time.Duration) *Budget {
+	return newBudget(&sync.Mutex{}, name, nil, maxAttempts, maxTime)
+}
+
+func newBudget(mu *sync.Mutex, name string, parent *Budget, maxAttempts int, maxTime time.Duration) *Budget {
+	if maxAttempts < 0 {
+		panic("budgets can't have a negative attempt limit")
+	}
+	if maxTime < 0 {
+		panic("budgets can't have a negative time limit")
+	}
+
+	return &Budget{mu: mu, name: name, parent: parent, maxAttempts: maxAttempts, maxTime: maxTime}
+}
+
+// Child returns a budget for one part of the work covered by b, with limits of its own (either of which can be zero for
+// no limit beyond b's). Attempts made under the child count towards b's limits too
+func (b *Budget) Child(name string, maxAttempts int, maxTime time.Duration) *Budget {
+	child := newBudget(b.mu, name, b, maxAttempts, maxTime)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.children = append(b.children, child)
+	return child
+}
+
+// WithBudget makes the retrier draw each attempt it makes from b, giving up once b (or any of its ancestors) has run
+// out of attempts, or when waiting for the next attempt would take it past one of their time limits. The retrier's own
+// limits still apply, too. See Budget
+func WithBudget(b *Budget) retrierOpt {
+	return func(r *Retrier) {
+		r.budget = b
+	}
+}
+
+// draw takes an attempt at now from b and each of its ancestors, or returns false if any of them don't have room for it
+func (b *Budget) draw(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if a := b.refusing(now, 0); a != nil {
+		a.exhausted = true
+		return false
+	}
+
+	for a := b; a != nil; a = a.parent {
+		if a.start.IsZero() {
+			a.start = now
+		}
+		a.attempts++
+		a.last = now
+	}
+	return true
+}
+
+// refusing returns the first of b and its ancestors that doesn't have room for another attempt after waiting for wait
+// from now, or nil if they all do. b.mu must be held
+func (b *Budget) refusing(now time.Time, wait time.Duration) *Budget {
+	for a := b; a != nil; a = a.parent {
+		if a.maxAttempts > 0 && a.attempts >= a.maxAttempts {
+			return a
+		}
+		if a.maxTime > 0 && !a.start.IsZero() && now.Add(wait).Sub(a.start) > a.maxTime {
+			return a
+		}
+	}
+	return nil
+}
+
+// allows returns whether b and all of its ancestors have room for another attempt after waiting for wait from now
+func (b *Budget) allows(now time.Time, wait time.Duration) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.refusing(now, wait) == nil
+}
+
+// giveUp records that a retrier drawing from b gave up at now, against whichever budget didn't have room for its next
+// attempt after waiting for wait, if any
+func (b *Budget) giveUp(now time.Time, wait time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if a := b.refusing(now, wait); a != nil {
+		a.exhausted = true
+	}
+}
+
+// finish records that an attempt drawn from b finished at now
+func (b *Budget) finish(now time.Time, failed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for a := b; a != nil; a = a.parent {
+		if failed {
+			a.failures++
+		}
+		a.last = now
+	}
+}
+
+// BudgetReport describes how much of a Budget, and each of its children, was used
+type BudgetReport struct {
+	Name        string
+	Attempts    int // The attempts made under the budget, including under its children
+	Failures    int // How many of those attempts failed
+	MaxAttempts int // The budget's attempt limit, or 0 if it doesn't have one
+
+	// Elapsed is the time from the start of the first attempt made under the budget to the end of the last one
+	Elapsed time.Duration
+	MaxTime time.Duration // The budget's time limit, or 0 if it doesn't have one
+
+	// Exhausted is whether a retrier gave up because this budget (rather than one of its ancestors) ran out
+	Exhausted bool
+
+	Children []BudgetReport
+}
+
+// Report returns a report on the budget and its children
+func (b *Budget) Report() BudgetReport {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.report()
+}
+
+// report is Report, for callers that already hold b.mu
+func (b *Budget) report() BudgetReport {
+	report := BudgetReport{
+		Name:        b.name,
+		Attempts:    b.attempts,
+		Failures:    b.failures,
+		MaxAttempts: b.maxAttempts,
+		Elapsed:     b.last.Sub(b.start),
+		MaxTime:     b.maxTime,
+		Exhausted:   b.exhausted,
+	}
+	for _, c := range b.children {
+		report.Children = append(report.Children, c.report())
+	}
+	return report
+}
+
+// String formats the report as an indented tree, one budget per line, like:
+//
+//	deploy: 9/20 attempts (3 failed) over 1m12s/10m0s
+//	  build: 4/5 attempts (3 failed) over 31s
+//	  release: 5 attempts over 41s/2m0s
+func (r BudgetReport) String() string {
+	var sb strings.Builder
+	r.write(&sb, 0)
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+func (r BudgetReport) write(sb *strings.Builder, depth int) {
+	sb.WriteString(strings.Repeat("  ", depth))
+	sb.WriteString(r.Name)
+	sb.WriteString(": ")
+
+	if r.MaxAttempts > 0 {
+		fmt.Fprintf(sb, "%d/%d attempts", r.Attempts, r.MaxAttempts)
+	} else {
+		fmt.Fprintf(sb, "%d attempts", r.Attempts)
+	}
+	if r.Failures > 0 {
+		fmt.Fprintf(sb, " (%d failed)", r.Failures)
+	}
+
+	sb.WriteString(" over ")
+	sb.WriteString(r.Elapsed.Round(time.Millisecond).String())
+	if r.MaxTime > 0 {
+		sb.WriteString("/")
+		sb.WriteString(r.MaxTime.String())
+	}
+	if r.Exhausted {
+		sb.WriteString(", exhausted")
+	}
+	sb.WriteString("\n")
+
+	for _, c := range r.Children {
+		c.write(sb, depth+1)
+	}
+}
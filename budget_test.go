@@ -0,0 +1,135 @@
+package roko
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+var errBudgetTest = errors.New("failed")
+
+func TestBudget_SharedAttempts(t *testing.T) {
+	t.Parallel()
+
+	workflow := NewBudget("workflow", 5, 0)
+	newStep := func(name string) *Retrier {
+		return NewRetrier(
+			WithMaxAttempts(4),
+			WithStrategy(Constant(time.Second)),
+			WithSleepFunc(func(time.Duration) {}),
+			WithBudget(workflow.Child(name, 0, 0)),
+		)
+	}
+
+	// The first step uses three of the workflow's five attempts...
+	first := newStep("first")
+	assert.NilError(t, first.Do(func(r *Retrier) error {
+		if r.AttemptCount() < 2 {
+			return errBudgetTest
+		}
+		return nil
+	}))
+
+	// ...so the second only gets two of its four
+	second := newStep("second")
+	assert.ErrorIs(t, second.Do(func(*Retrier) error { return errBudgetTest }), errBudgetTest)
+	assert.Equal(t, 2, second.Attempts())
+
+	// A third step doesn't get any
+	third := newStep("third")
+	called := false
+	assert.ErrorIs(t, third.Do(func(*Retrier) error {
+		called = true
+		return nil
+	}), ErrBudgetExhausted)
+	assert.Assert(t, !called)
+
+	report := workflow.Report()
+	assert.Equal(t, 5, report.Attempts)
+	assert.Equal(t, 4, report.Failures)
+	assert.Assert(t, report.Exhausted)
+	assert.Equal(t, 3, len(report.Children))
+	assert.Equal(t, 3, report.Children[0].Attempts)
+	assert.Equal(t, 2, report.Children[1].Attempts)
+	assert.Assert(t, !report.Children[1].Exhausted, "the workflow's budget ran out, not the step's")
+}
+
+func TestBudget_ChildLimits(t *testing.T) {
+	t.Parallel()
+
+	workflow := NewBudget("workflow", 10, 0)
+	step := workflow.Child("step", 2, 0)
+	r := NewRetrier(
+		WithMaxAttempts(5),
+		WithStrategy(Constant(time.Second)),
+		WithSleepFunc(func(time.Duration) {}),
+		WithBudget(step),
+	)
+
+	assert.ErrorIs(t, r.Do(func(*Retrier) error { return errBudgetTest }), errBudgetTest)
+	assert.Equal(t, 2, r.Attempts())
+
+	report := workflow.Report()
+	assert.Assert(t, !report.Exhausted)
+	assert.Assert(t, report.Children[0].Exhausted)
+	assert.Equal(t, 2, report.Attempts)
+}
+
+func TestBudget_TimeLimit(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewSimulatedClock(start)
+
+	workflow := NewBudget("workflow", 0, time.Minute)
+	r := NewRetrier(
+		TryForever(),
+		WithStrategy(Constant(25*time.Second)),
+		WithSimulation(1, clock),
+		WithBudget(workflow),
+	)
+
+	err := r.Do(func(*Retrier) error {
+		clock.Advance(time.Second)
+		return errBudgetTest
+	})
+
+	// Attempts start at 0s, 26s and 52s, and waiting for a fourth at 78s would go past the minute
+	assert.ErrorIs(t, err, errBudgetTest)
+	assert.Equal(t, 3, r.Attempts())
+	assert.Equal(t, start.Add(53*time.Second), clock.Now())
+
+	report := workflow.Report()
+	assert.Equal(t, 53*time.Second, report.Elapsed)
+	assert.Assert(t, report.Exhausted)
+}
+
+func TestBudgetReport_String(t *testing.T) {
+	t.Parallel()
+
+	report := BudgetReport{
+		Name:        "deploy",
+		Attempts:    9,
+		Failures:    3,
+		MaxAttempts: 20,
+		Elapsed:     72 * time.Second,
+		MaxTime:     10 * time.Minute,
+		Children: []BudgetReport{
+			{Name: "build", Attempts: 4, Failures: 3, MaxAttempts: 4, Elapsed: 31 * time.Second, Exhausted: true},
+			{Name: "release", Attempts: 5, Elapsed: 41 * time.Second, MaxTime: 2 * time.Minute},
+		},
+	}
+
+	assert.Equal(t, `deploy: 9/20 attempts (3 failed) over 1m12s/10m0s
+  build: 4/4 attempts (3 failed) over 31s, exhausted
+  release: 5 attempts over 41s/2m0s`, report.String())
+}
+
+func TestNewBudget_PanicsOnNegativeLimits(t *testing.T) {
+	t.Parallel()
+	defer func() { assert.Assert(t, recover() != nil) }()
+
+	NewBudget("bad", -1, 0)
+}
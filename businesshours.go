@@ -0,0 +1,85 @@
+package roko
+
+import "time"
+
+// BusinessHours configures WithBusinessHours
+type BusinessHours struct {
+	// Start and End are the times of day that business hours start and end at, given as the time since midnight
+	Start, End time.Duration
+
+	// Days are the days of the week with business hours. The default is Monday to Friday
+	Days []time.Weekday
+
+	// Location is the time zone that business hours are in. The default is the local time zone
+	Location *time.Location
+
+	// OnHoursFactor and OffHoursFactor scale the intervals calculated during and outside of business hours. Leaving
+	// either of them as zero means intervals aren't scaled at those times
+	OnHoursFactor, OffHoursFactor float64
+}
+
+// WithBusinessHours scales the intervals calculated by the retrier's strategy depending on whether it's business hours,
+// so that retries can be aggressive while customers are around, and gentle overnight:
+//
+//	roko.WithBusinessHours(roko.BusinessHours{
+//		Start:          9 * time.Hour,
+//		End:            17 * time.Hour,
+//		Location:       sydney,
+//		OnHoursFactor:  0.5, // Retry twice as often during the day...
+//		OffHoursFactor: 4,   // ...and four times less often at night and on weekends
+//	})
+//
+// Whether it's business hours is decided when the interval is calculated, before the attempt is made. Intervals set
+// using SetNextInterval aren't scaled
+func WithBusinessHours(b BusinessHours) retrierOpt {
+	if b.Start < 0 || b.Start >= 24*time.Hour || b.End < 0 || b.End >= 24*time.Hour || b.Start >= b.End {
+		panic("business hours must start before they end, within a day")
+	}
+	if b.OnHoursFactor < 0 || b.OffHoursFactor < 0 {
+		panic("business hours factors must not be negative")
+	}
+
+	if b.Days == nil {
+		b.Days = []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday}
+	}
+	if b.Location == nil {
+		b.Location = time.Local
+	}
+	if b.OnHoursFactor == 0 {
+		b.OnHoursFactor = 1
+	}
+	if b.OffHoursFactor == 0 {
+		b.OffHoursFactor = 1
+	}
+
+	return func(r *Retrier) {
+		r.businessHours = &b
+	}
+}
+
+// contains returns whether t is during business hours
+func (b *BusinessHours) contains(t time.Time) bool {
+	t = t.In(b.Location)
+
+	isWorkday := false
+	for _, d := range b.Days {
+		if t.Weekday() == d {
+			isWorkday = true
+			break
+		}
+	}
+
+	tod := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute +
+		time.Duration(t.Second())*time.Second + time.Duration(t.Nanosecond())
+
+	return isWorkday && tod >= b.Start && tod < b.End
+}
+
+// scale scales an interval calculated at t
+func (b *BusinessHours) scale(t time.Time, interval time.Duration) time.Duration {
+	factor := b.OffHoursFactor
+	if b.contains(t) {
+		factor = b.OnHoursFactor
+	}
+	return saturatingDuration(float64(interval)*factor, 1)
+}
@@ -0,0 +1,96 @@
+package roko
+
+import (
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestBusinessHours_Scale(t *testing.T) {
+	t.Parallel()
+
+	b := &BusinessHours{
+		Start:          9 * time.Hour,
+		End:            17 * time.Hour,
+		Days:           []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday},
+		Location:       time.UTC,
+		OnHoursFactor:  0.5,
+		OffHoursFactor: 4,
+	}
+
+	testCases := []struct {
+		name string
+		at   time.Time
+		want time.Duration
+	}{
+		{"weekday morning", time.Date(2022, 6, 15, 9, 0, 0, 0, time.UTC), 5 * time.Second},
+		{"weekday afternoon", time.Date(2022, 6, 15, 16, 59, 0, 0, time.UTC), 5 * time.Second},
+		{"weekday evening", time.Date(2022, 6, 15, 17, 0, 0, 0, time.UTC), 40 * time.Second},
+		{"weekday night", time.Date(2022, 6, 15, 3, 0, 0, 0, time.UTC), 40 * time.Second},
+		{"weekend", time.Date(2022, 6, 18, 12, 0, 0, 0, time.UTC), 40 * time.Second},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tc.want, b.scale(tc.at, 10*time.Second))
+		})
+	}
+}
+
+func TestBusinessHours_UsesItsTimeZone(t *testing.T) {
+	t.Parallel()
+
+	b := &BusinessHours{Start: 9 * time.Hour, End: 17 * time.Hour, Days: []time.Weekday{time.Wednesday}, Location: time.FixedZone("UTC+10", 10*60*60)}
+
+	assert.Assert(t, b.contains(time.Date(2022, 6, 15, 1, 0, 0, 0, time.UTC)))   // 11:00 in UTC+10
+	assert.Assert(t, !b.contains(time.Date(2022, 6, 15, 12, 0, 0, 0, time.UTC))) // 22:00 in UTC+10
+}
+
+func TestWithBusinessHours_ScalesCalculatedIntervals(t *testing.T) {
+	t.Parallel()
+
+	// Both factors are the same, so that the interval doesn't depend on when the test is run
+	insomniac := newInsomniac()
+	err := NewRetrier(
+		WithMaxAttempts(2),
+		WithStrategy(Constant(10*time.Second)),
+		WithBusinessHours(BusinessHours{Start: 9 * time.Hour, End: 17 * time.Hour, OnHoursFactor: 0.5, OffHoursFactor: 0.5}),
+		WithSleepFunc(insomniac.sleep),
+	).Do(func(r *Retrier) error {
+		return errDummy
+	})
+
+	assert.ErrorIs(t, err, errDummy)
+	assert.DeepEqual(t, []time.Duration{5 * time.Second}, insomniac.sleepIntervals)
+}
+
+func TestWithBusinessHours_DoesntScaleOverriddenIntervals(t *testing.T) {
+	t.Parallel()
+
+	insomniac := newInsomniac()
+	err := NewRetrier(
+		WithMaxAttempts(2),
+		WithStrategy(Constant(10*time.Second)),
+		WithBusinessHours(BusinessHours{Start: 0, End: time.Minute, OnHoursFactor: 3, OffHoursFactor: 3}),
+		WithSleepFunc(insomniac.sleep),
+	).Do(func(r *Retrier) error {
+		r.SetNextInterval(time.Second)
+		return errDummy
+	})
+
+	assert.ErrorIs(t, err, errDummy)
+	assert.DeepEqual(t, []time.Duration{time.Second}, insomniac.sleepIntervals)
+}
+
+func TestWithBusinessHours_WithInvalidHours_Panics(t *testing.T) {
+	t.Parallel()
+
+	defer func() {
+		assert.Assert(t, recover() != nil)
+	}()
+
+	WithBusinessHours(BusinessHours{Start: 17 * time.Hour, End: 9 * time.Hour})
+}
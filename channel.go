@@ -0,0 +1,52 @@
+package roko
+
+import (
+	"context"
+	"errors"
+)
+
+var (
+	// ErrChannelFull is returned by an attempt in Send when the channel has no room for the value
+	ErrChannelFull = errors.New("roko: channel is full")
+
+	// ErrChannelEmpty is returned by an attempt in Receive when there's no value waiting on the channel
+	ErrChannelEmpty = errors.New("roko: channel is empty")
+
+	// ErrChannelClosed is returned by Receive when the channel has been closed. Receive doesn't retry after seeing a
+	// closed channel, as no more values will ever arrive
+	ErrChannelClosed = errors.New("roko: channel is closed")
+)
+
+// Send tries to send v on ch without blocking, using r to retry (and back off) while the channel is full. This is useful
+// for applying backpressure to a bounded queue that might be momentarily full, without blocking forever on it.
+// If the retrier gives up, Send returns ErrChannelFull, or the context's error if ctx is done.
+// As with a regular channel send, sending on a closed channel panics.
+func Send[T any](ctx context.Context, r *Retrier, ch chan<- T, v T) error {
+	return r.run(ctx, func(*Retrier) error {
+		select {
+		case ch <- v:
+			return nil
+		default:
+			return ErrChannelFull
+		}
+	}, nil)
+}
+
+// Receive tries to receive a value from ch without blocking, using r to retry (and back off) while the channel is empty.
+// If the retrier gives up, Receive returns ErrChannelEmpty, or the context's error if ctx is done. If ch is closed,
+// Receive stops retrying and returns ErrChannelClosed.
+func Receive[T any](ctx context.Context, r *Retrier, ch <-chan T) (T, error) {
+	return DoFunc(ctx, r, func(r *Retrier) (T, error) {
+		var zero T
+		select {
+		case v, ok := <-ch:
+			if !ok {
+				r.Break()
+				return zero, ErrChannelClosed
+			}
+			return v, nil
+		default:
+			return zero, ErrChannelEmpty
+		}
+	})
+}
@@ -0,0 +1,123 @@
+package roko
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestSend_WhenChannelHasRoom_SendsImmediately(t *testing.T) {
+	t.Parallel()
+
+	ch := make(chan int, 1)
+	insomniac := newInsomniac()
+	err := Send(context.Background(), NewRetrier(
+		WithStrategy(Constant(1*time.Second)),
+		WithMaxAttempts(3),
+		WithSleepFunc(insomniac.sleep),
+	), ch, 42)
+	assert.NilError(t, err)
+
+	assert.Equal(t, 42, <-ch)
+	assert.Equal(t, 0, len(insomniac.sleepIntervals))
+}
+
+func TestSend_WhenChannelIsFull_RetriesUntilThereIsRoom(t *testing.T) {
+	t.Parallel()
+
+	ch := make(chan int, 1)
+	ch <- 1
+
+	attempts := 0
+	err := Send(context.Background(), NewRetrier(
+		WithStrategy(Constant(1*time.Second)),
+		WithMaxAttempts(5),
+		WithSleepFunc(func(time.Duration) {
+			attempts += 1
+			if attempts == 3 {
+				<-ch // make room on the third wait
+			}
+		}),
+	), ch, 2)
+	assert.NilError(t, err)
+
+	assert.Equal(t, 3, attempts)
+	assert.Equal(t, 2, <-ch)
+}
+
+func TestSend_WhenChannelStaysFull_ReturnsErrChannelFull(t *testing.T) {
+	t.Parallel()
+
+	ch := make(chan int)
+	err := Send(context.Background(), NewRetrier(
+		WithStrategy(Constant(1*time.Second)),
+		WithMaxAttempts(3),
+		WithSleepFunc(dummySleep),
+	), ch, 1)
+	assert.ErrorIs(t, err, ErrChannelFull)
+}
+
+func TestReceive_RetriesUntilAValueArrives(t *testing.T) {
+	t.Parallel()
+
+	ch := make(chan string, 1)
+	attempts := 0
+	v, err := Receive(context.Background(), NewRetrier(
+		WithStrategy(Constant(1*time.Second)),
+		WithMaxAttempts(5),
+		WithSleepFunc(func(time.Duration) {
+			attempts += 1
+			if attempts == 2 {
+				ch <- "hello"
+			}
+		}),
+	), ch)
+	assert.NilError(t, err)
+
+	assert.Equal(t, "hello", v)
+	assert.Equal(t, 2, attempts)
+}
+
+func TestReceive_WhenChannelStaysEmpty_ReturnsErrChannelEmpty(t *testing.T) {
+	t.Parallel()
+
+	ch := make(chan string)
+	_, err := Receive(context.Background(), NewRetrier(
+		WithStrategy(Constant(1*time.Second)),
+		WithMaxAttempts(3),
+		WithSleepFunc(dummySleep),
+	), ch)
+	assert.ErrorIs(t, err, ErrChannelEmpty)
+}
+
+func TestReceive_WhenChannelIsClosed_StopsRetrying(t *testing.T) {
+	t.Parallel()
+
+	ch := make(chan string)
+	close(ch)
+
+	r := NewRetrier(
+		WithStrategy(Constant(1*time.Second)),
+		WithMaxAttempts(10),
+		WithSleepFunc(dummySleep),
+	)
+	_, err := Receive(context.Background(), r, ch)
+	assert.ErrorIs(t, err, ErrChannelClosed)
+	assert.Equal(t, 1, r.AttemptCount())
+}
+
+func TestSend_WhenContextIsCancelled_ReturnsContextError(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	ch := make(chan int)
+	err := Send(ctx, NewRetrier(
+		WithStrategy(Constant(1*time.Second)),
+		TryForever(),
+	), ch, 1)
+	assert.ErrorIs(t, err, context.Canceled)
+}
@@ -0,0 +1,182 @@
+// Package chunked uploads large files in chunks, retrying each chunk independently, so that a failure partway through
+// an upload only means re-sending the chunks that failed, rather than the whole file.
+package chunked
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/buildkite/roko"
+)
+
+const (
+	defaultChunkSize   = 8 * 1024 * 1024
+	defaultParallelism = 4
+)
+
+// Chunk is a single part of the data being uploaded
+type Chunk struct {
+	Index  int   // The index of the chunk, starting at 0
+	Offset int64 // The offset of the start of the chunk within the data
+	Size   int64 // The size of the chunk in bytes. Every chunk but the last is the configured chunk size
+
+	src io.ReaderAt
+}
+
+// Reader returns a new reader for the chunk's data. Each call returns a reader positioned at the start of the chunk,
+// so each attempt at uploading the chunk should call Reader again
+func (c Chunk) Reader() *io.SectionReader {
+	return io.NewSectionReader(c.src, c.Offset, c.Size)
+}
+
+// ChunkError describes a chunk that couldn't be uploaded
+type ChunkError struct {
+	Chunk Chunk
+	Err   error // The last error returned by the upload function for this chunk
+}
+
+func (e *ChunkError) Error() string {
+	return fmt.Sprintf("chunk %d (bytes %d-%d): %v", e.Chunk.Index, e.Chunk.Offset, e.Chunk.Offset+e.Chunk.Size-1, e.Err)
+}
+
+func (e *ChunkError) Unwrap() error {
+	return e.Err
+}
+
+// UploadError is returned by Upload when one or more chunks couldn't be uploaded, even after retrying
+type UploadError struct {
+	Chunks int           // The total number of chunks in the upload
+	Failed []*ChunkError // The chunks that failed, in index order
+}
+
+func (e *UploadError) Error() string {
+	msgs := make([]string, 0, len(e.Failed))
+	for _, f := range e.Failed {
+		msgs = append(msgs, f.Error())
+	}
+	return fmt.Sprintf("%d of %d chunks failed to upload: %s", len(e.Failed), e.Chunks, strings.Join(msgs, "; "))
+}
+
+type config struct {
+	chunkSize   int64
+	parallelism int
+	newRetrier  func(Chunk) *roko.Retrier
+}
+
+type uploadOpt func(*config)
+
+// WithChunkSize sets the size of each chunk, in bytes. The default is 8MiB
+func WithChunkSize(size int64) uploadOpt {
+	if size <= 0 {
+		panic("chunk size must be positive")
+	}
+
+	return func(c *config) {
+		c.chunkSize = size
+	}
+}
+
+// WithParallelism sets the maximum number of chunks that are uploaded at once. The default is 4
+func WithParallelism(n int) uploadOpt {
+	if n <= 0 {
+		panic("parallelism must be positive")
+	}
+
+	return func(c *config) {
+		c.parallelism = n
+	}
+}
+
+// WithRetrier sets the function used to create the retrier for each chunk. It's called once per chunk, so different
+// chunks can have different policies if need be. By default, each chunk gets 5 attempts, with exponential backoff and
+// jitter
+func WithRetrier(f func(Chunk) *roko.Retrier) uploadOpt {
+	return func(c *config) {
+		c.newRetrier = f
+	}
+}
+
+func defaultRetrier(Chunk) *roko.Retrier {
+	return roko.NewRetrier(
+		roko.WithMaxAttempts(5),
+		roko.WithStrategy(roko.Exponential(2*time.Second, 0)),
+		roko.WithJitter(),
+	)
+}
+
+// Upload splits the size bytes of src into chunks, and calls upload for each of them, retrying each chunk according to
+// its own retrier. Chunks are uploaded in parallel, up to the configured limit. If any chunk still fails after retrying,
+// the other chunks are still uploaded, and Upload returns an *UploadError listing the chunks that failed, so that the
+// caller can decide what to do about them. If ctx is cancelled, Upload stops starting new chunks, and returns the
+// context's error.
+func Upload(ctx context.Context, src io.ReaderAt, size int64, upload func(context.Context, Chunk) error, opts ...uploadOpt) error {
+	c := &config{
+		chunkSize:   defaultChunkSize,
+		parallelism: defaultParallelism,
+		newRetrier:  defaultRetrier,
+	}
+	for _, o := range opts {
+		o(c)
+	}
+
+	chunks := split(src, size, c.chunkSize)
+	errs := make([]error, len(chunks))
+
+	work := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < c.parallelism && w < len(chunks); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range work {
+				chunk := chunks[i]
+				errs[i] = c.newRetrier(chunk).DoWithContext(ctx, func(r *roko.Retrier) error {
+					return upload(r.Context(), chunk)
+				})
+			}
+		}()
+	}
+
+	for i := range chunks {
+		if ctx.Err() != nil {
+			break
+		}
+		work <- i
+	}
+	close(work)
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	var failed []*ChunkError
+	for i, err := range errs {
+		if err != nil {
+			failed = append(failed, &ChunkError{Chunk: chunks[i], Err: err})
+		}
+	}
+
+	if len(failed) > 0 {
+		return &UploadError{Chunks: len(chunks), Failed: failed}
+	}
+
+	return nil
+}
+
+// split divides size bytes of src into chunks of chunkSize bytes, with a shorter chunk at the end if need be
+func split(src io.ReaderAt, size, chunkSize int64) []Chunk {
+	chunks := []Chunk{}
+	for offset := int64(0); offset < size; offset += chunkSize {
+		n := chunkSize
+		if offset+n > size {
+			n = size - offset
+		}
+		chunks = append(chunks, Chunk{Index: len(chunks), Offset: offset, Size: n, src: src})
+	}
+	return chunks
+}
@@ -0,0 +1,153 @@
+package chunked
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/buildkite/roko"
+	"gotest.tools/v3/assert"
+)
+
+var errFlaky = errors.New("connection reset")
+
+// store is a fake upload destination, that records the chunks it's been sent
+type store struct {
+	mu       sync.Mutex
+	chunks   map[int][]byte
+	attempts map[int]int
+}
+
+func newStore() *store {
+	return &store{chunks: map[int][]byte{}, attempts: map[int]int{}}
+}
+
+func (s *store) upload(failures map[int]int) func(context.Context, Chunk) error {
+	return func(_ context.Context, c Chunk) error {
+		data, err := io.ReadAll(c.Reader())
+		if err != nil {
+			return err
+		}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		s.attempts[c.Index]++
+		if s.attempts[c.Index] <= failures[c.Index] {
+			return errFlaky
+		}
+
+		s.chunks[c.Index] = data
+		return nil
+	}
+}
+
+func (s *store) assembled() []byte {
+	indexes := []int{}
+	for i := range s.chunks {
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
+
+	var buf bytes.Buffer
+	for _, i := range indexes {
+		buf.Write(s.chunks[i])
+	}
+	return buf.Bytes()
+}
+
+func testRetrier(attempts int) func(Chunk) *roko.Retrier {
+	return func(Chunk) *roko.Retrier {
+		return roko.NewRetrier(
+			roko.WithMaxAttempts(attempts),
+			roko.WithStrategy(roko.Constant(1*time.Second)),
+			roko.WithSleepFunc(func(time.Duration) {}),
+		)
+	}
+}
+
+func TestUpload_SplitsDataIntoChunks(t *testing.T) {
+	t.Parallel()
+
+	data := []byte("the quick brown fox jumps over the lazy dog")
+	s := newStore()
+
+	err := Upload(context.Background(), bytes.NewReader(data), int64(len(data)), s.upload(nil),
+		WithChunkSize(10),
+		WithRetrier(testRetrier(3)),
+	)
+	assert.NilError(t, err)
+
+	assert.Equal(t, 5, len(s.chunks))
+	assert.Equal(t, "the quick ", string(s.chunks[0]))
+	assert.Equal(t, "dog", string(s.chunks[4]))
+	assert.DeepEqual(t, data, s.assembled())
+}
+
+func TestUpload_OnlyRetriesFailedChunks(t *testing.T) {
+	t.Parallel()
+
+	data := bytes.Repeat([]byte("0123456789"), 10)
+	s := newStore()
+
+	err := Upload(context.Background(), bytes.NewReader(data), int64(len(data)), s.upload(map[int]int{3: 2, 7: 1}),
+		WithChunkSize(10),
+		WithParallelism(3),
+		WithRetrier(testRetrier(3)),
+	)
+	assert.NilError(t, err)
+
+	assert.DeepEqual(t, data, s.assembled())
+	for i := 0; i < 10; i++ {
+		want := 1
+		switch i {
+		case 3:
+			want = 3
+		case 7:
+			want = 2
+		}
+		assert.Equal(t, want, s.attempts[i], "chunk %d", i)
+	}
+}
+
+func TestUpload_WhenChunksKeepFailing_ReportsThem(t *testing.T) {
+	t.Parallel()
+
+	data := bytes.Repeat([]byte("x"), 50)
+	s := newStore()
+
+	err := Upload(context.Background(), bytes.NewReader(data), int64(len(data)), s.upload(map[int]int{1: 100, 4: 100}),
+		WithChunkSize(10),
+		WithRetrier(testRetrier(3)),
+	)
+	var uploadErr *UploadError
+	assert.Assert(t, errors.As(err, &uploadErr))
+	assert.Equal(t, 5, uploadErr.Chunks)
+	assert.Equal(t, 2, len(uploadErr.Failed))
+	assert.Equal(t, 1, uploadErr.Failed[0].Chunk.Index)
+	assert.Equal(t, 4, uploadErr.Failed[1].Chunk.Index)
+	assert.ErrorIs(t, uploadErr.Failed[0], errFlaky)
+	assert.Equal(t, "2 of 5 chunks failed to upload: chunk 1 (bytes 10-19): connection reset; chunk 4 (bytes 40-49): connection reset", err.Error())
+
+	// The chunks that didn't fail were still uploaded
+	assert.Equal(t, 3, len(s.chunks))
+}
+
+func TestUpload_WhenContextIsCancelled_ReturnsContextError(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	data := bytes.Repeat([]byte("x"), 50)
+	err := Upload(ctx, bytes.NewReader(data), int64(len(data)), newStore().upload(nil),
+		WithChunkSize(10),
+		WithRetrier(testRetrier(3)),
+	)
+	assert.ErrorIs(t, err, context.Canceled)
+}
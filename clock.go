@@ -0,0 +1,89 @@
+package roko
+
+import (
+	"context"
+	"time"
+)
+
+// Clock is where a retrier gets the time from, for measuring how long attempts and sleeps take, and for the options
+// that depend on the time of day (UntilClock, WithBlackout, WithBusinessHours and Cron).
+//
+// Elapsed times are always worked out by subtracting one time returned by Now from another, never from wall clock
+// readings like Unix timestamps, so that the clock being stepped (by NTP, say) doesn't distort them. time.Now's results
+// carry a monotonic clock reading that subtraction uses, and a Clock's results must behave the same way: either carry
+// their own monotonic reading, or come from a fake clock that only moves forward
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+// SystemClock is the Clock retriers use by default, which tells the time using time.Now
+var SystemClock Clock = systemClock{}
+
+// WithClock sets the clock the retrier gets the time from. It's mostly useful for tests, along with WithSleepFunc:
+// the clock controls what time the retrier thinks it is, and the sleep function controls how it waits
+func WithClock(c Clock) retrierOpt {
+	return func(r *Retrier) {
+		r.clock = c
+	}
+}
+
+// now returns the current time according to the retrier's clock
+func (r *Retrier) now() time.Time {
+	if r.clock == nil {
+		return time.Now()
+	}
+	return r.clock.Now()
+}
+
+// since returns the time elapsed since t, according to the retrier's clock
+func (r *Retrier) since(t time.Time) time.Duration {
+	return r.now().Sub(t)
+}
+
+// clockJumpThreshold is how far the wall clock has to move differently from the monotonic clock during a wait for it
+// to count as a clock jump. Small differences are normal, as the two clocks are adjusted separately
+const clockJumpThreshold = time.Second
+
+// ClockJump describes a wait between attempts during which the wall clock moved by a different amount than the
+// monotonic clock. This happens when the machine is suspended (on most platforms, the monotonic clock stops while it's
+// asleep, but the wall clock doesn't), or when the wall clock is stepped, by NTP or by hand
+type ClockJump struct {
+	Slept time.Duration // How long the wait took by the monotonic clock, which is what the retrier counts
+	Wall  time.Duration // How far the wall clock moved during the wait
+}
+
+// WithOnClockJump sets a function that the retrier calls after a wait between attempts during which the wall clock
+// jumped. Whether or not it's set, retriers handle jumps the same way: waits are counted by the monotonic clock, so a
+// jump doesn't use up the budget set by WithMaxTotalSleep, and if the context's deadline passed (by the wall clock)
+// while the machine was asleep, the retrier gives up with context.DeadlineExceeded straight away, rather than making
+// another attempt first
+func WithOnClockJump(f func(ClockJump)) retrierOpt {
+	return func(r *Retrier) {
+		r.onClockJump = f
+	}
+}
+
+// checkClockJump checks whether the wall clock jumped during a wait, that by the monotonic clock took slept, and by the
+// wall clock took wall. If it did, it calls the clock jump hook, and returns context.DeadlineExceeded if ctx's deadline
+// has passed by now
+func (r *Retrier) checkClockJump(ctx context.Context, slept, wall time.Duration, now time.Time) error {
+	jump := wall - slept
+	if jump > -clockJumpThreshold && jump < clockJumpThreshold {
+		return nil
+	}
+
+	if r.onClockJump != nil {
+		r.onClockJump(ClockJump{Slept: slept, Wall: wall})
+	}
+
+	// Context deadlines are enforced by timers, which run on the monotonic clock, so one that passed while the machine
+	// was suspended won't have fired yet
+	if deadline, ok := ctx.Deadline(); ok && !now.Round(0).Before(deadline) {
+		return context.DeadlineExceeded
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package roko
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+// fakeClock is a Clock that only moves when it's told to
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func TestSystemClock_HasAMonotonicReading(t *testing.T) {
+	t.Parallel()
+
+	// Times with a monotonic reading print it as m=±<seconds>
+	assert.Assert(t, strings.Contains(SystemClock.Now().String(), " m="))
+}
+
+func TestWithClock_MeasuresAttemptsAndSleepsWithTheClock(t *testing.T) {
+	t.Parallel()
+
+	clock := &fakeClock{now: time.Date(2022, 6, 15, 12, 0, 0, 0, time.UTC)}
+	events := []AttemptEvent{}
+
+	err := NewRetrier(
+		WithMaxAttempts(2),
+		WithStrategy(Constant(time.Minute)),
+		WithClock(clock),
+		WithSleepFunc(clock.Advance),
+		WithOnAttempt(func(e AttemptEvent) { events = append(events, e) }),
+	).Do(func(r *Retrier) error {
+		clock.Advance(3 * time.Second)
+		return errDummy
+	})
+
+	assert.ErrorIs(t, err, errDummy)
+	assert.Equal(t, 2, len(events))
+	assert.Equal(t, time.Date(2022, 6, 15, 12, 0, 0, 0, time.UTC), events[0].Start)
+	assert.Equal(t, 3*time.Second, events[0].Duration)
+	assert.Equal(t, time.Minute, events[1].Slept)
+	assert.Equal(t, time.Date(2022, 6, 15, 12, 1, 3, 0, time.UTC), events[1].Start)
+}
+
+func TestWithClock_UntilClockUsesTheClock(t *testing.T) {
+	t.Parallel()
+
+	clock := &fakeClock{now: time.Date(2022, 6, 15, 8, 0, 0, 0, time.UTC)}
+	insomniac := newInsomniac()
+
+	r := NewRetrier(
+		TryForever(),
+		WithStrategy(Constant(25*time.Minute)),
+		UntilClock(9, 0, time.UTC),
+		WithClock(clock),
+		WithSleepFunc(func(d time.Duration) {
+			insomniac.sleep(d)
+			clock.Advance(d)
+		}),
+	)
+	err := r.Do(func(r *Retrier) error { return errDummy })
+
+	// Waits at 08:00 and 08:25 are fine, but another from 08:50 would go past 09:00
+	assert.ErrorIs(t, err, errDummy)
+	assert.Equal(t, 2, len(insomniac.sleepIntervals))
+}
+
+func TestCheckClockJump(t *testing.T) {
+	t.Parallel()
+
+	jumps := []ClockJump{}
+	r := NewRetrier(NoRetry(), WithOnClockJump(func(j ClockJump) { jumps = append(jumps, j) }))
+	now := time.Now()
+
+	// Small differences between the clocks aren't jumps
+	assert.NilError(t, r.checkClockJump(context.Background(), time.Minute, time.Minute+500*time.Millisecond, now))
+	assert.Equal(t, 0, len(jumps))
+
+	// Being suspended for an hour partway through a minute's wait
+	assert.NilError(t, r.checkClockJump(context.Background(), time.Minute, time.Hour+time.Minute, now))
+	assert.DeepEqual(t, []ClockJump{{Slept: time.Minute, Wall: time.Hour + time.Minute}}, jumps)
+
+	// The wall clock being stepped backwards
+	assert.NilError(t, r.checkClockJump(context.Background(), time.Minute, -time.Minute, now))
+	assert.Equal(t, 2, len(jumps))
+}
+
+func TestCheckClockJump_WhenTheDeadlinePassedDuringTheJump_GivesUp(t *testing.T) {
+	t.Parallel()
+
+	r := NewRetrier(NoRetry())
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(time.Hour))
+	defer cancel()
+
+	afterDeadline := time.Now().Add(2 * time.Hour)
+	assert.ErrorIs(t, r.checkClockJump(ctx, time.Minute, 2*time.Hour, afterDeadline), context.DeadlineExceeded)
+
+	// Without a jump, the context's own timer is trusted
+	assert.NilError(t, r.checkClockJump(ctx, time.Minute, time.Minute, afterDeadline))
+}
+
+func TestWithClock_FakeClocksDontJump(t *testing.T) {
+	t.Parallel()
+
+	clock := &fakeClock{now: time.Date(2022, 6, 15, 12, 0, 0, 0, time.UTC)}
+	err := NewRetrier(
+		WithMaxAttempts(3),
+		WithStrategy(Constant(time.Hour)),
+		WithClock(clock),
+		WithSleepFunc(clock.Advance),
+		WithOnClockJump(func(j ClockJump) { t.Errorf("unexpected clock jump: %+v", j) }),
+	).Do(func(r *Retrier) error { return errDummy })
+
+	assert.ErrorIs(t, err, errDummy)
+}
@@ -0,0 +1,239 @@
+// Package cloudretry classifies errors from the AWS, Google Cloud and Azure APIs, deciding whether each is worth
+// retrying, whether it means the caller is being throttled, and how long the API asked the caller to wait, so that
+// tools that talk to more than one cloud can handle all of them the same way.
+//
+// It doesn't depend on any of the cloud SDKs. Callers pass an ErrorFunc that gets the parts of an error that matter
+// out of their SDK's errors. With the AWS SDK for Go v2, for example:
+//
+//	func awsError(err error) (cloudretry.Error, bool) {
+//		var e cloudretry.Error
+//		var apiErr smithy.APIError
+//		var respErr *awshttp.ResponseError
+//		if errors.As(err, &apiErr) {
+//			e.Code = apiErr.ErrorCode()
+//		}
+//		if errors.As(err, &respErr) {
+//			e.StatusCode = respErr.HTTPStatusCode()
+//			e.Header = respErr.Response.Header
+//		}
+//		return e, e.Code != "" || e.StatusCode != 0
+//	}
+//
+// with the Google API client libraries:
+//
+//	func gcpError(err error) (cloudretry.Error, bool) {
+//		var gerr *googleapi.Error
+//		if !errors.As(err, &gerr) {
+//			return cloudretry.Error{}, false
+//		}
+//		e := cloudretry.Error{StatusCode: gerr.Code, Header: gerr.Header}
+//		if len(gerr.Errors) > 0 {
+//			e.Code = gerr.Errors[0].Reason
+//		}
+//		return e, true
+//	}
+//
+// and with the Azure SDK for Go:
+//
+//	func azureError(err error) (cloudretry.Error, bool) {
+//		var rerr *azcore.ResponseError
+//		if !errors.As(err, &rerr) {
+//			return cloudretry.Error{}, false
+//		}
+//		return cloudretry.Error{StatusCode: rerr.StatusCode, Code: rerr.ErrorCode, Header: rerr.RawResponse.Header}, true
+//	}
+package cloudretry
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/buildkite/roko"
+)
+
+// Error is the parts of an error from a cloud API that say whether it's worth retrying
+type Error struct {
+	StatusCode int         // The HTTP status of the response, or 0 if there wasn't one
+	Code       string      // The error code (AWS and Azure), or reason (Google Cloud), from the body of the response
+	Header     http.Header // The headers of the response, or nil if there wasn't one
+}
+
+// ErrorFunc returns the parts of an error that Classify needs, and whether the error came from a cloud API at all
+type ErrorFunc func(err error) (Error, bool)
+
+// Verdict is a classifier's decision about an error
+type Verdict struct {
+	Retryable  bool          // Whether the request is worth retrying
+	Throttled  bool          // Whether the error means the caller is sending too many requests
+	RetryAfter time.Duration // How long the API asked the caller to wait before retrying, or 0 if it didn't say
+}
+
+// Classifier decides whether an error from a cloud API is worth retrying
+type Classifier func(e Error) Verdict
+
+// awsThrottlingCodes are the AWS error codes that mean a request was throttled, as the AWS SDKs recognise them
+var awsThrottlingCodes = codes(
+	"Throttling",
+	"ThrottlingException",
+	"ThrottledException",
+	"RequestThrottledException",
+	"TooManyRequestsException",
+	"ProvisionedThroughputExceededException",
+	"TransactionInProgressException",
+	"RequestLimitExceeded",
+	"BandwidthLimitExceeded",
+	"LimitExceededException",
+	"RequestThrottled",
+	"SlowDown",
+	"PriorRequestNotComplete",
+	"EC2ThrottledException",
+)
+
+// awsTransientCodes are the AWS error codes that mean a request failed for a reason that might go away
+var awsTransientCodes = codes(
+	"RequestTimeout",
+	"RequestTimeoutException",
+	"InternalError",
+	"InternalFailure",
+	"ServiceUnavailable",
+	"IDPCommunicationError",
+)
+
+// AWS classifies errors from AWS APIs. The throttling error codes (such as ThrottlingException, RequestLimitExceeded,
+// and S3's SlowDown) and 429 responses are throttled, and those, a handful of transient error codes, and 500, 502, 503
+// and 504 responses are retryable. AWS rarely says how long to wait, but a Retry-After header is honoured when it does
+func AWS(e Error) Verdict {
+	v := Verdict{Throttled: awsThrottlingCodes[e.Code] || e.StatusCode == http.StatusTooManyRequests}
+	v.Retryable = v.Throttled || awsTransientCodes[e.Code] || retryableStatus(e.StatusCode)
+	v.RetryAfter = retryAfter(e.Header, "Retry-After")
+	return v
+}
+
+// gcpThrottlingCodes are the Google Cloud error reasons (and gRPC status names) that mean a request was throttled
+var gcpThrottlingCodes = codes(
+	"rateLimitExceeded",
+	"userRateLimitExceeded",
+	"RATE_LIMIT_EXCEEDED",
+	"RESOURCE_EXHAUSTED",
+)
+
+// gcpTransientCodes are the Google Cloud error reasons that mean a request failed for a reason that might go away
+var gcpTransientCodes = codes(
+	"backendError",
+	"internalError",
+	"UNAVAILABLE",
+)
+
+// GCP classifies errors from Google Cloud APIs. 429 responses and the rateLimitExceeded and userRateLimitExceeded
+// reasons are throttled, and those, the backendError and internalError reasons, and 408, 500, 502, 503 and 504
+// responses are retryable. Some quota errors also come back as 403 rateLimitExceeded, which is throttled too, but 403s
+// with other reasons, like quotaExceeded for a daily quota, aren't retryable. A Retry-After header is honoured
+func GCP(e Error) Verdict {
+	v := Verdict{Throttled: gcpThrottlingCodes[e.Code] || e.StatusCode == http.StatusTooManyRequests}
+	v.Retryable = v.Throttled || gcpTransientCodes[e.Code] || retryableStatus(e.StatusCode) ||
+		e.StatusCode == http.StatusRequestTimeout
+	v.RetryAfter = retryAfter(e.Header, "Retry-After")
+	return v
+}
+
+// azureThrottlingCodes are the Azure error codes that mean a request was throttled
+var azureThrottlingCodes = codes(
+	"TooManyRequests",
+	"ServerBusy",
+	"SubscriptionRequestsThrottled",
+)
+
+// azureTransientCodes are the Azure error codes that mean a request failed for a reason that might go away
+var azureTransientCodes = codes(
+	"OperationTimedOut",
+	"InternalError",
+	"InternalServerError",
+)
+
+// Azure classifies errors from Azure APIs. 429 responses and the TooManyRequests, ServerBusy and
+// SubscriptionRequestsThrottled codes are throttled, and those, a handful of transient error codes, and 408, 500, 502,
+// 503 and 504 responses are retryable. Azure usually says how long to wait, and the headers it uses are honoured in the
+// same order the Azure SDKs check them: retry-after-ms, x-ms-retry-after-ms, and then Retry-After
+func Azure(e Error) Verdict {
+	v := Verdict{Throttled: azureThrottlingCodes[e.Code] || e.StatusCode == http.StatusTooManyRequests}
+	v.Retryable = v.Throttled || azureTransientCodes[e.Code] || retryableStatus(e.StatusCode) ||
+		e.StatusCode == http.StatusRequestTimeout
+	v.RetryAfter = retryAfter(e.Header, "retry-after-ms", "x-ms-retry-after-ms", "Retry-After")
+	return v
+}
+
+// Classify turns an error from a cloud API into one suitable for returning from a retrier's callback. Errors that
+// classify says aren't retryable are wrapped with roko.Unrecoverable. When a retryable error comes with a wait from the
+// API, Classify calls r.SetNextInterval with it. Errors that errorOf doesn't recognise, which usually come from the
+// network rather than the API, are returned as they are, to be retried
+func Classify(r *roko.Retrier, err error, errorOf ErrorFunc, classify Classifier) error {
+	if err == nil {
+		return nil
+	}
+
+	e, ok := errorOf(err)
+	if !ok {
+		return err
+	}
+
+	v := classify(e)
+	if !v.Retryable {
+		return roko.Unrecoverable(err)
+	}
+	if v.RetryAfter > 0 {
+		r.SetNextInterval(v.RetryAfter)
+	}
+	return err
+}
+
+func codes(cs ...string) map[string]bool {
+	m := make(map[string]bool, len(cs))
+	for _, c := range cs {
+		m[c] = true
+	}
+	return m
+}
+
+func retryableStatus(code int) bool {
+	switch code {
+	case http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// retryAfter returns the wait from the first of the headers that has one. Headers ending in "-ms" hold a number of
+// milliseconds, and any others are parsed as a Retry-After header
+func retryAfter(h http.Header, names ...string) time.Duration {
+	for _, name := range names {
+		value := h.Get(name)
+		if value == "" {
+			continue
+		}
+
+		if strings.HasSuffix(name, "-ms") {
+			if ms, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64); err == nil && ms >= 0 {
+				return time.Duration(ms) * time.Millisecond
+			}
+			continue
+		}
+
+		if d, ok := roko.ParseRetryAfter(value, time.Now()); ok {
+			return d
+		}
+	}
+	return 0
+}
+
+// NewRetrier returns a retrier suitable for a single request to a cloud API. It makes up to 8 attempts, waiting
+// exponentially longer between each, starting at half a second, with full jitter, which is what all three clouds
+// recommend for spreading out retries from clients that were throttled at the same time
+func NewRetrier() *roko.Retrier {
+	return roko.NewRetrier(
+		roko.WithMaxAttempts(8),
+		roko.WithStrategy(roko.ExponentialSubsecond(500*time.Millisecond)),
+		roko.WithJitter(roko.FullJitter),
+	)
+}
@@ -0,0 +1,106 @@
+package cloudretry
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/buildkite/roko"
+	"gotest.tools/v3/assert"
+)
+
+func TestClassifiers(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name     string
+		classify Classifier
+		err      Error
+		want     Verdict
+	}{
+		{"aws throttling", AWS, Error{StatusCode: 400, Code: "ThrottlingException"}, Verdict{Retryable: true, Throttled: true}},
+		{"aws ec2 request limit", AWS, Error{StatusCode: 503, Code: "RequestLimitExceeded"}, Verdict{Retryable: true, Throttled: true}},
+		{"aws s3 slow down", AWS, Error{StatusCode: 503, Code: "SlowDown"}, Verdict{Retryable: true, Throttled: true}},
+		{"aws transient", AWS, Error{StatusCode: 400, Code: "RequestTimeout"}, Verdict{Retryable: true}},
+		{"aws server error", AWS, Error{StatusCode: 502}, Verdict{Retryable: true}},
+		{"aws access denied", AWS, Error{StatusCode: 403, Code: "AccessDenied"}, Verdict{}},
+		{"aws validation", AWS, Error{StatusCode: 400, Code: "ValidationException"}, Verdict{}},
+
+		{"gcp too many requests", GCP, Error{StatusCode: 429}, Verdict{Retryable: true, Throttled: true}},
+		{"gcp rate limit", GCP, Error{StatusCode: 403, Code: "rateLimitExceeded"}, Verdict{Retryable: true, Throttled: true}},
+		{"gcp user rate limit", GCP, Error{StatusCode: 403, Code: "userRateLimitExceeded"}, Verdict{Retryable: true, Throttled: true}},
+		{"gcp daily quota", GCP, Error{StatusCode: 403, Code: "quotaExceeded"}, Verdict{}},
+		{"gcp backend error", GCP, Error{StatusCode: 500, Code: "backendError"}, Verdict{Retryable: true}},
+		{"gcp request timeout", GCP, Error{StatusCode: 408}, Verdict{Retryable: true}},
+		{"gcp not found", GCP, Error{StatusCode: 404, Code: "notFound"}, Verdict{}},
+
+		{
+			"azure too many requests", Azure,
+			Error{StatusCode: 429, Header: http.Header{"Retry-After": {"17"}}},
+			Verdict{Retryable: true, Throttled: true, RetryAfter: 17 * time.Second},
+		},
+		{
+			"azure milliseconds", Azure,
+			Error{StatusCode: 503, Code: "ServerBusy", Header: http.Header{"Retry-After-Ms": {"250"}, "Retry-After": {"1"}}},
+			Verdict{Retryable: true, Throttled: true, RetryAfter: 250 * time.Millisecond},
+		},
+		{
+			"azure x-ms milliseconds", Azure,
+			Error{StatusCode: 429, Header: http.Header{"X-Ms-Retry-After-Ms": {"1500"}}},
+			Verdict{Retryable: true, Throttled: true, RetryAfter: 1500 * time.Millisecond},
+		},
+		{
+			"azure malformed milliseconds", Azure,
+			Error{StatusCode: 429, Header: http.Header{"Retry-After-Ms": {"soon"}, "Retry-After": {"2"}}},
+			Verdict{Retryable: true, Throttled: true, RetryAfter: 2 * time.Second},
+		},
+		{"azure timed out", Azure, Error{StatusCode: 500, Code: "OperationTimedOut"}, Verdict{Retryable: true}},
+		{"azure conflict", Azure, Error{StatusCode: 409, Code: "Conflict"}, Verdict{}},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			assert.DeepEqual(t, tc.want, tc.classify(tc.err))
+		})
+	}
+}
+
+type apiError struct {
+	e Error
+}
+
+func (e *apiError) Error() string {
+	return e.e.Code
+}
+
+func errorOf(err error) (Error, bool) {
+	var aerr *apiError
+	if errors.As(err, &aerr) {
+		return aerr.e, true
+	}
+	return Error{}, false
+}
+
+func TestClassify(t *testing.T) {
+	t.Parallel()
+
+	r := roko.NewRetrier(roko.WithMaxAttempts(3), roko.WithStrategy(roko.Constant(time.Second)))
+
+	assert.NilError(t, Classify(r, nil, errorOf, AWS))
+
+	errNetwork := errors.New("dial tcp: i/o timeout")
+	assert.Equal(t, errNetwork, Classify(r, errNetwork, errorOf, AWS))
+
+	denied := &apiError{Error{StatusCode: 403, Code: "AccessDenied"}}
+	err := Classify(r, denied, errorOf, AWS)
+	assert.ErrorIs(t, err, roko.ErrUnrecoverable)
+	assert.ErrorIs(t, err, denied)
+
+	throttled := &apiError{Error{StatusCode: 429, Code: "TooManyRequests", Header: http.Header{"Retry-After": {"30"}}}}
+	err = Classify(r, throttled, errorOf, Azure)
+	assert.Equal(t, throttled, err)
+	assert.Equal(t, 30*time.Second, r.NextInterval())
+}
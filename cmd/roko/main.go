@@ -0,0 +1,289 @@
+// Command roko runs a shell command, retrying it when it fails, so that shell scripts can use the same retry policies
+// as Go code:
+//
+//	roko --attempts 5 --strategy 'exp(1s,2)' --jitter -- curl -fsS https://example.com/
+//
+// It describes its policy before the first attempt, and says how long it's going to wait before each retry, on stderr.
+// Once the command succeeds, or roko gives up, it exits with the command's last exit status. The command's stdin,
+// stdout and stderr are roko's own, so a command that reads from stdin only gets its input on the first attempt.
+//
+// Strategies are written like the names roko gives them:
+//
+//	constant(2s)       wait 2s between attempts
+//	exp(1s,2)          wait 1s, then 2s, 4s, 8s and so on (the multiplier defaults to 2)
+//	replay(1s,5s,30s)  wait each of the intervals in turn, and then the last one for every retry after that
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/buildkite/roko"
+)
+
+// Exit statuses for when the command couldn't be run, which match the ones shells use
+const (
+	exitUsage       = 2
+	exitCantExecute = 126
+	exitNotFound    = 127
+)
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	code := run(ctx, os.Args[1:], os.Stdin, os.Stdout, os.Stderr)
+	stop()
+	os.Exit(code)
+}
+
+// run runs roko with args (not including the program name), and returns the status to exit with
+func run(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("roko", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "usage: roko [flags] -- command [args...]")
+		flags.PrintDefaults()
+	}
+
+	attempts := flags.Int("attempts", 3, "the most attempts to make, including the first")
+	forever := flags.Bool("forever", false, "keep retrying until the command succeeds, ignoring --attempts")
+	strategy := flags.String("strategy", "exp(1s,2)", "how long to wait between attempts: constant(d), exp(d[,multiplier]) or replay(d,d,...)")
+	jitter := flags.Bool("jitter", false, "add up to a second of random jitter to each wait")
+	maxTotalSleep := flags.Duration("max-total-sleep", 0, "give up once the waits between attempts would add up to more than this")
+	retryOn := flags.String("retry-on", "", "a comma-separated list of the exit statuses to retry (default any but 0)")
+	quiet := flags.Bool("quiet", false, "don't describe the policy, or each retry")
+
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	command := flags.Args()
+	if len(command) == 0 {
+		flags.Usage()
+		return exitUsage
+	}
+
+	usageError := func(format string, a ...interface{}) int {
+		fmt.Fprintf(stderr, "roko: "+format+"\n", a...)
+		return exitUsage
+	}
+
+	s, name, err := parseStrategy(*strategy)
+	if err != nil {
+		return usageError("--strategy: %v", err)
+	}
+	retryable, err := parseExitStatuses(*retryOn)
+	if err != nil {
+		return usageError("--retry-on: %v", err)
+	}
+	if !*forever && *attempts < 1 {
+		return usageError("--attempts must be at least 1")
+	}
+	if *maxTotalSleep < 0 {
+		return usageError("--max-total-sleep can't be negative")
+	}
+
+	logf := func(format string, a ...interface{}) {
+		if !*quiet {
+			fmt.Fprintf(stderr, "roko: "+format+"\n", a...)
+		}
+	}
+
+	limit := roko.WithMaxAttempts(*attempts)
+	if *forever {
+		limit = roko.TryForever()
+	}
+	// Options that weren't asked for are replaced with ones that do nothing, since roko's option type can't be named
+	// here to build up a list of them
+	noop := func(*roko.Retrier) {}
+	jitterOpt := roko.WithJitter()
+	if !*jitter {
+		jitterOpt = noop
+	}
+	sleepOpt := noop
+	if *maxTotalSleep > 0 {
+		sleepOpt = roko.WithMaxTotalSleep(*maxTotalSleep)
+	}
+
+	r, err := newRetrier(func() *roko.Retrier {
+		return roko.NewRetrier(
+			limit,
+			jitterOpt,
+			roko.WithStrategy(s, name),
+			sleepOpt,
+			roko.WithOnAttempt(func(e roko.AttemptEvent) {
+				switch {
+				case e.Err == nil:
+				case e.Final:
+					logf("attempt %d failed (%v), giving up", e.Attempt, e.Err)
+				default:
+					logf("attempt %d failed (%v), retrying in %s", e.Attempt, e.Err, e.NextInterval.Round(time.Millisecond))
+				}
+			}),
+		)
+	})
+	if err != nil {
+		return usageError("%v", err)
+	}
+	logf("running %s with %s", command[0], r.Describe())
+
+	err = r.DoWithContext(ctx, func(r *roko.Retrier) error {
+		cmd := exec.CommandContext(r.Context(), command[0], command[1:]...)
+		cmd.Stdin = stdin
+		cmd.Stdout = stdout
+		cmd.Stderr = stderr
+
+		err := cmd.Run()
+		var exitErr *exec.ExitError
+		switch {
+		case err == nil:
+			return nil
+		case errors.As(err, &exitErr):
+			if retryable != nil && !retryable[exitErr.ExitCode()] {
+				return roko.Unrecoverable(err)
+			}
+			return err
+		default:
+			// The command couldn't be started at all, which trying again won't fix
+			return roko.Unrecoverable(err)
+		}
+	})
+
+	return exitStatus(err)
+}
+
+// newRetrier returns the retrier from f, or an error if f panics because its options don't make sense together, as
+// roko.NewRetrier does
+func newRetrier(f func() *roko.Retrier) (r *roko.Retrier, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("%v", p)
+		}
+	}()
+	return f(), nil
+}
+
+// exitStatus returns the status to exit with after the command's last attempt returned err
+func exitStatus(err error) int {
+	if err == nil {
+		return 0
+	}
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		if code := exitErr.ExitCode(); code >= 0 {
+			return code
+		}
+		return 1 // Killed by a signal
+	}
+	if errors.Is(err, exec.ErrNotFound) || errors.Is(err, os.ErrNotExist) {
+		return exitNotFound
+	}
+	return exitCantExecute
+}
+
+// parseStrategy parses a strategy written as kind(params), returning it along with its name
+func parseStrategy(spec string) (roko.Strategy, string, error) {
+	spec = strings.TrimSpace(spec)
+	kind, rest, ok := strings.Cut(spec, "(")
+	if !ok || !strings.HasSuffix(rest, ")") {
+		return nil, "", fmt.Errorf("%q should look like kind(params), such as exp(1s,2)", spec)
+	}
+
+	params := strings.Split(strings.TrimSuffix(rest, ")"), ",")
+	for i := range params {
+		params[i] = strings.TrimSpace(params[i])
+	}
+
+	switch strings.TrimSpace(kind) {
+	case "constant":
+		if len(params) != 1 {
+			return nil, "", errors.New("constant takes one interval, as in constant(2s)")
+		}
+		d, err := parseInterval(params[0])
+		if err != nil {
+			return nil, "", err
+		}
+		s, name := roko.Constant(d)
+		return s, name, nil
+
+	case "exp":
+		if len(params) < 1 || len(params) > 2 {
+			return nil, "", errors.New("exp takes an initial interval and an optional multiplier, as in exp(1s,2)")
+		}
+		initial, err := parseInterval(params[0])
+		if err != nil {
+			return nil, "", err
+		}
+		multiplier := 2.0
+		if len(params) == 2 {
+			if multiplier, err = strconv.ParseFloat(params[1], 64); err != nil || multiplier < 1 {
+				return nil, "", fmt.Errorf("exp's multiplier should be a number of at least 1, not %q", params[1])
+			}
+		}
+		s, _ := roko.Func(func(attempt int) time.Duration {
+			d := float64(initial) * math.Pow(multiplier, float64(attempt))
+			if d >= math.MaxInt64 {
+				return time.Duration(math.MaxInt64)
+			}
+			return time.Duration(d)
+		})
+		return s, fmt.Sprintf("exp(%s, %g)", initial, multiplier), nil
+
+	case "replay":
+		intervals, err := roko.ParseIntervals(strings.Join(params, ","))
+		if err != nil {
+			return nil, "", err
+		}
+		if len(intervals) == 0 {
+			return nil, "", errors.New("replay takes at least one interval, as in replay(1s,5s,30s)")
+		}
+		for _, d := range intervals {
+			if d < 0 {
+				return nil, "", fmt.Errorf("intervals can't be negative, but got %s", d)
+			}
+		}
+		s, name := roko.Replay(intervals...)
+		return s, name, nil
+
+	default:
+		return nil, "", fmt.Errorf("unknown strategy %q, which should be constant, exp or replay", kind)
+	}
+}
+
+func parseInterval(s string) (time.Duration, error) {
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, err
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("intervals can't be negative, but got %s", d)
+	}
+	return d, nil
+}
+
+// parseExitStatuses parses a comma-separated list of exit statuses into a set. It returns nil for an empty list
+func parseExitStatuses(s string) (map[int]bool, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	statuses := map[int]bool{}
+	for _, part := range strings.Split(s, ",") {
+		code, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || code < 1 || code > 255 {
+			return nil, fmt.Errorf("%q isn't an exit status between 1 and 255", part)
+		}
+		statuses[code] = true
+	}
+	return statuses, nil
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/buildkite/roko"
+	"gotest.tools/v3/assert"
+)
+
+func runRoko(t *testing.T, args ...string) (code int, stdout, stderr string) {
+	t.Helper()
+
+	var out, errOut bytes.Buffer
+	code = run(context.Background(), args, strings.NewReader(""), &out, &errOut)
+	return code, out.String(), errOut.String()
+}
+
+func TestRun_RetriesUntilTheCommandSucceeds(t *testing.T) {
+	t.Parallel()
+
+	// The command fails until it's been run three times, counting its runs in a file
+	counter := filepath.Join(t.TempDir(), "count")
+	script := `n=$(cat "$1" 2>/dev/null || echo 0); n=$((n+1)); echo $n > "$1"; echo "run $n"; [ $n -ge 3 ]`
+
+	code, stdout, stderr := runRoko(t, "--attempts", "5", "--strategy", "constant(1ms)", "--", "sh", "-c", script, "sh", counter)
+
+	assert.Equal(t, 0, code)
+	assert.Equal(t, "run 1\nrun 2\nrun 3\n", stdout)
+	assert.Equal(t, strings.Join([]string{
+		"roko: running sh with constant(1ms), up to 5 attempts",
+		"roko: attempt 1 failed (exit status 1), retrying in 1ms",
+		"roko: attempt 2 failed (exit status 1), retrying in 1ms",
+		"",
+	}, "\n"), stderr)
+}
+
+func TestRun_ExitsWithTheLastStatus(t *testing.T) {
+	t.Parallel()
+
+	code, _, stderr := runRoko(t, "--attempts", "2", "--strategy", "constant(0s)", "--", "sh", "-c", "exit 3")
+
+	assert.Equal(t, 3, code)
+	assert.Check(t, strings.Contains(stderr, "attempt 1 failed (exit status 3), retrying in 0s"), stderr)
+	assert.Check(t, strings.Contains(stderr, "attempt 2 failed (exit status 3), giving up"), stderr)
+}
+
+func TestRun_OnlyRetriesTheGivenStatuses(t *testing.T) {
+	t.Parallel()
+
+	counter := filepath.Join(t.TempDir(), "count")
+	script := `echo x >> "$1"; exit 4`
+
+	code, _, _ := runRoko(t, "--attempts", "5", "--strategy", "constant(0s)", "--retry-on", "1,2,3", "--", "sh", "-c", script, "sh", counter)
+	assert.Equal(t, 4, code)
+
+	runs, err := os.ReadFile(counter)
+	assert.NilError(t, err)
+	assert.Equal(t, "x\n", string(runs))
+}
+
+func TestRun_Quiet(t *testing.T) {
+	t.Parallel()
+
+	code, _, stderr := runRoko(t, "--quiet", "--attempts", "2", "--strategy", "constant(0s)", "--", "false")
+	assert.Equal(t, 1, code)
+	assert.Equal(t, "", stderr)
+}
+
+func TestRun_CommandNotFound(t *testing.T) {
+	t.Parallel()
+
+	code, _, stderr := runRoko(t, "--attempts", "5", "--", "roko-test-no-such-command")
+	assert.Equal(t, exitNotFound, code)
+	assert.Check(t, strings.Contains(stderr, "attempt 1 failed"), stderr)
+	assert.Check(t, strings.Contains(stderr, "giving up"), stderr)
+}
+
+func TestRun_UsageErrors(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		args []string
+		want string
+	}{
+		{[]string{}, "usage: roko"},
+		{[]string{"--strategy", "linear(1s)", "--", "true"}, `unknown strategy "linear"`},
+		{[]string{"--strategy", "exp(1s", "--", "true"}, "should look like kind(params)"},
+		{[]string{"--retry-on", "0", "--", "true"}, "isn't an exit status between 1 and 255"},
+		{[]string{"--attempts", "0", "--", "true"}, "--attempts must be at least 1"},
+		{[]string{"--forever", "--strategy", "constant(0s)", "--", "true"}, "must have an interval"},
+	}
+
+	for _, tc := range cases {
+		code, _, stderr := runRoko(t, tc.args...)
+		assert.Equal(t, exitUsage, code, "args %q", tc.args)
+		assert.Check(t, strings.Contains(stderr, tc.want), "args %q: %s", tc.args, stderr)
+	}
+}
+
+func TestParseStrategy(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		spec      string
+		name      string
+		intervals []time.Duration // The intervals the strategy gives before the first few retries
+	}{
+		{"constant(2s)", "constant(2s)", []time.Duration{2 * time.Second, 2 * time.Second}},
+		{"exp(1s,2)", "exp(1s, 2)", []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second}},
+		{"exp(100ms)", "exp(100ms, 2)", []time.Duration{100 * time.Millisecond, 200 * time.Millisecond}},
+		{" exp( 1s , 1.5 ) ", "exp(1s, 1.5)", []time.Duration{time.Second, 1500 * time.Millisecond, 2250 * time.Millisecond}},
+		{"replay(1s,5s,30s)", "replay(3 intervals)", []time.Duration{time.Second, 5 * time.Second, 30 * time.Second, 30 * time.Second}},
+	}
+
+	for _, tc := range cases {
+		s, name, err := parseStrategy(tc.spec)
+		assert.NilError(t, err, tc.spec)
+		assert.Equal(t, tc.name, name)
+
+		r := roko.NewRetrier(roko.WithStrategy(s, name), roko.WithMaxAttempts(len(tc.intervals)+1))
+		assert.DeepEqual(t, tc.intervals, r.PlannedIntervals(len(tc.intervals)))
+	}
+
+	for _, bad := range []string{"exp(1s,0.5)", "exp()", "exp(-1s)", "constant(1s,2s)", "replay()", "replay(1s,-1s)", "fibonacci(1s)"} {
+		_, _, err := parseStrategy(bad)
+		assert.Check(t, err != nil, bad)
+	}
+}
@@ -0,0 +1,24 @@
+// Package example is an example of a retrying wrapper generated by rokogen. It's compiled along with the rest of the
+// module, and the rokogen tests check that the generated code is up to date.
+package example
+
+import (
+	"context"
+	"io"
+)
+
+//go:generate go run github.com/buildkite/roko/cmd/rokogen -type Client
+
+type User struct {
+	ID, Name string
+}
+
+// Client is an example API client
+type Client interface {
+	GetUser(ctx context.Context, id string) (*User, error)
+	ListUsers(ctx context.Context, ids ...string) ([]User, string, error)
+	Delete(ctx context.Context, id string) error
+	Download(name string) (io.ReadCloser, error)
+	Endpoint() string
+	Close()
+}
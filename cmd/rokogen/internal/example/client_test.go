@@ -0,0 +1,58 @@
+package example
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/buildkite/roko"
+	"gotest.tools/v3/assert"
+)
+
+var errFlaky = errors.New("flaky")
+
+type flakyClient struct {
+	calls int
+}
+
+func (c *flakyClient) GetUser(ctx context.Context, id string) (*User, error) {
+	c.calls++
+	if c.calls < 3 {
+		return nil, errFlaky
+	}
+	return &User{ID: id, Name: "Joe"}, nil
+}
+
+func (c *flakyClient) ListUsers(ctx context.Context, ids ...string) ([]User, string, error) {
+	return nil, "", errFlaky
+}
+
+func (c *flakyClient) Delete(ctx context.Context, id string) error { return nil }
+func (c *flakyClient) Download(name string) (io.ReadCloser, error) { return nil, errFlaky }
+func (c *flakyClient) Endpoint() string                            { return "https://example.com" }
+func (c *flakyClient) Close()                                      {}
+
+func TestRetryingClient(t *testing.T) {
+	t.Parallel()
+
+	next := &flakyClient{}
+	c := NewRetryingClient(next, func() *roko.Retrier {
+		return roko.NewRetrier(
+			roko.WithMaxAttempts(3),
+			roko.WithStrategy(roko.Constant(time.Millisecond)),
+			roko.WithSleepFunc(func(time.Duration) {}),
+		)
+	})
+
+	u, err := c.GetUser(context.Background(), "42")
+	assert.NilError(t, err)
+	assert.Equal(t, "Joe", u.Name)
+	assert.Equal(t, 3, next.calls)
+
+	_, _, err = c.ListUsers(context.Background(), "1", "2")
+	assert.ErrorIs(t, err, errFlaky)
+
+	assert.Equal(t, "https://example.com", c.Endpoint())
+}
@@ -0,0 +1,69 @@
+// Code generated by rokogen. DO NOT EDIT.
+
+package example
+
+import (
+	"context"
+	"io"
+
+	"github.com/buildkite/roko"
+)
+
+// RetryingClient wraps a Client, retrying each of its methods that return an error. Each call gets a new retrier from
+// newRetrier
+type RetryingClient struct {
+	next       Client
+	newRetrier func() *roko.Retrier
+}
+
+var _ Client = (*RetryingClient)(nil)
+
+// NewRetryingClient returns a RetryingClient that calls next
+func NewRetryingClient(next Client, newRetrier func() *roko.Retrier) *RetryingClient {
+	return &RetryingClient{next: next, newRetrier: newRetrier}
+}
+
+func (w *RetryingClient) GetUser(p0 context.Context, p1 string) (*User, error) {
+	var r0 *User
+	err := w.newRetrier().DoWithContext(p0, func(r *roko.Retrier) error {
+		var err error
+		r0, err = w.next.GetUser(r.Context(), p1)
+		return err
+	})
+	return r0, err
+}
+
+func (w *RetryingClient) ListUsers(p0 context.Context, p1 ...string) ([]User, string, error) {
+	var r0 []User
+	var r1 string
+	err := w.newRetrier().DoWithContext(p0, func(r *roko.Retrier) error {
+		var err error
+		r0, r1, err = w.next.ListUsers(r.Context(), p1...)
+		return err
+	})
+	return r0, r1, err
+}
+
+func (w *RetryingClient) Delete(p0 context.Context, p1 string) error {
+	return w.newRetrier().DoWithContext(p0, func(r *roko.Retrier) error {
+		return w.next.Delete(r.Context(), p1)
+	})
+}
+
+func (w *RetryingClient) Download(p0 string) (io.ReadCloser, error) {
+	var r0 io.ReadCloser
+	err := w.newRetrier().Do(func(r *roko.Retrier) error {
+		var err error
+		r0, err = w.next.Download(p0)
+		return err
+	})
+	return r0, err
+}
+
+func (w *RetryingClient) Endpoint() string {
+	return w.next.Endpoint()
+}
+
+func (w *RetryingClient) Close() {
+	w.next.Close()
+}
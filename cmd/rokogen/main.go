@@ -0,0 +1,356 @@
+// Command rokogen generates a retrying wrapper for an interface, so that a client with dozens of methods doesn't have
+// to be wrapped in retries by hand. Given an interface like
+//
+//	type APIClient interface {
+//		GetUser(ctx context.Context, id string) (*User, error)
+//		// ...
+//	}
+//
+// rokogen -type APIClient generates a RetryingAPIClient, which implements APIClient by calling another APIClient, and
+// retrying each method that returns an error with a new retrier for each call. Methods that take a context.Context as
+// their first argument are passed the context for each attempt. Methods that don't return an error are passed
+// straight through. It's meant to be run with go generate, in the package that declares the interface:
+//
+//	//go:generate go run github.com/buildkite/roko/cmd/rokogen -type APIClient
+//
+// Only methods declared in the interface itself are wrapped - interfaces that embed other interfaces aren't supported.
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const rokoImport = "github.com/buildkite/roko"
+
+func main() {
+	typeName := flag.String("type", "", "the name of the interface to wrap (required)")
+	output := flag.String("output", "", "the file to write the wrapper to (default retrying_<type>.go)")
+	dir := flag.String("dir", ".", "the directory containing the package that declares the interface")
+	flag.Parse()
+
+	log.SetFlags(0)
+	log.SetPrefix("rokogen: ")
+
+	if *typeName == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if *output == "" {
+		*output = "retrying_" + strings.ToLower(*typeName) + ".go"
+	}
+	outPath := filepath.Join(*dir, *output)
+
+	fset := token.NewFileSet()
+	files, err := parsePackage(fset, *dir, outPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	src, err := generate(fset, files, *typeName)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := os.WriteFile(outPath, src, 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// parsePackage parses the non-test Go files in dir, apart from skip (the output from a previous run)
+func parsePackage(fset *token.FileSet, dir, skip string) ([]*ast.File, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	files := []*ast.File{}
+	for _, e := range entries {
+		name := e.Name()
+		path := filepath.Join(dir, name)
+		if e.IsDir() || !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") || path == filepath.Clean(skip) {
+			continue
+		}
+
+		f, err := parser.ParseFile(fset, path, nil, parser.ParseComments)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, f)
+	}
+
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no Go files in %s", dir)
+	}
+	return files, nil
+}
+
+// generate returns the source of a retrying wrapper for the interface called typeName, declared in one of files
+func generate(fset *token.FileSet, files []*ast.File, typeName string) ([]byte, error) {
+	file, iface := findInterface(files, typeName)
+	if iface == nil {
+		return nil, fmt.Errorf("no interface called %s", typeName)
+	}
+
+	g := &generator{fset: fset, imports: map[string]string{}}
+	for _, spec := range file.Imports {
+		path, _ := strconv.Unquote(spec.Path.Value)
+		name := filepath.Base(path)
+		if spec.Name != nil {
+			name = spec.Name.Name
+		}
+		g.available = append(g.available, importSpec{name: name, path: path})
+	}
+
+	wrapper := "Retrying" + typeName
+	g.printf("// %s wraps a %s, retrying each of its methods that return an error. Each call gets a new retrier from\n", wrapper, typeName)
+	g.printf("// newRetrier\n")
+	g.printf("type %s struct {\n\tnext %s\n\tnewRetrier func() *roko.Retrier\n}\n\n", wrapper, typeName)
+	g.printf("var _ %s = (*%s)(nil)\n\n", typeName, wrapper)
+	g.printf("// New%s returns a %s that calls next\n", wrapper, wrapper)
+	g.printf("func New%s(next %s, newRetrier func() *roko.Retrier) *%s {\n", wrapper, typeName, wrapper)
+	g.printf("\treturn &%s{next: next, newRetrier: newRetrier}\n}\n", wrapper)
+
+	for _, m := range iface.Methods.List {
+		ft, ok := m.Type.(*ast.FuncType)
+		if !ok || len(m.Names) == 0 {
+			return nil, errors.New("interfaces that embed other interfaces aren't supported")
+		}
+		for _, name := range m.Names {
+			g.method(wrapper, name.Name, ft)
+		}
+	}
+
+	return g.source(file.Name.Name)
+}
+
+func findInterface(files []*ast.File, name string) (*ast.File, *ast.InterfaceType) {
+	for _, f := range files {
+		for _, decl := range f.Decls {
+			gd, ok := decl.(*ast.GenDecl)
+			if !ok || gd.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range gd.Specs {
+				ts := spec.(*ast.TypeSpec)
+				if it, ok := ts.Type.(*ast.InterfaceType); ok && ts.Name.Name == name {
+					return f, it
+				}
+			}
+		}
+	}
+	return nil, nil
+}
+
+type importSpec struct {
+	name, path string
+}
+
+type generator struct {
+	fset      *token.FileSet
+	available []importSpec      // the imports of the file declaring the interface
+	imports   map[string]string // the imports the generated code uses, by name
+	body      bytes.Buffer
+}
+
+func (g *generator) printf(format string, args ...interface{}) {
+	fmt.Fprintf(&g.body, format, args...)
+}
+
+// expr prints a type expression, noting the imports it uses
+func (g *generator) expr(e ast.Expr) string {
+	ast.Inspect(e, func(n ast.Node) bool {
+		sel, ok := n.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+		if id, ok := sel.X.(*ast.Ident); ok {
+			for _, imp := range g.available {
+				if imp.name == id.Name {
+					g.imports[imp.name] = imp.path
+				}
+			}
+		}
+		return false
+	})
+
+	var b strings.Builder
+	printer.Fprint(&b, g.fset, e)
+	return b.String()
+}
+
+func isContext(e ast.Expr) bool {
+	sel, ok := e.(*ast.SelectorExpr)
+	if !ok {
+		return false
+	}
+	id, ok := sel.X.(*ast.Ident)
+	return ok && id.Name == "context" && sel.Sel.Name == "Context"
+}
+
+func isError(e ast.Expr) bool {
+	id, ok := e.(*ast.Ident)
+	return ok && id.Name == "error"
+}
+
+// method generates the wrapper for one of the interface's methods
+func (g *generator) method(wrapper, name string, ft *ast.FuncType) {
+	params, args, types := []string{}, []string{}, []ast.Expr{}
+	if ft.Params != nil {
+		for _, field := range ft.Params.List {
+			n := len(field.Names)
+			if n == 0 {
+				n = 1
+			}
+			for i := 0; i < n; i++ {
+				types = append(types, field.Type)
+			}
+		}
+	}
+	for i, t := range types {
+		p := fmt.Sprintf("p%d", i)
+		if ell, ok := t.(*ast.Ellipsis); ok {
+			params = append(params, fmt.Sprintf("%s ...%s", p, g.expr(ell.Elt)))
+			args = append(args, p+"...")
+		} else {
+			params = append(params, fmt.Sprintf("%s %s", p, g.expr(t)))
+			args = append(args, p)
+		}
+	}
+
+	results := []ast.Expr{}
+	if ft.Results != nil {
+		for _, field := range ft.Results.List {
+			n := len(field.Names)
+			if n == 0 {
+				n = 1
+			}
+			for i := 0; i < n; i++ {
+				results = append(results, field.Type)
+			}
+		}
+	}
+	resultTypes := []string{}
+	for _, t := range results {
+		resultTypes = append(resultTypes, g.expr(t))
+	}
+
+	signature := fmt.Sprintf("func (w *%s) %s(%s)", wrapper, name, strings.Join(params, ", "))
+	switch len(resultTypes) {
+	case 0:
+	case 1:
+		signature += " " + resultTypes[0]
+	default:
+		signature += " (" + strings.Join(resultTypes, ", ") + ")"
+	}
+
+	g.printf("\n%s {\n", signature)
+	defer g.printf("}\n")
+
+	if len(results) == 0 || !isError(results[len(results)-1]) {
+		// Nothing to retry on
+		call := fmt.Sprintf("w.next.%s(%s)", name, strings.Join(args, ", "))
+		if len(results) == 0 {
+			g.printf("\t%s\n", call)
+		} else {
+			g.printf("\treturn %s\n", call)
+		}
+		return
+	}
+
+	g.imports["roko"] = rokoImport
+
+	vals := []string{}
+	for i, t := range resultTypes[:len(resultTypes)-1] {
+		v := fmt.Sprintf("r%d", i)
+		g.printf("\tvar %s %s\n", v, t)
+		vals = append(vals, v)
+	}
+
+	assign := "err :="
+	if len(vals) == 0 {
+		assign = "return"
+	}
+
+	if len(types) > 0 && isContext(types[0]) {
+		args[0] = "r.Context()"
+		g.printf("\t%s w.newRetrier().DoWithContext(p0, func(r *roko.Retrier) error {\n", assign)
+	} else {
+		g.printf("\t%s w.newRetrier().Do(func(r *roko.Retrier) error {\n", assign)
+	}
+
+	call := fmt.Sprintf("w.next.%s(%s)", name, strings.Join(args, ", "))
+	if len(vals) == 0 {
+		g.printf("\t\treturn %s\n", call)
+	} else {
+		g.printf("\t\tvar err error\n")
+		g.printf("\t\t%s, err = %s\n", strings.Join(vals, ", "), call)
+		g.printf("\t\treturn err\n")
+	}
+	g.printf("\t})\n")
+	if len(vals) > 0 {
+		g.printf("\treturn %s\n", strings.Join(append(vals, "err"), ", "))
+	}
+}
+
+// isStdlib returns whether an import path is in the standard library, which (unlike everything else) doesn't have a
+// domain name as its first element
+func isStdlib(path string) bool {
+	first, _, _ := strings.Cut(path, "/")
+	return !strings.Contains(first, ".")
+}
+
+// source assembles and formats the generated file
+func (g *generator) source(pkg string) ([]byte, error) {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// Code generated by rokogen. DO NOT EDIT.\n\npackage %s\n\n", pkg)
+
+	if len(g.imports) > 0 {
+		names := make([]string, 0, len(g.imports))
+		for name := range g.imports {
+			names = append(names, name)
+		}
+		sort.Slice(names, func(i, j int) bool {
+			a, b := g.imports[names[i]], g.imports[names[j]]
+			if isStdlib(a) != isStdlib(b) {
+				return isStdlib(a)
+			}
+			return a < b
+		})
+
+		b.WriteString("import (\n")
+		for i, name := range names {
+			path := g.imports[name]
+			if i > 0 && isStdlib(g.imports[names[i-1]]) && !isStdlib(path) {
+				b.WriteString("\n")
+			}
+			if filepath.Base(path) == name {
+				fmt.Fprintf(&b, "\t%q\n", path)
+			} else {
+				fmt.Fprintf(&b, "\t%s %q\n", name, path)
+			}
+		}
+		b.WriteString(")\n\n")
+	}
+
+	b.Write(g.body.Bytes())
+
+	src, err := format.Source(b.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("formatting generated code: %w", err)
+	}
+	return src, nil
+}
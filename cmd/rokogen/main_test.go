@@ -0,0 +1,53 @@
+package main
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestGenerate_ExampleIsUpToDate(t *testing.T) {
+	t.Parallel()
+
+	dir := filepath.Join("internal", "example")
+	out := filepath.Join(dir, "retrying_client.go")
+
+	fset := token.NewFileSet()
+	files, err := parsePackage(fset, dir, out)
+	assert.NilError(t, err)
+
+	got, err := generate(fset, files, "Client")
+	assert.NilError(t, err)
+
+	want, err := os.ReadFile(out)
+	assert.NilError(t, err)
+	assert.Equal(t, string(want), string(got), "run go generate ./... to update the example")
+}
+
+func TestGenerate_Errors(t *testing.T) {
+	t.Parallel()
+
+	src := `package p
+
+import "io"
+
+type Embeds interface {
+	io.Reader
+	Close() error
+}
+`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.go", src, 0)
+	assert.NilError(t, err)
+
+	_, err = generate(fset, nil, "Missing")
+	assert.ErrorContains(t, err, "no interface called Missing")
+
+	_, err = generate(fset, []*ast.File{f}, "Embeds")
+	assert.ErrorContains(t, err, "embed")
+}
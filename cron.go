@@ -0,0 +1,192 @@
+package roko
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Cron returns a strategy that waits until the next time matching a cron expression, so that retries line up with
+// the clock rather than with the previous attempt - "0 * * * *" retries at the top of every hour, and "*/15 9-17 * * 1-5"
+// every quarter of an hour during business hours. Times are matched in the local time zone. The next matching time is
+// worked out once each attempt has finished, so an attempt that runs past a matching time is retried at the one after
+// it. WithJitter's default range is added on top of the matching time, but jitter modes (such as FullJitter) aren't
+// applied, since they would scatter retries away from the times the expression matches.
+//
+// Expressions use the usual five fields (minute, hour, day of month, month and day of week, with Sunday as 0 or 7),
+// each of which can be *, a number, a range like 1-5, a step like */10 or 1-30/5, or a comma-separated list of them.
+// As with cron, when both the day of month and day of week are restricted, a day matching either of them matches.
+// Cron panics if the expression isn't valid
+func Cron(expr string) (Strategy, string) {
+	sched, err := parseCron(expr)
+	if err != nil {
+		panic(fmt.Sprintf("invalid cron expression %q: %v", expr, err))
+	}
+
+	return func(r *Retrier) time.Duration {
+		now := r.now()
+		return saturatingAdd(sched.next(now).Sub(now), r.Jitter())
+	}, fmt.Sprintf("%s(%s)", cronStrategy, expr)
+}
+
+// clockAligned returns whether the retrier's strategy waits until a time on the clock, rather than for a length of
+// time, in which case the interval it calculated before an attempt is out of date by the time the attempt is over
+func (r *Retrier) clockAligned() bool {
+	return strategyKind(r.strategyType) == cronStrategy
+}
+
+// realignInterval recalculates the next interval once an attempt is over, for clock-aligned strategies, unless it's
+// been set using SetNextInterval
+func (r *Retrier) realignInterval() {
+	if !r.clockAligned() {
+		return
+	}
+
+	r.mu.Lock()
+	overridden := r.overridden
+	r.mu.Unlock()
+
+	if !overridden {
+		r.setCalculatedInterval(r.calculateNextInterval())
+	}
+}
+
+// cronSchedule holds the values each field of a cron expression matches, as bitsets
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64
+	domStar, dowStar              bool
+}
+
+type cronField struct {
+	name     string
+	min, max int
+}
+
+var cronFields = [5]cronField{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 7},
+}
+
+func parseCron(expr string) (*cronSchedule, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != len(cronFields) {
+		return nil, fmt.Errorf("expected %d fields, got %d", len(cronFields), len(fields))
+	}
+
+	var bits [5]uint64
+	for i, f := range fields {
+		b, err := parseCronField(f, cronFields[i])
+		if err != nil {
+			return nil, err
+		}
+		bits[i] = b
+	}
+
+	// Sunday can be written as either 0 or 7
+	if bits[4]&(1<<7) != 0 {
+		bits[4] |= 1
+	}
+
+	return &cronSchedule{
+		minute:  bits[0],
+		hour:    bits[1],
+		dom:     bits[2],
+		month:   bits[3],
+		dow:     bits[4],
+		domStar: fields[2] == "*",
+		dowStar: fields[4] == "*",
+	}, nil
+}
+
+func parseCronField(s string, f cronField) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(s, ",") {
+		rng, stepStr, hasStep := strings.Cut(part, "/")
+
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepStr)
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid step %q in %s field", stepStr, f.name)
+			}
+			step = n
+		}
+
+		lo, hi := f.min, f.max
+		switch {
+		case rng == "*":
+		case strings.Contains(rng, "-"):
+			loStr, hiStr, _ := strings.Cut(rng, "-")
+			var err error
+			if lo, err = parseCronValue(loStr, f); err != nil {
+				return 0, err
+			}
+			if hi, err = parseCronValue(hiStr, f); err != nil {
+				return 0, err
+			}
+			if lo > hi {
+				return 0, fmt.Errorf("invalid range %q in %s field", rng, f.name)
+			}
+		default:
+			n, err := parseCronValue(rng, f)
+			if err != nil {
+				return 0, err
+			}
+			lo = n
+			if !hasStep {
+				hi = n
+			}
+		}
+
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+func parseCronValue(s string, f cronField) (int, error) {
+	n, err := strconv.Atoi(s)
+	if err != nil || n < f.min || n > f.max {
+		return 0, fmt.Errorf("invalid value %q in %s field, must be between %d and %d", s, f.name, f.min, f.max)
+	}
+	return n, nil
+}
+
+// next returns the first time after t that matches the schedule, which is always on a minute boundary. Schedules that
+// can never match (like "0 0 31 2 *") return a time far enough in the future that the retrier effectively waits forever
+func (s *cronSchedule) next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+
+	// Every schedule that can match at all matches within a few years (leap days are the worst case)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		switch {
+		case s.month&(1<<uint(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !s.matchesDay(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case s.hour&(1<<uint(t.Hour())) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case s.minute&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return limit
+}
+
+func (s *cronSchedule) matchesDay(t time.Time) bool {
+	dom := s.dom&(1<<uint(t.Day())) != 0
+	dow := s.dow&(1<<uint(t.Weekday())) != 0
+
+	if s.domStar || s.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
@@ -0,0 +1,130 @@
+package roko
+
+import (
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestCronSchedule_Next(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2022, 6, 15, 10, 17, 42, 0, time.UTC) // A Wednesday
+
+	testCases := []struct {
+		expr string
+		want time.Time
+	}{
+		{"* * * * *", time.Date(2022, 6, 15, 10, 18, 0, 0, time.UTC)},
+		{"0 * * * *", time.Date(2022, 6, 15, 11, 0, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2022, 6, 15, 10, 30, 0, 0, time.UTC)},
+		{"17 10 * * *", time.Date(2022, 6, 16, 10, 17, 0, 0, time.UTC)},
+		{"0 9-17 * * 1-5", time.Date(2022, 6, 15, 11, 0, 0, 0, time.UTC)},
+		{"0 9 * * 6,0", time.Date(2022, 6, 18, 9, 0, 0, 0, time.UTC)},
+		{"0 9 * * 7", time.Date(2022, 6, 19, 9, 0, 0, 0, time.UTC)},
+		{"30 2 1 * *", time.Date(2022, 7, 1, 2, 30, 0, 0, time.UTC)},
+		{"0 0 1 1 *", time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1 * 1", time.Date(2022, 6, 20, 0, 0, 0, 0, time.UTC)}, // The 1st of the month, or a Monday
+		{"5-20/5 10 * * *", time.Date(2022, 6, 15, 10, 20, 0, 0, time.UTC)},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.expr, func(t *testing.T) {
+			t.Parallel()
+
+			sched, err := parseCron(tc.expr)
+			assert.NilError(t, err)
+			assert.Equal(t, tc.want, sched.next(now))
+		})
+	}
+}
+
+func TestParseCron_RejectsInvalidExpressions(t *testing.T) {
+	t.Parallel()
+
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"10-5 * * * *",
+		"a * * * *",
+	} {
+		_, err := parseCron(expr)
+		assert.Assert(t, err != nil, "expected %q to be invalid", expr)
+	}
+}
+
+func TestCron_WaitsUntilTheNextMatchingTime(t *testing.T) {
+	t.Parallel()
+
+	strategy, name := Cron("* * * * *")
+	assert.Equal(t, "cron(* * * * *)", name)
+
+	interval := strategy(NewRetrier(WithMaxAttempts(1)))
+	assert.Assert(t, interval > 0)
+	assert.Assert(t, interval <= time.Minute)
+}
+
+func TestCron_WithAnInvalidExpression_Panics(t *testing.T) {
+	t.Parallel()
+
+	defer func() {
+		assert.Assert(t, recover() != nil)
+	}()
+
+	Cron("not a cron expression")
+}
+
+func TestCron_WaitsFromTheEndOfTheAttempt(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2024, time.January, 1, 9, 59, 50, 0, time.UTC)
+	clock := NewSimulatedClock(start)
+
+	var starts []time.Time
+	err := NewRetrier(
+		WithMaxAttempts(3),
+		WithStrategy(Cron("0 * * * *")),
+		WithSimulation(1, clock),
+	).Do(func(*Retrier) error {
+		starts = append(starts, clock.Now())
+		clock.Advance(30 * time.Second) // Long enough to run past the top of the hour
+		return errDummy
+	})
+	assert.ErrorIs(t, err, errDummy)
+
+	// The first attempt runs over 10:00, so the retry is at 11:00, and each retry after that starts on the hour
+	assert.DeepEqual(t, []time.Time{
+		start,
+		time.Date(2024, time.January, 1, 11, 0, 0, 0, time.UTC),
+		time.Date(2024, time.January, 1, 12, 0, 0, 0, time.UTC),
+	}, starts)
+}
+
+func TestCron_IgnoresJitterModes(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2024, time.January, 1, 9, 30, 0, 0, time.UTC)
+	clock := NewSimulatedClock(start)
+
+	var starts []time.Time
+	err := NewRetrier(
+		WithMaxAttempts(2),
+		WithStrategy(Cron("0 * * * *")),
+		WithJitter(FullJitter),
+		WithSimulation(1, clock),
+	).Do(func(*Retrier) error {
+		starts = append(starts, clock.Now())
+		return errDummy
+	})
+	assert.ErrorIs(t, err, errDummy)
+	assert.DeepEqual(t, []time.Time{start, time.Date(2024, time.January, 1, 10, 0, 0, 0, time.UTC)}, starts)
+}
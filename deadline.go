@@ -0,0 +1,60 @@
+package roko
+
+import (
+	"context"
+	"time"
+)
+
+// WithSplitDeadline gives each attempt a share of the time remaining before the context's deadline, so that early
+// attempts can't use up the whole budget and leave nothing for the retries. Before each attempt, the context returned
+// by r.Context() gets a deadline of (time remaining / attempts remaining); callbacks should use that context for the
+// work they do.
+//
+// This only has an effect when the context passed to DoWithContext has a deadline, and the retrier has a maximum
+// attempt count - retriers that try forever give each attempt the whole of the remaining time
+func WithSplitDeadline() retrierOpt {
+	return func(r *Retrier) {
+		r.splitDeadline = true
+	}
+}
+
+// startAttemptContext sets the context returned by r.Context() for the duration of a single attempt, recording info
+// about the attempt and applying WithSplitDeadline if it's in use. The returned function must be called once the
+// attempt is over
+func (r *Retrier) startAttemptContext(loopCtx context.Context, info AttemptInfo) context.CancelFunc {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	valueCtx := WithAttemptInfo(loopCtx, info)
+
+	deadline, ok := loopCtx.Deadline()
+	if !r.splitDeadline || !ok || r.forever {
+		r.ctx = valueCtx
+		return func() {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			if r.ctx == valueCtx {
+				r.ctx = loopCtx
+			}
+		}
+	}
+
+	remainingAttempts := r.maxAttempts - r.attemptCount
+	if remainingAttempts < 1 {
+		remainingAttempts = 1
+	}
+
+	share := deadline.Sub(r.now()) / time.Duration(remainingAttempts)
+	attemptCtx, cancel := context.WithTimeout(valueCtx, share)
+	r.ctx = attemptCtx
+
+	return func() {
+		cancel()
+
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		if r.ctx == attemptCtx {
+			r.ctx = loopCtx
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package roko
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+	"gotest.tools/v3/assert/opt"
+)
+
+func TestWithSplitDeadline_SplitsRemainingTimeBetweenAttempts(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Hour)
+	defer cancel()
+
+	shares := []time.Duration{}
+	err := NewRetrier(
+		WithStrategy(Constant(1*time.Second)),
+		WithMaxAttempts(4),
+		WithSleepFunc(dummySleep),
+		WithSplitDeadline(),
+	).DoWithContext(ctx, func(r *Retrier) error {
+		deadline, ok := r.Context().Deadline()
+		assert.Assert(t, ok)
+		shares = append(shares, time.Until(deadline))
+		return errDummy
+	})
+	assert.ErrorIs(t, err, errDummy)
+
+	// The sleeps are fake, so (almost) no time passes between attempts
+	expected := []time.Duration{
+		15 * time.Minute, // 1 hour / 4 attempts
+		20 * time.Minute, // 1 hour / 3 attempts
+		30 * time.Minute, // 1 hour / 2 attempts
+		60 * time.Minute, // 1 hour / 1 attempt
+	}
+	assert.Equal(t, len(expected), len(shares))
+	for i := range expected {
+		assert.Check(t, cmp.DeepEqual(expected[i], shares[i], opt.DurationWithThreshold(1*time.Second)))
+	}
+}
+
+func TestWithSplitDeadline_UsesTheRetriersClock(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Hour)
+	defer cancel()
+
+	// The retrier thinks half of the hour has already gone
+	clock := NewSimulatedClock(time.Now().Add(30 * time.Minute))
+	shares := []time.Duration{}
+	err := NewRetrier(
+		WithStrategy(Constant(1*time.Second)),
+		WithMaxAttempts(2),
+		WithSleepFunc(dummySleep),
+		WithClock(clock),
+		WithSplitDeadline(),
+	).DoWithContext(ctx, func(r *Retrier) error {
+		deadline, ok := r.Context().Deadline()
+		assert.Assert(t, ok)
+		shares = append(shares, time.Until(deadline))
+		return errDummy
+	})
+	assert.ErrorIs(t, err, errDummy)
+
+	expected := []time.Duration{
+		15 * time.Minute, // 30 minutes / 2 attempts
+		30 * time.Minute, // 30 minutes / 1 attempt
+	}
+	assert.Equal(t, len(expected), len(shares))
+	for i := range expected {
+		assert.Check(t, cmp.DeepEqual(expected[i], shares[i], opt.DurationWithThreshold(1*time.Second)))
+	}
+}
+
+func TestWithSplitDeadline_AttemptContextIsCancelledAfterTheAttempt(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Hour)
+	defer cancel()
+
+	attemptCtxs := []context.Context{}
+	err := NewRetrier(
+		WithStrategy(Constant(1*time.Second)),
+		WithMaxAttempts(2),
+		WithSleepFunc(dummySleep),
+		WithSplitDeadline(),
+	).DoWithContext(ctx, func(r *Retrier) error {
+		attemptCtxs = append(attemptCtxs, r.Context())
+		return errDummy
+	})
+	assert.ErrorIs(t, err, errDummy)
+
+	for _, attemptCtx := range attemptCtxs {
+		assert.ErrorIs(t, attemptCtx.Err(), context.Canceled)
+	}
+	assert.NilError(t, ctx.Err())
+}
+
+func TestWithSplitDeadline_WithoutADeadline_DoesNothing(t *testing.T) {
+	t.Parallel()
+
+	err := NewRetrier(
+		WithStrategy(Constant(1*time.Second)),
+		WithMaxAttempts(2),
+		WithSleepFunc(dummySleep),
+		WithSplitDeadline(),
+	).DoWithContext(context.Background(), func(r *Retrier) error {
+		_, ok := r.Context().Deadline()
+		assert.Check(t, !ok)
+		return errDummy
+	})
+	assert.ErrorIs(t, err, errDummy)
+}
@@ -0,0 +1,67 @@
+package roko
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Describe returns a human-readable description of the retrier's policy - its strategy, jitter and limits - such as
+// "exponential(2s, 0s), jitter [0s, 1s), up to 5 attempts". It's intended for logging, so that when a retry loop
+// misbehaves, it's possible to tell what it was configured to do
+func (r *Retrier) Describe() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	parts := []string{}
+
+	switch {
+	case r.strategyType != "":
+		parts = append(parts, r.strategyType)
+	case r.intervalCalculator != nil:
+		parts = append(parts, "custom strategy")
+	default:
+		parts = append(parts, "no strategy")
+	}
+
+	if r.jitter {
+		if r.jitterMode.isSet() {
+			parts = append(parts, fmt.Sprintf("%s jitter", r.jitterMode))
+		} else {
+			parts = append(parts, fmt.Sprintf("jitter [%s, %s)", r.jitterRange.min, r.jitterRange.max))
+		}
+		if r.seeded {
+			parts = append(parts, fmt.Sprintf("seed %d", r.seed))
+		}
+	}
+
+	switch {
+	case r.forever:
+		parts = append(parts, "forever")
+	case r.maxAttempts == 1:
+		parts = append(parts, "1 attempt")
+	default:
+		parts = append(parts, fmt.Sprintf("up to %d attempts", r.maxAttempts))
+	}
+
+	if r.backpressure != nil {
+		parts = append(parts, "with backpressure")
+	}
+
+	if r.minInterval > 0 {
+		parts = append(parts, fmt.Sprintf("at least %s between attempts", r.minInterval))
+	}
+
+	if r.intervalCap > 0 {
+		parts = append(parts, fmt.Sprintf("at most %s between attempts", r.intervalCap))
+	}
+
+	if r.maxTotalSleep > 0 {
+		parts = append(parts, fmt.Sprintf("up to %s total sleep", r.maxTotalSleep))
+	}
+
+	if !r.giveUpAt.IsZero() {
+		parts = append(parts, fmt.Sprintf("until %s", r.giveUpAt.Format("2006-01-02 15:04 MST")))
+	}
+
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,57 @@
+package roko
+
+import (
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestDescribe(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t,
+		"constant(5s), up to 3 attempts",
+		NewRetrier(WithStrategy(Constant(5*time.Second)), WithMaxAttempts(3)).Describe(),
+	)
+
+	assert.Equal(t,
+		"exponential(2s, 500ms), jitter [0s, 1s), forever, up to 10m0s total sleep",
+		NewRetrier(
+			WithStrategy(Exponential(2*time.Second, 500*time.Millisecond)),
+			WithJitter(),
+			TryForever(),
+			WithMaxTotalSleep(10*time.Minute),
+		).Describe(),
+	)
+
+	assert.Equal(t,
+		"exponential-subsecond(100ms), jitter [-1s, 1s), up to 10 attempts",
+		NewRetrier(
+			WithStrategy(ExponentialSubsecond(100*time.Millisecond)),
+			WithJitterRange(-1*time.Second, 1*time.Second),
+			WithMaxAttempts(10),
+		).Describe(),
+	)
+
+	assert.Equal(t,
+		"constant(1s), full jitter, up to 2 attempts",
+		NewRetrier(WithStrategy(Constant(1*time.Second)), WithJitter(FullJitter), WithMaxAttempts(2)).Describe(),
+	)
+
+	assert.Equal(t,
+		"constant(1s), plus-minus(200ms) jitter, up to 2 attempts",
+		NewRetrier(WithStrategy(Constant(1*time.Second)), WithJitter(PlusMinus(200*time.Millisecond)), WithMaxAttempts(2)).Describe(),
+	)
+
+	assert.Equal(t, "no strategy, 1 attempt", NewRetrier(NoRetry()).Describe())
+}
+
+func TestDescribe_WithCustomStrategy_UsesItsName(t *testing.T) {
+	t.Parallel()
+
+	linear := func(r *Retrier) time.Duration { return time.Duration(r.AttemptCount()) * time.Second }
+	r := NewRetrier(WithStrategy(linear, "linear"), WithMaxAttempts(4))
+
+	assert.Equal(t, "linear, up to 4 attempts", r.Describe())
+}
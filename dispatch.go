@@ -0,0 +1,116 @@
+package roko
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+var (
+	// ErrNacked is the error recorded for deliveries that are nacked without an error
+	ErrNacked = errors.New("roko: delivery was nacked")
+
+	// ErrNotAcked is the error recorded for deliveries whose handler returned without acking or nacking them. They're
+	// treated as nacked, so that a forgetful handler can't lose messages
+	ErrNotAcked = errors.New("roko: delivery was neither acked nor nacked")
+)
+
+// Delivery is a single delivery of a message by a Dispatcher. The handler must call either Ack or Nack before it
+// returns; only the first call counts
+type Delivery[T any] struct {
+	Message T
+	Attempt int // 1 for the first delivery of the message, 2 for the second, and so on
+
+	mu      sync.Mutex
+	settled bool
+	err     error
+}
+
+// Ack marks the message as handled, so that it won't be delivered again
+func (d *Delivery[T]) Ack() {
+	d.settle(nil)
+}
+
+// Nack marks the message as not handled, so that it's delivered again later, according to the dispatcher's retrier. If
+// err is Unrecoverable, or the retrier gives up, the message goes to the dispatcher's dead letter function instead
+func (d *Delivery[T]) Nack(err error) {
+	if err == nil {
+		err = ErrNacked
+	}
+	d.settle(err)
+}
+
+func (d *Delivery[T]) settle(err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.settled {
+		return
+	}
+	d.settled, d.err = true, err
+}
+
+// result returns the error the delivery was settled with, settling it as not acked if it hasn't been
+func (d *Delivery[T]) result() error {
+	d.settle(ErrNotAcked)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.err
+}
+
+// Dispatcher delivers messages to a handler at least once, redelivering the ones that the handler nacks after backing
+// off. Each message gets its own retrier, so its maximum attempt count is the maximum number of times a message is
+// delivered before it's given up on and passed to the dead letter function. Messages are delivered using a Queue, so
+// the queue options (like WithWorkers) apply to dispatchers too, apart from WithOnTaskFailed
+type Dispatcher[T any] struct {
+	q      *Queue
+	handle func(ctx context.Context, d *Delivery[T])
+}
+
+// deadLetter carries a message that's been given up on through the queue to the dispatcher's dead letter function
+type deadLetter[T any] struct {
+	msg T
+	err error
+}
+
+func (e *deadLetter[T]) Error() string { return e.err.Error() }
+func (e *deadLetter[T]) Unwrap() error { return e.err }
+
+// NewDispatcher returns a dispatcher that delivers messages to handle, retrying them according to retriers from
+// newRetrier. onDead is called with each message that's given up on and the error from its last delivery
+func NewDispatcher[T any](newRetrier func() *Retrier, handle func(ctx context.Context, d *Delivery[T]), onDead func(msg T, err error), opts ...queueOpt) *Dispatcher[T] {
+	opts = append(opts, WithOnTaskFailed(func(err error) {
+		var dl *deadLetter[T]
+		if errors.As(err, &dl) {
+			onDead(dl.msg, dl.err)
+		}
+	}))
+
+	return &Dispatcher[T]{q: NewQueue(newRetrier, opts...), handle: handle}
+}
+
+// Publish adds a message to the dispatcher, to be delivered as soon as there's a worker free. It returns ErrQueueClosed
+// once the dispatcher has started draining
+func (d *Dispatcher[T]) Publish(msg T) error {
+	return d.q.Submit(func(r *Retrier) error {
+		delivery := &Delivery[T]{Message: msg, Attempt: r.Attempts()}
+		d.handle(r.Context(), delivery)
+
+		err := delivery.result()
+		if err == nil {
+			return nil
+		}
+		if errors.Is(err, ErrUnrecoverable) {
+			return Unrecoverable(&deadLetter[T]{msg: msg, err: err})
+		}
+		return &deadLetter[T]{msg: msg, err: err}
+	})
+}
+
+// Drain stops the dispatcher accepting messages, and waits for the ones already published to be acked or given up on.
+// As with Queue.Drain, if ctx is cancelled first, the dispatcher is stopped, and messages waiting to be redelivered are
+// dropped without being passed to the dead letter function
+func (d *Dispatcher[T]) Drain(ctx context.Context) error {
+	return d.q.Drain(ctx)
+}
@@ -0,0 +1,109 @@
+package roko
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestDispatcher_RedeliversNackedMessages(t *testing.T) {
+	t.Parallel()
+
+	var mu sync.Mutex
+	deliveries := map[string][]int{}
+
+	d := NewDispatcher(newQueueRetrier, func(ctx context.Context, d *Delivery[string]) {
+		mu.Lock()
+		deliveries[d.Message] = append(deliveries[d.Message], d.Attempt)
+		mu.Unlock()
+
+		if d.Message == "flaky" && d.Attempt == 1 {
+			d.Nack(errDummy)
+			return
+		}
+		d.Ack()
+	}, func(msg string, err error) {
+		t.Errorf("%s was dead lettered: %v", msg, err)
+	}, WithWorkers(2))
+
+	assert.NilError(t, d.Publish("steady"))
+	assert.NilError(t, d.Publish("flaky"))
+	assert.NilError(t, d.Drain(context.Background()))
+
+	assert.DeepEqual(t, map[string][]int{"steady": {1}, "flaky": {1, 2}}, deliveries)
+}
+
+func TestDispatcher_AfterTheMaxDeliveries_DeadLettersTheMessage(t *testing.T) {
+	t.Parallel()
+
+	var dead []string
+	var deadErr error
+	attempts := 0
+
+	d := NewDispatcher(newQueueRetrier, func(ctx context.Context, d *Delivery[string]) {
+		attempts = d.Attempt
+		d.Nack(errDummy)
+	}, func(msg string, err error) {
+		dead = append(dead, msg)
+		deadErr = err
+	})
+
+	assert.NilError(t, d.Publish("poison"))
+	assert.NilError(t, d.Drain(context.Background()))
+
+	assert.Equal(t, 3, attempts)
+	assert.DeepEqual(t, []string{"poison"}, dead)
+	assert.ErrorIs(t, deadErr, errDummy)
+}
+
+func TestDispatcher_UnrecoverableNack_DeadLettersStraightAway(t *testing.T) {
+	t.Parallel()
+
+	errMalformed := errors.New("malformed message")
+	var deadErr error
+	attempts := 0
+
+	d := NewDispatcher(newQueueRetrier, func(ctx context.Context, d *Delivery[int]) {
+		attempts++
+		d.Nack(Unrecoverable(errMalformed))
+	}, func(msg int, err error) {
+		deadErr = err
+	})
+
+	assert.NilError(t, d.Publish(42))
+	assert.NilError(t, d.Drain(context.Background()))
+
+	assert.Equal(t, 1, attempts)
+	assert.ErrorIs(t, deadErr, errMalformed)
+}
+
+func TestDispatcher_HandlerThatDoesntSettle_IsTreatedAsNacked(t *testing.T) {
+	t.Parallel()
+
+	var deadErr error
+	d := NewDispatcher(newQueueRetrier, func(ctx context.Context, d *Delivery[int]) {}, func(msg int, err error) {
+		deadErr = err
+	})
+
+	assert.NilError(t, d.Publish(1))
+	assert.NilError(t, d.Drain(context.Background()))
+
+	assert.ErrorIs(t, deadErr, ErrNotAcked)
+}
+
+func TestDelivery_OnlyTheFirstSettlementCounts(t *testing.T) {
+	t.Parallel()
+
+	d := &Delivery[int]{}
+	d.Ack()
+	d.Nack(errDummy)
+	assert.NilError(t, d.result())
+
+	d = &Delivery[int]{}
+	d.Nack(nil)
+	d.Ack()
+	assert.ErrorIs(t, d.result(), ErrNacked)
+}
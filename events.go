@@ -0,0 +1,110 @@
+package roko
+
+import (
+	"context"
+	"time"
+)
+
+// AttemptEvent describes an attempt that a retrier's Do or DoWithContext loop has finished. See WithOnAttempt
+type AttemptEvent struct {
+	Name    string // The name of the retrier, if it has one - see WithName
+	ID      string // The ID of the loop the attempt belongs to, as in AttemptInfo
+	Attempt int    // The number of the attempt within its loop, starting at 1
+
+	Start    time.Time     // When the attempt started
+	Duration time.Duration // How long the callback took
+	Err      error         // The error returned by the callback, or nil if it succeeded
+
+	// Slept is how long the retrier actually waited before this attempt (0 for the first attempt in a loop). Comparing
+	// it with Duration shows whether a slow loop is down to a slow operation, or to the backoff between attempts. It's
+	// measured, so it can be shorter than the previous attempt's NextInterval when a custom sleep function is in use
+	Slept time.Duration
+
+	// Final is whether this was the loop's last attempt - either because it succeeded, or because the retrier gave up
+	Final bool
+
+	// NextInterval is how long the retrier is going to wait before the next attempt. It's 0 when Final is true
+	NextInterval time.Duration
+
+	// TraceID and SpanID identify the trace and span that the loop was run in, for correlating retries with the request
+	// that caused them. They're only set when the retrier has a trace extractor - see WithTraceExtractor
+	TraceID string
+	SpanID  string
+}
+
+// WithOnAttempt adds a function that the retrier calls after each attempt, describing how it went. It's called from the
+// goroutine running the loop, before the retrier waits for the next attempt, so it shouldn't block. This is the hook to
+// use for logging, metrics and the like. It can be passed more than once to add more than one function - they're
+// called in the order they were added
+func WithOnAttempt(f func(AttemptEvent)) retrierOpt {
+	return func(r *Retrier) {
+		r.onAttempt = append(r.onAttempt, f)
+	}
+}
+
+// WithTraceExtractor sets a function that the retrier uses to get the trace and span IDs from the context each loop is
+// run with, to include in its AttemptEvents. This keeps roko independent of any particular tracing library - with
+// OpenTelemetry, for example, it'd be:
+//
+//	roko.WithTraceExtractor(func(ctx context.Context) (string, string) {
+//		sc := trace.SpanContextFromContext(ctx)
+//		if !sc.IsValid() {
+//			return "", ""
+//		}
+//		return sc.TraceID().String(), sc.SpanID().String()
+//	})
+func WithTraceExtractor(f func(ctx context.Context) (traceID, spanID string)) retrierOpt {
+	return func(r *Retrier) {
+		r.traceExtractor = f
+	}
+}
+
+// emit calls the retrier's attempt hooks with e, followed by the default hooks, unless the retrier has opted out of them
+func (r *Retrier) emit(e AttemptEvent) {
+	for _, f := range r.onAttempt {
+		f(e)
+	}
+	if r.noDefaultHooks {
+		return
+	}
+	for _, h := range registeredHooks() {
+		h.f(e)
+	}
+}
+
+// Sampled wraps an attempt hook so that it's only called for some of the attempts in each loop, for loops that can
+// retry often enough to flood a log: the first `first` attempts, then every `every`th attempt after that, and always
+// the final attempt, so that the outcome is never missed. If every is 0, only the first attempts and the final one are
+// passed on. For example, to log the first 3 attempts of each loop, then every 10th:
+//
+//	r := roko.NewRetrier(roko.WithOnAttempt(roko.Sampled(3, 10, logAttempt)), ...)
+func Sampled(first, every int, f func(AttemptEvent)) func(AttemptEvent) {
+	if first < 0 || every < 0 {
+		panic("sampling counts must not be negative")
+	}
+
+	return func(e AttemptEvent) {
+		if e.Final || e.Attempt <= first || (every > 0 && (e.Attempt-first)%every == 0) {
+			f(e)
+		}
+	}
+}
+
+// WithAttemptThreshold adds a function that's called when a loop has made n attempts without succeeding, and is going to
+// keep trying - for raising a warning when something has been retrying for suspiciously long, well before the retrier
+// gives up on it. If repeat is true, it's called again every n attempts after that. It's called with the event for the
+// attempt that crossed the threshold, as with WithOnAttempt
+func WithAttemptThreshold(n int, repeat bool, f func(AttemptEvent)) retrierOpt {
+	if n <= 0 {
+		panic("attempt thresholds must be positive")
+	}
+
+	return WithOnAttempt(func(e AttemptEvent) {
+		if e.Final {
+			return
+		}
+		if e.Attempt == n || (repeat && e.Attempt%n == 0) {
+			f(e)
+		}
+	})
+}
@@ -0,0 +1,221 @@
+package roko
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestWithOnAttempt_DescribesEachAttempt(t *testing.T) {
+	t.Parallel()
+
+	events := []AttemptEvent{}
+	calls := 0
+
+	r := NewRetrier(
+		WithMaxAttempts(5),
+		WithStrategy(Constant(5*time.Second)),
+		WithSleepFunc(dummySleep),
+		WithOnAttempt(func(e AttemptEvent) { events = append(events, e) }),
+	)
+	err := r.Do(func(r *Retrier) error {
+		calls++
+		if calls < 3 {
+			return errDummy
+		}
+		return nil
+	})
+	assert.NilError(t, err)
+
+	assert.Equal(t, 3, len(events))
+	for i, e := range events {
+		assert.Equal(t, i+1, e.Attempt)
+		assert.Equal(t, events[0].ID, e.ID)
+		assert.Assert(t, !e.Start.IsZero())
+	}
+
+	assert.ErrorIs(t, events[0].Err, errDummy)
+	assert.Equal(t, 5*time.Second, events[0].NextInterval)
+	assert.Assert(t, !events[0].Final)
+
+	assert.NilError(t, events[2].Err)
+	assert.Equal(t, time.Duration(0), events[2].NextInterval)
+	assert.Assert(t, events[2].Final)
+}
+
+func TestWithOnAttempt_MarksTheAttemptThatGivesUpAsFinal(t *testing.T) {
+	t.Parallel()
+
+	events := []AttemptEvent{}
+	r := NewRetrier(
+		WithMaxAttempts(2),
+		WithStrategy(Constant(5*time.Second)),
+		WithSleepFunc(dummySleep),
+		WithOnAttempt(func(e AttemptEvent) { events = append(events, e) }),
+	)
+	err := r.Do(func(r *Retrier) error { return errDummy })
+	assert.ErrorIs(t, err, errDummy)
+
+	assert.Equal(t, 2, len(events))
+	assert.Assert(t, !events[0].Final)
+	assert.Assert(t, events[1].Final)
+	assert.ErrorIs(t, events[1].Err, errDummy)
+	assert.Equal(t, time.Duration(0), events[1].NextInterval)
+}
+
+func TestWithOnAttempt_CallsEveryHookInOrder(t *testing.T) {
+	t.Parallel()
+
+	calls := []string{}
+	r := NewRetrier(
+		NoRetry(),
+		WithOnAttempt(func(AttemptEvent) { calls = append(calls, "first") }),
+		WithOnAttempt(func(AttemptEvent) { calls = append(calls, "second") }),
+	)
+	assert.NilError(t, r.Do(func(r *Retrier) error { return nil }))
+
+	assert.DeepEqual(t, []string{"first", "second"}, calls)
+}
+
+func TestSampled(t *testing.T) {
+	t.Parallel()
+
+	logged := []int{}
+	r := NewRetrier(
+		WithMaxAttempts(30),
+		WithStrategy(Constant(time.Second)),
+		WithSleepFunc(dummySleep),
+		WithOnAttempt(Sampled(3, 10, func(e AttemptEvent) { logged = append(logged, e.Attempt) })),
+	)
+	err := r.Do(func(r *Retrier) error {
+		if r.AttemptCount() == 26 {
+			return nil
+		}
+		return errDummy
+	})
+	assert.NilError(t, err)
+
+	assert.DeepEqual(t, []int{1, 2, 3, 13, 23, 27}, logged)
+}
+
+func TestSampled_WithoutEvery_OnlyPassesOnTheFirstAndFinalAttempts(t *testing.T) {
+	t.Parallel()
+
+	logged := []int{}
+	r := NewRetrier(
+		WithMaxAttempts(10),
+		WithStrategy(Constant(time.Second)),
+		WithSleepFunc(dummySleep),
+		WithOnAttempt(Sampled(2, 0, func(e AttemptEvent) { logged = append(logged, e.Attempt) })),
+	)
+	err := r.Do(func(r *Retrier) error { return errDummy })
+	assert.ErrorIs(t, err, errDummy)
+
+	assert.DeepEqual(t, []int{1, 2, 10}, logged)
+}
+
+type traceKey struct{}
+
+func TestWithTraceExtractor_AddsTraceIDsToEvents(t *testing.T) {
+	t.Parallel()
+
+	events := []AttemptEvent{}
+	r := NewRetrier(
+		WithMaxAttempts(2),
+		WithStrategy(Constant(time.Second)),
+		WithSleepFunc(dummySleep),
+		WithOnAttempt(func(e AttemptEvent) { events = append(events, e) }),
+		WithTraceExtractor(func(ctx context.Context) (string, string) {
+			ids, _ := ctx.Value(traceKey{}).([2]string)
+			return ids[0], ids[1]
+		}),
+	)
+
+	ctx := context.WithValue(context.Background(), traceKey{}, [2]string{"4bf92f3577b34da6a3ce929d0e0e4736", "00f067aa0ba902b7"})
+	err := r.DoWithContext(ctx, func(r *Retrier) error { return errDummy })
+	assert.ErrorIs(t, err, errDummy)
+
+	assert.Equal(t, 2, len(events))
+	for _, e := range events {
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", e.TraceID)
+		assert.Equal(t, "00f067aa0ba902b7", e.SpanID)
+	}
+}
+
+func TestWithAttemptThreshold(t *testing.T) {
+	t.Parallel()
+
+	crossed := []int{}
+	r := NewRetrier(
+		WithMaxAttempts(20),
+		WithStrategy(Constant(time.Second)),
+		WithSleepFunc(dummySleep),
+		WithAttemptThreshold(5, false, func(e AttemptEvent) { crossed = append(crossed, e.Attempt) }),
+	)
+	err := r.Do(func(r *Retrier) error { return errDummy })
+	assert.ErrorIs(t, err, errDummy)
+
+	assert.DeepEqual(t, []int{5}, crossed)
+}
+
+func TestWithAttemptThreshold_Repeating(t *testing.T) {
+	t.Parallel()
+
+	crossed := []int{}
+	r := NewRetrier(
+		WithMaxAttempts(20),
+		WithStrategy(Constant(time.Second)),
+		WithSleepFunc(dummySleep),
+		WithAttemptThreshold(5, true, func(e AttemptEvent) { crossed = append(crossed, e.Attempt) }),
+	)
+	err := r.Do(func(r *Retrier) error { return errDummy })
+	assert.ErrorIs(t, err, errDummy)
+
+	// The 20th attempt is the last, so there's nothing to warn about - the retrier is giving up
+	assert.DeepEqual(t, []int{5, 10, 15}, crossed)
+}
+
+func TestWithAttemptThreshold_NotCalledWhenTheLoopSucceedsFirst(t *testing.T) {
+	t.Parallel()
+
+	crossed := 0
+	r := NewRetrier(
+		WithMaxAttempts(20),
+		WithStrategy(Constant(time.Second)),
+		WithSleepFunc(dummySleep),
+		WithAttemptThreshold(5, true, func(AttemptEvent) { crossed++ }),
+	)
+	err := r.Do(func(r *Retrier) error {
+		if r.AttemptCount() < 4 {
+			return errDummy
+		}
+		return nil
+	})
+	assert.NilError(t, err)
+	assert.Equal(t, 0, crossed)
+}
+
+func TestWithOnAttempt_SeparatesCallbackTimeFromSleepTime(t *testing.T) {
+	t.Parallel()
+
+	events := []AttemptEvent{}
+	r := NewRetrier(
+		WithMaxAttempts(2),
+		WithStrategy(Constant(20*time.Millisecond)),
+		WithOnAttempt(func(e AttemptEvent) { events = append(events, e) }),
+	)
+	err := r.Do(func(r *Retrier) error {
+		time.Sleep(5 * time.Millisecond)
+		return errDummy
+	})
+	assert.ErrorIs(t, err, errDummy)
+
+	assert.Equal(t, 2, len(events))
+	assert.Equal(t, time.Duration(0), events[0].Slept)
+	assert.Assert(t, events[1].Slept >= 20*time.Millisecond, events[1].Slept)
+	for _, e := range events {
+		assert.Assert(t, e.Duration >= 5*time.Millisecond, e.Duration)
+	}
+}
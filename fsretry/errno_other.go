@@ -0,0 +1,8 @@
+//go:build !(aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris || windows)
+
+package fsretry
+
+import "syscall"
+
+// transientErrnos are the errors that IsTransient reports as transient. There aren't any on this platform
+var transientErrnos = []syscall.Errno{}
@@ -0,0 +1,13 @@
+//go:build aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+
+package fsretry
+
+import "syscall"
+
+// transientErrnos are the errors that IsTransient reports as transient
+var transientErrnos = []syscall.Errno{
+	syscall.EINTR,  // A signal interrupted the system call
+	syscall.EAGAIN, // The resource is temporarily unavailable, such as a file locked without blocking
+	syscall.EBUSY,  // The device or resource is busy, such as a mount point that's still in use
+	syscall.ESTALE, // An NFS file handle went stale, usually because the file was replaced on the server
+}
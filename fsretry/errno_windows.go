@@ -0,0 +1,19 @@
+//go:build windows
+
+package fsretry
+
+import "syscall"
+
+// Windows error codes that the syscall package doesn't have names for
+const (
+	errorSharingViolation syscall.Errno = 32 // ERROR_SHARING_VIOLATION
+	errorLockViolation    syscall.Errno = 33 // ERROR_LOCK_VIOLATION
+)
+
+// transientErrnos are the errors that IsTransient reports as transient. On Windows, these are mostly caused by another
+// process (often a virus scanner or the search indexer) having the file open without sharing it
+var transientErrnos = []syscall.Errno{
+	errorSharingViolation,
+	errorLockViolation,
+	syscall.ERROR_ACCESS_DENIED, // Also returned when renaming over or deleting a file that another process has open
+}
@@ -0,0 +1,81 @@
+// Package fsretry helps to retry filesystem operations that fail for reasons that usually go away on their own, such as
+// interrupted system calls, stale NFS file handles, and (on Windows) files that are briefly locked by another process,
+// like a virus scanner or search indexer.
+package fsretry
+
+import (
+	"context"
+	"errors"
+	"syscall"
+	"time"
+
+	"github.com/buildkite/roko"
+)
+
+const (
+	initialInterval = 10 * time.Millisecond
+	maxInterval     = 1 * time.Second
+	maxAttempts     = 10
+	maxTotalSleep   = 5 * time.Second
+)
+
+// IsTransient reports whether err is a filesystem error that might not happen if the operation is tried again. Which
+// errors count depends on the platform - see transientErrnos
+func IsTransient(err error) bool {
+	var errno syscall.Errno
+	if !errors.As(err, &errno) {
+		return false
+	}
+
+	for _, e := range transientErrnos {
+		if errno == e {
+			return true
+		}
+	}
+	return false
+}
+
+// Classify turns an error from a filesystem operation into an error suitable for returning from a retrier's callback.
+// Transient errors (see IsTransient) are returned as they are, to be retried, and other errors are wrapped with
+// roko.Unrecoverable - there's no point retrying an operation on a file that doesn't exist, for example
+func Classify(err error) error {
+	if err == nil || IsTransient(err) {
+		return err
+	}
+	return roko.Unrecoverable(err)
+}
+
+// NewRetrier returns a retrier suitable for filesystem operations. The errors it's meant for usually clear up in
+// milliseconds, so it starts by waiting 10ms between attempts, doubling each time up to a maximum of 1s, with jitter, and
+// gives up after 10 attempts or 5s spent waiting, whichever comes first
+func NewRetrier() *roko.Retrier {
+	return roko.NewRetrier(
+		roko.WithMaxAttempts(maxAttempts),
+		roko.WithMaxTotalSleep(maxTotalSleep),
+		roko.WithStrategy(roko.Func(interval)),
+		roko.WithJitter(roko.EqualJitter),
+	)
+}
+
+// interval is the wait before the next attempt, doubling from initialInterval up to maxInterval
+func interval(attempt int) time.Duration {
+	d := initialInterval
+	for i := 0; i < attempt && d < maxInterval; i++ {
+		d *= 2
+	}
+	if d > maxInterval {
+		d = maxInterval
+	}
+	return d
+}
+
+// Do runs op using r, retrying it while it fails with transient filesystem errors. For example:
+//
+//	err := fsretry.Do(ctx, fsretry.NewRetrier(), func() error {
+//		return os.Rename(tmp, path)
+//	})
+func Do(ctx context.Context, r *roko.Retrier, op func() error) error {
+	return r.DoWithContext(ctx, func(*roko.Retrier) error {
+		return Classify(op())
+	})
+}
@@ -0,0 +1,79 @@
+package fsretry
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/buildkite/roko"
+	"gotest.tools/v3/assert"
+)
+
+func newTestRetrier() *roko.Retrier {
+	return roko.NewRetrier(
+		roko.WithMaxAttempts(5),
+		roko.WithStrategy(roko.Func(interval)),
+		roko.WithSleepFunc(func(time.Duration) {}),
+	)
+}
+
+func TestIsTransient(t *testing.T) {
+	t.Parallel()
+
+	for _, errno := range transientErrnos {
+		err := &os.PathError{Op: "rename", Path: "/tmp/artifact", Err: errno}
+		assert.Check(t, IsTransient(err), errno.Error())
+	}
+
+	assert.Check(t, !IsTransient(nil))
+	assert.Check(t, !IsTransient(errors.New("something else")))
+	assert.Check(t, !IsTransient(&os.PathError{Op: "open", Path: "/nope", Err: fs.ErrNotExist}))
+}
+
+func TestInterval(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, 10*time.Millisecond, interval(0))
+	assert.Equal(t, 20*time.Millisecond, interval(1))
+	assert.Equal(t, 640*time.Millisecond, interval(6))
+	assert.Equal(t, time.Second, interval(7))
+	assert.Equal(t, time.Second, interval(100))
+}
+
+func TestDo_GivesUpOnPermanentErrors(t *testing.T) {
+	t.Parallel()
+
+	calls := 0
+	err := Do(context.Background(), newTestRetrier(), func() error {
+		calls++
+		_, err := os.Open("/this/file/does/not/exist")
+		return err
+	})
+
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+	assert.ErrorIs(t, err, roko.ErrUnrecoverable)
+	assert.Equal(t, 1, calls)
+}
+
+func TestDo_RetriesTransientErrors(t *testing.T) {
+	t.Parallel()
+
+	if len(transientErrnos) == 0 {
+		t.Skip("no transient errors on this platform")
+	}
+
+	calls := 0
+	err := Do(context.Background(), newTestRetrier(), func() error {
+		calls++
+		if calls < 3 {
+			return &os.LinkError{Op: "rename", Old: "a", New: "b", Err: transientErrnos[0]}
+		}
+		return nil
+	})
+
+	assert.NilError(t, err)
+	assert.Equal(t, 3, calls)
+}
@@ -0,0 +1,142 @@
+// Package gitretry helps to retry git commands that fail because of problems with the network, the git host, or
+// another git process holding a lock, while giving up straight away on failures that retrying won't fix.
+package gitretry
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/buildkite/roko"
+)
+
+// transientMessages are fragments of git's output that indicate a failure that might succeed if tried again. They're
+// matched case-insensitively
+var transientMessages = []string{
+	// The connection dropped partway through a transfer
+	"early eof",
+	"the remote end hung up unexpectedly",
+	"unexpected disconnect while reading sideband packet",
+	"rpc failed",
+	"connection reset by peer",
+	"connection timed out",
+	"operation timed out",
+	"could not read from remote repository",
+	"gnutls_handshake() failed",
+	"ssl_read",
+	"temporary failure in name resolution",
+
+	// The git host is overloaded or rate limiting us
+	"the requested url returned error: 429",
+	"the requested url returned error: 500",
+	"the requested url returned error: 502",
+	"the requested url returned error: 503",
+	"the requested url returned error: 504",
+
+	// Another git process is working in the same repository
+	"index.lock': file exists",
+	"another git process seems to be running",
+	"cannot lock ref",
+	"unable to create '",
+}
+
+// permanentMessages are fragments of git's output that indicate a failure that won't succeed no matter how many times
+// it's tried, even if the output also contains one of the transient messages. For example, git reports "Could not read
+// from remote repository" both when the connection drops and when the repository doesn't exist
+var permanentMessages = []string{
+	"authentication failed",
+	"permission denied",
+	"repository not found",
+	"does not appear to be a git repository",
+	"couldn't find remote ref",
+	"not a git repository",
+	"the requested url returned error: 401",
+	"the requested url returned error: 403",
+	"the requested url returned error: 404",
+}
+
+// IsTransient reports whether output from a failed git command indicates a failure that might succeed if the command
+// is run again
+func IsTransient(output string) bool {
+	output = strings.ToLower(output)
+
+	for _, msg := range permanentMessages {
+		if strings.Contains(output, msg) {
+			return false
+		}
+	}
+
+	for _, msg := range transientMessages {
+		if strings.Contains(output, msg) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Error is returned when a git command fails
+type Error struct {
+	Err    error  // The error returned when running the command, usually an *exec.ExitError
+	Output string // The output of the command
+}
+
+func (e *Error) Error() string {
+	output := strings.TrimSpace(e.Output)
+	if output == "" {
+		return fmt.Sprintf("git: %v", e.Err)
+	}
+
+	// git's output can be long, but the last line is usually the interesting one
+	if i := strings.LastIndex(output, "\n"); i >= 0 {
+		output = output[i+1:]
+	}
+	return fmt.Sprintf("git: %v: %s", e.Err, output)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Classify turns the output and error from running a git command into an error suitable for returning from a retrier's
+// callback. It returns nil if err is nil, an *Error if the failure is transient, and an *Error wrapped with
+// roko.Unrecoverable otherwise, so that the retrier gives up straight away
+func Classify(output []byte, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	gitErr := &Error{Err: err, Output: string(output)}
+	if !IsTransient(gitErr.Output) && !IsTransient(err.Error()) {
+		return roko.Unrecoverable(gitErr)
+	}
+
+	return gitErr
+}
+
+// NewRetrier returns a retrier suitable for git commands that talk to a remote, such as fetch, clone and push. It makes
+// up to 4 attempts, waiting up to 5s, then 15s, then 25s, with equal jitter so that each wait is at least half that. A
+// remote that hangs up or sends an early EOF is usually shedding load, and won't have recovered a second or two later,
+// while a big clone is too expensive to repeat many times - so there are few attempts, with waits that start long and
+// grow steadily. The jitter keeps many agents fetching from the same host after an outage from retrying at once,
+// without ever retrying straight away
+func NewRetrier() *roko.Retrier {
+	return roko.NewRetrier(
+		roko.WithMaxAttempts(4),
+		roko.WithStrategy(roko.Linear(5*time.Second, 10*time.Second)),
+		roko.WithJitter(roko.EqualJitter),
+	)
+}
+
+// Do runs a git command using r, retrying it while it fails with transient errors. run should run the command with the
+// given context, and return its combined output, for example:
+//
+//	err := gitretry.Do(ctx, gitretry.NewRetrier(), func(ctx context.Context) ([]byte, error) {
+//		return exec.CommandContext(ctx, "git", "fetch", "origin").CombinedOutput()
+//	})
+func Do(ctx context.Context, r *roko.Retrier, run func(context.Context) ([]byte, error)) error {
+	return r.DoWithContext(ctx, func(r *roko.Retrier) error {
+		return Classify(run(r.Context()))
+	})
+}
@@ -0,0 +1,101 @@
+package gitretry
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/buildkite/roko"
+	"gotest.tools/v3/assert"
+)
+
+var errExit = errors.New("exit status 128")
+
+func TestIsTransient(t *testing.T) {
+	t.Parallel()
+
+	transient := []string{
+		"error: RPC failed; curl 18 transfer closed with outstanding read data remaining\nfatal: early EOF",
+		"fatal: the remote end hung up unexpectedly",
+		"error: The requested URL returned error: 429",
+		"fatal: Unable to create '/repo/.git/index.lock': File exists.\n\nAnother git process seems to be running in this repository",
+		"error: cannot lock ref 'refs/remotes/origin/main': is at abc but expected def",
+		"ssh: connect to host github.com port 22: Connection timed out\nfatal: Could not read from remote repository.",
+	}
+	for _, output := range transient {
+		assert.Check(t, IsTransient(output), output)
+	}
+
+	permanent := []string{
+		"",
+		"fatal: Authentication failed for 'https://github.com/buildkite/roko.git/'",
+		"ERROR: Repository not found.\nfatal: Could not read from remote repository.",
+		"fatal: couldn't find remote ref refs/heads/nope",
+		"error: pathspec 'nope' did not match any file(s) known to git",
+	}
+	for _, output := range permanent {
+		assert.Check(t, !IsTransient(output), output)
+	}
+}
+
+func TestClassify(t *testing.T) {
+	t.Parallel()
+
+	assert.NilError(t, Classify([]byte("everything is fine"), nil))
+
+	err := Classify([]byte("remote: Counting objects\nfatal: early EOF\n"), errExit)
+	assert.Error(t, err, "git: exit status 128: fatal: early EOF")
+	assert.ErrorIs(t, err, errExit)
+	assert.Check(t, !errors.Is(err, roko.ErrUnrecoverable))
+
+	err = Classify([]byte("fatal: Authentication failed"), errExit)
+	assert.ErrorIs(t, err, roko.ErrUnrecoverable)
+	var gitErr *Error
+	assert.Assert(t, errors.As(err, &gitErr))
+	assert.Equal(t, "fatal: Authentication failed", gitErr.Output)
+}
+
+func TestDo_RetriesTransientFailures(t *testing.T) {
+	t.Parallel()
+
+	outputs := []string{
+		"fatal: the remote end hung up unexpectedly",
+		"error: The requested URL returned error: 503",
+	}
+	calls := 0
+
+	r := roko.NewRetrier(
+		roko.WithMaxAttempts(5),
+		roko.WithStrategy(roko.Constant(time.Second)),
+		roko.WithSleepFunc(func(time.Duration) {}),
+	)
+	err := Do(context.Background(), r, func(ctx context.Context) ([]byte, error) {
+		calls++
+		if calls <= len(outputs) {
+			return []byte(outputs[calls-1]), errExit
+		}
+		return nil, nil
+	})
+
+	assert.NilError(t, err)
+	assert.Equal(t, 3, calls)
+}
+
+func TestDo_GivesUpOnPermanentFailures(t *testing.T) {
+	t.Parallel()
+
+	calls := 0
+	r := roko.NewRetrier(
+		roko.WithMaxAttempts(5),
+		roko.WithStrategy(roko.Constant(time.Second)),
+		roko.WithSleepFunc(func(time.Duration) {}),
+	)
+	err := Do(context.Background(), r, func(ctx context.Context) ([]byte, error) {
+		calls++
+		return []byte("ERROR: Repository not found."), errExit
+	})
+
+	assert.ErrorIs(t, err, roko.ErrUnrecoverable)
+	assert.Equal(t, 1, calls)
+}
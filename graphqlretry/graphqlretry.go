@@ -0,0 +1,220 @@
+// Package graphqlretry helps to retry GraphQL requests made over HTTP. GraphQL servers usually respond with 200 OK even
+// when a request fails, putting the reasons in the response's "errors", so the HTTP status alone can't say whether a
+// request is worth retrying. This package looks at both: transport errors, 5xx and 429 responses, and errors that say
+// the request was rate limited (such as Shopify's THROTTLED, or GitHub's RATE_LIMITED) are retried, while everything
+// else - most importantly, queries that fail validation - is given up on straight away.
+package graphqlretry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/buildkite/roko"
+)
+
+// ThrottledCodes are the error codes that mean a request was rate limited, and can be retried once the limit has
+// reset. Codes are read from an error's "extensions.code", or its "type" if it has no code, as GitHub uses
+var ThrottledCodes = []string{"THROTTLED", "RATE_LIMITED"}
+
+// Query is the body of a GraphQL request
+type Query struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName,omitempty"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+}
+
+// Error is one of the errors in a GraphQL response
+type Error struct {
+	Message    string                 `json:"message"`
+	Path       []interface{}          `json:"path,omitempty"`
+	Type       string                 `json:"type,omitempty"`
+	Extensions map[string]interface{} `json:"extensions,omitempty"`
+}
+
+func (e Error) Error() string {
+	return e.Message
+}
+
+// Code returns the error's code, from its "extensions.code", or from its "type" if it doesn't have one
+func (e Error) Code() string {
+	if code, ok := e.Extensions["code"].(string); ok {
+		return code
+	}
+	return e.Type
+}
+
+// Throttled reports whether the error's code is one of ThrottledCodes
+func (e Error) Throttled() bool {
+	code := e.Code()
+	for _, c := range ThrottledCodes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// Errors are the errors in a GraphQL response, returned (possibly wrapped) by Classify and Do when there are any
+type Errors []Error
+
+func (es Errors) Error() string {
+	switch len(es) {
+	case 0:
+		return "graphql: no errors"
+	case 1:
+		return "graphql: " + es[0].Message
+	default:
+		return fmt.Sprintf("graphql: %s (and %d more errors)", es[0].Message, len(es)-1)
+	}
+}
+
+// Throttled reports whether any of the errors say the request was rate limited
+func (es Errors) Throttled() bool {
+	for _, e := range es {
+		if e.Throttled() {
+			return true
+		}
+	}
+	return false
+}
+
+// Response is a GraphQL response
+type Response struct {
+	Data       json.RawMessage        `json:"data"`
+	Errors     Errors                 `json:"errors,omitempty"`
+	Extensions map[string]interface{} `json:"extensions,omitempty"`
+}
+
+// StatusError is returned when a GraphQL server responds with a status other than 2xx. Errors holds the GraphQL errors
+// in the response, if it had any
+type StatusError struct {
+	StatusCode int
+	Errors     Errors
+}
+
+func (e *StatusError) Error() string {
+	msg := fmt.Sprintf("graphql server responded with %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	if len(e.Errors) > 0 {
+		msg += ": " + e.Errors.Error()
+	}
+	return msg
+}
+
+func (e *StatusError) Unwrap() error {
+	if len(e.Errors) == 0 {
+		return nil
+	}
+	return e.Errors
+}
+
+// NewRequest returns a POST request for a GraphQL query, with a JSON body. Do calls its newRequest func for every
+// attempt, as the body can only be read once, so this is usually called from there, after which the caller can add any
+// headers it needs, such as for authentication
+func NewRequest(ctx context.Context, url string, q Query) (*http.Request, error) {
+	body, err := json.Marshal(q)
+	if err != nil {
+		return nil, fmt.Errorf("encoding graphql query: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/graphql-response+json, application/json")
+	return req, nil
+}
+
+// Classify reads and closes the body of a response to a GraphQL request, and turns it into a result suitable for
+// returning from a retrier's callback:
+//
+//   - errors making the request, or reading the response, are returned as they are, to be retried
+//   - 5xx and 429 responses, and responses with Throttled errors, give a *StatusError or Errors, to be retried. If the
+//     response has a Retry-After header, Classify calls r.SetNextInterval with it
+//   - other responses that aren't 2xx give a *StatusError, wrapped with roko.Unrecoverable
+//   - responses that can't be decoded give an error wrapped with roko.Unrecoverable
+//   - responses with any other errors (such as GRAPHQL_VALIDATION_FAILED or BAD_USER_INPUT) give Errors, wrapped with
+//     roko.Unrecoverable, since sending the same query again will fail in the same way
+//
+// The decoded response is returned whenever there is one, even alongside an error, since responses with errors can
+// still have partial data
+func Classify(r *roko.Retrier, resp *http.Response, err error) (*Response, error) {
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var gr *Response
+	decodeErr := json.Unmarshal(body, &gr)
+	if decodeErr == nil && gr == nil {
+		decodeErr = errors.New("response is null")
+	}
+	if decodeErr != nil {
+		gr = &Response{}
+	}
+
+	code := resp.StatusCode
+	retryable := code >= 500 || code == http.StatusTooManyRequests || gr.Errors.Throttled()
+	if retryable {
+		if d, ok := roko.ParseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+			r.SetNextInterval(d)
+		}
+	}
+
+	switch {
+	case code < 200 || code > 299:
+		err := &StatusError{StatusCode: code, Errors: gr.Errors}
+		if retryable {
+			return gr, err
+		}
+		return gr, roko.Unrecoverable(err)
+
+	case decodeErr != nil:
+		return nil, roko.Unrecoverable(fmt.Errorf("decoding graphql response: %w", decodeErr))
+
+	case len(gr.Errors) == 0:
+		return gr, nil
+
+	case retryable:
+		return gr, gr.Errors
+
+	default:
+		return gr, roko.Unrecoverable(gr.Errors)
+	}
+}
+
+// Do makes a GraphQL request using r, and returns the response. It calls newRequest to create the request for each
+// attempt (usually with NewRequest), and uses client to make it. See Classify for which failures are retried
+func Do(ctx context.Context, r *roko.Retrier, client *http.Client, newRequest func(context.Context) (*http.Request, error)) (*Response, error) {
+	return roko.DoFunc(ctx, r, func(r *roko.Retrier) (*Response, error) {
+		req, err := newRequest(r.Context())
+		if err != nil {
+			return nil, roko.Unrecoverable(err)
+		}
+
+		resp, err := client.Do(req)
+		return Classify(r, resp, err)
+	})
+}
+
+// NewRetrier returns a retrier suitable for a single GraphQL request. It makes up to 6 attempts, waiting exponentially
+// longer between each, starting at half a second, with jitter, which gives most rate limits (which are usually measured
+// per second, or per minute) time to start letting requests through again
+func NewRetrier() *roko.Retrier {
+	return roko.NewRetrier(
+		roko.WithMaxAttempts(6),
+		roko.WithStrategy(roko.ExponentialSubsecond(500*time.Millisecond)),
+		roko.WithJitter(roko.EqualJitter),
+	)
+}
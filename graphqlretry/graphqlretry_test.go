@@ -0,0 +1,191 @@
+package graphqlretry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/buildkite/roko"
+	"gotest.tools/v3/assert"
+)
+
+func response(code int, header http.Header, body string) *http.Response {
+	if header == nil {
+		header = http.Header{}
+	}
+	return &http.Response{StatusCode: code, Header: header, Body: io.NopCloser(strings.NewReader(body))}
+}
+
+func TestClassify(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name          string
+		resp          *http.Response
+		wantErr       string
+		unrecoverable bool
+	}{
+		{
+			name: "success",
+			resp: response(http.StatusOK, nil, `{"data": {"viewer": {"login": "roko"}}}`),
+		},
+		{
+			name:    "throttled",
+			resp:    response(http.StatusOK, nil, `{"errors": [{"message": "Throttled", "extensions": {"code": "THROTTLED"}}]}`),
+			wantErr: "graphql: Throttled",
+		},
+		{
+			name:    "rate limited",
+			resp:    response(http.StatusOK, nil, `{"errors": [{"type": "RATE_LIMITED", "message": "API rate limit exceeded"}]}`),
+			wantErr: "graphql: API rate limit exceeded",
+		},
+		{
+			name:          "validation failed",
+			resp:          response(http.StatusOK, nil, `{"errors": [{"message": "Cannot query field \"nope\"", "extensions": {"code": "GRAPHQL_VALIDATION_FAILED"}}, {"message": "another"}]}`),
+			wantErr:       `graphql: Cannot query field "nope" (and 1 more errors)`,
+			unrecoverable: true,
+		},
+		{
+			name:    "server error",
+			resp:    response(http.StatusBadGateway, nil, `<html>Bad Gateway</html>`),
+			wantErr: "graphql server responded with 502 Bad Gateway",
+		},
+		{
+			name:    "too many requests",
+			resp:    response(http.StatusTooManyRequests, nil, ``),
+			wantErr: "graphql server responded with 429 Too Many Requests",
+		},
+		{
+			name:          "bad request",
+			resp:          response(http.StatusBadRequest, nil, `{"errors": [{"message": "Syntax Error"}]}`),
+			wantErr:       "graphql server responded with 400 Bad Request: graphql: Syntax Error",
+			unrecoverable: true,
+		},
+		{
+			name:    "rate limited with a non-2xx status",
+			resp:    response(http.StatusForbidden, nil, `{"errors": [{"type": "RATE_LIMITED", "message": "slow down"}]}`),
+			wantErr: "graphql server responded with 403 Forbidden: graphql: slow down",
+		},
+		{
+			name:          "not json",
+			resp:          response(http.StatusOK, nil, `<html>Sign in to the wifi</html>`),
+			wantErr:       "decoding graphql response: invalid character '<' looking for beginning of value",
+			unrecoverable: true,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := Classify(newTestRetrier(), tc.resp, nil)
+			if tc.wantErr == "" {
+				assert.NilError(t, err)
+				return
+			}
+			assert.Error(t, err, tc.wantErr)
+			assert.Equal(t, tc.unrecoverable, errors.Is(err, roko.ErrUnrecoverable))
+		})
+	}
+}
+
+func TestClassify_TransportErrorsAreRetried(t *testing.T) {
+	t.Parallel()
+
+	errConn := errors.New("connection refused")
+	gr, err := Classify(newTestRetrier(), nil, errConn)
+	assert.ErrorIs(t, err, errConn)
+	assert.Check(t, !errors.Is(err, roko.ErrUnrecoverable))
+	assert.Check(t, gr == nil)
+}
+
+func TestClassify_ReturnsPartialData(t *testing.T) {
+	t.Parallel()
+
+	gr, err := Classify(newTestRetrier(), response(http.StatusOK, nil,
+		`{"data": {"user": null}, "errors": [{"message": "Not found", "path": ["user"], "extensions": {"code": "NOT_FOUND"}}]}`), nil)
+
+	var errs Errors
+	assert.Assert(t, errors.As(err, &errs))
+	assert.Equal(t, "NOT_FOUND", errs[0].Code())
+	assert.DeepEqual(t, []interface{}{"user"}, errs[0].Path)
+	assert.Equal(t, `{"user": null}`, string(gr.Data))
+}
+
+func TestClassify_HonoursRetryAfter(t *testing.T) {
+	t.Parallel()
+
+	r := roko.NewRetrier(roko.WithMaxAttempts(2), roko.WithStrategy(roko.Constant(time.Second)))
+	_, err := Classify(r, response(http.StatusOK, http.Header{"Retry-After": {"7"}},
+		`{"errors": [{"message": "Throttled", "extensions": {"code": "THROTTLED"}}]}`), nil)
+
+	assert.ErrorContains(t, err, "Throttled")
+	assert.Equal(t, 7*time.Second, r.NextInterval())
+}
+
+func newTestRetrier() *roko.Retrier {
+	return roko.NewRetrier(
+		roko.WithMaxAttempts(5),
+		roko.WithStrategy(roko.Constant(time.Millisecond)),
+		roko.WithSleepFunc(func(time.Duration) {}),
+	)
+}
+
+func TestDo_RetriesThrottledRequests(t *testing.T) {
+	t.Parallel()
+
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var q Query
+		assert.Check(t, json.NewDecoder(r.Body).Decode(&q))
+		assert.Check(t, q.Query == "{ viewer { login } }")
+		assert.Check(t, r.Header.Get("Authorization") == "Bearer hunter2")
+
+		if atomic.AddInt32(&requests, 1) < 3 {
+			fmt.Fprint(w, `{"errors": [{"message": "Throttled", "extensions": {"code": "THROTTLED"}}]}`)
+			return
+		}
+		fmt.Fprint(w, `{"data": {"viewer": {"login": "roko"}}}`)
+	}))
+	defer srv.Close()
+
+	gr, err := Do(context.Background(), newTestRetrier(), srv.Client(), func(ctx context.Context) (*http.Request, error) {
+		req, err := NewRequest(ctx, srv.URL, Query{Query: "{ viewer { login } }"})
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer hunter2")
+		return req, nil
+	})
+
+	assert.NilError(t, err)
+	assert.Equal(t, `{"viewer": {"login": "roko"}}`, string(gr.Data))
+	assert.Equal(t, int32(3), atomic.LoadInt32(&requests))
+}
+
+func TestDo_GivesUpOnValidationErrors(t *testing.T) {
+	t.Parallel()
+
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		fmt.Fprint(w, `{"errors": [{"message": "Cannot query field \"nope\"", "extensions": {"code": "GRAPHQL_VALIDATION_FAILED"}}]}`)
+	}))
+	defer srv.Close()
+
+	_, err := Do(context.Background(), newTestRetrier(), srv.Client(), func(ctx context.Context) (*http.Request, error) {
+		return NewRequest(ctx, srv.URL, Query{Query: "{ nope }"})
+	})
+
+	assert.ErrorIs(t, err, roko.ErrUnrecoverable)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+}
@@ -0,0 +1,73 @@
+package roko
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// Group runs several retry loops concurrently, using a shared retry policy and context. If any loop in the group fails
+// with an unrecoverable error (one that matches ErrUnrecoverable), the group's context is cancelled, so that the other
+// loops stop retrying rather than carrying on with work that's no longer useful
+type Group struct {
+	opts   []retrierOpt
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu               sync.Mutex
+	firstErr         error
+	unrecoverableErr error
+}
+
+// NewGroup creates a new Group, and a derived context that is cancelled when a loop in the group fails unrecoverably,
+// or when Wait returns. Each loop in the group gets its own Retrier, created by passing opts to NewRetrier.
+// Callbacks should use the returned context for any work they do, so that it's abandoned when the group is cancelled
+func NewGroup(ctx context.Context, opts ...retrierOpt) (*Group, context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	return &Group{opts: opts, ctx: ctx, cancel: cancel}, ctx
+}
+
+// Go starts a new retry loop in its own goroutine, calling callback until it succeeds, the loop's retrier gives up, or
+// the group's context is cancelled
+func (g *Group) Go(callback func(*Retrier) error) {
+	r := NewRetrier(g.opts...)
+
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+
+		err := r.DoWithContext(g.ctx, callback)
+		if err == nil {
+			return
+		}
+
+		g.mu.Lock()
+		defer g.mu.Unlock()
+
+		if errors.Is(err, ErrUnrecoverable) {
+			if g.unrecoverableErr == nil {
+				g.unrecoverableErr = err
+				g.cancel()
+			}
+			return
+		}
+
+		if g.firstErr == nil {
+			g.firstErr = err
+		}
+	}()
+}
+
+// Wait blocks until every loop in the group has finished. If a loop failed unrecoverably, Wait returns that loop's
+// error. Otherwise, it returns the first error returned by a loop that gave up, or nil if every loop succeeded
+func (g *Group) Wait() error {
+	g.wg.Wait()
+	g.cancel()
+
+	if g.unrecoverableErr != nil {
+		return g.unrecoverableErr
+	}
+
+	return g.firstErr
+}
@@ -0,0 +1,76 @@
+package roko
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestGroup_WhenAllLoopsSucceed_ReturnsNil(t *testing.T) {
+	t.Parallel()
+
+	var calls int32
+	g, _ := NewGroup(context.Background(),
+		WithStrategy(Constant(1*time.Second)),
+		WithMaxAttempts(5),
+		WithSleepFunc(dummySleep),
+	)
+
+	for i := 0; i < 5; i++ {
+		g.Go(func(r *Retrier) error {
+			atomic.AddInt32(&calls, 1)
+			if r.AttemptCount() < 2 {
+				return errDummy
+			}
+			return nil
+		})
+	}
+
+	assert.NilError(t, g.Wait())
+	assert.Equal(t, int32(15), atomic.LoadInt32(&calls))
+}
+
+func TestGroup_WhenALoopGivesUp_ReturnsItsError(t *testing.T) {
+	t.Parallel()
+
+	errGaveUp := errors.New("gave up")
+	g, _ := NewGroup(context.Background(),
+		WithStrategy(Constant(1*time.Second)),
+		WithMaxAttempts(3),
+		WithSleepFunc(dummySleep),
+	)
+
+	g.Go(func(*Retrier) error { return nil })
+	g.Go(func(*Retrier) error { return errGaveUp })
+
+	assert.ErrorIs(t, g.Wait(), errGaveUp)
+}
+
+func TestGroup_WhenALoopFailsUnrecoverably_CancelsTheOthers(t *testing.T) {
+	t.Parallel()
+
+	errFatal := errors.New("fatal")
+	g, ctx := NewGroup(context.Background(),
+		WithStrategy(Constant(1*time.Millisecond)),
+		TryForever(),
+	)
+
+	for i := 0; i < 3; i++ {
+		g.Go(func(*Retrier) error { return errDummy }) // These would retry forever if not for the cancellation
+	}
+	g.Go(func(r *Retrier) error {
+		if r.AttemptCount() < 2 {
+			return errDummy
+		}
+		return Unrecoverable(errFatal)
+	})
+
+	err := g.Wait()
+	assert.ErrorIs(t, err, errFatal)
+	assert.ErrorIs(t, err, ErrUnrecoverable)
+	assert.ErrorIs(t, ctx.Err(), context.Canceled)
+}
@@ -0,0 +1,268 @@
+// Package grpcretry helps to retry gRPC calls, deciding whether a failed call is worth retrying from its status code,
+// and waiting as long as the server asked, when it includes a google.rpc.RetryInfo in the status's details.
+//
+// It doesn't depend on grpc-go. Callers pass a StatusFunc that converts their errors, which with grpc-go is:
+//
+//	func statusOf(err error) (grpcretry.Status, bool) {
+//		st, ok := status.FromError(err)
+//		if !ok {
+//			return grpcretry.Status{}, false
+//		}
+//		s := grpcretry.Status{Code: grpcretry.Code(st.Code())}
+//		for _, d := range st.Proto().GetDetails() {
+//			s.Details = append(s.Details, grpcretry.Detail{TypeURL: d.GetTypeUrl(), Value: d.GetValue()})
+//		}
+//		return s, true
+//	}
+package grpcretry
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/buildkite/roko"
+)
+
+// Code is a gRPC status code, with the same values as grpc-go's codes.Code
+type Code uint32
+
+// The gRPC status codes
+const (
+	OK                 Code = 0
+	Canceled           Code = 1
+	Unknown            Code = 2
+	InvalidArgument    Code = 3
+	DeadlineExceeded   Code = 4
+	NotFound           Code = 5
+	AlreadyExists      Code = 6
+	PermissionDenied   Code = 7
+	ResourceExhausted  Code = 8
+	FailedPrecondition Code = 9
+	Aborted            Code = 10
+	OutOfRange         Code = 11
+	Unimplemented      Code = 12
+	Internal           Code = 13
+	Unavailable        Code = 14
+	DataLoss           Code = 15
+	Unauthenticated    Code = 16
+)
+
+var codeNames = []string{
+	"OK", "CANCELLED", "UNKNOWN", "INVALID_ARGUMENT", "DEADLINE_EXCEEDED", "NOT_FOUND", "ALREADY_EXISTS",
+	"PERMISSION_DENIED", "RESOURCE_EXHAUSTED", "FAILED_PRECONDITION", "ABORTED", "OUT_OF_RANGE", "UNIMPLEMENTED",
+	"INTERNAL", "UNAVAILABLE", "DATA_LOSS", "UNAUTHENTICATED",
+}
+
+// String returns the code's name, as it's written in the gRPC spec and in service configs, such as "UNAVAILABLE"
+func (c Code) String() string {
+	if int(c) < len(codeNames) {
+		return codeNames[c]
+	}
+	return fmt.Sprintf("CODE(%d)", uint32(c))
+}
+
+// ParseCode parses a status code's name, as written in service configs. Names are matched case-insensitively, and
+// "CANCELED" is accepted as well as "CANCELLED"
+func ParseCode(name string) (Code, error) {
+	name = strings.ToUpper(name)
+	if name == "CANCELED" {
+		return Canceled, nil
+	}
+	for i, n := range codeNames {
+		if n == name {
+			return Code(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown gRPC status code %q", name)
+}
+
+// DefaultRetryable are the status codes that Classify retries unless it's given others. They're the ones that mean
+// that the server couldn't handle the call at the time, but might be able to later, whatever the call was. Calls that
+// are safe to repeat can also retry DeadlineExceeded, or Internal
+var DefaultRetryable = []Code{Unavailable, ResourceExhausted, Aborted}
+
+// Detail is one of a status's details: a protobuf Any, holding an encoded message and its type
+type Detail struct {
+	TypeURL string
+	Value   []byte
+}
+
+// Status is the status of a failed gRPC call
+type Status struct {
+	Code    Code
+	Details []Detail
+}
+
+// StatusFunc returns the gRPC status of an error returned by a call, and whether it had one
+type StatusFunc func(err error) (Status, bool)
+
+// retryInfoType is the type of google.rpc.RetryInfo details
+const retryInfoType = "google.rpc.RetryInfo"
+
+// RetryDelay returns the retry delay from a google.rpc.RetryInfo in s's details, and whether it had one
+func (s Status) RetryDelay() (time.Duration, bool) {
+	for _, d := range s.Details {
+		if d.TypeURL != retryInfoType && !strings.HasSuffix(d.TypeURL, "/"+retryInfoType) {
+			continue
+		}
+		if delay, err := decodeRetryInfo(d.Value); err == nil {
+			return delay, true
+		}
+	}
+	return 0, false
+}
+
+// Classify turns an error from a gRPC call into one suitable for returning from a retrier's callback. Errors with a
+// status code in retryable (or DefaultRetryable, if none are given) are returned as they are, to be retried, and if
+// their status has a google.rpc.RetryInfo, Classify calls r.SetNextInterval with its delay. Errors with any other
+// status code are wrapped with roko.Unrecoverable. Errors without a status, which come from the client rather than the
+// server, are returned as they are
+func Classify(r *roko.Retrier, err error, statusOf StatusFunc, retryable ...Code) error {
+	if err == nil {
+		return nil
+	}
+
+	st, ok := statusOf(err)
+	if !ok {
+		return err
+	}
+
+	if len(retryable) == 0 {
+		retryable = DefaultRetryable
+	}
+	if !isRetryable(st.Code, retryable) {
+		return roko.Unrecoverable(err)
+	}
+
+	if delay, ok := st.RetryDelay(); ok {
+		r.SetNextInterval(delay)
+	}
+	return err
+}
+
+func isRetryable(code Code, retryable []Code) bool {
+	for _, c := range retryable {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// Do makes a gRPC call with call, retrying it with r while it fails with a retryable status code (see Classify)
+func Do(ctx context.Context, r *roko.Retrier, statusOf StatusFunc, call func(ctx context.Context) error, retryable ...Code) error {
+	return r.DoWithContext(ctx, func(r *roko.Retrier) error {
+		return Classify(r, call(r.Context()), statusOf, retryable...)
+	})
+}
+
+var errMalformed = errors.New("malformed protobuf message")
+
+// decodeRetryInfo decodes the retry_delay field of an encoded google.rpc.RetryInfo message, which is field 1, holding a
+// google.protobuf.Duration (seconds in field 1, and nanoseconds in field 2)
+func decodeRetryInfo(b []byte) (time.Duration, error) {
+	var delay time.Duration
+	found := false
+
+	err := decodeFields(b, func(field uint64, value []byte, varint uint64) error {
+		if field != 1 || value == nil {
+			return nil
+		}
+
+		var seconds, nanos int64
+		err := decodeFields(value, func(field uint64, _ []byte, varint uint64) error {
+			switch field {
+			case 1:
+				seconds = int64(varint)
+			case 2:
+				nanos = int64(int32(varint))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		if seconds > int64(math.MaxInt64/time.Second)-1 {
+			delay = time.Duration(math.MaxInt64)
+		} else {
+			delay = time.Duration(seconds)*time.Second + time.Duration(nanos)
+		}
+		found = true
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	if !found || delay < 0 {
+		return 0, errMalformed
+	}
+	return delay, nil
+}
+
+// decodeFields calls f with each field of an encoded protobuf message. Length-delimited fields are passed as value
+// (which is never nil for them), and varint fields as varint. Fixed-width fields are skipped
+func decodeFields(b []byte, f func(field uint64, value []byte, varint uint64) error) error {
+	for len(b) > 0 {
+		key, n := binary.Uvarint(b)
+		if n <= 0 {
+			return errMalformed
+		}
+		b = b[n:]
+
+		field := key >> 3
+		switch key & 7 {
+		case 0: // varint
+			v, n := binary.Uvarint(b)
+			if n <= 0 {
+				return errMalformed
+			}
+			b = b[n:]
+			if err := f(field, nil, v); err != nil {
+				return err
+			}
+
+		case 1: // 64-bit
+			if len(b) < 8 {
+				return errMalformed
+			}
+			b = b[8:]
+
+		case 2: // length-delimited
+			l, n := binary.Uvarint(b)
+			if n <= 0 || l > uint64(len(b)-n) {
+				return errMalformed
+			}
+			value := b[n : n+int(l)]
+			b = b[n+int(l):]
+			if err := f(field, append([]byte{}, value...), 0); err != nil {
+				return err
+			}
+
+		case 5: // 32-bit
+			if len(b) < 4 {
+				return errMalformed
+			}
+			b = b[4:]
+
+		default:
+			return errMalformed
+		}
+	}
+	return nil
+}
+
+// NewRetrier returns a retrier suitable for a single gRPC call. It makes up to 5 attempts, waiting exponentially
+// longer between each, with jitter, as the gRPC retry design recommends
+func NewRetrier() *roko.Retrier {
+	return roko.NewRetrier(
+		roko.WithMaxAttempts(5),
+		roko.WithStrategy(roko.ExponentialSubsecond(200*time.Millisecond)),
+		roko.WithJitter(roko.FullJitter),
+	)
+}
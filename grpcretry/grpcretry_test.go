@@ -0,0 +1,139 @@
+package grpcretry
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/buildkite/roko"
+	"gotest.tools/v3/assert"
+)
+
+// statusError stands in for grpc-go's status errors
+type statusError struct{ st Status }
+
+func (e *statusError) Error() string { return "rpc error: " + e.st.Code.String() }
+
+func statusOf(err error) (Status, bool) {
+	var serr *statusError
+	if errors.As(err, &serr) {
+		return serr.st, true
+	}
+	return Status{}, false
+}
+
+func uvarint(v uint64) []byte {
+	b := make([]byte, binary.MaxVarintLen64)
+	return b[:binary.PutUvarint(b, v)]
+}
+
+func field(number uint64, wireType uint64, value []byte) []byte {
+	b := uvarint(number<<3 | wireType)
+	if wireType == 2 {
+		b = append(b, uvarint(uint64(len(value)))...)
+	}
+	return append(b, value...)
+}
+
+// retryInfo encodes a google.rpc.RetryInfo with the given delay
+func retryInfo(d time.Duration) Detail {
+	duration := append(field(1, 0, uvarint(uint64(d/time.Second))), field(2, 0, uvarint(uint64(d%time.Second)))...)
+	return Detail{TypeURL: "type.googleapis.com/google.rpc.RetryInfo", Value: field(1, 2, duration)}
+}
+
+func newTestRetrier() *roko.Retrier {
+	return roko.NewRetrier(
+		roko.WithMaxAttempts(3),
+		roko.WithStrategy(roko.Constant(time.Second)),
+		roko.WithSleepFunc(func(time.Duration) {}),
+	)
+}
+
+func TestCode_StringAndParse(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, Unavailable.String(), "UNAVAILABLE")
+	assert.Equal(t, Code(42).String(), "CODE(42)")
+
+	for c := OK; c <= Unauthenticated; c++ {
+		parsed, err := ParseCode(c.String())
+		assert.NilError(t, err)
+		assert.Equal(t, parsed, c)
+	}
+
+	parsed, err := ParseCode("canceled")
+	assert.NilError(t, err)
+	assert.Equal(t, parsed, Canceled)
+
+	_, err = ParseCode("BROKEN")
+	assert.Error(t, err, `unknown gRPC status code "BROKEN"`)
+}
+
+func TestStatus_RetryDelay(t *testing.T) {
+	t.Parallel()
+
+	st := Status{Code: ResourceExhausted, Details: []Detail{
+		{TypeURL: "type.googleapis.com/google.rpc.ErrorInfo", Value: field(1, 2, []byte("RATE_LIMITED"))},
+		retryInfo(3*time.Second + 500*time.Millisecond),
+	}}
+	delay, ok := st.RetryDelay()
+	assert.Assert(t, ok)
+	assert.Equal(t, delay, 3*time.Second+500*time.Millisecond)
+
+	_, ok = Status{Code: Unavailable}.RetryDelay()
+	assert.Assert(t, !ok)
+
+	_, ok = Status{Details: []Detail{{TypeURL: "type.googleapis.com/google.rpc.RetryInfo", Value: []byte{0x0a, 0x05}}}}.RetryDelay()
+	assert.Assert(t, !ok, "truncated message should be ignored")
+}
+
+func TestClassify(t *testing.T) {
+	t.Parallel()
+
+	r := newTestRetrier()
+	assert.NilError(t, Classify(r, nil, statusOf))
+
+	errLocal := errors.New("connection refused")
+	assert.Equal(t, Classify(r, errLocal, statusOf), errLocal)
+
+	unavailable := &statusError{Status{Code: Unavailable}}
+	assert.Equal(t, Classify(r, unavailable, statusOf), error(unavailable))
+
+	notFound := &statusError{Status{Code: NotFound}}
+	err := Classify(r, notFound, statusOf)
+	assert.ErrorIs(t, err, roko.ErrUnrecoverable)
+	assert.ErrorIs(t, err, notFound)
+
+	timeout := &statusError{Status{Code: DeadlineExceeded}}
+	assert.ErrorIs(t, Classify(r, timeout, statusOf), roko.ErrUnrecoverable)
+	assert.Equal(t, Classify(r, timeout, statusOf, DeadlineExceeded, Unavailable), error(timeout))
+}
+
+func TestDo_WaitsForTheServersRetryDelay(t *testing.T) {
+	t.Parallel()
+
+	var slept []time.Duration
+	r := roko.NewRetrier(
+		roko.WithMaxAttempts(3),
+		roko.WithStrategy(roko.Constant(time.Second)),
+		roko.WithSleepFunc(func(d time.Duration) { slept = append(slept, d) }),
+	)
+
+	calls := 0
+	err := Do(context.Background(), r, statusOf, func(ctx context.Context) error {
+		calls++
+		switch calls {
+		case 1:
+			return &statusError{Status{Code: ResourceExhausted, Details: []Detail{retryInfo(7 * time.Second)}}}
+		case 2:
+			return &statusError{Status{Code: Unavailable}}
+		default:
+			return nil
+		}
+	})
+
+	assert.NilError(t, err)
+	assert.DeepEqual(t, slept, []time.Duration{7 * time.Second, time.Second})
+}
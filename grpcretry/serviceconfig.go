@@ -0,0 +1,265 @@
+package grpcretry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/buildkite/roko"
+)
+
+// maxServiceConfigAttempts is the most attempts a service config retry policy can ask for. Larger values are treated as
+// this one, as the gRPC clients do
+const maxServiceConfigAttempts = 5
+
+// Policy is the retry policy for a method, from a gRPC service config
+type Policy struct {
+	MaxAttempts       int           // The number of attempts to make, including the first, which is between 2 and 5
+	InitialBackoff    time.Duration // The longest wait before the first retry
+	MaxBackoff        time.Duration // The longest wait before any retry
+	BackoffMultiplier float64       // How much the longest wait grows by after each retry
+	RetryableCodes    []Code        // The status codes to retry
+}
+
+// NewRetrier returns a retrier that follows the policy. As in the gRPC clients, the wait before each retry is a random
+// interval between zero and InitialBackoff * BackoffMultiplier^(retries so far), up to MaxBackoff
+func (p *Policy) NewRetrier() *roko.Retrier {
+	return roko.NewRetrier(
+		roko.WithMaxAttempts(p.MaxAttempts),
+		roko.WithStrategy(roko.Func(p.backoff)),
+		roko.WithJitter(roko.FullJitter),
+	)
+}
+
+// backoff returns the longest wait before a retry, once attempt attempts have been made
+func (p *Policy) backoff(attempt int) time.Duration {
+	backoff := float64(p.InitialBackoff) * math.Pow(p.BackoffMultiplier, float64(attempt))
+	if backoff >= float64(p.MaxBackoff) {
+		return p.MaxBackoff
+	}
+	return time.Duration(backoff)
+}
+
+// Throttle limits retries to a server while it's failing to handle many calls, as described by a service config's
+// retryThrottling. It holds up to maxTokens tokens, and starts full. Every failed attempt with a retryable status code
+// takes a token away, and every successful attempt adds tokenRatio tokens back. While it has half of maxTokens or fewer,
+// no retries are made. A Throttle is shared by every call to a server, and is safe to use concurrently
+type Throttle struct {
+	mu         sync.Mutex
+	tokens     float64
+	maxTokens  float64
+	tokenRatio float64
+}
+
+// NewThrottle returns a throttle that holds up to maxTokens tokens, and gets tokenRatio tokens back for each successful
+// attempt
+func NewThrottle(maxTokens, tokenRatio float64) *Throttle {
+	if maxTokens <= 0 || tokenRatio <= 0 {
+		panic("throttles must have positive max tokens and token ratio")
+	}
+
+	return &Throttle{tokens: maxTokens, maxTokens: maxTokens, tokenRatio: tokenRatio}
+}
+
+// Allow reports whether the throttle currently allows retries
+func (t *Throttle) Allow() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.tokens > t.maxTokens/2
+}
+
+// Succeeded records a successful attempt
+func (t *Throttle) Succeeded() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.tokens = math.Min(t.tokens+t.tokenRatio, t.maxTokens)
+}
+
+// Failed records an attempt that failed with a retryable status code
+func (t *Throttle) Failed() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.tokens = math.Max(t.tokens-1, 0)
+}
+
+type methodName struct {
+	service, method string
+}
+
+// ServiceConfig holds the retry policies from a gRPC service config - see ParseServiceConfig
+type ServiceConfig struct {
+	policies map[methodName]*Policy
+	throttle *Throttle
+}
+
+// ParseServiceConfig parses the retry policies and retry throttling from a gRPC service config, in its JSON form:
+//
+//	{
+//	  "methodConfig": [{
+//	    "name": [{"service": "example.Users"}],
+//	    "retryPolicy": {
+//	      "maxAttempts": 4,
+//	      "initialBackoff": "0.1s",
+//	      "maxBackoff": "1s",
+//	      "backoffMultiplier": 2,
+//	      "retryableStatusCodes": ["UNAVAILABLE"]
+//	    }
+//	  }],
+//	  "retryThrottling": {"maxTokens": 10, "tokenRatio": 0.1}
+//	}
+//
+// Everything else in the config (timeouts, hedging, load balancing and so on) is ignored. Policies are validated the
+// way the gRPC clients validate them, and a maxAttempts over 5 is treated as 5
+func ParseServiceConfig(data []byte) (*ServiceConfig, error) {
+	var raw struct {
+		MethodConfig []struct {
+			Name []struct {
+				Service string `json:"service"`
+				Method  string `json:"method"`
+			} `json:"name"`
+			RetryPolicy *struct {
+				MaxAttempts          int      `json:"maxAttempts"`
+				InitialBackoff       string   `json:"initialBackoff"`
+				MaxBackoff           string   `json:"maxBackoff"`
+				BackoffMultiplier    float64  `json:"backoffMultiplier"`
+				RetryableStatusCodes []string `json:"retryableStatusCodes"`
+			} `json:"retryPolicy"`
+		} `json:"methodConfig"`
+		RetryThrottling *struct {
+			MaxTokens  float64 `json:"maxTokens"`
+			TokenRatio float64 `json:"tokenRatio"`
+		} `json:"retryThrottling"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("parsing service config: %w", err)
+	}
+
+	c := &ServiceConfig{policies: map[methodName]*Policy{}}
+	for i, mc := range raw.MethodConfig {
+		rp := mc.RetryPolicy
+		if rp == nil {
+			continue
+		}
+
+		p := &Policy{MaxAttempts: rp.MaxAttempts, BackoffMultiplier: rp.BackoffMultiplier}
+		var err error
+		if p.InitialBackoff, err = parseDuration(rp.InitialBackoff); err != nil {
+			return nil, fmt.Errorf("methodConfig[%d]: initialBackoff: %w", i, err)
+		}
+		if p.MaxBackoff, err = parseDuration(rp.MaxBackoff); err != nil {
+			return nil, fmt.Errorf("methodConfig[%d]: maxBackoff: %w", i, err)
+		}
+		for _, name := range rp.RetryableStatusCodes {
+			code, err := ParseCode(name)
+			if err != nil {
+				return nil, fmt.Errorf("methodConfig[%d]: retryableStatusCodes: %w", i, err)
+			}
+			p.RetryableCodes = append(p.RetryableCodes, code)
+		}
+		if err := p.validate(); err != nil {
+			return nil, fmt.Errorf("methodConfig[%d]: %w", i, err)
+		}
+
+		for _, name := range mc.Name {
+			if name.Service == "" && name.Method != "" {
+				return nil, fmt.Errorf("methodConfig[%d]: method %q has no service", i, name.Method)
+			}
+			n := methodName{service: name.Service, method: name.Method}
+			if _, ok := c.policies[n]; ok {
+				return nil, fmt.Errorf("methodConfig[%d]: more than one policy for %s/%s", i, n.service, n.method)
+			}
+			c.policies[n] = p
+		}
+	}
+
+	if rt := raw.RetryThrottling; rt != nil {
+		if rt.MaxTokens <= 0 || rt.MaxTokens > 1000 || rt.TokenRatio <= 0 {
+			return nil, errors.New("retryThrottling: maxTokens must be in (0, 1000], and tokenRatio must be positive")
+		}
+		c.throttle = NewThrottle(rt.MaxTokens, rt.TokenRatio)
+	}
+
+	return c, nil
+}
+
+// parseDuration parses a duration in the JSON form of a google.protobuf.Duration, such as "0.1s"
+func parseDuration(s string) (time.Duration, error) {
+	seconds := strings.TrimSuffix(s, "s")
+	if seconds == s || seconds == "" || strings.Trim(seconds, "-.0123456789") != "" {
+		return 0, fmt.Errorf("%q isn't a duration in seconds, like \"0.1s\"", s)
+	}
+	return time.ParseDuration(s)
+}
+
+// validate checks that the policy is one the gRPC clients would accept, and caps its MaxAttempts as they do
+func (p *Policy) validate() error {
+	switch {
+	case p.MaxAttempts < 2:
+		return errors.New("maxAttempts must be at least 2")
+	case p.InitialBackoff <= 0:
+		return errors.New("initialBackoff must be positive")
+	case p.MaxBackoff <= 0:
+		return errors.New("maxBackoff must be positive")
+	case p.BackoffMultiplier <= 0:
+		return errors.New("backoffMultiplier must be positive")
+	case len(p.RetryableCodes) == 0:
+		return errors.New("retryableStatusCodes must not be empty")
+	}
+
+	if p.MaxAttempts > maxServiceConfigAttempts {
+		p.MaxAttempts = maxServiceConfigAttempts
+	}
+	return nil
+}
+
+// Policy returns the retry policy for a method, given its full name in the form "/package.Service/Method" (as passed
+// to gRPC interceptors), and whether there is one. As in the gRPC clients, a policy for the method itself is used in
+// preference to one for its whole service, which is used in preference to the default policy (with an empty name)
+func (c *ServiceConfig) Policy(fullMethod string) (*Policy, bool) {
+	service, method, _ := strings.Cut(strings.TrimPrefix(fullMethod, "/"), "/")
+
+	for _, name := range []methodName{{service, method}, {service, ""}, {"", ""}} {
+		if p, ok := c.policies[name]; ok {
+			return p, true
+		}
+	}
+	return nil, false
+}
+
+// Throttle returns the config's retry throttle, or nil if it doesn't have retryThrottling
+func (c *ServiceConfig) Throttle() *Throttle {
+	return c.throttle
+}
+
+// Do makes a call to the method named fullMethod with call, retrying it according to the method's policy (see Policy)
+// and the config's retry throttling. If the method doesn't have a policy, call is called once
+func (c *ServiceConfig) Do(ctx context.Context, fullMethod string, statusOf StatusFunc, call func(ctx context.Context) error) error {
+	p, ok := c.Policy(fullMethod)
+	if !ok {
+		return call(ctx)
+	}
+
+	return Do(ctx, p.NewRetrier(), statusOf, func(ctx context.Context) error {
+		err := call(ctx)
+		if c.throttle == nil {
+			return err
+		}
+
+		if err == nil {
+			c.throttle.Succeeded()
+			return nil
+		}
+		if st, ok := statusOf(err); ok && isRetryable(st.Code, p.RetryableCodes) {
+			c.throttle.Failed()
+			if !c.throttle.Allow() {
+				return roko.Unrecoverable(err)
+			}
+		}
+		return err
+	}, p.RetryableCodes...)
+}
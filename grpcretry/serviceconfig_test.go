@@ -0,0 +1,181 @@
+package grpcretry
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+const testServiceConfig = `{
+  "loadBalancingConfig": [{"round_robin": {}}],
+  "methodConfig": [
+    {
+      "name": [{"service": "example.Users"}],
+      "timeout": "5s",
+      "retryPolicy": {
+        "maxAttempts": 4,
+        "initialBackoff": "0.1s",
+        "maxBackoff": "1s",
+        "backoffMultiplier": 2,
+        "retryableStatusCodes": ["UNAVAILABLE", "resource_exhausted"]
+      }
+    },
+    {
+      "name": [{"service": "example.Users", "method": "Delete"}],
+      "retryPolicy": {
+        "maxAttempts": 9,
+        "initialBackoff": "1s",
+        "maxBackoff": "10s",
+        "backoffMultiplier": 1.5,
+        "retryableStatusCodes": ["UNAVAILABLE"]
+      }
+    },
+    {
+      "name": [{"service": "example.Health"}],
+      "timeout": "1s"
+    }
+  ],
+  "retryThrottling": {"maxTokens": 4, "tokenRatio": 0.5}
+}`
+
+func TestParseServiceConfig(t *testing.T) {
+	t.Parallel()
+
+	c, err := ParseServiceConfig([]byte(testServiceConfig))
+	assert.NilError(t, err)
+
+	p, ok := c.Policy("/example.Users/Get")
+	assert.Assert(t, ok)
+	assert.DeepEqual(t, p, &Policy{
+		MaxAttempts:       4,
+		InitialBackoff:    100 * time.Millisecond,
+		MaxBackoff:        time.Second,
+		BackoffMultiplier: 2,
+		RetryableCodes:    []Code{Unavailable, ResourceExhausted},
+	})
+
+	p, ok = c.Policy("/example.Users/Delete")
+	assert.Assert(t, ok)
+	assert.Equal(t, p.MaxAttempts, 5, "maxAttempts is capped")
+	assert.Equal(t, p.InitialBackoff, time.Second)
+
+	_, ok = c.Policy("/example.Health/Check")
+	assert.Assert(t, !ok)
+
+	assert.Assert(t, c.Throttle() != nil)
+}
+
+func TestParseServiceConfig_DefaultPolicy(t *testing.T) {
+	t.Parallel()
+
+	c, err := ParseServiceConfig([]byte(`{"methodConfig": [{"name": [{}], "retryPolicy": {
+		"maxAttempts": 3, "initialBackoff": "0.5s", "maxBackoff": "2s", "backoffMultiplier": 3,
+		"retryableStatusCodes": ["UNAVAILABLE"]}}]}`))
+	assert.NilError(t, err)
+
+	p, ok := c.Policy("/anything.At/All")
+	assert.Assert(t, ok)
+	assert.Equal(t, p.MaxAttempts, 3)
+	assert.Assert(t, c.Throttle() == nil)
+}
+
+func TestParseServiceConfig_RejectsInvalidPolicies(t *testing.T) {
+	t.Parallel()
+
+	valid := `"maxAttempts": 3, "initialBackoff": "0.5s", "maxBackoff": "2s", "backoffMultiplier": 2`
+	cases := map[string]string{
+		"not json":         `{`,
+		"one attempt":      `{"methodConfig": [{"name": [{}], "retryPolicy": {"maxAttempts": 1, "initialBackoff": "0.5s", "maxBackoff": "2s", "backoffMultiplier": 2, "retryableStatusCodes": ["UNAVAILABLE"]}}]}`,
+		"no codes":         `{"methodConfig": [{"name": [{}], "retryPolicy": {` + valid + `}}]}`,
+		"unknown code":     `{"methodConfig": [{"name": [{}], "retryPolicy": {` + valid + `, "retryableStatusCodes": ["FLAKY"]}}]}`,
+		"bad duration":     `{"methodConfig": [{"name": [{}], "retryPolicy": {"maxAttempts": 3, "initialBackoff": "500ms", "maxBackoff": "2s", "backoffMultiplier": 2, "retryableStatusCodes": ["UNAVAILABLE"]}}]}`,
+		"method only":      `{"methodConfig": [{"name": [{"method": "Get"}], "retryPolicy": {` + valid + `, "retryableStatusCodes": ["UNAVAILABLE"]}}]}`,
+		"duplicate":        `{"methodConfig": [{"name": [{}, {}], "retryPolicy": {` + valid + `, "retryableStatusCodes": ["UNAVAILABLE"]}}]}`,
+		"bad throttling":   `{"retryThrottling": {"maxTokens": 0, "tokenRatio": 0.1}}`,
+		"too many tokens":  `{"retryThrottling": {"maxTokens": 1001, "tokenRatio": 0.1}}`,
+		"zero multiplier":  `{"methodConfig": [{"name": [{}], "retryPolicy": {"maxAttempts": 3, "initialBackoff": "0.5s", "maxBackoff": "2s", "backoffMultiplier": 0, "retryableStatusCodes": ["UNAVAILABLE"]}}]}`,
+		"negative backoff": `{"methodConfig": [{"name": [{}], "retryPolicy": {"maxAttempts": 3, "initialBackoff": "-1s", "maxBackoff": "2s", "backoffMultiplier": 2, "retryableStatusCodes": ["UNAVAILABLE"]}}]}`,
+	}
+
+	for name, config := range cases {
+		config := config
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := ParseServiceConfig([]byte(config))
+			assert.Assert(t, err != nil)
+		})
+	}
+}
+
+func TestPolicy_NewRetrier_BacksOffUpToTheMax(t *testing.T) {
+	t.Parallel()
+
+	p := &Policy{MaxAttempts: 5, InitialBackoff: 100 * time.Millisecond, MaxBackoff: 300 * time.Millisecond, BackoffMultiplier: 2, RetryableCodes: []Code{Unavailable}}
+	assert.DeepEqual(t, p.NewRetrier().PlannedIntervals(10), []time.Duration{
+		100 * time.Millisecond, 200 * time.Millisecond, 300 * time.Millisecond, 300 * time.Millisecond,
+	})
+}
+
+func TestThrottle(t *testing.T) {
+	t.Parallel()
+
+	th := NewThrottle(4, 0.5)
+	assert.Assert(t, th.Allow())
+
+	th.Failed()
+	assert.Assert(t, th.Allow()) // 3 tokens
+	th.Failed()
+	assert.Assert(t, !th.Allow()) // 2 tokens
+
+	th.Succeeded()
+	assert.Assert(t, th.Allow()) // 2.5 tokens
+
+	for i := 0; i < 10; i++ {
+		th.Succeeded()
+	}
+	for i := 0; i < 2; i++ {
+		th.Failed()
+	}
+	assert.Assert(t, !th.Allow(), "tokens should be capped at the max")
+}
+
+func TestServiceConfig_Do(t *testing.T) {
+	t.Parallel()
+
+	c, err := ParseServiceConfig([]byte(`{"methodConfig": [{"name": [{"service": "example.Users"}], "retryPolicy": {
+		"maxAttempts": 5, "initialBackoff": "0.001s", "maxBackoff": "0.001s", "backoffMultiplier": 1,
+		"retryableStatusCodes": ["UNAVAILABLE"]}}], "retryThrottling": {"maxTokens": 6, "tokenRatio": 1}}`))
+	assert.NilError(t, err)
+
+	unavailable := &statusError{Status{Code: Unavailable}}
+	calls := 0
+	err = c.Do(context.Background(), "/example.Users/Get", statusOf, func(ctx context.Context) error {
+		calls++
+		if calls < 3 {
+			return unavailable
+		}
+		return nil
+	})
+	assert.NilError(t, err)
+	assert.Equal(t, calls, 3)
+
+	// The throttle has 5 tokens now, so it allows two more failures before it stops retries
+	calls = 0
+	err = c.Do(context.Background(), "/example.Users/Get", statusOf, func(ctx context.Context) error {
+		calls++
+		return unavailable
+	})
+	assert.ErrorIs(t, err, unavailable)
+	assert.Equal(t, calls, 2)
+
+	calls = 0
+	err = c.Do(context.Background(), "/example.Health/Check", statusOf, func(ctx context.Context) error {
+		calls++
+		return unavailable
+	})
+	assert.ErrorIs(t, err, unavailable)
+	assert.Equal(t, calls, 1, "methods without a policy aren't retried")
+}
@@ -0,0 +1,163 @@
+package roko
+
+import "context"
+
+// DoHedged is a hedged variant of DoFunc, for latency-sensitive operations. Rather than waiting for an attempt to fail
+// before trying again, it starts an additional, concurrent attempt each time the retrier's next interval elapses, up to
+// the retrier's attempt limit. It returns the result of the first attempt to succeed, and cancels the context passed to
+// all of the others. If every attempt fails, it returns the result of the last attempt to finish.
+//
+// Attempts stop being started once an attempt fails with an error matching ErrUnrecoverable, but attempts that are
+// already running carry on. Because attempts run concurrently, callback isn't passed the retrier; its context carries
+// the attempt's AttemptInfo instead (see AttemptFromContext). Each attempt is counted against the retrier's limits and
+// budget, passed to its attempt hooks and included in its Stats as it would be by DoWithContext, and the interval before
+// each additional attempt is calculated from the number of attempts that have failed so far. Attempts that are still
+// running when DoHedged returns are abandoned, and aren't counted as failures. As with DoWithContext, errors from a
+// named retrier are wrapped in a *NamedError, and if ctx is done once an attempt has failed, the error is an
+// *InterruptedError carrying the last attempt's error.
+// (Note this is not a method of Retrier, since methods can't be generic.)
+func DoHedged[T any](ctx context.Context, r *Retrier, callback func(context.Context) (T, error)) (T, error) {
+	t, err := doHedged(ctx, r, callback)
+	if err != nil && r.name != "" {
+		err = &NamedError{Name: r.name, Err: err}
+	}
+	return t, err
+}
+
+// doHedged is DoHedged, without wrapping the error in a NamedError
+func doHedged[T any](ctx context.Context, r *Retrier, callback func(context.Context) (T, error)) (T, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	l := r.newLoop(ctx, nil)
+
+	type result struct {
+		t     T
+		event AttemptEvent
+	}
+
+	results := make(chan result)
+	running := 0
+	defer func() {
+		// Release the attempts that are being abandoned, so that loops sharing the retrier can use them
+		r.mu.Lock()
+		r.inFlight -= running
+		r.mu.Unlock()
+	}()
+
+	// start starts another attempt, or returns an error if the retrier doesn't have one to spare (see startAttempt)
+	start := func() error {
+		if err := l.startAttempt(); err != nil {
+			return err
+		}
+		r.setCalculatedInterval(r.calculateNextInterval())
+
+		l.info.Attempt += 1
+		attempt, attemptCtx := l.info.Attempt, WithAttemptInfo(l.ctx, l.info)
+		running++
+		go func() {
+			start := r.now()
+			t, err := callback(attemptCtx)
+			select {
+			case results <- result{t: t, event: l.newEvent(attempt, start, err)}:
+			case <-ctx.Done():
+			}
+		}()
+		return nil
+	}
+
+	wake := make(chan struct{})
+	waitToStart := func() bool {
+		r.mu.Lock()
+		canStart := !r.shouldGiveUp() && !l.outOfAttempts() && (r.forever || r.attemptCount+r.inFlight < r.maxAttempts)
+		interval := r.nextInterval
+		if canStart && interval > 0 {
+			r.totalSleep += interval
+			r.recordSleep(interval)
+		}
+		r.mu.Unlock()
+
+		if !canStart {
+			return false
+		}
+
+		go func() {
+			if r.sleepOrDone(ctx, interval) == nil {
+				select {
+				case wake <- struct{}{}:
+				case <-ctx.Done():
+				}
+			}
+		}()
+		return true
+	}
+
+	// finish records that the loop is over, with err as its outcome
+	finish := func(err error, exhausted bool) {
+		r.mu.Lock()
+		r.recordLoop(l.info.Attempt, err, exhausted)
+		r.mu.Unlock()
+	}
+
+	var last result
+	if err := start(); err != nil {
+		var zero T
+		finish(err, true)
+		return zero, err
+	}
+	waiting := waitToStart()
+
+	for {
+		select {
+		case res := <-results:
+			running--
+
+			r.mu.Lock()
+			r.finishAttempt(res.event)
+			if res.event.Err == nil {
+				r.recordLoop(l.info.Attempt, nil, false)
+				r.mu.Unlock()
+
+				res.event.Final = true
+				l.emit(res.event)
+				return res.t, nil
+			}
+
+			last = res
+			if r.breakNext {
+				waiting = false // Any wake that's still to come won't be able to start an attempt anyway
+			}
+			final := running == 0 && !waiting
+			if final {
+				r.recordLoop(l.info.Attempt, res.event.Err, !r.breakNext)
+			}
+			r.mu.Unlock()
+
+			res.event.Final = final
+			l.emit(res.event)
+			if final {
+				return last.t, last.event.Err
+			}
+
+		case <-wake:
+			waiting = false
+			if start() != nil {
+				if running == 0 {
+					finish(last.event.Err, true)
+					return last.t, last.event.Err
+				}
+				continue
+			}
+			waiting = waitToStart()
+
+		case <-ctx.Done():
+			var zero T
+			err := ctx.Err()
+			if last.event.Err != nil {
+				err = &InterruptedError{Err: last.event.Err, Cause: err}
+			}
+			finish(err, false)
+			return zero, err
+		}
+	}
+}
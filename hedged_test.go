@@ -0,0 +1,204 @@
+package roko
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestDoHedged_WhenFirstAttemptSucceeds_DoesNotStartAnother(t *testing.T) {
+	t.Parallel()
+
+	var calls int32
+	v, err := DoHedged(context.Background(), NewRetrier(
+		WithStrategy(Constant(1*time.Hour)),
+		WithMaxAttempts(3),
+	), func(context.Context) (string, error) {
+		atomic.AddInt32(&calls, 1)
+		return "fast", nil
+	})
+	assert.NilError(t, err)
+
+	assert.Equal(t, "fast", v)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
+
+func TestDoHedged_ReturnsTheFirstSuccessAndCancelsTheRest(t *testing.T) {
+	t.Parallel()
+
+	var calls int32
+	slowCancelled := make(chan struct{})
+	v, err := DoHedged(context.Background(), NewRetrier(
+		WithStrategy(Constant(1*time.Millisecond)),
+		WithMaxAttempts(3),
+	), func(ctx context.Context) (int, error) {
+		n := atomic.AddInt32(&calls, 1)
+		if n == 1 {
+			// The first attempt hangs until it's cancelled
+			<-ctx.Done()
+			close(slowCancelled)
+			return 0, ctx.Err()
+		}
+		return int(n), nil
+	})
+	assert.NilError(t, err)
+
+	assert.Check(t, v > 1, "the first attempt should never succeed, got result from attempt %d", v)
+
+	select {
+	case <-slowCancelled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("slow attempt was never cancelled")
+	}
+}
+
+func TestDoHedged_WhenEveryAttemptFails_ReturnsTheLastError(t *testing.T) {
+	t.Parallel()
+
+	var calls int32
+	_, err := DoHedged(context.Background(), NewRetrier(
+		WithStrategy(Constant(1*time.Millisecond)),
+		WithMaxAttempts(4),
+	), func(context.Context) (int, error) {
+		atomic.AddInt32(&calls, 1)
+		return 0, errDummy
+	})
+	assert.ErrorIs(t, err, errDummy)
+
+	assert.Equal(t, int32(4), atomic.LoadInt32(&calls))
+}
+
+func TestDoHedged_WhenAnAttemptIsUnrecoverable_StopsStartingAttempts(t *testing.T) {
+	t.Parallel()
+
+	errFatal := errors.New("fatal")
+	var calls int32
+	_, err := DoHedged(context.Background(), NewRetrier(
+		WithStrategy(Constant(10*time.Millisecond)),
+		WithMaxAttempts(10),
+	), func(context.Context) (int, error) {
+		atomic.AddInt32(&calls, 1)
+		return 0, Unrecoverable(errFatal)
+	})
+	assert.ErrorIs(t, err, errFatal)
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
+
+func TestDoHedged_WhenContextIsCancelled_ReturnsContextError(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err := DoHedged(ctx, NewRetrier(
+		WithStrategy(Constant(1*time.Hour)),
+		TryForever(),
+	), func(ctx context.Context) (int, error) {
+		<-ctx.Done()
+		return 0, ctx.Err()
+	})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestDoHedged_WhenContextIsCancelledAfterAFailure_KeepsTheLastError(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err := DoHedged(ctx, NewRetrier(
+		WithStrategy(Constant(1*time.Hour)),
+		TryForever(),
+	), func(context.Context) (int, error) {
+		return 0, errDummy
+	})
+
+	var interrupted *InterruptedError
+	assert.Assert(t, errors.As(err, &interrupted))
+	assert.ErrorIs(t, err, errDummy)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestDoHedged_WithANamedRetrier_ReturnsANamedError(t *testing.T) {
+	t.Parallel()
+
+	_, err := DoHedged(context.Background(), NewRetrier(
+		WithName("lookups"),
+		WithStrategy(Constant(1*time.Millisecond)),
+		WithMaxAttempts(2),
+	), func(context.Context) (int, error) {
+		return 0, errDummy
+	})
+	assert.ErrorIs(t, err, errDummy)
+
+	var named *NamedError
+	assert.Assert(t, errors.As(err, &named))
+	assert.Equal(t, "lookups", named.Name)
+}
+
+func TestDoHedged_CountsAndReportsEveryAttempt(t *testing.T) {
+	t.Parallel()
+
+	var mu sync.Mutex
+	var events []AttemptEvent
+	budget := NewBudget("hedged", 0, 0)
+	r := NewRetrier(
+		WithStrategy(Constant(time.Millisecond)),
+		WithMaxAttempts(3),
+		WithBudget(budget),
+		WithOnAttempt(func(e AttemptEvent) {
+			mu.Lock()
+			defer mu.Unlock()
+			events = append(events, e)
+		}),
+	)
+
+	_, err := DoHedged(context.Background(), r, func(ctx context.Context) (int, error) {
+		info, ok := AttemptFromContext(ctx)
+		assert.Check(t, ok)
+		assert.Check(t, info.Attempt > 0)
+		return 0, errDummy
+	})
+	assert.ErrorIs(t, err, errDummy)
+
+	assert.Equal(t, 3, r.Attempts())
+	assert.Equal(t, 3, r.AttemptCount())
+	assert.Equal(t, 3, len(events))
+	assert.Assert(t, events[2].Final)
+	assert.Assert(t, !events[0].Final)
+	assert.Equal(t, 1, r.Stats().Loops)
+	assert.Equal(t, 3, budget.Report().Attempts)
+	assert.Equal(t, 3, budget.Report().Failures)
+}
+
+func TestDoHedged_ReleasesAbandonedAttempts(t *testing.T) {
+	t.Parallel()
+
+	r := NewRetrier(WithStrategy(Constant(time.Millisecond)), WithMaxAttempts(3))
+	release := make(chan struct{})
+	defer close(release)
+
+	var calls int32
+	v, err := DoHedged(context.Background(), r, func(ctx context.Context) (int32, error) {
+		n := atomic.AddInt32(&calls, 1)
+		if n == 1 {
+			<-release // The first attempt hangs, even once it's been cancelled
+			return 0, ctx.Err()
+		}
+		return n, nil
+	})
+	assert.NilError(t, err)
+	assert.Check(t, v > 1)
+
+	// The hanging attempt isn't counted as a failure, and doesn't hold on to one of the retrier's attempts
+	assert.Equal(t, 0, r.AttemptCount())
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	assert.Equal(t, 0, r.inFlight)
+}
@@ -0,0 +1,68 @@
+package roko
+
+import "sync"
+
+// defaultHooks are the attempt hooks registered with RegisterDefaultHook
+var defaultHooks struct {
+	mu    sync.RWMutex
+	hooks []*defaultHook
+}
+
+// defaultHook wraps a registered hook, so that it can be unregistered by identity (funcs can't be compared)
+type defaultHook struct {
+	f func(AttemptEvent)
+}
+
+// RegisterDefaultHook adds an attempt hook that every retrier in the process calls after each attempt, as though it had
+// been passed to WithOnAttempt, so that retry telemetry can be set up once, in main, rather than at every call site:
+//
+//	roko.RegisterDefaultHook(func(e roko.AttemptEvent) {
+//		retryAttempts.WithLabelValues(e.Name, outcome(e)).Inc()
+//	})
+//
+// Default hooks apply to retriers created before they were registered, as well as after, and are called after the
+// retrier's own hooks. Retriers created with WithoutDefaultHooks don't call them, and neither do the retriers used by
+// Simulate. The returned func unregisters the hook, which is mostly useful in tests. It's safe to call concurrently
+func RegisterDefaultHook(f func(AttemptEvent)) (unregister func()) {
+	if f == nil {
+		panic("default hooks must not be nil")
+	}
+
+	h := &defaultHook{f: f}
+
+	defaultHooks.mu.Lock()
+	defer defaultHooks.mu.Unlock()
+	// The slice is copied rather than appended to in place, so that emit can use the old one without holding the lock
+	defaultHooks.hooks = append(defaultHooks.hooks[:len(defaultHooks.hooks):len(defaultHooks.hooks)], h)
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			defaultHooks.mu.Lock()
+			defer defaultHooks.mu.Unlock()
+
+			hooks := make([]*defaultHook, 0, len(defaultHooks.hooks))
+			for _, other := range defaultHooks.hooks {
+				if other != h {
+					hooks = append(hooks, other)
+				}
+			}
+			defaultHooks.hooks = hooks
+		})
+	}
+}
+
+// WithoutDefaultHooks opts the retrier out of the hooks registered with RegisterDefaultHook, for retriers whose
+// attempts shouldn't show up in process-wide telemetry, such as ones used in health checks or simulations
+func WithoutDefaultHooks() retrierOpt {
+	return func(r *Retrier) {
+		r.noDefaultHooks = true
+	}
+}
+
+// registeredHooks returns the hooks registered with RegisterDefaultHook
+func registeredHooks() []*defaultHook {
+	defaultHooks.mu.RLock()
+	defer defaultHooks.mu.RUnlock()
+	return defaultHooks.hooks
+}
@@ -0,0 +1,108 @@
+package roko
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+// recordHook returns a default hook that records the attempts of the retrier with the given name, since other tests'
+// retriers call default hooks too
+func recordHook(name string) (hook func(AttemptEvent), attempts func() []int) {
+	var mu sync.Mutex
+	var seen []int
+
+	hook = func(e AttemptEvent) {
+		if e.Name != name {
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		seen = append(seen, e.Attempt)
+	}
+	attempts = func() []int {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]int(nil), seen...)
+	}
+	return hook, attempts
+}
+
+func TestRegisterDefaultHook(t *testing.T) {
+	t.Parallel()
+
+	// The retrier is created before the hook is registered, which shouldn't matter
+	var order []string
+	r := NewRetrier(
+		WithName("default-hooks"),
+		WithMaxAttempts(3),
+		WithStrategy(Constant(time.Second)),
+		WithSleepFunc(func(time.Duration) {}),
+		WithOnAttempt(func(AttemptEvent) { order = append(order, "own") }),
+	)
+
+	hook, attempts := recordHook("default-hooks")
+	unregister := RegisterDefaultHook(func(e AttemptEvent) {
+		if e.Name == "default-hooks" {
+			order = append(order, "default")
+		}
+		hook(e)
+	})
+	defer unregister()
+
+	_ = r.Do(func(r *Retrier) error {
+		if r.AttemptCount() < 1 {
+			return errors.New("failed")
+		}
+		return nil
+	})
+
+	assert.DeepEqual(t, []int{1, 2}, attempts())
+	assert.DeepEqual(t, []string{"own", "default", "own", "default"}, order)
+
+	unregister()
+	unregister() // Unregistering twice is harmless
+	_ = r.Do(func(*Retrier) error { return nil })
+	assert.DeepEqual(t, []int{1, 2}, attempts())
+}
+
+func TestWithoutDefaultHooks(t *testing.T) {
+	t.Parallel()
+
+	hook, attempts := recordHook("no-default-hooks")
+	defer RegisterDefaultHook(hook)()
+
+	r := NewRetrier(WithName("no-default-hooks"), WithMaxAttempts(1), WithoutDefaultHooks())
+	_ = r.Do(func(*Retrier) error { return nil })
+
+	assert.Equal(t, 0, len(attempts()))
+}
+
+func TestSimulate_SkipsDefaultHooks(t *testing.T) {
+	t.Parallel()
+
+	hook, attempts := recordHook("simulated")
+	defer RegisterDefaultHook(hook)()
+
+	Simulate(FailureModel{SuccessProbability: 0.5}, 10, 1, WithName("simulated"), WithMaxAttempts(3))
+	assert.Equal(t, 0, len(attempts()))
+}
+
+func TestRegisterDefaultHook_UnregisteringKeepsOtherHooks(t *testing.T) {
+	t.Parallel()
+
+	first, firstAttempts := recordHook("several-default-hooks")
+	second, secondAttempts := recordHook("several-default-hooks")
+	unregisterFirst := RegisterDefaultHook(first)
+	defer RegisterDefaultHook(second)()
+	unregisterFirst()
+
+	r := NewRetrier(WithName("several-default-hooks"), WithMaxAttempts(1))
+	_ = r.Do(func(*Retrier) error { return nil })
+
+	assert.Equal(t, 0, len(firstAttempts()))
+	assert.DeepEqual(t, []int{1}, secondAttempts())
+}
@@ -0,0 +1,104 @@
+// Package imds helps to retry requests to cloud instance metadata services (IMDS), such as the ones on EC2, GCE and
+// Azure. At boot, the metadata service is often not ready yet, or not reachable until networking has finished coming
+// up, so the first few requests fail - but it usually starts answering within a second or two, so the waits between
+// attempts should be short, and the whole thing should give up quickly if it doesn't.
+package imds
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/buildkite/roko"
+)
+
+// ErrNotFound is returned when the metadata service says the requested key doesn't exist. On most clouds, this means
+// the instance doesn't have that piece of metadata at all (for example, it has no instance profile), rather than that
+// the metadata service isn't ready, so it's not retried
+var ErrNotFound = errors.New("instance metadata not found")
+
+const (
+	initialInterval = 50 * time.Millisecond
+	maxInterval     = 1 * time.Second
+	maxAttempts     = 15
+	maxTotalSleep   = 10 * time.Second
+)
+
+// NewRetrier returns a retrier tuned for metadata services. It starts by waiting 50ms between attempts, doubling each
+// time up to a maximum of 1s, with jitter, and gives up after 15 attempts or 10s spent waiting, whichever comes first
+func NewRetrier() *roko.Retrier {
+	return roko.NewRetrier(
+		roko.WithMaxAttempts(maxAttempts),
+		roko.WithMaxTotalSleep(maxTotalSleep),
+		roko.WithStrategy(roko.Func(interval)),
+		roko.WithJitter(roko.EqualJitter),
+	)
+}
+
+// interval is the wait before the next attempt, doubling from initialInterval up to maxInterval
+func interval(attempt int) time.Duration {
+	d := initialInterval
+	for i := 0; i < attempt && d < maxInterval; i++ {
+		d *= 2
+	}
+	if d > maxInterval {
+		d = maxInterval
+	}
+	return d
+}
+
+// StatusError is returned when the metadata service responds with an unexpected status
+type StatusError struct {
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("instance metadata service responded with %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+// Classify turns the result of a request to a metadata service into an error suitable for returning from a retrier's
+// callback. Errors making the request, 5xx responses, 429 Too Many Requests and 401 Unauthorized (which IMDSv2 returns
+// when its session token has expired) are retried. 404 Not Found gives ErrNotFound, and other 4xx responses give a
+// *StatusError, both wrapped with roko.Unrecoverable
+func Classify(resp *http.Response, err error) error {
+	if err != nil {
+		return err
+	}
+
+	switch code := resp.StatusCode; {
+	case code < 400:
+		return nil
+	case code == http.StatusNotFound:
+		return roko.Unrecoverable(ErrNotFound)
+	case code >= 500, code == http.StatusTooManyRequests, code == http.StatusUnauthorized:
+		return &StatusError{StatusCode: code}
+	default:
+		return roko.Unrecoverable(&StatusError{StatusCode: code})
+	}
+}
+
+// Get makes a request to a metadata service using r, and returns the body of the response. It calls newRequest to
+// create the request for each attempt, so that it can add any headers needed (such as an IMDSv2 session token, which
+// might need to be fetched again if it's expired), and uses client to make it
+func Get(ctx context.Context, r *roko.Retrier, client *http.Client, newRequest func(context.Context) (*http.Request, error)) ([]byte, error) {
+	return roko.DoFunc(ctx, r, func(r *roko.Retrier) ([]byte, error) {
+		req, err := newRequest(r.Context())
+		if err != nil {
+			return nil, roko.Unrecoverable(err)
+		}
+
+		resp, err := client.Do(req)
+		if err := Classify(resp, err); err != nil {
+			if resp != nil {
+				resp.Body.Close()
+			}
+			return nil, err
+		}
+		defer resp.Body.Close()
+
+		return io.ReadAll(resp.Body)
+	})
+}
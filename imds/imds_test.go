@@ -0,0 +1,99 @@
+package imds
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/buildkite/roko"
+	"gotest.tools/v3/assert"
+)
+
+func TestNewRetrier_IntervalsAreShortAndCapped(t *testing.T) {
+	t.Parallel()
+
+	assert.DeepEqual(t, []time.Duration{
+		50 * time.Millisecond,
+		100 * time.Millisecond,
+		200 * time.Millisecond,
+		400 * time.Millisecond,
+		800 * time.Millisecond,
+		1 * time.Second,
+		1 * time.Second,
+	}, []time.Duration{interval(0), interval(1), interval(2), interval(3), interval(4), interval(5), interval(6)})
+}
+
+func TestClassify(t *testing.T) {
+	t.Parallel()
+
+	errConn := errors.New("connect: network is unreachable")
+	assert.ErrorIs(t, Classify(nil, errConn), errConn)
+
+	assert.NilError(t, Classify(&http.Response{StatusCode: http.StatusOK}, nil))
+
+	err := Classify(&http.Response{StatusCode: http.StatusNotFound}, nil)
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.ErrorIs(t, err, roko.ErrUnrecoverable)
+
+	for _, code := range []int{http.StatusUnauthorized, http.StatusTooManyRequests, http.StatusServiceUnavailable} {
+		err := Classify(&http.Response{StatusCode: code}, nil)
+		assert.Check(t, !errors.Is(err, roko.ErrUnrecoverable), "status %d", code)
+	}
+
+	err = Classify(&http.Response{StatusCode: http.StatusForbidden}, nil)
+	assert.ErrorIs(t, err, roko.ErrUnrecoverable)
+	assert.Error(t, err, "instance metadata service responded with 403 Forbidden")
+}
+
+func newTestRetrier() *roko.Retrier {
+	return roko.NewRetrier(
+		roko.WithMaxAttempts(5),
+		roko.WithStrategy(roko.Func(interval)),
+		roko.WithSleepFunc(func(time.Duration) {}),
+	)
+}
+
+func TestGet_RetriesUntilTheServiceIsReady(t *testing.T) {
+	t.Parallel()
+
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, "i-0123456789abcdef0")
+	}))
+	defer srv.Close()
+
+	body, err := Get(context.Background(), newTestRetrier(), srv.Client(), func(ctx context.Context) (*http.Request, error) {
+		return http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/latest/meta-data/instance-id", nil)
+	})
+
+	assert.NilError(t, err)
+	assert.Equal(t, "i-0123456789abcdef0", string(body))
+	assert.Equal(t, int32(3), atomic.LoadInt32(&requests))
+}
+
+func TestGet_DoesntRetryNotFound(t *testing.T) {
+	t.Parallel()
+
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		http.NotFound(w, r)
+	}))
+	defer srv.Close()
+
+	_, err := Get(context.Background(), newTestRetrier(), srv.Client(), func(ctx context.Context) (*http.Request, error) {
+		return http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/latest/meta-data/iam/info", nil)
+	})
+
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+}
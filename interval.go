@@ -0,0 +1,129 @@
+package roko
+
+import "time"
+
+// WithQuantize causes the retrier to round each interval it calculates to the nearest multiple of unit, after any jitter
+// has been applied. Jitter and the growth of exponential strategies produce intervals like 3.651203941s, which are hard
+// to read in logs and UIs; rounding them at the source means they don't need to be formatted everywhere they're shown.
+// Intervals set using SetNextInterval aren't rounded
+func WithQuantize(unit time.Duration) retrierOpt {
+	if unit <= 0 {
+		panic("quantize unit must be positive")
+	}
+
+	return func(r *Retrier) {
+		r.quantum = unit
+	}
+}
+
+// WithMinInterval makes the retrier wait at least d between attempts, whatever its strategy calculates. It's a safety
+// net against strategies that are misconfigured in a way that produces zero or tiny intervals (such as an exponential
+// strategy with a zero initial interval), which would otherwise retry in a hot loop. The minimum is applied after
+// jitter and WithQuantize; intervals set using SetNextInterval aren't affected
+func WithMinInterval(d time.Duration) retrierOpt {
+	if d <= 0 {
+		panic("min interval must be positive")
+	}
+
+	return func(r *Retrier) {
+		r.minInterval = d
+	}
+}
+
+// WithMaxInterval makes the retrier wait no more than d between attempts, whatever its strategy calculates, so that an
+// exponential strategy can grow quickly at first without its waits growing without bound:
+//
+//	roko.NewRetrier(
+//		roko.TryForever(),
+//		roko.WithStrategy(roko.Exponential(2*time.Second, 0)),
+//		roko.WithMaxInterval(time.Minute), // 1s, 2s, 4s ... 32s, then a minute between every attempt after that
+//	)
+//
+// The maximum is applied after jitter and WithQuantize, so jitter can't push a wait past it. Intervals set using
+// SetNextInterval aren't affected, so that a server's Retry-After is still respected
+func WithMaxInterval(d time.Duration) retrierOpt {
+	if d <= 0 {
+		panic("max interval must be positive")
+	}
+
+	return func(r *Retrier) {
+		r.intervalCap = d
+	}
+}
+
+// StopOnNegativeInterval makes the retrier give up, rather than retrying straight away, when it calculates a negative
+// interval - for custom strategies whose arithmetic going below zero means there's no point trying again, such as one
+// that works out how long is left before a deadline. It's as if the strategy had called Break: the attempt that's
+// about to be made is the last one. Without it, negative intervals are clamped to zero
+func StopOnNegativeInterval() retrierOpt {
+	return func(r *Retrier) {
+		r.stopOnNegative = true
+	}
+}
+
+// clampNegative returns interval, or 0 if it's negative - in which case, if the retrier was created with
+// StopOnNegativeInterval, it also stops the retrier with Break
+func (r *Retrier) clampNegative(interval time.Duration) time.Duration {
+	if interval >= 0 {
+		return interval
+	}
+
+	if r.stopOnNegative {
+		r.Break()
+	}
+	return 0
+}
+
+// calculateNextInterval calculates the interval the retrier should wait before its next attempt, using its strategy,
+// business hours, backpressure, jitter mode, quantum, minimum and maximum intervals, and TruncateFinalSleep. Retriers
+// without a strategy (which is only useful alongside NoRetry) don't wait at all. Negative intervals (from negative
+// jitter, or a custom strategy's arithmetic) are clamped to zero, or stop the retrier (see StopOnNegativeInterval)
+func (r *Retrier) calculateNextInterval() time.Duration {
+	if r.intervalCalculator == nil {
+		return 0
+	}
+
+	interval := r.intervalCalculator(r)
+
+	if r.businessHours != nil {
+		interval = r.businessHours.scale(r.now(), interval)
+	}
+
+	if r.backpressure != nil {
+		interval = r.backpressure.stretch(interval)
+	}
+
+	if r.jitter && r.jitterMode.isSet() && !r.clockAligned() {
+		interval = r.jitterMode.apply(r, interval)
+	}
+
+	interval = r.quantize(interval)
+	if r.intervalCap > 0 && interval > r.intervalCap {
+		interval = r.intervalCap
+	}
+	interval = r.clampNegative(interval)
+	if interval < r.minInterval {
+		interval = r.minInterval
+	}
+
+	if r.truncateFinalSleep {
+		// Other loops sharing the retrier add to its total sleep as they go, so read it under the lock
+		r.mu.Lock()
+		remaining := r.maxTotalSleep - r.totalSleep
+		r.mu.Unlock()
+		if remaining > 0 && interval > remaining {
+			interval = remaining
+		}
+	}
+
+	return interval
+}
+
+// quantize rounds interval to the nearest multiple of the retrier's quantum, if it has one
+func (r *Retrier) quantize(interval time.Duration) time.Duration {
+	if r.quantum <= 0 {
+		return interval
+	}
+
+	return interval.Round(r.quantum)
+}
@@ -0,0 +1,284 @@
+package roko
+
+import (
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestWithQuantize_RoundsIntervals(t *testing.T) {
+	t.Parallel()
+
+	insomniac := newInsomniac()
+	err := NewRetrier(
+		WithStrategy(ExponentialSubsecond(1*time.Second)),
+		WithQuantize(100*time.Millisecond),
+		WithMaxAttempts(6),
+		WithSleepFunc(insomniac.sleep),
+	).Do(func(_ *Retrier) error { return errDummy })
+	assert.ErrorIs(t, err, errDummy)
+
+	assert.DeepEqual(t, []time.Duration{
+		1000 * time.Millisecond,
+		1500 * time.Millisecond, // 1539ms
+		2400 * time.Millisecond, // 2371ms
+		3700 * time.Millisecond, // 3651ms
+		5600 * time.Millisecond, // 5623ms
+	}, insomniac.sleepIntervals, DurationExact())
+}
+
+func TestWithQuantize_RoundsJitteredIntervals(t *testing.T) {
+	t.Parallel()
+
+	insomniac := newInsomniac()
+	err := NewRetrier(
+		WithStrategy(Constant(5*time.Second)),
+		WithJitter(),
+		WithQuantize(time.Second),
+		WithMaxAttempts(100),
+		WithSleepFunc(insomniac.sleep),
+	).Do(func(_ *Retrier) error { return errDummy })
+	assert.ErrorIs(t, err, errDummy)
+
+	for _, interval := range insomniac.sleepIntervals {
+		assert.Check(t, interval == 5*time.Second || interval == 6*time.Second, "interval: %s", interval)
+	}
+}
+
+func TestWithQuantize_AppliesToPlannedIntervals(t *testing.T) {
+	t.Parallel()
+
+	r := NewRetrier(
+		WithStrategy(ExponentialSubsecond(1*time.Second)),
+		WithQuantize(time.Second),
+		WithMaxAttempts(4),
+	)
+
+	assert.DeepEqual(t, []time.Duration{
+		1 * time.Second,
+		2 * time.Second,
+		2 * time.Second,
+	}, r.PlannedIntervals(3), DurationExact())
+}
+
+func TestNextInterval_NegativeIntervalsAreClampedToZero(t *testing.T) {
+	t.Parallel()
+
+	insomniac := newInsomniac()
+	err := NewRetrier(
+		WithStrategy(Func(func(attempt int) time.Duration {
+			return 2*time.Second - time.Duration(attempt)*time.Second
+		})),
+		WithMaxAttempts(5),
+		WithSleepFunc(insomniac.sleep),
+	).Do(func(_ *Retrier) error { return errDummy })
+	assert.ErrorIs(t, err, errDummy)
+
+	assert.DeepEqual(t, []time.Duration{
+		2 * time.Second,
+		1 * time.Second,
+		0,
+		0,
+	}, insomniac.sleepIntervals, DurationExact())
+}
+
+func TestStopOnNegativeInterval_EndsTheLoop(t *testing.T) {
+	t.Parallel()
+
+	insomniac := newInsomniac()
+	calls := 0
+	r := NewRetrier(
+		WithStrategy(Func(func(attempt int) time.Duration {
+			return 2*time.Second - time.Duration(attempt)*time.Second
+		})),
+		TryForever(),
+		StopOnNegativeInterval(),
+		WithSleepFunc(insomniac.sleep),
+	)
+	err := r.Do(func(_ *Retrier) error {
+		calls++
+		return errDummy
+	})
+	assert.ErrorIs(t, err, errDummy)
+
+	// The interval after the fourth attempt would be -1s, so that attempt is the last
+	assert.DeepEqual(t, []time.Duration{
+		2 * time.Second,
+		1 * time.Second,
+		0,
+	}, insomniac.sleepIntervals, DurationExact())
+	assert.Equal(t, 4, calls)
+}
+
+func TestStopOnNegativeInterval_AppliesToJitter(t *testing.T) {
+	t.Parallel()
+
+	calls := 0
+	err := NewRetrier(
+		WithStrategy(Constant(1*time.Second)),
+		WithJitterRange(-3*time.Second, -2*time.Second),
+		TryForever(),
+		StopOnNegativeInterval(),
+		WithSleepFunc(dummySleep),
+	).Do(func(_ *Retrier) error {
+		calls++
+		return errDummy
+	})
+	assert.ErrorIs(t, err, errDummy)
+	assert.Equal(t, 1, calls)
+}
+
+func TestStopOnNegativeInterval_AppliesToPlannedIntervals(t *testing.T) {
+	t.Parallel()
+
+	r := NewRetrier(
+		WithStrategy(Func(func(attempt int) time.Duration {
+			return 2*time.Second - time.Duration(attempt)*time.Second
+		})),
+		TryForever(),
+		StopOnNegativeInterval(),
+	)
+	assert.DeepEqual(t, []time.Duration{2 * time.Second, time.Second, 0}, r.PlannedIntervals(10), DurationExact())
+}
+
+func TestNextInterval_NegativeJitterIsClampedToZero(t *testing.T) {
+	t.Parallel()
+
+	insomniac := newInsomniac()
+	err := NewRetrier(
+		WithStrategy(Constant(1*time.Second)),
+		WithJitterRange(-3*time.Second, -2*time.Second),
+		WithMaxAttempts(5),
+		WithSleepFunc(insomniac.sleep),
+	).Do(func(_ *Retrier) error { return errDummy })
+	assert.ErrorIs(t, err, errDummy)
+
+	for _, interval := range insomniac.sleepIntervals {
+		assert.Equal(t, time.Duration(0), interval)
+	}
+}
+
+func TestWithMinInterval_ClampsShortIntervals(t *testing.T) {
+	t.Parallel()
+
+	insomniac := newInsomniac()
+	err := NewRetrier(
+		WithMaxAttempts(4),
+		WithStrategy(Func(func(attempt int) time.Duration {
+			switch attempt {
+			case 0:
+				return 0
+			case 1:
+				return time.Microsecond
+			default:
+				return time.Second
+			}
+		})),
+		WithMinInterval(100*time.Millisecond),
+		WithSleepFunc(insomniac.sleep),
+	).Do(func(r *Retrier) error {
+		return errDummy
+	})
+
+	assert.ErrorIs(t, err, errDummy)
+	assert.DeepEqual(t, []time.Duration{100 * time.Millisecond, 100 * time.Millisecond, time.Second}, insomniac.sleepIntervals, DurationExact())
+}
+
+func TestWithMinInterval_AppliesToPlannedIntervals(t *testing.T) {
+	t.Parallel()
+
+	r := NewRetrier(WithMaxAttempts(3), WithStrategy(Func(func(int) time.Duration { return 0 })), WithMinInterval(time.Second))
+	assert.DeepEqual(t, []time.Duration{time.Second, time.Second}, r.PlannedIntervals(10), DurationExact())
+	assert.Equal(t, "func, up to 3 attempts, at least 1s between attempts", r.Describe())
+}
+
+func TestWithMinInterval_DoesntAffectOverriddenIntervals(t *testing.T) {
+	t.Parallel()
+
+	insomniac := newInsomniac()
+	err := NewRetrier(
+		WithMaxAttempts(2),
+		WithStrategy(Constant(time.Second)),
+		WithMinInterval(500*time.Millisecond),
+		WithSleepFunc(insomniac.sleep),
+	).Do(func(r *Retrier) error {
+		r.SetNextInterval(time.Millisecond)
+		return errDummy
+	})
+
+	assert.ErrorIs(t, err, errDummy)
+	assert.DeepEqual(t, []time.Duration{time.Millisecond}, insomniac.sleepIntervals, DurationExact())
+}
+
+func TestWithMinInterval_PanicsWhenNotPositive(t *testing.T) {
+	t.Parallel()
+
+	defer func() {
+		assert.Assert(t, recover() != nil)
+	}()
+
+	WithMinInterval(0)
+}
+
+func TestWithMaxInterval_CapsIntervals(t *testing.T) {
+	t.Parallel()
+
+	r := NewRetrier(
+		WithStrategy(Exponential(2*time.Second, 0)),
+		WithMaxInterval(10*time.Second),
+		WithMaxAttempts(7),
+	)
+
+	assert.DeepEqual(t, []time.Duration{
+		1 * time.Second,
+		2 * time.Second,
+		4 * time.Second,
+		8 * time.Second,
+		10 * time.Second,
+		10 * time.Second,
+	}, r.PlannedIntervals(6))
+	assert.Equal(t, "exponential(2s, 0s), up to 7 attempts, at most 10s between attempts", r.Describe())
+}
+
+func TestWithMaxInterval_AppliesAfterJitter(t *testing.T) {
+	t.Parallel()
+
+	insomniac := newInsomniac()
+	err := NewRetrier(
+		WithStrategy(Constant(10*time.Second)),
+		WithJitter(PlusMinus(5*time.Second)),
+		WithMaxInterval(10*time.Second),
+		WithMaxAttempts(50),
+		WithSleepFunc(insomniac.sleep),
+	).Do(func(_ *Retrier) error { return errDummy })
+	assert.ErrorIs(t, err, errDummy)
+
+	for _, d := range insomniac.sleepIntervals {
+		assert.Assert(t, d <= 10*time.Second, "slept for %s", d)
+	}
+}
+
+func TestWithMaxInterval_DoesNotAffectOverrides(t *testing.T) {
+	t.Parallel()
+
+	insomniac := newInsomniac()
+	_ = NewRetrier(
+		WithStrategy(Constant(time.Second)),
+		WithMaxInterval(10*time.Second),
+		WithMaxAttempts(2),
+		WithSleepFunc(insomniac.sleep),
+	).Do(func(r *Retrier) error {
+		r.SetNextInterval(time.Minute)
+		return errDummy
+	})
+
+	assert.DeepEqual(t, []time.Duration{time.Minute}, insomniac.sleepIntervals)
+}
+
+func TestWithMaxInterval_PanicsBelowTheMinInterval(t *testing.T) {
+	t.Parallel()
+	defer func() { assert.Assert(t, recover() != nil) }()
+
+	NewRetrier(WithMaxAttempts(3), WithStrategy(Constant(time.Second)), WithMinInterval(time.Minute), WithMaxInterval(time.Second))
+}
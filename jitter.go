@@ -0,0 +1,172 @@
+package roko
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// JitterMode is a jitter algorithm that can be passed to WithJitter. Each mode takes the interval calculated by the
+// retrier's strategy, and returns the interval that the retrier should actually wait
+type JitterMode struct {
+	name string
+
+	// apply returns the jittered interval
+	apply func(r *Retrier, interval time.Duration) time.Duration
+
+	// worst returns the longest interval apply could return, given the worst case for the previous interval
+	worst func(interval, previous time.Duration) time.Duration
+}
+
+func (m JitterMode) isSet() bool {
+	return m.apply != nil
+}
+
+func (m JitterMode) String() string {
+	return m.name
+}
+
+var (
+	// FullJitter waits a random amount of time between zero and the interval calculated by the strategy. It spreads
+	// retries out the most, at the cost of sometimes retrying almost immediately
+	FullJitter = JitterMode{
+		name: "full",
+		apply: func(r *Retrier, interval time.Duration) time.Duration {
+			return r.randomDuration(0, interval)
+		},
+		worst: func(interval, _ time.Duration) time.Duration { return interval },
+	}
+
+	// EqualJitter waits for half of the interval calculated by the strategy, plus a random amount of time up to the
+	// other half. This guarantees a minimum wait while still spreading retries out
+	EqualJitter = JitterMode{
+		name: "equal",
+		apply: func(r *Retrier, interval time.Duration) time.Duration {
+			half := interval / 2
+			return half + r.randomDuration(0, interval-half)
+		},
+		worst: func(interval, _ time.Duration) time.Duration { return interval },
+	}
+
+	// DecorrelatedJitter waits a random amount of time between the interval calculated by the strategy and three times
+	// the previous interval, so that each wait depends on the one before it rather than only on the attempt count.
+	// It's most useful with a Constant strategy, where the constant acts as the minimum wait
+	DecorrelatedJitter = JitterMode{
+		name: "decorrelated",
+		apply: func(r *Retrier, interval time.Duration) time.Duration {
+			return r.randomDuration(interval, saturatingDuration(3, r.NextInterval()))
+		},
+		worst: func(interval, previous time.Duration) time.Duration {
+			if longest := saturatingDuration(3, previous); longest > interval {
+				return longest
+			}
+			return interval
+		},
+	}
+)
+
+// PlusMinus returns a jitter mode that adds a random amount of time in the range [-d, d) to the interval calculated by
+// the strategy. If this would make the interval negative, the interval is clamped to zero
+func PlusMinus(d time.Duration) JitterMode {
+	if d <= 0 {
+		panic("PlusMinus jitter must have a positive range")
+	}
+
+	return JitterMode{
+		name: fmt.Sprintf("plus-minus(%s)", d),
+		apply: func(r *Retrier, interval time.Duration) time.Duration {
+			jittered := saturatingAdd(interval, r.randomDuration(-d, d))
+			if jittered < 0 {
+				return 0
+			}
+			return jittered
+		},
+		worst: func(interval, _ time.Duration) time.Duration { return saturatingAdd(interval, d) },
+	}
+}
+
+// AtLeast returns a jitter mode that works like m, but never waits less than fraction of the interval calculated by the
+// strategy, so that unlucky attempts don't retry almost immediately. For example, FullJitter.AtLeast(0.1) waits a
+// random amount of time between 10% and 100% of the interval. fraction must be greater than 0, and at most 1
+func (m JitterMode) AtLeast(fraction float64) JitterMode {
+	if !m.isSet() {
+		panic("AtLeast needs a jitter mode")
+	}
+	if fraction <= 0 || fraction > 1 {
+		panic("jitter floors must be greater than 0, and at most 1")
+	}
+
+	floor := func(interval time.Duration) time.Duration {
+		return saturatingDuration(float64(interval)*fraction, 1)
+	}
+
+	return JitterMode{
+		name: fmt.Sprintf("%s (at least %g%%)", m.name, fraction*100),
+		apply: func(r *Retrier, interval time.Duration) time.Duration {
+			jittered, min := m.apply(r, interval), floor(interval)
+			if jittered < min {
+				return min
+			}
+			return jittered
+		},
+		worst: func(interval, previous time.Duration) time.Duration {
+			worst, min := m.worst(interval, previous), floor(interval)
+			if worst < min {
+				return min
+			}
+			return worst
+		},
+	}
+}
+
+// randomDuration returns a random duration in the range [min, max), using the retrier's source of randomness. If
+// max <= min, it returns min
+func (r *Retrier) randomDuration(min, max time.Duration) time.Duration {
+	if max <= min {
+		return min
+	}
+
+	return min + time.Duration(float64(max-min)*r.randFloat64())
+}
+
+// randFloat64 returns a random number in the range [0, 1) from the retrier's source of randomness (see WithRand and
+// WithSeed), or from the math/rand package's if it doesn't have one
+func (r *Retrier) randFloat64() float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.rand == nil {
+		return rand.Float64()
+	}
+	return r.rand.Float64()
+}
+
+// WithJitteredOverrides causes the retrier to apply its jitter to intervals set using SetNextInterval, as well as ones
+// calculated by its strategy. By default, overridden intervals are used exactly as given. This is useful when the
+// overrides are chosen by the program itself, rather than dictated by a server
+func WithJitteredOverrides() retrierOpt {
+	return func(r *Retrier) {
+		r.jitterOverrides = true
+	}
+}
+
+// jitterOverriddenInterval applies the retrier's jitter to the next interval, if it was set using SetNextInterval
+func (r *Retrier) jitterOverriddenInterval() {
+	r.mu.Lock()
+	interval, overridden := r.nextInterval, r.overridden
+	r.mu.Unlock()
+
+	if !overridden || !r.jitter {
+		return
+	}
+
+	if r.jitterMode.isSet() {
+		interval = r.jitterMode.apply(r, interval)
+	} else {
+		interval += r.Jitter()
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.nextInterval = interval
+}
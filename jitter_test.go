@@ -0,0 +1,212 @@
+package roko
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestWithJitter_FullJitter(t *testing.T) {
+	t.Parallel()
+
+	insomniac := newInsomniac()
+	err := NewRetrier(
+		WithStrategy(Constant(10*time.Second)),
+		WithJitter(FullJitter),
+		WithMaxAttempts(1000),
+		WithSleepFunc(insomniac.sleep),
+	).Do(func(_ *Retrier) error { return errDummy })
+	assert.ErrorIs(t, err, errDummy)
+
+	for _, interval := range insomniac.sleepIntervals {
+		assert.Check(t, interval >= 0 && interval < 10*time.Second, "interval: %s", interval)
+	}
+}
+
+func TestWithJitter_EqualJitter(t *testing.T) {
+	t.Parallel()
+
+	insomniac := newInsomniac()
+	err := NewRetrier(
+		WithStrategy(Exponential(2*time.Second, 0)),
+		WithJitter(EqualJitter),
+		WithMaxAttempts(6),
+		WithSleepFunc(insomniac.sleep),
+	).Do(func(_ *Retrier) error { return errDummy })
+	assert.ErrorIs(t, err, errDummy)
+
+	nominal := []time.Duration{1 * time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 16 * time.Second}
+	assert.Equal(t, len(nominal), len(insomniac.sleepIntervals))
+	for i, interval := range insomniac.sleepIntervals {
+		assert.Check(t, interval >= nominal[i]/2 && interval < nominal[i], "interval: %s, nominal: %s", interval, nominal[i])
+	}
+}
+
+func TestWithJitter_DecorrelatedJitter(t *testing.T) {
+	t.Parallel()
+
+	insomniac := newInsomniac()
+	err := NewRetrier(
+		WithStrategy(Constant(1*time.Second)),
+		WithJitter(DecorrelatedJitter),
+		WithMaxAttempts(1000),
+		WithSleepFunc(insomniac.sleep),
+	).Do(func(_ *Retrier) error { return errDummy })
+	assert.ErrorIs(t, err, errDummy)
+
+	previous := time.Duration(0)
+	for _, interval := range insomniac.sleepIntervals {
+		assert.Check(t, interval >= 1*time.Second, "interval: %s", interval)
+		if previous > 0 {
+			assert.Check(t, interval <= saturatingDuration(3, previous), "interval: %s, previous: %s", interval, previous)
+		}
+		previous = interval
+	}
+}
+
+func TestWithJitter_PlusMinus(t *testing.T) {
+	t.Parallel()
+
+	insomniac := newInsomniac()
+	err := NewRetrier(
+		WithStrategy(Constant(1*time.Second)),
+		WithJitter(PlusMinus(2*time.Second)),
+		WithMaxAttempts(1000),
+		WithSleepFunc(insomniac.sleep),
+	).Do(func(_ *Retrier) error { return errDummy })
+	assert.ErrorIs(t, err, errDummy)
+
+	for _, interval := range insomniac.sleepIntervals {
+		assert.Check(t, interval >= 0 && interval < 3*time.Second, "interval: %s", interval)
+	}
+}
+
+func TestWithJitter_AtLeast_NeverWaitsLessThanTheFloor(t *testing.T) {
+	t.Parallel()
+
+	insomniac := newInsomniac()
+	err := NewRetrier(
+		WithStrategy(Constant(10*time.Second)),
+		WithJitter(FullJitter.AtLeast(0.5)),
+		WithMaxAttempts(1000),
+		WithSleepFunc(insomniac.sleep),
+	).Do(func(_ *Retrier) error { return errDummy })
+	assert.ErrorIs(t, err, errDummy)
+
+	for _, interval := range insomniac.sleepIntervals {
+		assert.Check(t, interval >= 5*time.Second && interval < 10*time.Second, "interval: %s", interval)
+	}
+}
+
+func TestWithJitter_AtLeast_DescribesTheFloor(t *testing.T) {
+	t.Parallel()
+
+	r := NewRetrier(WithStrategy(Constant(10*time.Second)), WithJitter(FullJitter.AtLeast(0.1)), WithMaxAttempts(3))
+
+	assert.Equal(t, "constant(10s), full (at least 10%) jitter, up to 3 attempts", r.Describe())
+	assert.Equal(t, 20*time.Second, r.EstimateTotal(3))
+}
+
+func TestWithJitter_AtLeast_PanicsWithAnInvalidFraction(t *testing.T) {
+	t.Parallel()
+
+	for _, fraction := range []float64{0, -0.5, 1.5} {
+		fraction := fraction
+		t.Run(fmt.Sprint(fraction), func(t *testing.T) {
+			t.Parallel()
+
+			defer func() {
+				assert.Assert(t, recover() != nil)
+			}()
+
+			FullJitter.AtLeast(fraction)
+		})
+	}
+}
+
+func TestWithJitter_WithMode_DoesNotAddDefaultJitter(t *testing.T) {
+	t.Parallel()
+
+	r := NewRetrier(
+		WithStrategy(Constant(1*time.Second)),
+		WithJitter(FullJitter),
+		WithMaxAttempts(1),
+	)
+
+	assert.Equal(t, time.Duration(0), r.Jitter())
+}
+
+func TestSetNextInterval_ByDefault_IsNotJittered(t *testing.T) {
+	t.Parallel()
+
+	insomniac := newInsomniac()
+	err := NewRetrier(
+		WithStrategy(Constant(1*time.Second)),
+		WithJitter(),
+		WithMaxAttempts(50),
+		WithSleepFunc(insomniac.sleep),
+	).Do(func(r *Retrier) error {
+		r.SetNextInterval(5 * time.Second)
+		return errDummy
+	})
+	assert.ErrorIs(t, err, errDummy)
+
+	for _, interval := range insomniac.sleepIntervals {
+		assert.Check(t, interval == 5*time.Second, "interval: %s", interval)
+	}
+}
+
+func TestSetNextInterval_WithJitteredOverrides_IsJittered(t *testing.T) {
+	t.Parallel()
+
+	insomniac := newInsomniac()
+	err := NewRetrier(
+		WithStrategy(Constant(1*time.Second)),
+		WithJitter(),
+		WithJitteredOverrides(),
+		WithMaxAttempts(50),
+		WithSleepFunc(insomniac.sleep),
+	).Do(func(r *Retrier) error {
+		if r.AttemptCount()%2 == 0 {
+			r.SetNextInterval(5 * time.Second)
+		}
+		return errDummy
+	})
+	assert.ErrorIs(t, err, errDummy)
+
+	jittered := 0
+	for i, interval := range insomniac.sleepIntervals {
+		nominal := 1 * time.Second
+		if i%2 == 0 {
+			nominal = 5 * time.Second
+		}
+		assert.Check(t, interval >= nominal && interval < nominal+defaultJitterInterval, "interval: %s", interval)
+		if interval != nominal {
+			jittered++
+		}
+	}
+	assert.Check(t, jittered > 0)
+}
+
+func TestSetNextInterval_WithJitteredOverrides_UsesJitterMode(t *testing.T) {
+	t.Parallel()
+
+	insomniac := newInsomniac()
+	err := NewRetrier(
+		WithStrategy(Constant(1*time.Second)),
+		WithJitter(EqualJitter),
+		WithJitteredOverrides(),
+		WithMaxAttempts(50),
+		WithSleepFunc(insomniac.sleep),
+	).Do(func(r *Retrier) error {
+		r.SetNextInterval(10 * time.Second)
+		return errDummy
+	})
+	assert.ErrorIs(t, err, errDummy)
+
+	for _, interval := range insomniac.sleepIntervals {
+		assert.Check(t, interval >= 5*time.Second && interval < 10*time.Second, "interval: %s", interval)
+	}
+}
@@ -0,0 +1,79 @@
+package roko
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// JSONEncoder writes attempt events to an io.Writer as JSON, one object per line, for ingestion by log pipelines. Pass
+// its Encode method to WithOnAttempt:
+//
+//	enc := roko.NewJSONEncoder(os.Stderr)
+//	r := roko.NewRetrier(roko.WithOnAttempt(enc.Encode), ...)
+//
+// Each line looks like:
+//
+//	{"time":"2022-06-01T12:00:01.5Z","name":"artifact-upload","id":"1f2e3d4c5b6a7980","attempt":2,"duration_seconds":0.25,"error":"connection refused","final":false,"next_interval_seconds":4}
+//
+// A JSONEncoder is safe to use from several retriers at once - lines from different events are never interleaved
+type JSONEncoder struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+	err error
+}
+
+// NewJSONEncoder returns a JSONEncoder that writes to w
+func NewJSONEncoder(w io.Writer) *JSONEncoder {
+	return &JSONEncoder{enc: json.NewEncoder(w)}
+}
+
+// jsonEvent is the JSON representation of an AttemptEvent
+type jsonEvent struct {
+	Time         string  `json:"time"`
+	Name         string  `json:"name,omitempty"`
+	ID           string  `json:"id"`
+	Attempt      int     `json:"attempt"`
+	Duration     float64 `json:"duration_seconds"`
+	Slept        float64 `json:"slept_seconds,omitempty"`
+	Error        string  `json:"error,omitempty"`
+	Final        bool    `json:"final"`
+	NextInterval float64 `json:"next_interval_seconds,omitempty"`
+	TraceID      string  `json:"trace_id,omitempty"`
+	SpanID       string  `json:"span_id,omitempty"`
+}
+
+// Encode writes e as a line of JSON. The time recorded is when the attempt finished. Errors writing to the underlying
+// writer are remembered rather than returned, as there's nothing a retrier could do with them - see Err
+func (j *JSONEncoder) Encode(e AttemptEvent) {
+	event := jsonEvent{
+		Time:         e.Start.Add(e.Duration).UTC().Format(time.RFC3339Nano),
+		Name:         e.Name,
+		ID:           e.ID,
+		Attempt:      e.Attempt,
+		Duration:     e.Duration.Seconds(),
+		Slept:        e.Slept.Seconds(),
+		Final:        e.Final,
+		NextInterval: e.NextInterval.Seconds(),
+		TraceID:      e.TraceID,
+		SpanID:       e.SpanID,
+	}
+	if e.Err != nil {
+		event.Error = e.Err.Error()
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if err := j.enc.Encode(event); err != nil && j.err == nil {
+		j.err = err
+	}
+}
+
+// Err returns the first error that happened writing an event, if there was one
+func (j *JSONEncoder) Err() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.err
+}
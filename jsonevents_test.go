@@ -0,0 +1,80 @@
+package roko
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestJSONEncoder_Encode(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	enc := NewJSONEncoder(&buf)
+
+	start := time.Date(2022, time.June, 1, 12, 0, 1, 250_000_000, time.UTC)
+	enc.Encode(AttemptEvent{
+		Name:         "artifact-upload",
+		ID:           "1f2e3d4c5b6a7980",
+		Attempt:      2,
+		Start:        start,
+		Duration:     250 * time.Millisecond,
+		Err:          errors.New("connection refused"),
+		NextInterval: 4 * time.Second,
+	})
+	enc.Encode(AttemptEvent{
+		ID:       "1f2e3d4c5b6a7980",
+		Attempt:  3,
+		Start:    start.Add(5 * time.Second),
+		Duration: time.Second,
+		Slept:    4 * time.Second,
+		Final:    true,
+		TraceID:  "4bf92f3577b34da6a3ce929d0e0e4736",
+		SpanID:   "00f067aa0ba902b7",
+	})
+
+	assert.NilError(t, enc.Err())
+	assert.Equal(t, strings.Join([]string{
+		`{"time":"2022-06-01T12:00:01.5Z","name":"artifact-upload","id":"1f2e3d4c5b6a7980","attempt":2,"duration_seconds":0.25,"error":"connection refused","final":false,"next_interval_seconds":4}`,
+		`{"time":"2022-06-01T12:00:07.25Z","id":"1f2e3d4c5b6a7980","attempt":3,"duration_seconds":1,"slept_seconds":4,"final":true,"trace_id":"4bf92f3577b34da6a3ce929d0e0e4736","span_id":"00f067aa0ba902b7"}`,
+		"",
+	}, "\n"), buf.String())
+}
+
+type failingWriter struct{}
+
+var errWrite = errors.New("disk full")
+
+func (failingWriter) Write([]byte) (int, error) { return 0, errWrite }
+
+func TestJSONEncoder_RemembersWriteErrors(t *testing.T) {
+	t.Parallel()
+
+	enc := NewJSONEncoder(failingWriter{})
+	enc.Encode(AttemptEvent{Attempt: 1})
+	assert.ErrorIs(t, enc.Err(), errWrite)
+}
+
+func TestJSONEncoder_WithRetrier(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	enc := NewJSONEncoder(&buf)
+
+	err := NewRetrier(
+		WithMaxAttempts(2),
+		WithStrategy(Constant(time.Second)),
+		WithSleepFunc(dummySleep),
+		WithOnAttempt(enc.Encode),
+	).Do(func(r *Retrier) error { return errDummy })
+	assert.ErrorIs(t, err, errDummy)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Equal(t, 2, len(lines))
+	assert.Assert(t, strings.Contains(lines[0], `"attempt":1`))
+	assert.Assert(t, strings.Contains(lines[1], `"final":true`))
+}
@@ -0,0 +1,150 @@
+// Package kafkaretry helps to retry producing messages to Kafka, telling the broker errors that are worth retrying (like
+// a leader election in progress) from the ones that aren't (like a message that's too large).
+//
+// It works with any Kafka client library, using the error codes from the Kafka protocol, which every client exposes.
+// Callers pass a CodeFunc that gets the code out of their client's errors. With sarama, for example:
+//
+//	func code(err error) (int16, bool) {
+//		var kerr sarama.KError
+//		if errors.As(err, &kerr) {
+//			return int16(kerr), true
+//		}
+//		return 0, false
+//	}
+//
+// and with franz-go:
+//
+//	func code(err error) (int16, bool) {
+//		var kerr *kerr.Error
+//		if errors.As(err, &kerr) {
+//			return kerr.Code, true
+//		}
+//		return 0, false
+//	}
+package kafkaretry
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/buildkite/roko"
+)
+
+// CodeFunc returns the Kafka protocol error code from an error returned by a client library, and whether it had one
+type CodeFunc func(err error) (code int16, ok bool)
+
+type kafkaError struct {
+	name      string
+	retriable bool
+}
+
+// kafkaErrors holds the protocol error codes that producers can get back, and whether the protocol documents them as
+// retriable
+var kafkaErrors = map[int16]kafkaError{
+	-1: {"UNKNOWN_SERVER_ERROR", false},
+	2:  {"CORRUPT_MESSAGE", true},
+	3:  {"UNKNOWN_TOPIC_OR_PARTITION", true},
+	5:  {"LEADER_NOT_AVAILABLE", true},
+	6:  {"NOT_LEADER_OR_FOLLOWER", true},
+	7:  {"REQUEST_TIMED_OUT", true},
+	8:  {"BROKER_NOT_AVAILABLE", false},
+	9:  {"REPLICA_NOT_AVAILABLE", true},
+	10: {"MESSAGE_TOO_LARGE", false},
+	13: {"NETWORK_EXCEPTION", true},
+	14: {"COORDINATOR_LOAD_IN_PROGRESS", true},
+	15: {"COORDINATOR_NOT_AVAILABLE", true},
+	16: {"NOT_COORDINATOR", true},
+	17: {"INVALID_TOPIC_EXCEPTION", false},
+	18: {"RECORD_LIST_TOO_LARGE", false},
+	19: {"NOT_ENOUGH_REPLICAS", true},
+	20: {"NOT_ENOUGH_REPLICAS_AFTER_APPEND", true},
+	21: {"INVALID_REQUIRED_ACKS", false},
+	29: {"TOPIC_AUTHORIZATION_FAILED", false},
+	31: {"CLUSTER_AUTHORIZATION_FAILED", false},
+	32: {"INVALID_TIMESTAMP", false},
+	41: {"NOT_CONTROLLER", true},
+	45: {"OUT_OF_ORDER_SEQUENCE_NUMBER", false},
+	46: {"DUPLICATE_SEQUENCE_NUMBER", false},
+	47: {"INVALID_PRODUCER_EPOCH", false},
+	51: {"CONCURRENT_TRANSACTIONS", true},
+	56: {"KAFKA_STORAGE_ERROR", true},
+	74: {"FENCED_LEADER_EPOCH", true},
+	75: {"UNKNOWN_LEADER_EPOCH", true},
+	76: {"UNSUPPORTED_COMPRESSION_TYPE", false},
+	87: {"INVALID_RECORD", false},
+	89: {"THROTTLING_QUOTA_EXCEEDED", true},
+	90: {"PRODUCER_FENCED", false},
+}
+
+// Retriable returns whether a Kafka protocol error code is one that's worth retrying: a temporary state of the
+// cluster, such as a leader election or a broker restarting, rather than a problem with the message or the producer.
+// Codes it doesn't know about aren't retriable
+func Retriable(code int16) bool {
+	return kafkaErrors[code].retriable
+}
+
+// CodeName returns the protocol's name for a Kafka error code, such as "MESSAGE_TOO_LARGE", or a placeholder with the
+// number in it for codes it doesn't know about
+func CodeName(code int16) string {
+	if e, ok := kafkaErrors[code]; ok {
+		return e.name
+	}
+	return fmt.Sprintf("error code %d", code)
+}
+
+// Classify turns an error from producing a message into one suitable for returning from a retrier's callback. Errors
+// with a Kafka error code that isn't Retriable are wrapped with roko.Unrecoverable, and all other errors (including
+// ones without an error code, such as network errors) are returned as they are, to be retried
+func Classify(err error, codeOf CodeFunc) error {
+	if err == nil {
+		return nil
+	}
+
+	if code, ok := codeOf(err); ok && !Retriable(code) {
+		return roko.Unrecoverable(fmt.Errorf("%s: %w", CodeName(code), err))
+	}
+	return err
+}
+
+// DeliveryError is returned by Deliver when a message couldn't be delivered
+type DeliveryError struct {
+	Deliveries int   // The number of times the message was sent
+	Err        error // The error from the last delivery
+}
+
+func (e *DeliveryError) Error() string {
+	return fmt.Sprintf("message not delivered after %d attempts: %v", e.Deliveries, e.Err)
+}
+
+func (e *DeliveryError) Unwrap() error {
+	return e.Err
+}
+
+// Deliver sends a message using send, retrying retriable failures (see Classify) with r. The retrier's maximum
+// attempt count bounds the number of times the message is sent. Unless the producer is idempotent, a message whose
+// delivery timed out may actually have been written, so retries can produce duplicates.
+//
+// If the message can't be delivered, Deliver returns a *DeliveryError
+func Deliver(ctx context.Context, r *roko.Retrier, codeOf CodeFunc, send func(ctx context.Context) error) error {
+	deliveries := 0
+	err := r.DoWithContext(ctx, func(r *roko.Retrier) error {
+		deliveries++
+		return Classify(send(r.Context()), codeOf)
+	})
+	if err != nil {
+		return &DeliveryError{Deliveries: deliveries, Err: err}
+	}
+	return nil
+}
+
+// NewRetrier returns a retrier suitable for delivering a single message, sending it at most maxDeliveries times, with
+// exponential backoff and jitter between them. The waits start at a quarter of a second, and grow slowly enough to ride
+// out a leader election, which usually takes a few seconds
+func NewRetrier(maxDeliveries int) *roko.Retrier {
+	return roko.NewRetrier(
+		roko.WithMaxAttempts(maxDeliveries),
+		roko.WithStrategy(roko.ExponentialSubsecond(250*time.Millisecond)),
+		roko.WithJitter(roko.EqualJitter),
+	)
+}
@@ -0,0 +1,118 @@
+package kafkaretry
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/buildkite/roko"
+	"gotest.tools/v3/assert"
+)
+
+// brokerError stands in for a client library's error type
+type brokerError int16
+
+func (e brokerError) Error() string { return "broker error" }
+
+func code(err error) (int16, bool) {
+	var berr brokerError
+	if errors.As(err, &berr) {
+		return int16(berr), true
+	}
+	return 0, false
+}
+
+func newTestRetrier(maxDeliveries int) *roko.Retrier {
+	return roko.NewRetrier(
+		roko.WithMaxAttempts(maxDeliveries),
+		roko.WithStrategy(roko.Constant(time.Second)),
+		roko.WithSleepFunc(func(time.Duration) {}),
+	)
+}
+
+func TestRetriable(t *testing.T) {
+	t.Parallel()
+
+	assert.Check(t, Retriable(6))   // NOT_LEADER_OR_FOLLOWER
+	assert.Check(t, Retriable(7))   // REQUEST_TIMED_OUT
+	assert.Check(t, !Retriable(10)) // MESSAGE_TOO_LARGE
+	assert.Check(t, !Retriable(29)) // TOPIC_AUTHORIZATION_FAILED
+	assert.Check(t, !Retriable(999))
+}
+
+func TestCodeName(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, CodeName(10), "MESSAGE_TOO_LARGE")
+	assert.Equal(t, CodeName(999), "error code 999")
+}
+
+func TestClassify(t *testing.T) {
+	t.Parallel()
+
+	assert.NilError(t, Classify(nil, code))
+
+	errNetwork := errors.New("connection reset by peer")
+	assert.Equal(t, Classify(errNetwork, code), errNetwork)
+
+	leaderElection := brokerError(6)
+	assert.Equal(t, Classify(leaderElection, code), error(leaderElection))
+
+	err := Classify(brokerError(10), code)
+	assert.ErrorIs(t, err, roko.ErrUnrecoverable)
+	assert.ErrorIs(t, err, brokerError(10))
+	assert.ErrorContains(t, err, "MESSAGE_TOO_LARGE")
+}
+
+func TestDeliver_RetriesRetriableErrors(t *testing.T) {
+	t.Parallel()
+
+	sends := 0
+	err := Deliver(context.Background(), newTestRetrier(5), code, func(ctx context.Context) error {
+		sends++
+		if sends < 3 {
+			return brokerError(5) // LEADER_NOT_AVAILABLE
+		}
+		return nil
+	})
+
+	assert.NilError(t, err)
+	assert.Equal(t, sends, 3)
+}
+
+func TestDeliver_GivesUpOnPermanentErrors(t *testing.T) {
+	t.Parallel()
+
+	sends := 0
+	err := Deliver(context.Background(), newTestRetrier(5), code, func(ctx context.Context) error {
+		sends++
+		return brokerError(10)
+	})
+
+	var derr *DeliveryError
+	assert.Assert(t, errors.As(err, &derr))
+	assert.Equal(t, derr.Deliveries, 1)
+	assert.ErrorIs(t, err, brokerError(10))
+	assert.Equal(t, sends, 1)
+}
+
+func TestDeliver_StopsAtTheMaximumDeliveries(t *testing.T) {
+	t.Parallel()
+
+	sends := 0
+	err := Deliver(context.Background(), newTestRetrier(4), code, func(ctx context.Context) error {
+		sends++
+		return brokerError(7)
+	})
+
+	assert.Error(t, err, "message not delivered after 4 attempts: broker error")
+	assert.Equal(t, sends, 4)
+}
+
+func TestNewRetrier(t *testing.T) {
+	t.Parallel()
+
+	r := NewRetrier(6)
+	assert.Equal(t, len(r.PlannedIntervals(10)), 5)
+}
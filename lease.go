@@ -0,0 +1,102 @@
+package roko
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrLeaseLost is returned by Lease.Err when a lease couldn't be renewed before it expired
+var ErrLeaseLost = errors.New("lease lost")
+
+// Lease keeps a lease, such as a lock with a TTL, renewed in the background - see KeepRenewed
+type Lease struct {
+	done chan struct{}
+
+	mu     sync.Mutex
+	expiry time.Time
+	err    error
+}
+
+// KeepRenewed keeps a lease that expires at expiry renewed, until ctx is cancelled or the lease is lost. renew should
+// renew the lease, and return its new expiry.
+//
+// Each renewal happens halfway between the time it's scheduled and the lease's expiry, leaving the other half of the
+// remaining time to retry the renewal if it fails. Failed renewals are retried using a new retrier from newRetrier for
+// each renewal, with the retrier's loop (and the context passed to renew) bounded by the lease's current expiry - there's
+// no point carrying on after the lease has expired, as it might have been taken by someone else by then. Note that this
+// means that every successful renewal moves the deadline for the next one.
+//
+// The lease is lost if it expires before it can be renewed, if the retrier gives up, or if renew returns an error
+// wrapped with Unrecoverable (for example, because someone else holds the lease now). When it is, or when ctx is
+// cancelled, the Lease's Done channel is closed, and Err says why
+func KeepRenewed(ctx context.Context, expiry time.Time, newRetrier func() *Retrier, renew func(ctx context.Context) (time.Time, error)) *Lease {
+	l := &Lease{done: make(chan struct{}), expiry: expiry}
+	go l.run(ctx, newRetrier, renew)
+	return l
+}
+
+// Done returns a channel that's closed when the lease stops being renewed, either because it's been lost, or because
+// the context passed to KeepRenewed was cancelled
+func (l *Lease) Done() <-chan struct{} {
+	return l.done
+}
+
+// Err returns nil while the lease is being renewed. After Done is closed, it returns an error wrapping ErrLeaseLost if
+// the lease was lost, or the context's error if the context passed to KeepRenewed was cancelled
+func (l *Lease) Err() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.err
+}
+
+// Expiry returns the time the lease is currently due to expire
+func (l *Lease) Expiry() time.Time {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.expiry
+}
+
+func (l *Lease) run(ctx context.Context, newRetrier func() *Retrier, renew func(ctx context.Context) (time.Time, error)) {
+	defer close(l.done)
+
+	for {
+		expiry := l.Expiry()
+
+		t := time.NewTimer(time.Until(expiry) / 2)
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			l.stop(ctx.Err())
+			return
+		}
+
+		renewCtx, cancel := context.WithDeadline(ctx, expiry)
+		newExpiry, err := DoFunc(renewCtx, newRetrier(), func(r *Retrier) (time.Time, error) {
+			return renew(r.Context())
+		})
+		cancel()
+
+		switch {
+		case ctx.Err() != nil:
+			l.stop(ctx.Err())
+			return
+		case err != nil:
+			l.stop(fmt.Errorf("%w: %v", ErrLeaseLost, err))
+			return
+		}
+
+		l.mu.Lock()
+		l.expiry = newExpiry
+		l.mu.Unlock()
+	}
+}
+
+func (l *Lease) stop(err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.err = err
+}
@@ -0,0 +1,78 @@
+package roko
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+const testLeaseTTL = 100 * time.Millisecond
+
+func TestKeepRenewed_RenewsUntilCancelled(t *testing.T) {
+	t.Parallel()
+
+	var renewals int32
+	ctx, cancel := context.WithCancel(context.Background())
+
+	lease := KeepRenewed(ctx, time.Now().Add(testLeaseTTL), func() *Retrier {
+		return NewRetrier(WithMaxAttempts(3), WithStrategy(Constant(10*time.Millisecond)))
+	}, func(ctx context.Context) (time.Time, error) {
+		atomic.AddInt32(&renewals, 1)
+		return time.Now().Add(testLeaseTTL), nil
+	})
+
+	time.Sleep(3 * testLeaseTTL)
+	assert.NilError(t, lease.Err())
+	assert.Assert(t, lease.Expiry().After(time.Now()))
+
+	cancel()
+	<-lease.Done()
+
+	assert.ErrorIs(t, lease.Err(), context.Canceled)
+	assert.Assert(t, atomic.LoadInt32(&renewals) >= 3)
+}
+
+func TestKeepRenewed_WhenRenewalsFail_LosesTheLeaseAtExpiry(t *testing.T) {
+	t.Parallel()
+
+	errConn := errors.New("connection refused")
+	expiry := time.Now().Add(testLeaseTTL)
+
+	lease := KeepRenewed(context.Background(), expiry, func() *Retrier {
+		return NewRetrier(TryForever(), WithStrategy(Constant(10*time.Millisecond)))
+	}, func(ctx context.Context) (time.Time, error) {
+		return time.Time{}, errConn
+	})
+
+	<-lease.Done()
+
+	assert.ErrorIs(t, lease.Err(), ErrLeaseLost)
+	assert.Assert(t, !time.Now().Before(expiry))
+}
+
+func TestKeepRenewed_WhenRenewalIsUnrecoverable_LosesTheLeaseStraightAway(t *testing.T) {
+	t.Parallel()
+
+	errTaken := errors.New("lease is held by another process")
+	var renewals int32
+	var expiry time.Time
+	lease := KeepRenewed(context.Background(), time.Now().Add(testLeaseTTL), func() *Retrier {
+		return NewRetrier(TryForever(), WithStrategy(Constant(10*time.Millisecond)))
+	}, func(ctx context.Context) (time.Time, error) {
+		if atomic.AddInt32(&renewals, 1) == 1 {
+			expiry = time.Now().Add(testLeaseTTL)
+			return expiry, nil
+		}
+		return time.Time{}, Unrecoverable(errTaken)
+	})
+
+	<-lease.Done()
+
+	assert.ErrorIs(t, lease.Err(), ErrLeaseLost)
+	assert.ErrorContains(t, lease.Err(), errTaken.Error())
+	assert.Equal(t, expiry, lease.Expiry())
+}
@@ -0,0 +1,133 @@
+// Package mongoretry helps to retry MongoDB transactions, following the pattern the MongoDB drivers document: the whole
+// transaction is retried when it fails with an error labelled TransientTransactionError, but only the commit is retried
+// when the commit fails with one labelled UnknownTransactionCommitResult, since running the transaction again could
+// apply it twice.
+//
+// It doesn't depend on the MongoDB driver. Errors are checked for labels using the HasErrorLabel method that the
+// driver's server errors have.
+package mongoretry
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/buildkite/roko"
+)
+
+// The error labels that MongoDB servers and drivers attach to errors that are worth retrying
+const (
+	// TransientTransactionError means that the transaction as a whole can be retried, from the start
+	TransientTransactionError = "TransientTransactionError"
+
+	// UnknownTransactionCommitResult means that a commit might or might not have been applied, and that the commit
+	// (but not the rest of the transaction) can be retried
+	UnknownTransactionCommitResult = "UnknownTransactionCommitResult"
+)
+
+// labelled is implemented by the MongoDB driver's server errors, such as mongo.CommandError and mongo.WriteException
+type labelled interface {
+	HasErrorLabel(label string) bool
+}
+
+// HasLabel reports whether err, or any error it wraps, has the given MongoDB error label
+func HasLabel(err error, label string) bool {
+	for err != nil {
+		var l labelled
+		if !errors.As(err, &l) {
+			return false
+		}
+		if l.HasErrorLabel(label) {
+			return true
+		}
+		err = errors.Unwrap(l.(error))
+	}
+	return false
+}
+
+type transactionOpt func(*transactionConfig)
+
+type transactionConfig struct {
+	newCommitRetrier func() *roko.Retrier
+}
+
+// WithCommitRetrier sets where Transaction gets the retrier it uses to retry commits whose result is unknown. Each
+// commit gets a new retrier. The default is NewCommitRetrier
+func WithCommitRetrier(newRetrier func() *roko.Retrier) transactionOpt {
+	return func(c *transactionConfig) {
+		c.newCommitRetrier = newRetrier
+	}
+}
+
+// Transaction runs a MongoDB transaction, retrying it with r. body should start the transaction and do its work, commit
+// should commit it, and abort should abort it. With the Go driver, for example:
+//
+//	err := mongoretry.Transaction(ctx, r,
+//		func(ctx context.Context) error {
+//			if err := sess.StartTransaction(); err != nil {
+//				return err
+//			}
+//			return transfer(mongo.NewSessionContext(ctx, sess))
+//		},
+//		sess.CommitTransaction,
+//		sess.AbortTransaction,
+//	)
+//
+// When body fails, the transaction is aborted, and then started again if the error is labelled
+// TransientTransactionError. When commit fails with an error labelled UnknownTransactionCommitResult, only the commit
+// is retried, using a retrier from WithCommitRetrier. If it fails with one labelled TransientTransactionError instead,
+// the whole transaction is retried. All other errors are returned without any more retries
+func Transaction(ctx context.Context, r *roko.Retrier, body, commit, abort func(ctx context.Context) error, opts ...transactionOpt) error {
+	c := &transactionConfig{newCommitRetrier: NewCommitRetrier}
+	for _, o := range opts {
+		o(c)
+	}
+
+	return r.DoWithContext(ctx, func(r *roko.Retrier) error {
+		if err := body(r.Context()); err != nil {
+			// The transaction has to be aborted before another can be started, but the abort failing doesn't change
+			// whether the transaction is worth retrying
+			_ = abort(ctx)
+			return classify(err)
+		}
+
+		err := c.newCommitRetrier().DoWithContext(ctx, func(cr *roko.Retrier) error {
+			err := commit(cr.Context())
+			if err != nil && !HasLabel(err, UnknownTransactionCommitResult) {
+				cr.Break()
+			}
+			return err
+		})
+		return classify(err)
+	})
+}
+
+// classify returns err as it is if the transaction it came from can be retried, and wraps it with roko.Unrecoverable
+// otherwise
+func classify(err error) error {
+	if err == nil || HasLabel(err, TransientTransactionError) {
+		return err
+	}
+	return roko.Unrecoverable(err)
+}
+
+// NewRetrier returns a retrier suitable for a whole transaction. It makes up to 5 attempts, with short, jittered waits
+// between them, since transient transaction errors are usually write conflicts with other transactions, which clear up
+// quickly
+func NewRetrier() *roko.Retrier {
+	return roko.NewRetrier(
+		roko.WithMaxAttempts(5),
+		roko.WithStrategy(roko.ExponentialSubsecond(50*time.Millisecond)),
+		roko.WithJitter(roko.FullJitter.AtLeast(0.5)),
+	)
+}
+
+// NewCommitRetrier returns a retrier suitable for retrying a commit whose result is unknown. It makes up to 5 attempts,
+// waiting exponentially longer between each, since an unknown result usually means a primary is being elected
+func NewCommitRetrier() *roko.Retrier {
+	return roko.NewRetrier(
+		roko.WithMaxAttempts(5),
+		roko.WithStrategy(roko.ExponentialSubsecond(250*time.Millisecond)),
+		roko.WithJitter(roko.EqualJitter),
+	)
+}
@@ -0,0 +1,139 @@
+package mongoretry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/buildkite/roko"
+	"gotest.tools/v3/assert"
+)
+
+// serverError stands in for the driver's mongo.CommandError
+type serverError struct {
+	msg    string
+	labels []string
+}
+
+func (e *serverError) Error() string { return e.msg }
+
+func (e *serverError) HasErrorLabel(label string) bool {
+	for _, l := range e.labels {
+		if l == label {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	errWriteConflict = &serverError{"WriteConflict", []string{TransientTransactionError}}
+	errCommitUnknown = &serverError{"connection reset during commit", []string{UnknownTransactionCommitResult}}
+	errDuplicateKey  = &serverError{"E11000 duplicate key error", nil}
+)
+
+func newTestRetrier() *roko.Retrier {
+	return roko.NewRetrier(
+		roko.WithMaxAttempts(3),
+		roko.WithStrategy(roko.Constant(time.Second)),
+		roko.WithSleepFunc(func(time.Duration) {}),
+	)
+}
+
+// txn records what happened to a transaction
+type txn struct {
+	bodies, commits, aborts int
+	bodyErrs, commitErrs    []error
+}
+
+func (tx *txn) run(ctx context.Context) error {
+	return Transaction(ctx, newTestRetrier(),
+		func(context.Context) error {
+			tx.bodies++
+			return next(&tx.bodyErrs)
+		},
+		func(context.Context) error {
+			tx.commits++
+			return next(&tx.commitErrs)
+		},
+		func(context.Context) error {
+			tx.aborts++
+			return nil
+		},
+		WithCommitRetrier(newTestRetrier),
+	)
+}
+
+func next(errs *[]error) error {
+	if len(*errs) == 0 {
+		return nil
+	}
+	err := (*errs)[0]
+	*errs = (*errs)[1:]
+	return err
+}
+
+func TestHasLabel(t *testing.T) {
+	t.Parallel()
+
+	assert.Check(t, HasLabel(errWriteConflict, TransientTransactionError))
+	assert.Check(t, HasLabel(fmt.Errorf("transfer: %w", errWriteConflict), TransientTransactionError))
+	assert.Check(t, !HasLabel(errWriteConflict, UnknownTransactionCommitResult))
+	assert.Check(t, !HasLabel(errors.New("plain"), TransientTransactionError))
+	assert.Check(t, !HasLabel(nil, TransientTransactionError))
+}
+
+func TestTransaction_RetriesTheWholeTransactionOnTransientErrors(t *testing.T) {
+	t.Parallel()
+
+	tx := &txn{bodyErrs: []error{errWriteConflict}}
+	assert.NilError(t, tx.run(context.Background()))
+	assert.Equal(t, tx.bodies, 2)
+	assert.Equal(t, tx.aborts, 1)
+	assert.Equal(t, tx.commits, 1)
+}
+
+func TestTransaction_RetriesOnlyTheCommitWhenItsResultIsUnknown(t *testing.T) {
+	t.Parallel()
+
+	tx := &txn{commitErrs: []error{errCommitUnknown, errCommitUnknown}}
+	assert.NilError(t, tx.run(context.Background()))
+	assert.Equal(t, tx.bodies, 1)
+	assert.Equal(t, tx.commits, 3)
+	assert.Equal(t, tx.aborts, 0)
+}
+
+func TestTransaction_RetriesTheWholeTransactionWhenTheCommitIsTransient(t *testing.T) {
+	t.Parallel()
+
+	tx := &txn{commitErrs: []error{errWriteConflict}}
+	assert.NilError(t, tx.run(context.Background()))
+	assert.Equal(t, tx.bodies, 2)
+	assert.Equal(t, tx.commits, 2)
+}
+
+func TestTransaction_GivesUpOnOtherErrors(t *testing.T) {
+	t.Parallel()
+
+	tx := &txn{bodyErrs: []error{errDuplicateKey}}
+	assert.ErrorIs(t, tx.run(context.Background()), errDuplicateKey)
+	assert.Equal(t, tx.bodies, 1)
+	assert.Equal(t, tx.aborts, 1)
+	assert.Equal(t, tx.commits, 0)
+
+	tx = &txn{commitErrs: []error{errDuplicateKey}}
+	assert.ErrorIs(t, tx.run(context.Background()), errDuplicateKey)
+	assert.Equal(t, tx.bodies, 1)
+	assert.Equal(t, tx.commits, 1)
+}
+
+func TestTransaction_GivesUpWhenTheCommitResultStaysUnknown(t *testing.T) {
+	t.Parallel()
+
+	tx := &txn{commitErrs: []error{errCommitUnknown, errCommitUnknown, errCommitUnknown}}
+	assert.ErrorIs(t, tx.run(context.Background()), errCommitUnknown)
+	assert.Equal(t, tx.bodies, 1)
+	assert.Equal(t, tx.commits, 3)
+}
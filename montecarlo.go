@@ -0,0 +1,170 @@
+package roko
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"time"
+)
+
+// errSimulatedFailure is what a simulated operation fails with
+var errSimulatedFailure = errors.New("roko: simulated failure")
+
+// simulationEpoch is when every trial's simulated clock starts. It's a Monday morning, so that policies that depend on
+// the time of day (such as WithBusinessHours) can be simulated, and get the same results every time
+var simulationEpoch = time.Date(2024, time.January, 1, 9, 0, 0, 0, time.UTC)
+
+// FailureModel describes how a simulated operation behaves, for Simulate
+type FailureModel struct {
+	// SuccessProbability is the chance that each attempt succeeds, between 0 and 1. Attempts are independent
+	SuccessProbability float64
+
+	// Latency returns how long an attempt takes, using rng for any randomness, so that trials can be reproduced. If it's
+	// nil, attempts take no time at all. See UniformLatency and ExponentialLatency
+	Latency func(rng *rand.Rand) time.Duration
+}
+
+// UniformLatency returns a latency distribution for a FailureModel where every duration between min and max is equally
+// likely
+func UniformLatency(min, max time.Duration) func(*rand.Rand) time.Duration {
+	if min < 0 || max < min {
+		panic("uniform latencies must have 0 <= min <= max")
+	}
+
+	return func(rng *rand.Rand) time.Duration {
+		return min + time.Duration(rng.Float64()*float64(max-min))
+	}
+}
+
+// ExponentialLatency returns a latency distribution for a FailureModel with the given mean, where most attempts are
+// quick, but some take much longer - a rough model of a service with a long tail of slow requests
+func ExponentialLatency(mean time.Duration) func(*rand.Rand) time.Duration {
+	if mean < 0 {
+		panic("exponential latencies can't have a negative mean")
+	}
+
+	return func(rng *rand.Rand) time.Duration {
+		return time.Duration(rng.ExpFloat64() * float64(mean))
+	}
+}
+
+// SimulationResult holds the outcomes of all of the trials run by Simulate
+type SimulationResult struct {
+	Trials    int
+	Successes int // The number of trials in which the operation eventually succeeded
+
+	// TotalTimes are how long each trial took, from the start of its first attempt to the end of its last, including
+	// the waits between them, in ascending order
+	TotalTimes []time.Duration
+
+	// Attempts are how many attempts each trial made, in ascending order
+	Attempts []int
+}
+
+// SuccessRate returns the fraction of trials in which the operation eventually succeeded
+func (s SimulationResult) SuccessRate() float64 {
+	if s.Trials == 0 {
+		return 0
+	}
+	return float64(s.Successes) / float64(s.Trials)
+}
+
+// TotalTime returns the pth percentile (between 0 and 100) of the trials' total times
+func (s SimulationResult) TotalTime(p float64) time.Duration {
+	if len(s.TotalTimes) == 0 {
+		return 0
+	}
+	return s.TotalTimes[percentileIndex(p, len(s.TotalTimes))]
+}
+
+// AttemptCount returns the pth percentile (between 0 and 100) of the number of attempts the trials made
+func (s SimulationResult) AttemptCount(p float64) int {
+	if len(s.Attempts) == 0 {
+		return 0
+	}
+	return s.Attempts[percentileIndex(p, len(s.Attempts))]
+}
+
+// percentileIndex returns the index of the pth percentile in a sorted slice of n values, using the nearest rank
+func percentileIndex(p float64, n int) int {
+	if p < 0 || p > 100 {
+		panic("percentiles must be between 0 and 100")
+	}
+
+	i := int(math.Ceil(p/100*float64(n))) - 1
+	if i < 0 {
+		return 0
+	}
+	return i
+}
+
+// String summarises the result, such as "1000 trials, 99.2% succeeded, total time p50 1.2s p90 3.5s p99 9.8s,
+// attempts p50 1 p90 3 p99 5"
+func (s SimulationResult) String() string {
+	return fmt.Sprintf("%d trials, %.1f%% succeeded, total time p50 %s p90 %s p99 %s, attempts p50 %d p90 %d p99 %d",
+		s.Trials, s.SuccessRate()*100,
+		s.TotalTime(50).Round(time.Millisecond), s.TotalTime(90).Round(time.Millisecond), s.TotalTime(99).Round(time.Millisecond),
+		s.AttemptCount(50), s.AttemptCount(90), s.AttemptCount(99))
+}
+
+// Simulate runs trials Monte Carlo trials of the retry policy described by opts against an operation that behaves as
+// model says, and reports how long the trials took, and how many attempts they made. Each trial gets a new retrier,
+// created with opts and WithSimulation, so no time passes for real, and WithoutDefaultHooks, so that simulated attempts
+// don't show up in real telemetry:
+//
+//	result := roko.Simulate(roko.FailureModel{
+//		SuccessProbability: 0.7,
+//		Latency:            roko.ExponentialLatency(200 * time.Millisecond),
+//	}, 10000, 1,
+//		roko.WithMaxAttempts(5),
+//		roko.WithStrategy(roko.Exponential(2*time.Second, 0)),
+//		roko.WithJitter(roko.FullJitter),
+//	)
+//	fmt.Println(result.TotalTime(99))
+//
+// The same seed always gives the same result. Simulate panics if model's SuccessProbability isn't between 0 and 1, and
+// a policy that tries forever should have a total sleep limit, unless every attempt has some chance of succeeding
+func Simulate(model FailureModel, trials int, seed int64, opts ...retrierOpt) SimulationResult {
+	if model.SuccessProbability < 0 || model.SuccessProbability > 1 {
+		panic("failure models must have a success probability between 0 and 1")
+	}
+	if trials <= 0 {
+		panic("simulations must run at least one trial")
+	}
+
+	// Both the failure model and the seed for each trial's jitter come from seed, so the whole simulation is repeatable
+	rng := rand.New(rand.NewSource(seed))
+
+	result := SimulationResult{
+		Trials:     trials,
+		TotalTimes: make([]time.Duration, 0, trials),
+		Attempts:   make([]int, 0, trials),
+	}
+	for i := 0; i < trials; i++ {
+		clock := NewSimulatedClock(simulationEpoch)
+		r := NewRetrier(append(opts[:len(opts):len(opts)], WithSimulation(rng.Int63(), clock), WithoutDefaultHooks())...)
+
+		err := r.DoWithContext(context.Background(), func(*Retrier) error {
+			if model.Latency != nil {
+				clock.Advance(model.Latency(rng))
+			}
+			if rng.Float64() < model.SuccessProbability {
+				return nil
+			}
+			return errSimulatedFailure
+		})
+
+		if err == nil {
+			result.Successes++
+		}
+		result.TotalTimes = append(result.TotalTimes, clock.Now().Sub(simulationEpoch))
+		result.Attempts = append(result.Attempts, r.Attempts())
+	}
+
+	sort.Slice(result.TotalTimes, func(i, j int) bool { return result.TotalTimes[i] < result.TotalTimes[j] })
+	sort.Ints(result.Attempts)
+	return result
+}
@@ -0,0 +1,106 @@
+package roko
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestSimulate_AlwaysSucceeding(t *testing.T) {
+	t.Parallel()
+
+	result := Simulate(FailureModel{SuccessProbability: 1}, 100, 1,
+		WithMaxAttempts(3),
+		WithStrategy(Constant(time.Second)),
+	)
+
+	assert.Equal(t, 100, result.Trials)
+	assert.Equal(t, 100, result.Successes)
+	assert.Equal(t, 1.0, result.SuccessRate())
+	assert.Equal(t, 1, result.AttemptCount(100))
+	assert.Equal(t, time.Duration(0), result.TotalTime(100))
+}
+
+func TestSimulate_AlwaysFailing(t *testing.T) {
+	t.Parallel()
+
+	result := Simulate(FailureModel{
+		SuccessProbability: 0,
+		Latency:            UniformLatency(100*time.Millisecond, 100*time.Millisecond),
+	}, 10, 1,
+		WithMaxAttempts(3),
+		WithStrategy(Constant(time.Second)),
+	)
+
+	assert.Equal(t, 0, result.Successes)
+	assert.Equal(t, 3, result.AttemptCount(0))
+	assert.Equal(t, 3, result.AttemptCount(100))
+
+	// Three attempts of 100ms each, with two waits of a second between them
+	assert.Equal(t, 2300*time.Millisecond, result.TotalTime(0))
+	assert.Equal(t, 2300*time.Millisecond, result.TotalTime(100))
+}
+
+func TestSimulate_MatchesTheProbabilities(t *testing.T) {
+	t.Parallel()
+
+	result := Simulate(FailureModel{SuccessProbability: 0.5}, 10000, 42,
+		WithMaxAttempts(4),
+		WithStrategy(Constant(time.Second)),
+	)
+
+	// Each trial fails only if all 4 attempts fail, which happens 1 time in 16, and half of the trials succeed first time
+	assert.Check(t, math.Abs(result.SuccessRate()-15.0/16) < 0.01, "success rate %f", result.SuccessRate())
+	assert.Equal(t, 1, result.AttemptCount(40))
+	assert.Equal(t, 4, result.AttemptCount(99))
+	assert.Equal(t, time.Duration(0), result.TotalTime(40))
+	assert.Equal(t, 3*time.Second, result.TotalTime(99))
+}
+
+func TestSimulate_IsRepeatable(t *testing.T) {
+	t.Parallel()
+
+	run := func(seed int64) SimulationResult {
+		return Simulate(FailureModel{
+			SuccessProbability: 0.3,
+			Latency:            ExponentialLatency(200 * time.Millisecond),
+		}, 500, seed,
+			WithMaxAttempts(6),
+			WithStrategy(Exponential(2*time.Second, 0)),
+			WithJitter(FullJitter),
+		)
+	}
+
+	assert.DeepEqual(t, run(7), run(7))
+	assert.Check(t, run(7).String() != run(8).String())
+}
+
+func TestSimulationResult_Percentiles(t *testing.T) {
+	t.Parallel()
+
+	result := SimulationResult{
+		Trials:     4,
+		Successes:  3,
+		TotalTimes: []time.Duration{1 * time.Second, 2 * time.Second, 3 * time.Second, 10 * time.Second},
+		Attempts:   []int{1, 1, 2, 5},
+	}
+
+	assert.Equal(t, 1*time.Second, result.TotalTime(0))
+	assert.Equal(t, 2*time.Second, result.TotalTime(50))
+	assert.Equal(t, 3*time.Second, result.TotalTime(75))
+	assert.Equal(t, 10*time.Second, result.TotalTime(90))
+	assert.Equal(t, 2, result.AttemptCount(75))
+	assert.Equal(t, 0.75, result.SuccessRate())
+	assert.Equal(t, "4 trials, 75.0% succeeded, total time p50 2s p90 10s p99 10s, attempts p50 1 p90 5 p99 5", result.String())
+
+	assert.Equal(t, time.Duration(0), SimulationResult{}.TotalTime(50))
+}
+
+func TestSimulate_PanicsOnBadModels(t *testing.T) {
+	t.Parallel()
+	defer func() { assert.Assert(t, recover() != nil) }()
+
+	Simulate(FailureModel{SuccessProbability: 1.5}, 10, 1, WithMaxAttempts(3))
+}
@@ -0,0 +1,33 @@
+package roko
+
+import "fmt"
+
+// NamedError is returned by a named retrier's Do and DoWithContext loops when they fail, so that the error says which
+// retrier it came from. See WithName
+type NamedError struct {
+	Name string // The name of the retrier
+	Err  error  // The error the loop failed with
+}
+
+func (e *NamedError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Name, e.Err)
+}
+
+func (e *NamedError) Unwrap() error {
+	return e.Err
+}
+
+// WithName gives the retrier a name, such as "artifact-upload", which identifies it in its AttemptEvents (and so in
+// anything built on them, like logs and metrics), and in the errors returned by its loops, which are wrapped in a
+// *NamedError. Giving each retry policy in a program a stable name makes it possible to tell them apart when their
+// events are aggregated
+func WithName(name string) retrierOpt {
+	return func(r *Retrier) {
+		r.name = name
+	}
+}
+
+// Name returns the retrier's name, or "" if it doesn't have one
+func (r *Retrier) Name() string {
+	return r.name
+}
@@ -0,0 +1,74 @@
+package roko
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestWithName_WrapsErrors(t *testing.T) {
+	t.Parallel()
+
+	r := NewRetrier(
+		WithName("artifact-upload"),
+		WithMaxAttempts(2),
+		WithStrategy(Constant(time.Second)),
+		WithSleepFunc(dummySleep),
+	)
+	assert.Equal(t, "artifact-upload", r.Name())
+
+	err := r.Do(func(r *Retrier) error { return errDummy })
+	assert.Error(t, err, "artifact-upload: this makes it retry")
+	assert.ErrorIs(t, err, errDummy)
+
+	var named *NamedError
+	assert.Assert(t, errors.As(err, &named))
+	assert.Equal(t, "artifact-upload", named.Name)
+}
+
+func TestWithName_KeepsUnrecoverableAndContextErrorsRecognisable(t *testing.T) {
+	t.Parallel()
+
+	r := NewRetrier(WithName("fetch"), WithMaxAttempts(3), WithStrategy(Constant(time.Hour)))
+	err := r.Do(func(r *Retrier) error { return Unrecoverable(errDummy) })
+	assert.ErrorIs(t, err, ErrUnrecoverable)
+	assert.ErrorIs(t, err, errDummy)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r = NewRetrier(WithName("fetch"), WithMaxAttempts(3), WithStrategy(Constant(time.Hour)))
+	err = r.DoWithContext(ctx, func(r *Retrier) error {
+		cancel()
+		return errDummy
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestWithName_SuccessIsNil(t *testing.T) {
+	t.Parallel()
+
+	r := NewRetrier(WithName("fetch"), NoRetry())
+	assert.NilError(t, r.Do(func(r *Retrier) error { return nil }))
+}
+
+func TestWithName_AddsTheNameToEvents(t *testing.T) {
+	t.Parallel()
+
+	var h History
+	r := NewRetrier(WithName("fetch"), NoRetry(), WithOnAttempt(h.Record))
+	assert.NilError(t, r.Do(func(r *Retrier) error { return nil }))
+
+	assert.Equal(t, "fetch", h.Events()[0].Name)
+}
+
+func TestName_Unnamed(t *testing.T) {
+	t.Parallel()
+
+	r := NewRetrier(NoRetry())
+	assert.Equal(t, "", r.Name())
+
+	err := r.Do(func(r *Retrier) error { return errDummy })
+	assert.Equal(t, errDummy, err)
+}
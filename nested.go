@@ -0,0 +1,77 @@
+package roko
+
+import "context"
+
+// NestedPolicy controls what a retrier does when its DoWithContext loop is started from inside another retrier's loop.
+// Nesting retriers multiplies their attempt counts - an inner retrier with 25 attempts inside an outer retrier with 25
+// attempts can end up calling the operation 625 times - which is rarely what anyone intended
+type NestedPolicy int
+
+const (
+	// NestedAllow runs nested retriers exactly as configured. This is the default
+	NestedAllow NestedPolicy = iota
+
+	// NestedCollapse makes a nested retrier try its operation only once, leaving the retrying to the outer retrier
+	NestedCollapse
+
+	// NestedInherit caps a nested retrier's attempts at the number of attempts the outer retrier has remaining, so that
+	// the inner loop can't outlast the budget of the loop it's running in
+	NestedInherit
+)
+
+type retrierContextKey struct{}
+
+// WithNestedPolicy sets what the retrier does when it detects that it's being run inside another retrier's loop.
+// Nesting is detected through the context passed to DoWithContext, so it's only detected when that context is (or is
+// derived from) the outer retrier's Context()
+func WithNestedPolicy(p NestedPolicy) retrierOpt {
+	return func(r *Retrier) {
+		r.nestedPolicy = p
+	}
+}
+
+// WithNestedWarning sets a function that's called with the outer retrier whenever the retrier detects that it's being
+// run inside another retrier's loop. This is useful for logging accidental nesting, and works with any NestedPolicy
+func WithNestedWarning(f func(outer *Retrier)) retrierOpt {
+	return func(r *Retrier) {
+		r.onNested = f
+	}
+}
+
+// enterLoop applies the retrier's nesting policy if ctx shows that we're already inside another retrier's loop, and
+// returns a context recording that we're now inside this one, which it also stores for r.Context(). It also returns the
+// most attempts the policy allows the loop to make, or 0 if it doesn't limit them. The limit only applies to the loop
+// that's being entered, so the retrier's own configuration is left as it is for any loops it runs later
+func (r *Retrier) enterLoop(ctx context.Context) (context.Context, int) {
+	maxAttempts := 0
+	if outer, ok := ctx.Value(retrierContextKey{}).(*Retrier); ok && outer != r {
+		if r.onNested != nil {
+			r.onNested(outer)
+		}
+
+		outer.mu.Lock()
+		outerForever, outerRemaining := outer.forever, outer.maxAttempts-outer.attemptCount
+		outer.mu.Unlock()
+
+		switch r.nestedPolicy {
+		case NestedCollapse:
+			maxAttempts = 1
+
+		case NestedInherit:
+			if !outerForever {
+				if outerRemaining < 1 {
+					outerRemaining = 1
+				}
+				maxAttempts = outerRemaining
+			}
+		}
+	}
+
+	ctx = context.WithValue(ctx, retrierContextKey{}, r)
+
+	r.mu.Lock()
+	r.ctx = ctx
+	r.mu.Unlock()
+
+	return ctx, maxAttempts
+}
@@ -0,0 +1,147 @@
+package roko
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func newNestingTestRetrier(attempts int, opts ...retrierOpt) *Retrier {
+	return NewRetrier(append([]retrierOpt{
+		WithStrategy(Constant(1 * time.Second)),
+		WithMaxAttempts(attempts),
+		WithSleepFunc(dummySleep),
+	}, opts...)...)
+}
+
+func TestNested_ByDefault_AttemptsMultiply(t *testing.T) {
+	t.Parallel()
+
+	calls := 0
+	err := newNestingTestRetrier(5).Do(func(outer *Retrier) error {
+		return newNestingTestRetrier(5).DoWithContext(outer.Context(), func(*Retrier) error {
+			calls += 1
+			return errDummy
+		})
+	})
+	assert.ErrorIs(t, err, errDummy)
+
+	assert.Equal(t, 25, calls)
+}
+
+func TestNested_WithNestedCollapse_InnerLoopTriesOnce(t *testing.T) {
+	t.Parallel()
+
+	calls := 0
+	err := newNestingTestRetrier(5).Do(func(outer *Retrier) error {
+		return newNestingTestRetrier(5, WithNestedPolicy(NestedCollapse)).DoWithContext(outer.Context(), func(*Retrier) error {
+			calls += 1
+			return errDummy
+		})
+	})
+	assert.ErrorIs(t, err, errDummy)
+
+	assert.Equal(t, 5, calls)
+}
+
+func TestNested_WithNestedInherit_InnerLoopUsesOuterRemainingAttempts(t *testing.T) {
+	t.Parallel()
+
+	innerCalls := []int{}
+	err := newNestingTestRetrier(4).Do(func(outer *Retrier) error {
+		calls := 0
+		err := NewRetrier(
+			WithStrategy(Constant(1*time.Second)),
+			TryForever(),
+			WithSleepFunc(dummySleep),
+			WithNestedPolicy(NestedInherit),
+		).DoWithContext(outer.Context(), func(*Retrier) error {
+			calls += 1
+			return errDummy
+		})
+		innerCalls = append(innerCalls, calls)
+		return err
+	})
+	assert.ErrorIs(t, err, errDummy)
+
+	assert.DeepEqual(t, []int{4, 3, 2, 1}, innerCalls)
+}
+
+func TestNested_WithNestedCollapse_LeavesTheRetrierAsConfigured(t *testing.T) {
+	t.Parallel()
+
+	inner := newNestingTestRetrier(3, WithNestedPolicy(NestedCollapse))
+	calls := 0
+	err := newNestingTestRetrier(1).Do(func(outer *Retrier) error {
+		return inner.DoWithContext(outer.Context(), func(*Retrier) error {
+			calls += 1
+			return errDummy
+		})
+	})
+	assert.ErrorIs(t, err, errDummy)
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, "constant(1s), up to 3 attempts", inner.Describe())
+
+	// Outside the nest, the retrier carries on with the rest of its attempts
+	err = inner.Do(func(*Retrier) error {
+		calls += 1
+		return errDummy
+	})
+	assert.ErrorIs(t, err, errDummy)
+	assert.Equal(t, 3, calls)
+}
+
+func TestNested_WithNestedInherit_LeavesTheRetrierAsConfigured(t *testing.T) {
+	t.Parallel()
+
+	inner := NewRetrier(
+		WithStrategy(Constant(1*time.Second)),
+		TryForever(),
+		WithSleepFunc(dummySleep),
+		WithNestedPolicy(NestedInherit),
+	)
+	err := newNestingTestRetrier(1).Do(func(outer *Retrier) error {
+		return inner.DoWithContext(outer.Context(), func(*Retrier) error { return errDummy })
+	})
+	assert.ErrorIs(t, err, errDummy)
+
+	// Outside the nest, it tries forever again
+	calls := 0
+	assert.NilError(t, inner.Do(func(*Retrier) error {
+		calls += 1
+		if calls < 10 {
+			return errDummy
+		}
+		return nil
+	}))
+	assert.Equal(t, 10, calls)
+}
+
+func TestNested_WithNestedWarning_IsCalledWithTheOuterRetrier(t *testing.T) {
+	t.Parallel()
+
+	var warnedAbout *Retrier
+	outer := newNestingTestRetrier(1)
+	err := outer.Do(func(outer *Retrier) error {
+		return newNestingTestRetrier(1, WithNestedWarning(func(o *Retrier) {
+			warnedAbout = o
+		})).DoWithContext(outer.Context(), func(*Retrier) error { return nil })
+	})
+	assert.NilError(t, err)
+
+	assert.Equal(t, outer, warnedAbout)
+}
+
+func TestNested_WhenNotNested_DoesNotWarn(t *testing.T) {
+	t.Parallel()
+
+	warned := false
+	err := newNestingTestRetrier(1, WithNestedWarning(func(*Retrier) {
+		warned = true
+	})).DoWithContext(context.Background(), func(*Retrier) error { return nil })
+	assert.NilError(t, err)
+
+	assert.Check(t, !warned)
+}
@@ -0,0 +1,196 @@
+// Package ociretry helps to retry requests to container registries that implement the OCI distribution spec, such as
+// pulling and pushing image layers.
+package ociretry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/buildkite/roko"
+)
+
+// ErrUploadSessionExpired is returned by Classify when the registry no longer knows about a blob upload session,
+// usually because it took too long and the registry expired it. Retrying the same request won't help - the upload has
+// to be started again from the beginning, with a new session - so Classify stops the retrier it's given with Break.
+// The error isn't wrapped with roko.Unrecoverable, though, so callers that retry whole uploads (rather than individual
+// requests within one) with a retrier of their own can treat it as transient
+var ErrUploadSessionExpired = errors.New("registry blob upload session expired")
+
+// maxErrorBody limits how much of an error response Classify reads, looking for error codes
+const maxErrorBody = 64 * 1024
+
+// ResponseError is returned by Classify when the registry responds with an error status
+type ResponseError struct {
+	StatusCode int
+	Codes      []string // The error codes from the response body, such as "BLOB_UNKNOWN" or "DENIED"
+}
+
+func (e *ResponseError) Error() string {
+	if len(e.Codes) == 0 {
+		return fmt.Sprintf("registry responded with %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+	return fmt.Sprintf("registry responded with %d %s %v", e.StatusCode, http.StatusText(e.StatusCode), e.Codes)
+}
+
+// Classify turns the result of a request to a registry into an error suitable for returning from a retrier's callback:
+//
+//   - errors making the request at all (err != nil) are returned as they are, to be retried
+//   - 2xx and 3xx responses give nil
+//   - 429 Too Many Requests is retried, waiting as long as the Retry-After header asks (if it's present) by calling
+//     r.SetNextInterval
+//   - 5xx responses are retried
+//   - responses saying that a blob upload session doesn't exist give ErrUploadSessionExpired, and stop r by calling
+//     r.Break
+//   - all other responses are wrapped with roko.Unrecoverable, as they won't change if the request is tried again
+//
+// When the response is an error, Classify reads (some of) its body looking for error codes, and closes it.
+func Classify(r *roko.Retrier, resp *http.Response, err error) error {
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode < 400 {
+		return nil
+	}
+
+	respErr := &ResponseError{StatusCode: resp.StatusCode, Codes: errorCodes(resp.Body)}
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		if d, ok := roko.ParseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+			r.SetNextInterval(d)
+		}
+		return respErr
+
+	case resp.StatusCode >= 500:
+		return respErr
+
+	case respErr.hasCode("BLOB_UPLOAD_UNKNOWN"), respErr.hasCode("BLOB_UPLOAD_INVALID"):
+		r.Break()
+		return fmt.Errorf("%w: %v", ErrUploadSessionExpired, respErr)
+
+	default:
+		return roko.Unrecoverable(respErr)
+	}
+}
+
+func (e *ResponseError) hasCode(code string) bool {
+	for _, c := range e.Codes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// errorCodes returns the error codes from an OCI distribution error response body, of the form
+// {"errors": [{"code": "...", "message": "..."}]}. It returns nil if the body isn't in that form
+func errorCodes(body io.Reader) []string {
+	var payload struct {
+		Errors []struct {
+			Code string `json:"code"`
+		} `json:"errors"`
+	}
+	if err := json.NewDecoder(io.LimitReader(body, maxErrorBody)).Decode(&payload); err != nil {
+		return nil
+	}
+
+	codes := make([]string, 0, len(payload.Errors))
+	for _, e := range payload.Errors {
+		codes = append(codes, e.Code)
+	}
+	return codes
+}
+
+// NewRetrier returns a retrier suitable for a single registry operation, such as pulling or pushing one layer. It makes
+// up to 6 attempts, waiting exponentially longer between each, starting at a second, with full jitter. Registries
+// mostly fail by throttling (which Classify handles by waiting as long as Retry-After asks) or with brief 5xx errors
+// from their load balancers, and many nodes tend to pull the same image at once during a deploy, so the waits are kept
+// short and spread out as widely as possible
+func NewRetrier() *roko.Retrier {
+	return roko.NewRetrier(
+		roko.WithMaxAttempts(6),
+		roko.WithStrategy(roko.ExponentialSubsecond(time.Second)),
+		roko.WithJitter(roko.FullJitter),
+	)
+}
+
+// LayerError is returned by DoLayers when an operation on a layer fails
+type LayerError[T any] struct {
+	Layer T
+	Index int
+	Err   error
+}
+
+func (e *LayerError[T]) Error() string {
+	return fmt.Sprintf("layer %d: %v", e.Index, e.Err)
+}
+
+func (e *LayerError[T]) Unwrap() error {
+	return e.Err
+}
+
+// DoLayers runs op for each of layers, up to parallelism at a time, retrying each layer independently with its own
+// retrier from newRetrier, so that one flaky layer doesn't cause the layers that have already finished to be fetched
+// again. If any layer fails, DoLayers stops starting new layers, waits for the ones in progress to finish, and returns
+// a *LayerError for the first layer that failed
+func DoLayers[T any](ctx context.Context, layers []T, parallelism int, newRetrier func() *roko.Retrier, op func(ctx context.Context, layer T) error) error {
+	if parallelism <= 0 {
+		panic("parallelism must be positive")
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		mu       sync.Mutex
+		firstErr error
+	)
+	sem := make(chan struct{}, parallelism)
+	var wg sync.WaitGroup
+
+	for i, layer := range layers {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		wg.Add(1)
+		go func(i int, layer T) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			// DoFunc rather than DoWithContext, so that a soft-failing retrier can't make a failed layer look like it was
+			// transferred
+			_, err := roko.DoFunc(ctx, newRetrier(), func(r *roko.Retrier) (struct{}, error) {
+				return struct{}{}, op(r.Context(), layer)
+			})
+			if err != nil {
+				mu.Lock()
+				defer mu.Unlock()
+				if firstErr == nil {
+					firstErr = &LayerError[T]{Layer: layer, Index: i, Err: err}
+					cancel()
+				}
+			}
+		}(i, layer)
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+
+	// Only report the context's error if it came from outside, rather than from a failed layer
+	return ctx.Err()
+}
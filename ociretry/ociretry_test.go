@@ -0,0 +1,166 @@
+package ociretry
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/buildkite/roko"
+	"gotest.tools/v3/assert"
+)
+
+func response(status int, header http.Header, body string) *http.Response {
+	if header == nil {
+		header = http.Header{}
+	}
+	return &http.Response{StatusCode: status, Header: header, Body: io.NopCloser(strings.NewReader(body))}
+}
+
+func newTestRetrier() *roko.Retrier {
+	return roko.NewRetrier(
+		roko.WithMaxAttempts(3),
+		roko.WithStrategy(roko.Constant(time.Second)),
+		roko.WithSleepFunc(func(time.Duration) {}),
+	)
+}
+
+func TestClassify_Success(t *testing.T) {
+	t.Parallel()
+
+	assert.NilError(t, Classify(newTestRetrier(), response(http.StatusOK, nil, ""), nil))
+	assert.NilError(t, Classify(newTestRetrier(), response(http.StatusTemporaryRedirect, nil, ""), nil))
+}
+
+func TestClassify_NetworkErrorsAreRetried(t *testing.T) {
+	t.Parallel()
+
+	errConn := errors.New("connection refused")
+	err := Classify(newTestRetrier(), nil, errConn)
+	assert.ErrorIs(t, err, errConn)
+	assert.Check(t, !errors.Is(err, roko.ErrUnrecoverable))
+}
+
+func TestClassify_TooManyRequestsUsesRetryAfter(t *testing.T) {
+	t.Parallel()
+
+	r := newTestRetrier()
+	err := Classify(r, response(http.StatusTooManyRequests, http.Header{"Retry-After": []string{"7"}}, `{"errors":[{"code":"TOOMANYREQUESTS"}]}`), nil)
+	assert.Error(t, err, "registry responded with 429 Too Many Requests [TOOMANYREQUESTS]")
+	assert.Check(t, !errors.Is(err, roko.ErrUnrecoverable))
+	assert.Equal(t, 7*time.Second, r.NextInterval())
+}
+
+func TestClassify_ServerErrorsAreRetried(t *testing.T) {
+	t.Parallel()
+
+	err := Classify(newTestRetrier(), response(http.StatusBadGateway, nil, "<html>bad gateway</html>"), nil)
+	assert.Error(t, err, "registry responded with 502 Bad Gateway")
+	assert.Check(t, !errors.Is(err, roko.ErrUnrecoverable))
+}
+
+func TestClassify_ExpiredUploadSession(t *testing.T) {
+	t.Parallel()
+
+	err := Classify(newTestRetrier(), response(http.StatusNotFound, nil, `{"errors":[{"code":"BLOB_UPLOAD_UNKNOWN","message":"blob upload unknown to registry"}]}`), nil)
+	assert.ErrorIs(t, err, ErrUploadSessionExpired)
+	assert.Check(t, !errors.Is(err, roko.ErrUnrecoverable))
+}
+
+func TestClassify_ExpiredUploadSession_StopsTheRequestLoopButNotTheUpload(t *testing.T) {
+	t.Parallel()
+
+	uploads, requests := 0, 0
+	err := newTestRetrier().Do(func(*roko.Retrier) error {
+		uploads++
+		return newTestRetrier().Do(func(r *roko.Retrier) error {
+			requests++
+			if uploads == 1 {
+				return Classify(r, response(http.StatusNotFound, nil, `{"errors":[{"code":"BLOB_UPLOAD_UNKNOWN"}]}`), nil)
+			}
+			return Classify(r, response(http.StatusCreated, nil, ""), nil)
+		})
+	})
+
+	// The expired session isn't retried within the first upload, but the upload as a whole is started again
+	assert.NilError(t, err)
+	assert.Equal(t, 2, uploads)
+	assert.Equal(t, 2, requests)
+}
+
+func TestClassify_ClientErrorsAreUnrecoverable(t *testing.T) {
+	t.Parallel()
+
+	err := Classify(newTestRetrier(), response(http.StatusUnauthorized, nil, `{"errors":[{"code":"UNAUTHORIZED"}]}`), nil)
+	assert.ErrorIs(t, err, roko.ErrUnrecoverable)
+
+	var respErr *ResponseError
+	assert.Assert(t, errors.As(err, &respErr))
+	assert.Equal(t, http.StatusUnauthorized, respErr.StatusCode)
+	assert.DeepEqual(t, []string{"UNAUTHORIZED"}, respErr.Codes)
+}
+
+func TestDoLayers_RetriesEachLayerIndependently(t *testing.T) {
+	t.Parallel()
+
+	var mu sync.Mutex
+	attempts := map[string]int{}
+
+	layers := []string{"sha256:aaa", "sha256:bbb", "sha256:ccc"}
+	err := DoLayers(context.Background(), layers, 2, newTestRetrier, func(ctx context.Context, layer string) error {
+		mu.Lock()
+		defer mu.Unlock()
+
+		attempts[layer]++
+		if layer == "sha256:bbb" && attempts[layer] < 3 {
+			return errors.New("unexpected EOF")
+		}
+		return nil
+	})
+
+	assert.NilError(t, err)
+	assert.DeepEqual(t, map[string]int{"sha256:aaa": 1, "sha256:bbb": 3, "sha256:ccc": 1}, attempts)
+}
+
+func TestDoLayers_WithASoftFailingRetrier_StillReportsFailedLayers(t *testing.T) {
+	t.Parallel()
+
+	newRetrier := func() *roko.Retrier {
+		return roko.NewRetrier(
+			roko.WithMaxAttempts(2),
+			roko.WithStrategy(roko.Constant(time.Second)),
+			roko.WithSleepFunc(func(time.Duration) {}),
+			roko.WithSoftFail(func(roko.SoftFailure) {}),
+		)
+	}
+
+	errDenied := errors.New("denied")
+	err := DoLayers(context.Background(), []string{"sha256:aaa"}, 1, newRetrier, func(context.Context, string) error {
+		return errDenied
+	})
+
+	assert.ErrorIs(t, err, errDenied)
+}
+
+func TestDoLayers_WhenALayerFails_ReturnsItsError(t *testing.T) {
+	t.Parallel()
+
+	errDenied := errors.New("denied")
+	layers := []string{"sha256:aaa", "sha256:bbb", "sha256:ccc"}
+	err := DoLayers(context.Background(), layers, 1, newTestRetrier, func(ctx context.Context, layer string) error {
+		if layer == "sha256:bbb" {
+			return roko.Unrecoverable(errDenied)
+		}
+		return nil
+	})
+
+	assert.ErrorIs(t, err, errDenied)
+	var layerErr *LayerError[string]
+	assert.Assert(t, errors.As(err, &layerErr))
+	assert.Equal(t, "sha256:bbb", layerErr.Layer)
+	assert.Equal(t, 1, layerErr.Index)
+}
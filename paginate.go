@@ -0,0 +1,67 @@
+package roko
+
+import (
+	"context"
+	"time"
+)
+
+// Paginate fetches every page of a paginated API, calling yield with each item in turn. fetch is called with the cursor
+// for the page to fetch (starting with "", for the first page), and returns the items on that page and the cursor for
+// the next one, or "" if it was the last page. Each page is fetched with a new retrier from newRetrier, so that a page
+// that needs retrying doesn't use up the attempts available to the ones after it.
+//
+// Rate limits are handled through the retrier that's passed to fetch:
+//
+//   - when a page fails because of a rate limit, fetch can call r.SetNextInterval with the time the API asked it to
+//     wait (for example, using ParseRetryAfter) before returning the error, as with any other retry loop
+//   - when a page succeeds, but the API says that the rate limit has been used up (for example, with an
+//     X-RateLimit-Remaining: 0 header), fetch can call r.SetNextInterval before returning the items, and Paginate will
+//     wait that long before fetching the next page
+//
+// Paginate stops, and returns the error, when a page can't be fetched or when yield returns an error.
+// (Note this is not a method of Retrier, since methods can't be generic.)
+func Paginate[T any](ctx context.Context, newRetrier func() *Retrier, fetch func(r *Retrier, cursor string) (items []T, next string, err error), yield func(T) error) error {
+	cursor := ""
+	for {
+		r := newRetrier()
+
+		var next string
+		items, err := DoFunc(ctx, r, func(r *Retrier) ([]T, error) {
+			items, n, err := fetch(r, cursor)
+			next = n
+			return items, err
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, item := range items {
+			if err := yield(item); err != nil {
+				return err
+			}
+		}
+
+		if next == "" {
+			return nil
+		}
+		cursor = next
+
+		if wait := r.overriddenInterval(); wait > 0 {
+			if err := r.sleepOrDone(ctx, wait); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// overriddenInterval returns the interval set by SetNextInterval during the most recent attempt, or 0 if it wasn't
+// called
+func (r *Retrier) overriddenInterval() time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.overridden {
+		return 0
+	}
+	return r.nextInterval
+}
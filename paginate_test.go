@@ -0,0 +1,125 @@
+package roko
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+// pagedAPI is a fake paginated API, with three items per page
+type pagedAPI struct {
+	items    []string
+	failures map[string]int // how many times to fail fetching the page at each cursor
+	calls    map[string]int
+}
+
+func (a *pagedAPI) fetch(r *Retrier, cursor string) ([]string, string, error) {
+	a.calls[cursor]++
+	if a.calls[cursor] <= a.failures[cursor] {
+		r.SetNextInterval(30 * time.Second)
+		return nil, "", errors.New("429 Too Many Requests")
+	}
+
+	start := 0
+	if cursor != "" {
+		fmt.Sscanf(cursor, "page-%d", &start)
+	}
+	end := start + 3
+	if end >= len(a.items) {
+		return a.items[start:], "", nil
+	}
+	return a.items[start:end], fmt.Sprintf("page-%d", end), nil
+}
+
+func TestPaginate_YieldsEveryItem(t *testing.T) {
+	t.Parallel()
+
+	api := &pagedAPI{items: []string{"a", "b", "c", "d", "e", "f", "g"}, calls: map[string]int{}}
+	insomniac := newInsomniac()
+
+	got := []string{}
+	err := Paginate(context.Background(), func() *Retrier {
+		return NewRetrier(WithMaxAttempts(3), WithStrategy(Constant(time.Second)), WithSleepFunc(insomniac.sleep))
+	}, api.fetch, func(item string) error {
+		got = append(got, item)
+		return nil
+	})
+
+	assert.NilError(t, err)
+	assert.DeepEqual(t, api.items, got)
+	assert.DeepEqual(t, map[string]int{"": 1, "page-3": 1, "page-6": 1}, api.calls)
+	assert.DeepEqual(t, []time.Duration{}, insomniac.sleepIntervals)
+}
+
+func TestPaginate_RetriesEachPageWithItsOwnRetrier(t *testing.T) {
+	t.Parallel()
+
+	api := &pagedAPI{
+		items:    []string{"a", "b", "c", "d", "e", "f", "g"},
+		failures: map[string]int{"": 2, "page-3": 2},
+		calls:    map[string]int{},
+	}
+	insomniac := newInsomniac()
+
+	got := []string{}
+	err := Paginate(context.Background(), func() *Retrier {
+		return NewRetrier(WithMaxAttempts(3), WithStrategy(Constant(time.Second)), WithSleepFunc(insomniac.sleep))
+	}, api.fetch, func(item string) error {
+		got = append(got, item)
+		return nil
+	})
+
+	assert.NilError(t, err)
+	assert.DeepEqual(t, api.items, got)
+	assert.DeepEqual(t, map[string]int{"": 3, "page-3": 3, "page-6": 1}, api.calls)
+	assert.DeepEqual(t, []time.Duration{30 * time.Second, 30 * time.Second, 30 * time.Second, 30 * time.Second}, insomniac.sleepIntervals)
+}
+
+func TestPaginate_WaitsBetweenPagesWhenAsked(t *testing.T) {
+	t.Parallel()
+
+	insomniac := newInsomniac()
+	pages := 0
+
+	err := Paginate(context.Background(), func() *Retrier {
+		return NewRetrier(WithMaxAttempts(3), WithStrategy(Constant(time.Second)), WithSleepFunc(insomniac.sleep))
+	}, func(r *Retrier, cursor string) ([]int, string, error) {
+		pages++
+		if pages == 3 {
+			return []int{pages}, "", nil
+		}
+		// The rate limit has run out, so wait before fetching the next page
+		r.SetNextInterval(10 * time.Second)
+		return []int{pages}, "next", nil
+	}, func(int) error { return nil })
+
+	assert.NilError(t, err)
+	assert.Equal(t, 3, pages)
+	assert.DeepEqual(t, []time.Duration{10 * time.Second, 10 * time.Second}, insomniac.sleepIntervals)
+}
+
+func TestPaginate_StopsWhenYieldReturnsAnError(t *testing.T) {
+	t.Parallel()
+
+	api := &pagedAPI{items: []string{"a", "b", "c", "d", "e", "f", "g"}, calls: map[string]int{}}
+	errEnough := errors.New("that's enough")
+
+	got := []string{}
+	err := Paginate(context.Background(), func() *Retrier {
+		return NewRetrier(WithMaxAttempts(3), WithStrategy(Constant(time.Second)), WithSleepFunc(dummySleep))
+	}, api.fetch, func(item string) error {
+		if item == "e" {
+			return errEnough
+		}
+		got = append(got, item)
+		return nil
+	})
+
+	assert.ErrorIs(t, err, errEnough)
+	assert.DeepEqual(t, []string{"a", "b", "c", "d"}, got)
+	assert.DeepEqual(t, map[string]int{"": 1, "page-3": 1}, api.calls)
+}
@@ -0,0 +1,71 @@
+package roko
+
+import (
+	"context"
+	"fmt"
+)
+
+// Stage is a single step in a Pipeline. Each stage has its own Retrier, so that (for example) a create step can be
+// retried differently to a verify step
+type Stage struct {
+	// Name identifies the stage in the error returned when the pipeline fails
+	Name string
+
+	// Retrier is the retrier used to run this stage
+	Retrier *Retrier
+
+	// Do is the operation performed by this stage. It's retried as it would be by Retrier.DoWithContext, so it can use
+	// the retrier passed to it to Break or SetNextInterval as usual. WithSoftFail doesn't apply, though, since the later
+	// stages depend on this one having succeeded
+	Do func(*Retrier) error
+}
+
+// Pipeline is a sequence of stages that are run in order, each of which is retried according to its own Retrier. If a
+// stage fails, only that stage is retried - stages that have already succeeded aren't run again. To keep the pipeline as
+// a whole within a limit, give each stage's retrier a child of one Budget (see WithBudget)
+type Pipeline struct {
+	stages []Stage
+}
+
+// StageError is returned by Pipeline.Do when one of the pipeline's stages gives up. It reports which stage failed, and
+// wraps the last error returned by that stage
+type StageError struct {
+	Stage string // The name of the stage that failed
+	Index int    // The index of the stage that failed within the pipeline
+	Err   error  // The last error returned by the stage
+}
+
+func (e *StageError) Error() string {
+	return fmt.Sprintf("pipeline stage %d (%s) failed: %v", e.Index, e.Stage, e.Err)
+}
+
+func (e *StageError) Unwrap() error {
+	return e.Err
+}
+
+// NewPipeline creates a Pipeline that runs the given stages in order
+func NewPipeline(stages ...Stage) *Pipeline {
+	for i, s := range stages {
+		if s.Retrier == nil {
+			panic(fmt.Sprintf("pipeline stage %d (%s) must have a retrier", i, s.Name))
+		}
+
+		if s.Do == nil {
+			panic(fmt.Sprintf("pipeline stage %d (%s) must have a Do function", i, s.Name))
+		}
+	}
+
+	return &Pipeline{stages: stages}
+}
+
+// Do runs each stage of the pipeline in order, retrying each stage according to its retrier. It returns nil if every
+// stage succeeds, or a *StageError describing the first stage that gave up
+func (p *Pipeline) Do(ctx context.Context) error {
+	for i, s := range p.stages {
+		if err := s.Retrier.run(ctx, s.Do, nil); err != nil {
+			return &StageError{Stage: s.Name, Index: i, Err: err}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,129 @@
+package roko
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestPipeline_RunsEachStageInOrder(t *testing.T) {
+	t.Parallel()
+
+	ran := []string{}
+	stage := func(name string) Stage {
+		return Stage{
+			Name:    name,
+			Retrier: NewRetrier(WithStrategy(Constant(1*time.Second)), WithMaxAttempts(3), WithSleepFunc(dummySleep)),
+			Do: func(*Retrier) error {
+				ran = append(ran, name)
+				return nil
+			},
+		}
+	}
+
+	err := NewPipeline(stage("create"), stage("attach"), stage("verify")).Do(context.Background())
+	assert.NilError(t, err)
+
+	assert.DeepEqual(t, []string{"create", "attach", "verify"}, ran)
+}
+
+func TestPipeline_OnlyRetriesTheFailingStage(t *testing.T) {
+	t.Parallel()
+
+	createCalls, attachCalls := 0, 0
+	err := NewPipeline(
+		Stage{
+			Name:    "create",
+			Retrier: NewRetrier(WithStrategy(Constant(1*time.Second)), WithMaxAttempts(3), WithSleepFunc(dummySleep)),
+			Do: func(*Retrier) error {
+				createCalls += 1
+				return nil
+			},
+		},
+		Stage{
+			Name:    "attach",
+			Retrier: NewRetrier(WithStrategy(Constant(1*time.Second)), WithMaxAttempts(5), WithSleepFunc(dummySleep)),
+			Do: func(*Retrier) error {
+				attachCalls += 1
+				if attachCalls < 4 {
+					return errDummy
+				}
+				return nil
+			},
+		},
+	).Do(context.Background())
+	assert.NilError(t, err)
+
+	assert.Equal(t, 1, createCalls)
+	assert.Equal(t, 4, attachCalls)
+}
+
+func TestPipeline_WhenAStageGivesUp_ReportsWhichStageFailed(t *testing.T) {
+	t.Parallel()
+
+	verifyRan := false
+	err := NewPipeline(
+		Stage{
+			Name:    "create",
+			Retrier: NewRetrier(WithStrategy(Constant(1*time.Second)), WithMaxAttempts(3), WithSleepFunc(dummySleep)),
+			Do:      func(*Retrier) error { return nil },
+		},
+		Stage{
+			Name:    "attach",
+			Retrier: NewRetrier(WithStrategy(Constant(1*time.Second)), WithMaxAttempts(3), WithSleepFunc(dummySleep)),
+			Do:      func(*Retrier) error { return errDummy },
+		},
+		Stage{
+			Name:    "verify",
+			Retrier: NewRetrier(WithStrategy(Constant(1*time.Second)), WithMaxAttempts(3), WithSleepFunc(dummySleep)),
+			Do: func(*Retrier) error {
+				verifyRan = true
+				return nil
+			},
+		},
+	).Do(context.Background())
+	assert.ErrorIs(t, err, errDummy)
+
+	var stageErr *StageError
+	assert.Assert(t, errors.As(err, &stageErr))
+	assert.Equal(t, "attach", stageErr.Stage)
+	assert.Equal(t, 1, stageErr.Index)
+	assert.Equal(t, "pipeline stage 1 (attach) failed: this makes it retry", err.Error())
+	assert.Check(t, !verifyRan)
+}
+
+func TestPipeline_WhenAStageSoftFails_StopsAtThatStage(t *testing.T) {
+	t.Parallel()
+
+	softFailed, verifyRan := false, false
+	err := NewPipeline(
+		Stage{
+			Name: "attach",
+			Retrier: NewRetrier(
+				WithStrategy(Constant(1*time.Second)),
+				WithMaxAttempts(3),
+				WithSleepFunc(dummySleep),
+				WithSoftFail(func(SoftFailure) { softFailed = true }),
+			),
+			Do: func(*Retrier) error { return errDummy },
+		},
+		Stage{
+			Name:    "verify",
+			Retrier: NewRetrier(WithStrategy(Constant(1*time.Second)), WithMaxAttempts(3), WithSleepFunc(dummySleep)),
+			Do: func(*Retrier) error {
+				verifyRan = true
+				return nil
+			},
+		},
+	).Do(context.Background())
+	assert.ErrorIs(t, err, errDummy)
+
+	var stageErr *StageError
+	assert.Assert(t, errors.As(err, &stageErr))
+	assert.Equal(t, "attach", stageErr.Stage)
+	assert.Check(t, !softFailed)
+	assert.Check(t, !verifyRan)
+}
@@ -0,0 +1,131 @@
+package roko
+
+import "time"
+
+// PlannedIntervals returns the intervals the retrier will wait between its next n attempts, without sleeping or calling
+// anything. It's useful for showing users when an operation will be retried. The intervals are calculated from the
+// retrier's current state, so for a retrier that hasn't been used yet they start at the first wait.
+//
+// Jitter is random, so it isn't included - the intervals returned are the ones the strategy calculates before jitter
+// is applied. Fewer than n intervals are returned if the retrier would give up sooner, because of its maximum attempt
+// count or WithMaxTotalSleep. Intervals overridden using SetNextInterval can't be known in advance, so aren't included
+func (r *Retrier) PlannedIntervals(n int) []time.Duration {
+	return r.planIntervals(n, false)
+}
+
+// EstimateTotal returns the worst-case total time the retrier will spend waiting between its first n attempts (from
+// its current state), taking the largest possible jitter for every wait. It's useful for asserting in tests that a
+// retry policy can't exceed some length of time:
+//
+//	assert.Check(t, r.EstimateTotal(math.MaxInt) <= 5*time.Minute)
+//
+// Like PlannedIntervals, it stops early if the retrier would give up sooner, and can't account for intervals set
+// using SetNextInterval. The time spent performing the operation itself isn't included. For retriers that try forever
+// without a WithMaxTotalSleep limit, n must be small enough to calculate that many intervals
+func (r *Retrier) EstimateTotal(n int) time.Duration {
+	total := time.Duration(0)
+	for _, interval := range r.planIntervals(n-1, true) {
+		total = saturatingAdd(total, interval)
+	}
+
+	if r.maxTotalSleep > 0 && total > r.maxTotalSleep {
+		// The retrier gives up rather than going over its limit, even if jitter takes an interval past it
+		return r.maxTotalSleep
+	}
+
+	return total
+}
+
+// planIntervals calculates the retrier's next n intervals, either before jitter, or with the worst case jitter
+func (r *Retrier) planIntervals(n int, worstCase bool) []time.Duration {
+	preview := r.preview()
+
+	if !preview.forever {
+		if remaining := preview.maxAttempts - preview.attemptCount - 1; remaining < n {
+			n = remaining
+		}
+	}
+
+	if n <= 0 {
+		return nil
+	}
+
+	capacity := n
+	if capacity > 64 {
+		capacity = 64 // n is often "as many as there are", so don't trust it for the allocation
+	}
+
+	intervals := make([]time.Duration, 0, capacity)
+	previous := time.Duration(0)
+	for i := 0; i < n; i++ {
+		interval := preview.calculateNextInterval()
+		if preview.breakNext {
+			break // The strategy has given up, as Repeat does once it's been through its intervals
+		}
+		if worstCase {
+			interval = r.worstCaseJitter(interval, previous)
+		}
+
+		if preview.maxTotalSleep > 0 && saturatingAdd(preview.totalSleep, interval) > preview.maxTotalSleep {
+			if worstCase {
+				// The real interval might be shorter, so in the worst case there's still another wait to come
+				intervals = append(intervals, interval)
+			}
+			break
+		}
+
+		intervals = append(intervals, interval)
+		preview.totalSleep = saturatingAdd(preview.totalSleep, interval)
+		preview.attemptCount++
+		previous = interval
+	}
+
+	return intervals
+}
+
+// worstCaseJitter returns the longest interval the retrier's jitter could turn interval into
+func (r *Retrier) worstCaseJitter(interval, previous time.Duration) time.Duration {
+	if !r.jitter {
+		return interval
+	}
+
+	if r.jitterMode.isSet() {
+		if !r.clockAligned() {
+			interval = r.jitterMode.worst(interval, previous)
+		}
+	} else if r.jitterRange.max > 0 {
+		interval = saturatingAdd(interval, r.jitterRange.max)
+	}
+
+	interval = r.quantize(interval)
+	if r.intervalCap > 0 && interval > r.intervalCap {
+		return r.intervalCap
+	}
+	return interval
+}
+
+// preview returns a copy of the retrier's configuration and current state, with jitter disabled, that can be used to
+// calculate future intervals without affecting the retrier itself
+func (r *Retrier) preview() *Retrier {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return &Retrier{
+		maxAttempts:        r.maxAttempts,
+		attemptCount:       r.attemptCount,
+		forever:            r.forever,
+		maxTotalSleep:      r.maxTotalSleep,
+		truncateFinalSleep: r.truncateFinalSleep,
+		totalSleep:         r.totalSleep,
+		intervalCalculator: r.intervalCalculator,
+		strategyType:       r.strategyType,
+		nextInterval:       r.nextInterval,
+		quantum:            r.quantum,
+		minInterval:        r.minInterval,
+		intervalCap:        r.intervalCap,
+		stopOnNegative:     r.stopOnNegative,
+		businessHours:      r.businessHours,
+		clock:              r.clock,
+		rand:               r.rand,
+	}
+}
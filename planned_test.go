@@ -0,0 +1,168 @@
+package roko
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestPlannedIntervals_MatchesTheIntervalsUsed(t *testing.T) {
+	t.Parallel()
+
+	insomniac := newInsomniac()
+	r := NewRetrier(
+		WithStrategy(Exponential(2*time.Second, 1*time.Second)),
+		WithMaxAttempts(6),
+		WithSleepFunc(insomniac.sleep),
+	)
+
+	planned := r.PlannedIntervals(10)
+	assert.DeepEqual(t, []time.Duration{
+		2 * time.Second,
+		3 * time.Second,
+		5 * time.Second,
+		9 * time.Second,
+		17 * time.Second,
+	}, planned, DurationExact())
+
+	err := r.Do(func(*Retrier) error { return errDummy })
+	assert.ErrorIs(t, err, errDummy)
+
+	assert.DeepEqual(t, planned, insomniac.sleepIntervals, DurationExact())
+}
+
+func TestPlannedIntervals_StartsFromTheCurrentAttempt(t *testing.T) {
+	t.Parallel()
+
+	r := NewRetrier(
+		WithStrategy(Exponential(2*time.Second, 0)),
+		TryForever(),
+		WithSleepFunc(dummySleep),
+	)
+
+	var planned []time.Duration
+	err := r.Do(func(r *Retrier) error {
+		if r.AttemptCount() == 3 {
+			planned = r.PlannedIntervals(3)
+			return nil
+		}
+		return errDummy
+	})
+	assert.NilError(t, err)
+
+	assert.DeepEqual(t, []time.Duration{
+		8 * time.Second,
+		16 * time.Second,
+		32 * time.Second,
+	}, planned, DurationExact())
+	assert.Equal(t, 3, r.AttemptCount())
+}
+
+func TestPlannedIntervals_StopsAtMaxTotalSleep(t *testing.T) {
+	t.Parallel()
+
+	r := NewRetrier(
+		WithStrategy(Constant(10*time.Second)),
+		TryForever(),
+		WithMaxTotalSleep(35*time.Second),
+	)
+
+	assert.DeepEqual(t, []time.Duration{
+		10 * time.Second,
+		10 * time.Second,
+		10 * time.Second,
+	}, r.PlannedIntervals(100), DurationExact())
+}
+
+func TestPlannedIntervals_TruncatesTheFinalSleep(t *testing.T) {
+	t.Parallel()
+
+	r := NewRetrier(
+		WithStrategy(Constant(10*time.Second)),
+		TryForever(),
+		WithMaxTotalSleep(35*time.Second),
+		TruncateFinalSleep(),
+	)
+
+	assert.DeepEqual(t, []time.Duration{
+		10 * time.Second,
+		10 * time.Second,
+		10 * time.Second,
+		5 * time.Second,
+	}, r.PlannedIntervals(100), DurationExact())
+}
+
+func TestPlannedIntervals_DoesNotIncludeJitter(t *testing.T) {
+	t.Parallel()
+
+	r := NewRetrier(
+		WithStrategy(Constant(10*time.Second)),
+		WithJitter(),
+		WithMaxAttempts(3),
+	)
+
+	assert.DeepEqual(t, []time.Duration{
+		10 * time.Second,
+		10 * time.Second,
+	}, r.PlannedIntervals(5), DurationExact())
+}
+
+func TestPlannedIntervals_WithNoRetry_IsEmpty(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, 0, len(NewRetrier(NoRetry()).PlannedIntervals(5)))
+}
+
+func TestEstimateTotal_WithoutJitter_IsTheSumOfTheIntervals(t *testing.T) {
+	t.Parallel()
+
+	r := NewRetrier(
+		WithStrategy(Exponential(2*time.Second, 0)),
+		WithMaxAttempts(5),
+	)
+
+	// 1 + 2 + 4 + 8 seconds between 5 attempts
+	assert.Equal(t, 15*time.Second, r.EstimateTotal(5))
+	assert.Equal(t, 15*time.Second, r.EstimateTotal(math.MaxInt))
+	assert.Equal(t, 3*time.Second, r.EstimateTotal(3))
+	assert.Equal(t, time.Duration(0), r.EstimateTotal(1))
+}
+
+func TestEstimateTotal_IncludesWorstCaseJitter(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		jitter retrierOpt
+		want   time.Duration
+	}{
+		"default jitter": {jitter: WithJitter(), want: 4 * (10*time.Second + time.Second)},
+		"jitter range":   {jitter: WithJitterRange(-1*time.Second, 3*time.Second), want: 4 * (10*time.Second + 3*time.Second)},
+		"full jitter":    {jitter: WithJitter(FullJitter), want: 4 * 10 * time.Second},
+		"plus minus":     {jitter: WithJitter(PlusMinus(2 * time.Second)), want: 4 * (10*time.Second + 2*time.Second)},
+		"decorrelated":   {jitter: WithJitter(DecorrelatedJitter), want: (10 + 30 + 90 + 270) * time.Second},
+	}
+
+	for name, test := range tests {
+		r := NewRetrier(
+			WithStrategy(Constant(10*time.Second)),
+			test.jitter,
+			WithMaxAttempts(5),
+		)
+		assert.Equal(t, test.want, r.EstimateTotal(5), name)
+	}
+}
+
+func TestEstimateTotal_IsCappedByMaxTotalSleep(t *testing.T) {
+	t.Parallel()
+
+	r := NewRetrier(
+		WithStrategy(Constant(10*time.Second)),
+		WithJitter(),
+		TryForever(),
+		WithMaxTotalSleep(35*time.Second),
+	)
+
+	assert.Equal(t, 35*time.Second, r.EstimateTotal(100))
+}
@@ -0,0 +1,21 @@
+package roko
+
+import "context"
+
+// Prepared runs an operation that's split into a prepare step, which is safe to retry, and a commit step, which isn't -
+// validating a payment and then charging for it, for example. prepare is retried using r until it succeeds, and then
+// commit is called exactly once with its result. If prepare never succeeds, commit isn't called at all, and Prepared
+// returns the error from the last attempt at prepare. Otherwise it returns commit's error, which is never retried.
+//
+// Keeping the two steps apart like this stops a failed commit from being retried along with the preparation, which is
+// easy to do by accident when both are done in one callback to Do
+func Prepared[T any](ctx context.Context, r *Retrier, prepare func(ctx context.Context) (T, error), commit func(ctx context.Context, prepared T) error) error {
+	prepared, err := DoFunc(ctx, r, func(r *Retrier) (T, error) {
+		return prepare(r.Context())
+	})
+	if err != nil {
+		return err
+	}
+
+	return commit(ctx, prepared)
+}
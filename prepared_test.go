@@ -0,0 +1,90 @@
+package roko
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func newPreparedRetrier() *Retrier {
+	return NewRetrier(WithMaxAttempts(3), WithStrategy(Constant(time.Millisecond)), WithSleepFunc(dummySleep))
+}
+
+func TestPrepared_RetriesPrepareThenCommitsOnce(t *testing.T) {
+	t.Parallel()
+
+	prepares, commits := 0, 0
+	err := Prepared(context.Background(), newPreparedRetrier(), func(ctx context.Context) (string, error) {
+		prepares++
+		if prepares < 3 {
+			return "", errDummy
+		}
+		return "quote-123", nil
+	}, func(ctx context.Context, quote string) error {
+		commits++
+		assert.Equal(t, "quote-123", quote)
+		return nil
+	})
+
+	assert.NilError(t, err)
+	assert.Equal(t, 3, prepares)
+	assert.Equal(t, 1, commits)
+}
+
+func TestPrepared_WhenCommitFails_DoesntRetry(t *testing.T) {
+	t.Parallel()
+
+	errDeclined := errors.New("card declined")
+	prepares, commits := 0, 0
+	err := Prepared(context.Background(), newPreparedRetrier(), func(ctx context.Context) (int, error) {
+		prepares++
+		return 42, nil
+	}, func(ctx context.Context, amount int) error {
+		commits++
+		return errDeclined
+	})
+
+	assert.ErrorIs(t, err, errDeclined)
+	assert.Equal(t, 1, prepares)
+	assert.Equal(t, 1, commits)
+}
+
+func TestPrepared_WhenPrepareNeverSucceeds_DoesntCommit(t *testing.T) {
+	t.Parallel()
+
+	err := Prepared(context.Background(), newPreparedRetrier(), func(ctx context.Context) (int, error) {
+		return 0, errDummy
+	}, func(ctx context.Context, amount int) error {
+		t.Error("commit shouldn't be called")
+		return nil
+	})
+
+	assert.ErrorIs(t, err, errDummy)
+}
+
+func TestPrepared_WithSoftFail_DoesntCommitWhenPrepareNeverSucceeds(t *testing.T) {
+	t.Parallel()
+
+	r := NewRetrier(
+		WithMaxAttempts(3),
+		WithStrategy(Constant(time.Millisecond)),
+		WithSleepFunc(dummySleep),
+		WithSoftFail(func(SoftFailure) { t.Error("Prepared shouldn't soft fail") }),
+	)
+
+	prepares, commits := 0, 0
+	err := Prepared(context.Background(), r, func(ctx context.Context) (int, error) {
+		prepares++
+		return 0, errDummy
+	}, func(ctx context.Context, amount int) error {
+		commits++
+		return nil
+	})
+
+	assert.ErrorIs(t, err, errDummy)
+	assert.Equal(t, 3, prepares)
+	assert.Equal(t, 0, commits)
+}
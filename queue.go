@@ -0,0 +1,402 @@
+package roko
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrQueueClosed is returned by Queue.Submit once the queue has started draining
+var ErrQueueClosed = errors.New("queue is closed")
+
+// Queue runs tasks on a pool of workers, retrying the ones that fail later on, rather than blocking a worker while they
+// wait - for when what's needed is "try this again later", rather than a loop that blocks until it succeeds. Each task
+// gets its own retrier, which decides how long to wait before it's tried again, and when to give up on it. The task's
+// attempts are counted, limited and reported just as they would be by DoWithContext, so options like
+// WithMaxTotalSleep, WithOnAttempt, WithBudget and WithSoftFail all apply, and the task's error is reported to
+// WithOnTaskFailed as DoWithContext would return it. The retrier waits on a timer between attempts, rather than
+// sleeping, so WithSleepFunc, WithBackpressure's pauses and WithTrigger don't apply.
+//
+// If the queue has a Store, tasks submitted with SubmitKeyed have their state saved after each failed attempt, and
+// can be picked up again with Resume after the process restarts.
+//
+// A Queue is safe to use concurrently
+type Queue struct {
+	newRetrier   func() *Retrier
+	onFailed     func(error)
+	store        Store
+	onStoreError func(key string, err error)
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	ready   []*queuedTask
+	timers  map[*queuedTask]*time.Timer
+	pending int  // tasks that have been submitted but haven't finished, whether they're running, ready or waiting
+	closed  bool // whether the queue has stopped accepting new tasks
+	stopped bool // whether the queue has stopped running tasks
+
+	workers sync.WaitGroup
+}
+
+type queuedTask struct {
+	do       func(*Retrier) error
+	loop     *loop
+	state    *RetryState    // nil unless the task was submitted with a key
+	attempts []AttemptEvent // The task's attempts, if its retrier soft fails (see WithSoftFail)
+}
+
+func (q *Queue) newQueuedTask(do func(*Retrier) error, r *Retrier, state *RetryState) *queuedTask {
+	t := &queuedTask{do: do, state: state}
+
+	var record func(AttemptEvent)
+	if r.softFail != nil {
+		record = func(e AttemptEvent) { t.attempts = append(t.attempts, e) }
+	}
+	t.loop = r.newLoop(q.ctx, record)
+	return t
+}
+
+type queueOpt func(*queueConfig)
+
+type queueConfig struct {
+	workers      int
+	onFailed     func(error)
+	store        Store
+	onStoreError func(key string, err error)
+}
+
+// WithWorkers sets the number of tasks a queue runs at once. The default is 1
+func WithWorkers(n int) queueOpt {
+	if n <= 0 {
+		panic("queues must have at least one worker")
+	}
+
+	return func(c *queueConfig) {
+		c.workers = n
+	}
+}
+
+// WithOnTaskFailed sets a function that a queue calls with the last error from a task that it's given up on, either
+// because the task's retrier gave up, or because the queue was stopped before the task could succeed (in which case the
+// error is an InterruptedError, as it would be from DoWithContext)
+func WithOnTaskFailed(f func(error)) queueOpt {
+	return func(c *queueConfig) {
+		c.onFailed = f
+	}
+}
+
+// WithStore sets the store that a queue saves the state of keyed tasks in
+func WithStore(s Store) queueOpt {
+	return func(c *queueConfig) {
+		c.store = s
+	}
+}
+
+// WithOnStoreError sets a function that a queue calls when it can't save or delete the state of a keyed task. The
+// task carries on regardless, but may be lost, or run again, if the process restarts
+func WithOnStoreError(f func(key string, err error)) queueOpt {
+	return func(c *queueConfig) {
+		c.onStoreError = f
+	}
+}
+
+// NewQueue returns a queue that's ready to accept tasks, with each task getting a new retrier from newRetrier
+func NewQueue(newRetrier func() *Retrier, opts ...queueOpt) *Queue {
+	c := &queueConfig{workers: 1, onFailed: func(error) {}, onStoreError: func(string, error) {}}
+	for _, o := range opts {
+		o(c)
+	}
+
+	q := &Queue{
+		newRetrier:   newRetrier,
+		onFailed:     c.onFailed,
+		store:        c.store,
+		onStoreError: c.onStoreError,
+		timers:       map[*queuedTask]*time.Timer{},
+	}
+	q.cond = sync.NewCond(&q.mu)
+	q.ctx, q.cancel = context.WithCancel(context.Background())
+
+	for i := 0; i < c.workers; i++ {
+		q.workers.Add(1)
+		go q.work()
+	}
+
+	return q
+}
+
+// Submit adds a task to the queue. As with Do, the task is passed its retrier, and can use it to call Break or
+// SetNextInterval. r.Context() carries the task's AttemptInfo (see AttemptFromContext), and is cancelled if the queue is
+// stopped while the task is running
+func (q *Queue) Submit(task func(r *Retrier) error) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return ErrQueueClosed
+	}
+
+	q.pending++
+	q.ready = append(q.ready, q.newQueuedTask(task, q.newRetrier(), nil))
+	q.cond.Signal()
+	return nil
+}
+
+// SubmitKeyed adds a task to the queue, like Submit, and saves its state in the queue's store under key, along with data,
+// which should hold whatever's needed to recreate the task if it has to be resumed. The state is deleted once the task
+// succeeds or is given up on. If the state can't be saved, the task isn't added to the queue. SubmitKeyed panics if the
+// queue doesn't have a store
+func (q *Queue) SubmitKeyed(key string, data []byte, task func(r *Retrier) error) error {
+	if q.store == nil {
+		panic("SubmitKeyed needs a queue with a store")
+	}
+
+	state := &RetryState{Key: key, Data: data}
+	if err := q.store.Save(context.Background(), *state); err != nil {
+		return err
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		q.forget(state)
+		return ErrQueueClosed
+	}
+
+	q.pending++
+	q.ready = append(q.ready, q.newQueuedTask(task, q.newRetrier(), state))
+	q.cond.Signal()
+	return nil
+}
+
+// Resume loads the tasks left in the queue's store by a previous process, and adds them back to the queue. restore is
+// called with the state of each one, and returns the task to run, or nil if it should be dropped. Each resumed task's
+// retrier picks up where it left off, with the attempts it's already made counted against it, and its next attempt is
+// made when the previous process planned to make it. Resume returns the number of tasks that were resumed, and panics
+// if the queue doesn't have a store
+func (q *Queue) Resume(ctx context.Context, restore func(state RetryState) func(r *Retrier) error) (int, error) {
+	if q.store == nil {
+		panic("Resume needs a queue with a store")
+	}
+
+	states, err := q.store.Load(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	resumed := 0
+	for i := range states {
+		state := &states[i]
+
+		task := restore(*state)
+		if task == nil {
+			q.forget(state)
+			continue
+		}
+
+		r := q.newRetrier()
+		for j := 0; j < state.Attempts; j++ {
+			r.MarkAttempt()
+		}
+
+		q.mu.Lock()
+		if q.closed {
+			q.mu.Unlock()
+			return resumed, ErrQueueClosed
+		}
+		q.pending++
+		q.schedule(q.newQueuedTask(task, r, state), state.NextAttempt.Sub(r.now()))
+		q.mu.Unlock()
+		resumed++
+	}
+
+	return resumed, nil
+}
+
+// Drain stops the queue from accepting new tasks, and waits for the tasks already in it to finish, including any
+// retries they need. If ctx is cancelled first, Drain stops the queue: tasks that are waiting to be retried are given
+// up on, the contexts of running tasks are cancelled, and Drain returns ctx's error once they've returned
+// Keyed tasks that are given up on this way keep their state in the store, so that they can be resumed later
+func (q *Queue) Drain(ctx context.Context) error {
+	q.mu.Lock()
+	q.closed = true
+	q.cond.Broadcast()
+	q.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		q.workers.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+	}
+
+	q.stop()
+	<-done
+	return ctx.Err()
+}
+
+// stop stops the queue running tasks, giving up on the ones that haven't finished
+func (q *Queue) stop() {
+	q.mu.Lock()
+	q.stopped = true
+	q.cancel()
+
+	dropped := q.ready
+	for t, timer := range q.timers {
+		timer.Stop()
+		delete(q.timers, t)
+		dropped = append(dropped, t)
+	}
+	q.ready = nil
+	q.pending -= len(dropped)
+
+	q.cond.Broadcast()
+	q.mu.Unlock()
+
+	for _, t := range dropped {
+		q.interrupt(t, q.ctx.Err())
+	}
+}
+
+func (q *Queue) work() {
+	defer q.workers.Done()
+
+	for {
+		q.mu.Lock()
+		for len(q.ready) == 0 && !q.stopped && !(q.closed && q.pending == 0) {
+			q.cond.Wait()
+		}
+		if len(q.ready) == 0 || q.stopped {
+			q.mu.Unlock()
+			return
+		}
+		t := q.ready[0]
+		q.ready = q.ready[1:]
+		q.mu.Unlock()
+
+		q.run(t)
+	}
+}
+
+// run makes one attempt at a task, and either finishes it or schedules its next attempt
+func (q *Queue) run(t *queuedTask) {
+	r := t.loop.r
+	if t.loop.info.Attempt == 0 && r.requireBound && r.isUnbounded(q.ctx) {
+		q.finish(t, ErrUnbounded)
+		return
+	}
+
+	interval, _, done, err := t.loop.step(t.do)
+	if done {
+		q.finish(t, err)
+		return
+	}
+
+	if t.state != nil {
+		t.state.Attempts = r.AttemptCount()
+		t.state.NextAttempt = r.now().Add(interval)
+		t.state.LastError = t.loop.lastErr.Error()
+		if serr := q.store.Save(context.Background(), *t.state); serr != nil {
+			q.onStoreError(t.state.Key, serr)
+		}
+	}
+
+	q.mu.Lock()
+	if q.stopped {
+		q.mu.Unlock()
+		t.state = nil // Leave its state in the store, so that it can be resumed
+		q.interrupt(t, q.ctx.Err())
+		q.finish(t, nil)
+		return
+	}
+	q.schedule(t, interval)
+	q.mu.Unlock()
+}
+
+// schedule makes a task ready to run again after d. q.mu must be held
+func (q *Queue) schedule(t *queuedTask, d time.Duration) {
+	if d <= 0 {
+		q.ready = append(q.ready, t)
+		q.cond.Signal()
+		return
+	}
+
+	q.timers[t] = time.AfterFunc(d, func() {
+		q.mu.Lock()
+		defer q.mu.Unlock()
+
+		if _, ok := q.timers[t]; !ok {
+			return // the queue was stopped, and has already given up on the task
+		}
+		delete(q.timers, t)
+		q.ready = append(q.ready, t)
+		q.cond.Signal()
+	})
+}
+
+// finish records that a task has finished, reporting its error if it failed, and deleting its state if it had any
+func (q *Queue) finish(t *queuedTask, err error) {
+	if err != nil {
+		q.report(t, err)
+	}
+	if t.state != nil {
+		q.forget(t.state)
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.pending--
+	if q.pending == 0 {
+		q.cond.Broadcast()
+	}
+}
+
+// interrupt gives up on a task that's waiting for its next attempt because the queue has been stopped, recording it in
+// the stats of the task's retrier as DoWithContext would record a loop whose context was cancelled (see
+// InterruptedError), and then reporting its error. It doesn't count the task as finished: stop has already done so
+func (q *Queue) interrupt(t *queuedTask, cause error) {
+	var err error = &InterruptedError{Err: t.loop.lastErr, Cause: cause}
+	if t.loop.lastErr == nil {
+		err = cause
+	}
+
+	r := t.loop.r
+	r.mu.Lock()
+	r.recordLoop(t.loop.info.Attempt, err, false)
+	r.nextAttemptAt = time.Time{}
+	r.mu.Unlock()
+
+	q.report(t, err)
+}
+
+// report reports the error from a task that's been given up on, as DoWithContext would return it: passing it to the
+// retrier's soft fail func if it has one, and to the queue's WithOnTaskFailed func otherwise
+func (q *Queue) report(t *queuedTask, err error) {
+	r := t.loop.r
+	if r.name != "" {
+		err = &NamedError{Name: r.name, Err: err}
+	}
+	if r.softFail != nil && !errors.Is(err, ErrUnbounded) {
+		r.softFail(SoftFailure{Name: r.name, Err: err, Attempts: t.attempts})
+		return
+	}
+	q.onFailed(err)
+}
+
+// forget deletes a task's state from the store
+func (q *Queue) forget(state *RetryState) {
+	if err := q.store.Delete(context.Background(), state.Key); err != nil {
+		q.onStoreError(state.Key, err)
+	}
+}
@@ -0,0 +1,352 @@
+package roko
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+	"gotest.tools/v3/assert/opt"
+)
+
+func newQueueRetrier() *Retrier {
+	return NewRetrier(WithMaxAttempts(3), WithStrategy(Constant(10*time.Millisecond)))
+}
+
+func TestQueue_RunsTasks(t *testing.T) {
+	t.Parallel()
+
+	q := NewQueue(newQueueRetrier, WithWorkers(4))
+
+	var ran int32
+	for i := 0; i < 20; i++ {
+		assert.NilError(t, q.Submit(func(r *Retrier) error {
+			atomic.AddInt32(&ran, 1)
+			return nil
+		}))
+	}
+
+	assert.NilError(t, q.Drain(context.Background()))
+	assert.Equal(t, int32(20), atomic.LoadInt32(&ran))
+}
+
+func TestQueue_RetriesFailedTasksLater(t *testing.T) {
+	t.Parallel()
+
+	var mu sync.Mutex
+	order := []string{}
+	record := func(s string) {
+		mu.Lock()
+		defer mu.Unlock()
+		order = append(order, s)
+	}
+
+	q := NewQueue(newQueueRetrier)
+
+	assert.NilError(t, q.Submit(func(r *Retrier) error {
+		record("flaky")
+		if r.AttemptCount() == 0 {
+			return errDummy
+		}
+		return nil
+	}))
+	assert.NilError(t, q.Submit(func(r *Retrier) error {
+		record("steady")
+		return nil
+	}))
+
+	assert.NilError(t, q.Drain(context.Background()))
+
+	// The flaky task's retry doesn't hold up the one behind it, even with a single worker
+	assert.DeepEqual(t, []string{"flaky", "steady", "flaky"}, order)
+}
+
+func TestQueue_GivesUpWhenTheRetrierDoes(t *testing.T) {
+	t.Parallel()
+
+	var failed []error
+	var mu sync.Mutex
+	q := NewQueue(newQueueRetrier, WithOnTaskFailed(func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		failed = append(failed, err)
+	}))
+
+	var attempts int32
+	assert.NilError(t, q.Submit(func(r *Retrier) error {
+		atomic.AddInt32(&attempts, 1)
+		return errDummy
+	}))
+	assert.NilError(t, q.Submit(func(r *Retrier) error {
+		atomic.AddInt32(&attempts, 1)
+		return Unrecoverable(errDummy)
+	}))
+
+	assert.NilError(t, q.Drain(context.Background()))
+	assert.Equal(t, int32(4), atomic.LoadInt32(&attempts))
+	assert.Equal(t, 2, len(failed))
+	for _, err := range failed {
+		assert.ErrorIs(t, err, errDummy)
+	}
+}
+
+func TestQueue_RespectsTheRetriersLimitsAndHooks(t *testing.T) {
+	t.Parallel()
+
+	var mu sync.Mutex
+	var events []AttemptEvent
+	var failed []error
+	r := NewRetrier(
+		WithName("sync"),
+		TryForever(),
+		WithStrategy(Constant(10*time.Millisecond)),
+		WithMaxTotalSleep(25*time.Millisecond),
+		WithOnAttempt(func(e AttemptEvent) {
+			mu.Lock()
+			defer mu.Unlock()
+			events = append(events, e)
+		}),
+	)
+
+	q := NewQueue(func() *Retrier { return r }, WithOnTaskFailed(func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		failed = append(failed, err)
+	}))
+	assert.NilError(t, q.Submit(func(*Retrier) error { return errDummy }))
+	assert.NilError(t, q.Drain(context.Background()))
+
+	// It waits 10ms twice, and then gives up, rather than going past the 25ms limit
+	assert.Equal(t, 3, r.Attempts())
+	assert.Equal(t, 3, len(events))
+	assert.Assert(t, events[2].Final)
+	assert.Equal(t, 10*time.Millisecond, events[0].NextInterval)
+
+	assert.Equal(t, 1, len(failed))
+	assert.ErrorIs(t, failed[0], errDummy)
+	var named *NamedError
+	assert.Assert(t, errors.As(failed[0], &named))
+
+	stats := r.Stats()
+	assert.Equal(t, 1, stats.Loops)
+	assert.Equal(t, 1, stats.Exhaustions)
+}
+
+func TestQueue_SoftFailingTasks(t *testing.T) {
+	t.Parallel()
+
+	var failures []SoftFailure
+	q := NewQueue(func() *Retrier {
+		return NewRetrier(
+			WithMaxAttempts(2),
+			WithStrategy(Constant(time.Millisecond)),
+			WithSoftFail(func(f SoftFailure) { failures = append(failures, f) }),
+		)
+	}, WithOnTaskFailed(func(err error) { t.Errorf("unexpected failure: %v", err) }))
+
+	assert.NilError(t, q.Submit(func(*Retrier) error { return errDummy }))
+	assert.NilError(t, q.Drain(context.Background()))
+
+	assert.Equal(t, 1, len(failures))
+	assert.ErrorIs(t, failures[0].Err, errDummy)
+	assert.Equal(t, 2, len(failures[0].Attempts))
+}
+
+func TestQueue_SubmitAfterDrain_ReturnsErrQueueClosed(t *testing.T) {
+	t.Parallel()
+
+	q := NewQueue(newQueueRetrier)
+	assert.NilError(t, q.Drain(context.Background()))
+
+	err := q.Submit(func(r *Retrier) error { return nil })
+	assert.ErrorIs(t, err, ErrQueueClosed)
+}
+
+func TestQueue_WhenDrainIsCancelled_StopsTheQueue(t *testing.T) {
+	t.Parallel()
+
+	var failed int32
+	q := NewQueue(func() *Retrier {
+		return NewRetrier(TryForever(), WithStrategy(Constant(time.Hour)))
+	}, WithWorkers(2), WithOnTaskFailed(func(err error) {
+		if errors.Is(err, context.Canceled) || errors.Is(err, errDummy) {
+			atomic.AddInt32(&failed, 1)
+		}
+	}))
+
+	started := make(chan struct{})
+	// This task will wait an hour to be retried
+	assert.NilError(t, q.Submit(func(r *Retrier) error { return errDummy }))
+	// This one runs until the queue is stopped
+	assert.NilError(t, q.Submit(func(r *Retrier) error {
+		close(started)
+		<-r.Context().Done()
+		return r.Context().Err()
+	}))
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := q.Drain(ctx)
+
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&failed))
+}
+
+func TestQueue_SubmitKeyed_DeletesStateOnceTheTaskFinishes(t *testing.T) {
+	t.Parallel()
+
+	store := NewMemoryStore()
+	q := NewQueue(newQueueRetrier, WithStore(store))
+
+	assert.NilError(t, q.SubmitKeyed("flaky", nil, func(r *Retrier) error {
+		if r.AttemptCount() == 0 {
+			return errDummy
+		}
+		return nil
+	}))
+	assert.NilError(t, q.SubmitKeyed("broken", nil, func(r *Retrier) error {
+		return errDummy
+	}))
+
+	assert.NilError(t, q.Drain(context.Background()))
+
+	states, err := store.Load(context.Background())
+	assert.NilError(t, err)
+	assert.Equal(t, 0, len(states))
+}
+
+func TestQueue_Resume_PicksUpWhereTheLastQueueLeftOff(t *testing.T) {
+	t.Parallel()
+
+	store := NewMemoryStore()
+	newRetrier := func() *Retrier {
+		return NewRetrier(WithMaxAttempts(3), WithStrategy(Constant(time.Hour)))
+	}
+
+	q := NewQueue(newRetrier, WithStore(store))
+	assert.NilError(t, q.SubmitKeyed("job", []byte("payload"), func(r *Retrier) error {
+		return errDummy
+	}))
+
+	// The task is waiting for an hour before its next attempt, so stopping the queue leaves it in the store
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, q.Drain(ctx), context.DeadlineExceeded)
+
+	states, err := store.Load(context.Background())
+	assert.NilError(t, err)
+	assert.Equal(t, 1, len(states))
+	assert.Equal(t, 1, states[0].Attempts)
+	assert.Equal(t, errDummy.Error(), states[0].LastError)
+	assert.Assert(t, time.Until(states[0].NextAttempt) > 59*time.Minute)
+
+	// Pretend the hour has passed
+	states[0].NextAttempt = time.Time{}
+	assert.NilError(t, store.Save(context.Background(), states[0]))
+
+	var attempts []int
+	var data string
+	q = NewQueue(newRetrier, WithStore(store), WithOnTaskFailed(func(err error) {
+		t.Errorf("task failed: %v", err)
+	}))
+	n, err := q.Resume(context.Background(), func(state RetryState) func(r *Retrier) error {
+		data = string(state.Data)
+		return func(r *Retrier) error {
+			attempts = append(attempts, r.AttemptCount())
+			return nil
+		}
+	})
+	assert.NilError(t, err)
+	assert.Equal(t, 1, n)
+	assert.NilError(t, q.Drain(context.Background()))
+
+	assert.Equal(t, "payload", data)
+	assert.DeepEqual(t, []int{1}, attempts)
+
+	states, err = store.Load(context.Background())
+	assert.NilError(t, err)
+	assert.Equal(t, 0, len(states))
+}
+
+func TestQueue_SavesAndResumesStatesUsingTheRetriersClock(t *testing.T) {
+	t.Parallel()
+
+	// The retrier's clock is two hours ahead of the wall clock
+	clock := NewSimulatedClock(time.Now().Add(2 * time.Hour))
+	newRetrier := func() *Retrier {
+		return NewRetrier(WithMaxAttempts(3), WithStrategy(Constant(time.Hour)), WithClock(clock))
+	}
+
+	store := NewMemoryStore()
+	q := NewQueue(newRetrier, WithStore(store))
+	assert.NilError(t, q.SubmitKeyed("job", nil, func(r *Retrier) error {
+		return errDummy
+	}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, q.Drain(ctx), context.DeadlineExceeded)
+
+	states, err := store.Load(context.Background())
+	assert.NilError(t, err)
+	assert.Equal(t, 1, len(states))
+	assert.Check(t, cmp.DeepEqual(clock.Now().Add(time.Hour), states[0].NextAttempt, opt.TimeWithThreshold(time.Second)))
+
+	// By the retrier's clock, the next attempt is already due, even though it's an hour away by the wall clock
+	states[0].NextAttempt = time.Now().Add(time.Hour)
+	assert.NilError(t, store.Save(context.Background(), states[0]))
+
+	q = NewQueue(newRetrier, WithStore(store))
+	n, err := q.Resume(context.Background(), func(RetryState) func(r *Retrier) error {
+		return func(*Retrier) error { return nil }
+	})
+	assert.NilError(t, err)
+	assert.Equal(t, 1, n)
+
+	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	assert.NilError(t, q.Drain(ctx))
+}
+
+func TestQueue_SubmitKeyedWithoutAStore_Panics(t *testing.T) {
+	t.Parallel()
+
+	defer func() {
+		assert.Assert(t, recover() != nil)
+	}()
+
+	q := NewQueue(newQueueRetrier)
+	defer q.Drain(context.Background())
+	_ = q.SubmitKeyed("job", nil, func(r *Retrier) error { return nil })
+}
+
+func TestQueue_TasksHaveAttemptInfo(t *testing.T) {
+	t.Parallel()
+
+	infos := []AttemptInfo{}
+	q := NewQueue(func() *Retrier {
+		return NewRetrier(WithMaxAttempts(3), WithStrategy(Constant(time.Millisecond)), WithName("sync"))
+	})
+
+	assert.NilError(t, q.Submit(func(r *Retrier) error {
+		info, ok := AttemptFromContext(r.Context())
+		assert.Check(t, ok)
+		infos = append(infos, info)
+		if info.Attempt < 2 {
+			return errDummy
+		}
+		return nil
+	}))
+	assert.NilError(t, q.Drain(context.Background()))
+
+	assert.Equal(t, 2, len(infos))
+	assert.Equal(t, infos[0].ID, infos[1].ID)
+	assert.Equal(t, 1, infos[0].Attempt)
+	assert.Equal(t, 2, infos[1].Attempt)
+	assert.Equal(t, "sync", infos[1].Name)
+}
@@ -0,0 +1,104 @@
+package roko
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// defaultStablePeriod is how long a connection has to last for KeepConnected to treat it as stable, unless it's given
+// WithStablePeriod
+const defaultStablePeriod = time.Minute
+
+type connectionOpt func(*connectionConfig)
+
+type connectionConfig struct {
+	stablePeriod time.Duration
+	onDisconnect func(err error)
+}
+
+// WithStablePeriod sets how long a connection has to last before KeepConnected treats it as stable, and starts backing
+// off from scratch when it's lost. The default is a minute
+func WithStablePeriod(d time.Duration) connectionOpt {
+	if d <= 0 {
+		panic("stable periods must be positive")
+	}
+
+	return func(c *connectionConfig) {
+		c.stablePeriod = d
+	}
+}
+
+// WithOnDisconnect sets a function that KeepConnected calls with the reason each time a connection is lost, before it
+// reconnects
+func WithOnDisconnect(f func(err error)) connectionOpt {
+	return func(c *connectionConfig) {
+		c.onDisconnect = f
+	}
+}
+
+// KeepConnected keeps a connection to a pub-sub server (such as NATS, or a message broker) open, reconnecting whenever
+// it's lost, until ctx is cancelled. It's for clients that don't reconnect by themselves, or whose own reconnection
+// logic doesn't back off well.
+//
+// connect opens a connection. serve is then called with the connection, and should subscribe to whatever the client
+// needs (since subscriptions don't survive a reconnection), and then block until the connection is lost, returning why.
+// serve is responsible for closing the connection before it returns. For clients that report a lost connection through
+// a callback, serve can wait for the callback on a channel:
+//
+//	roko.KeepConnected(ctx, newRetrier, connect, func(ctx context.Context, conn *Conn) error {
+//		defer conn.Close()
+//		lost := make(chan error, 1)
+//		conn.OnDisconnect(func(err error) { lost <- err })
+//		if err := conn.Subscribe("events", handle); err != nil {
+//			return err
+//		}
+//		select {
+//		case err := <-lost:
+//			return err
+//		case <-ctx.Done():
+//			return ctx.Err()
+//		}
+//	})
+//
+// Failures to connect or subscribe, and connections that are lost before they've been up for the stable period (see
+// WithStablePeriod), are retried using a retrier from newRetrier, so a server that keeps dropping connections is backed
+// off from. Once a connection has been stable, losing it starts again with a new retrier, reconnecting straight away.
+//
+// KeepConnected returns nil if serve does, when the connection was closed on purpose. Otherwise, it returns the last
+// error once the retrier gives up, or the context's error (see InterruptedError) once ctx is done. Errors from connect or
+// serve that are wrapped with Unrecoverable stop it straight away, however long the connection had been up
+func KeepConnected[C any](ctx context.Context, newRetrier func() *Retrier, connect func(ctx context.Context) (C, error), serve func(ctx context.Context, conn C) error, opts ...connectionOpt) error {
+	c := &connectionConfig{stablePeriod: defaultStablePeriod, onDisconnect: func(error) {}}
+	for _, o := range opts {
+		o(c)
+	}
+
+	for {
+		stable := false
+		err := newRetrier().run(ctx, func(r *Retrier) error {
+			conn, err := connect(r.Context())
+			if err != nil {
+				return err
+			}
+
+			connectedAt := time.Now()
+			// serve gets ctx rather than the attempt's context, which could have a timeout that's meant for connecting
+			err = serve(ctx, conn)
+			if err == nil || ctx.Err() != nil {
+				return err
+			}
+
+			c.onDisconnect(err)
+			if time.Since(connectedAt) >= c.stablePeriod {
+				stable = true
+				r.Break() // Start again with a new retrier, rather than carrying on backing off from earlier failures
+			}
+			return err
+		}, nil)
+
+		if !stable || ctx.Err() != nil || errors.Is(err, ErrUnrecoverable) {
+			return err
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package roko
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+var errConnectionLost = errors.New("connection lost")
+
+type testConn struct{ id int }
+
+func TestKeepConnected_RetriesConnectingUntilItSucceeds(t *testing.T) {
+	t.Parallel()
+
+	connects := 0
+	served := []int{}
+	err := KeepConnected(context.Background(), func() *Retrier {
+		return NewRetrier(WithMaxAttempts(5), WithStrategy(Constant(0)))
+	}, func(ctx context.Context) (testConn, error) {
+		connects++
+		if connects < 3 {
+			return testConn{}, errDummy
+		}
+		return testConn{id: connects}, nil
+	}, func(ctx context.Context, conn testConn) error {
+		served = append(served, conn.id)
+		return nil
+	})
+
+	assert.NilError(t, err)
+	assert.Equal(t, connects, 3)
+	assert.DeepEqual(t, served, []int{3})
+}
+
+func TestKeepConnected_BacksOffFromUnstableConnections(t *testing.T) {
+	t.Parallel()
+
+	connects, disconnects := 0, 0
+	err := KeepConnected(context.Background(), func() *Retrier {
+		return NewRetrier(WithMaxAttempts(3), WithStrategy(Constant(0)))
+	}, func(ctx context.Context) (testConn, error) {
+		connects++
+		return testConn{id: connects}, nil
+	}, func(ctx context.Context, conn testConn) error {
+		return errConnectionLost
+	}, WithOnDisconnect(func(err error) {
+		assert.Check(t, errors.Is(err, errConnectionLost))
+		disconnects++
+	}))
+
+	assert.ErrorIs(t, err, errConnectionLost)
+	assert.Equal(t, connects, 3)
+	assert.Equal(t, disconnects, 3)
+}
+
+func TestKeepConnected_StartsAgainAfterAStableConnection(t *testing.T) {
+	t.Parallel()
+
+	retriers, connects := 0, 0
+	err := KeepConnected(context.Background(), func() *Retrier {
+		retriers++
+		return NewRetrier(WithMaxAttempts(1))
+	}, func(ctx context.Context) (testConn, error) {
+		connects++
+		return testConn{id: connects}, nil
+	}, func(ctx context.Context, conn testConn) error {
+		if conn.id == 4 {
+			return nil
+		}
+		time.Sleep(20 * time.Millisecond)
+		return errConnectionLost
+	}, WithStablePeriod(10*time.Millisecond))
+
+	assert.NilError(t, err)
+	assert.Equal(t, connects, 4)
+	assert.Equal(t, retriers, 4)
+}
+
+func TestKeepConnected_StopsOnUnrecoverableErrors(t *testing.T) {
+	t.Parallel()
+
+	errKicked := errors.New("kicked by the server")
+	connects := 0
+	err := KeepConnected(context.Background(), func() *Retrier {
+		return NewRetrier(TryForever(), WithStrategy(Constant(time.Millisecond)))
+	}, func(ctx context.Context) (testConn, error) {
+		connects++
+		return testConn{}, nil
+	}, func(ctx context.Context, conn testConn) error {
+		time.Sleep(20 * time.Millisecond)
+		return Unrecoverable(errKicked)
+	}, WithStablePeriod(10*time.Millisecond))
+
+	assert.ErrorIs(t, err, errKicked)
+	assert.Equal(t, connects, 1)
+}
+
+func TestKeepConnected_StopsWhenTheContextIsCancelled(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	err := KeepConnected(ctx, func() *Retrier {
+		return NewRetrier(TryForever(), WithStrategy(Constant(time.Millisecond)))
+	}, func(ctx context.Context) (testConn, error) {
+		return testConn{}, nil
+	}, func(ctx context.Context, conn testConn) error {
+		cancel()
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestWithStablePeriod_PanicsWhenNotPositive(t *testing.T) {
+	t.Parallel()
+
+	defer func() {
+		assert.Assert(t, recover() != nil)
+	}()
+
+	WithStablePeriod(0)
+}
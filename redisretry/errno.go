@@ -0,0 +1,13 @@
+//go:build !plan9
+
+package redisretry
+
+import "syscall"
+
+// transientErrnos are the network errors that IsTransient reports as transient, for connections that are reset,
+// refused or closed under a command, as they are while a server restarts or fails over
+var transientErrnos = []syscall.Errno{
+	syscall.ECONNRESET,
+	syscall.ECONNREFUSED,
+	syscall.EPIPE,
+}
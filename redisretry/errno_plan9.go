@@ -0,0 +1,9 @@
+//go:build plan9
+
+package redisretry
+
+import "syscall"
+
+// transientErrnos are the network errors that IsTransient reports as transient. Plan 9 reports errors as strings
+// rather than numbers, so there aren't any, but connection failures are still caught as net.Errors
+var transientErrnos = []syscall.Errno{}
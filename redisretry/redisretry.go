@@ -0,0 +1,167 @@
+// Package redisretry helps to retry Redis commands that fail because the server or cluster is temporarily unable to
+// serve them - while it's loading its dataset, failing over, or resharding - or because the connection dropped, while
+// giving up straight away on errors that retrying won't fix, such as a command run against the wrong type of key.
+//
+// It works with any Redis client library, by looking at the error replies Redis sends, which clients pass on as the
+// text of their errors.
+package redisretry
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/buildkite/roko"
+)
+
+// transientReplies are the codes at the start of Redis error replies that indicate a temporary condition, which might
+// clear up if the command is tried again
+var transientReplies = []string{
+	"LOADING",     // The server is loading its dataset into memory, after a restart
+	"BUSY",        // A script or function is running, and hasn't yielded
+	"TRYAGAIN",    // A multi-key command's keys are being migrated between cluster nodes
+	"CLUSTERDOWN", // The cluster can't serve requests, usually while it fails over
+	"MASTERDOWN",  // The replica has lost its link with its master
+	"READONLY",    // The node was demoted to a replica by a failover, and the client hasn't noticed yet
+	"NOREPLICAS",  // Not enough replicas acknowledged the write
+	"MOVED",       // The key's slot has moved to another node
+	"ASK",         // The key's slot is being migrated to another node
+}
+
+// nilReply is the error go-redis returns when a key doesn't exist. It isn't a failure, but it can't be retried either
+const nilReply = "redis: nil"
+
+// Redirect is a MOVED or ASK error reply from a Redis cluster node, saying that another node serves the key
+type Redirect struct {
+	Ask  bool   // Whether the slot is being migrated (ASK), rather than having moved (MOVED)
+	Slot int    // The hash slot of the key
+	Addr string // The address of the node to send the command to
+}
+
+// ParseRedirect parses a MOVED or ASK error reply, such as "MOVED 3999 127.0.0.1:6381". It returns false if err isn't
+// one. Cluster-aware clients follow redirects themselves, so they usually only reach callers when a client has an
+// out-of-date view of the cluster, or isn't cluster-aware at all
+func ParseRedirect(err error) (Redirect, bool) {
+	if err == nil {
+		return Redirect{}, false
+	}
+
+	fields := strings.Fields(reply(err))
+	if len(fields) != 3 || (fields[0] != "MOVED" && fields[0] != "ASK") {
+		return Redirect{}, false
+	}
+
+	slot, perr := strconv.Atoi(fields[1])
+	if perr != nil {
+		return Redirect{}, false
+	}
+
+	return Redirect{Ask: fields[0] == "ASK", Slot: slot, Addr: fields[2]}, true
+}
+
+// IsTransient reports whether err, returned by a Redis command, indicates a failure that might succeed if the command
+// is run again: one of the temporary error replies (such as LOADING or CLUSTERDOWN), or a network error
+func IsTransient(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	code, _, _ := strings.Cut(reply(err), " ")
+	for _, transient := range transientReplies {
+		if code == transient {
+			return true
+		}
+	}
+
+	for _, errno := range transientErrnos {
+		if errors.Is(err, errno) {
+			return true
+		}
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, net.ErrClosed)
+}
+
+// reply returns the Redis error reply in err's message. Client libraries (and callers) sometimes add context to the
+// front of the reply, as in "get user:1: LOADING Redis is loading the dataset in memory", so it's the first part of the
+// message, either at the start or after a ": ", that starts with an upper case word
+func reply(err error) string {
+	msg := err.Error()
+	for {
+		if isReplyCode(msg) {
+			return msg
+		}
+		i := strings.Index(msg, ": ")
+		if i < 0 {
+			return msg
+		}
+		msg = msg[i+2:]
+	}
+}
+
+// isReplyCode reports whether s starts with an upper case word, as Redis error replies do
+func isReplyCode(s string) bool {
+	code, _, _ := strings.Cut(s, " ")
+	if code == "" {
+		return false
+	}
+	for _, c := range code {
+		if c < 'A' || c > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+// Classify turns an error from a Redis command into one suitable for returning from a retrier's callback:
+//
+//   - go-redis's "redis: nil", for a key that doesn't exist, is wrapped with roko.Unrecoverable, since retrying won't
+//     make the key appear
+//   - MOVED and ASK redirects are retried straight away, by calling r.SetNextInterval(0), since they're not a sign of
+//     an overloaded server
+//   - other transient errors (see IsTransient) are returned as they are, to be retried
+//   - all other errors, including error replies like WRONGTYPE, NOSCRIPT or NOAUTH, are wrapped with roko.Unrecoverable
+func Classify(r *roko.Retrier, err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case err.Error() == nilReply:
+		return roko.Unrecoverable(err)
+	}
+
+	if _, ok := ParseRedirect(err); ok {
+		r.SetNextInterval(0)
+		return err
+	}
+
+	if IsTransient(err) {
+		return err
+	}
+	return roko.Unrecoverable(err)
+}
+
+// Do runs a Redis command with op, retrying it with r while it fails with transient errors (see Classify)
+func Do(ctx context.Context, r *roko.Retrier, op func(ctx context.Context) error) error {
+	return r.DoWithContext(ctx, func(r *roko.Retrier) error {
+		return Classify(r, op(r.Context()))
+	})
+}
+
+// NewRetrier returns a retrier suitable for a single Redis command. It makes up to 8 attempts over about 15 seconds,
+// starting with short waits, since most transient errors (like a failover) clear up within a second or two, and growing
+// exponentially, with jitter, for the ones that take longer, like LOADING
+func NewRetrier() *roko.Retrier {
+	return roko.NewRetrier(
+		roko.WithMaxAttempts(8),
+		roko.WithStrategy(roko.ExponentialSubsecond(500*time.Millisecond)),
+		roko.WithJitter(roko.EqualJitter),
+	)
+}
@@ -0,0 +1,133 @@
+package redisretry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/buildkite/roko"
+	"gotest.tools/v3/assert"
+)
+
+// redisError stands in for a client library's error reply type
+type redisError string
+
+func (e redisError) Error() string { return string(e) }
+
+func newTestRetrier() *roko.Retrier {
+	return roko.NewRetrier(
+		roko.WithMaxAttempts(3),
+		roko.WithStrategy(roko.Constant(time.Second)),
+		roko.WithSleepFunc(func(time.Duration) {}),
+	)
+}
+
+func TestIsTransient(t *testing.T) {
+	t.Parallel()
+
+	transient := []error{
+		redisError("LOADING Redis is loading the dataset in memory"),
+		redisError("CLUSTERDOWN The cluster is down"),
+		redisError("TRYAGAIN Multiple keys request during rehashing of slot"),
+		redisError("READONLY You can't write against a read only replica."),
+		redisError("MOVED 3999 127.0.0.1:6381"),
+		fmt.Errorf("get user:1: %w", redisError("LOADING Redis is loading the dataset in memory")),
+		io.EOF,
+		fmt.Errorf("read tcp 10.0.0.1:51000->10.0.0.2:6379: %w", syscall.ECONNRESET),
+		context.DeadlineExceeded,
+	}
+	for _, err := range transient {
+		assert.Check(t, IsTransient(err), "%v", err)
+	}
+
+	permanent := []error{
+		nil,
+		redisError("WRONGTYPE Operation against a key holding the wrong kind of value"),
+		redisError("NOSCRIPT No matching script. Please use EVAL."),
+		redisError("ERR unknown command 'FOO'"),
+		redisError("LOADINGX not a real reply"),
+		errors.New("redis: nil"),
+	}
+	for _, err := range permanent {
+		assert.Check(t, !IsTransient(err), "%v", err)
+	}
+}
+
+func TestParseRedirect(t *testing.T) {
+	t.Parallel()
+
+	redirect, ok := ParseRedirect(redisError("MOVED 3999 127.0.0.1:6381"))
+	assert.Assert(t, ok)
+	assert.Equal(t, redirect, Redirect{Slot: 3999, Addr: "127.0.0.1:6381"})
+
+	redirect, ok = ParseRedirect(fmt.Errorf("set: %w", redisError("ASK 12182 10.0.0.3:6379")))
+	assert.Assert(t, ok)
+	assert.Equal(t, redirect, Redirect{Ask: true, Slot: 12182, Addr: "10.0.0.3:6379"})
+
+	for _, err := range []error{nil, redisError("MOVED soon"), redisError("CLUSTERDOWN The cluster is down")} {
+		_, ok := ParseRedirect(err)
+		assert.Check(t, !ok, "%v", err)
+	}
+}
+
+func TestClassify(t *testing.T) {
+	t.Parallel()
+
+	r := newTestRetrier()
+	assert.NilError(t, Classify(r, nil))
+
+	loading := redisError("LOADING Redis is loading the dataset in memory")
+	assert.Equal(t, Classify(r, loading), error(loading))
+
+	err := Classify(r, redisError("WRONGTYPE Operation against a key holding the wrong kind of value"))
+	assert.ErrorIs(t, err, roko.ErrUnrecoverable)
+
+	err = Classify(r, errors.New("redis: nil"))
+	assert.ErrorIs(t, err, roko.ErrUnrecoverable)
+}
+
+func TestDo_RetriesRedirectsStraightAway(t *testing.T) {
+	t.Parallel()
+
+	var slept []time.Duration
+	r := roko.NewRetrier(
+		roko.WithMaxAttempts(3),
+		roko.WithStrategy(roko.Constant(time.Second)),
+		roko.WithSleepFunc(func(d time.Duration) { slept = append(slept, d) }),
+	)
+
+	calls := 0
+	err := Do(context.Background(), r, func(ctx context.Context) error {
+		calls++
+		switch calls {
+		case 1:
+			return redisError("MOVED 3999 127.0.0.1:6381")
+		case 2:
+			return redisError("CLUSTERDOWN The cluster is down")
+		default:
+			return nil
+		}
+	})
+
+	assert.NilError(t, err)
+	assert.Equal(t, calls, 3)
+	assert.DeepEqual(t, slept, []time.Duration{0, time.Second})
+}
+
+func TestDo_GivesUpOnPermanentErrors(t *testing.T) {
+	t.Parallel()
+
+	calls := 0
+	errWrongType := redisError("WRONGTYPE Operation against a key holding the wrong kind of value")
+	err := Do(context.Background(), newTestRetrier(), func(ctx context.Context) error {
+		calls++
+		return errWrongType
+	})
+
+	assert.ErrorIs(t, err, errWrongType)
+	assert.Equal(t, calls, 1)
+}
@@ -0,0 +1,87 @@
+package roko
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Recording records the intervals a retrier actually waited between attempts, after jitter, SetNextInterval and every
+// other adjustment, so that the schedule can be replayed later with Replay. Pass it to a retrier with WithRecording.
+// If the retrier is shared between loops, the intervals from all of them are recorded in the order they were chosen.
+//
+// A Recording is safe to use concurrently
+type Recording struct {
+	mu        sync.Mutex
+	intervals []time.Duration
+}
+
+// WithRecording makes the retrier record the interval it chooses after each failed attempt in rec
+func WithRecording(rec *Recording) retrierOpt {
+	return WithOnAttempt(func(e AttemptEvent) {
+		if e.Final {
+			return
+		}
+
+		rec.mu.Lock()
+		defer rec.mu.Unlock()
+		rec.intervals = append(rec.intervals, e.NextInterval)
+	})
+}
+
+// Intervals returns the intervals recorded so far, in order
+func (rec *Recording) Intervals() []time.Duration {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	return append([]time.Duration(nil), rec.intervals...)
+}
+
+// String returns the recorded intervals as a comma-separated list, such as "1.204s,2.91s,4.5s", for logging. The
+// list can be turned back into intervals with ParseIntervals. Durations are formatted to the nanosecond, so nothing is
+// lost along the way
+func (rec *Recording) String() string {
+	intervals := rec.Intervals()
+	parts := make([]string, len(intervals))
+	for i, interval := range intervals {
+		parts[i] = interval.String()
+	}
+	return strings.Join(parts, ",")
+}
+
+// ParseIntervals parses a comma-separated list of intervals, as returned by Recording.String
+func ParseIntervals(s string) ([]time.Duration, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	parts := strings.Split(s, ",")
+	intervals := make([]time.Duration, len(parts))
+	for i, part := range parts {
+		interval, err := time.ParseDuration(strings.TrimSpace(part))
+		if err != nil {
+			return nil, fmt.Errorf("parsing interval %d: %w", i, err)
+		}
+		intervals[i] = interval
+	}
+	return intervals, nil
+}
+
+// Replay returns a strategy that waits for each of the given intervals in turn - typically ones captured with a
+// Recording - so that a schedule that led to a timing-dependent failure can be reproduced exactly. Once the intervals
+// run out, it keeps waiting for the last one, so the retrier should usually be given WithMaxAttempts(len(intervals)+1).
+//
+// The intervals are used as they are, without jitter, since the jitter that was chosen is already part of them. For the
+// same reason, Replay shouldn't be combined with WithJitter, or with anything else that adjusts intervals (such as
+// WithBusinessHours). Replay panics if it's given no intervals, or a negative one
+func Replay(intervals ...time.Duration) (Strategy, string) {
+	intervals = checkIntervals(replayStrategy, intervals)
+	return func(r *Retrier) time.Duration {
+		attempt := r.AttemptCount()
+		if attempt >= len(intervals) {
+			return intervals[len(intervals)-1]
+		}
+		return intervals[attempt]
+	}, fmt.Sprintf("%s(%d intervals)", replayStrategy, len(intervals))
+}
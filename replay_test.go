@@ -0,0 +1,96 @@
+package roko
+
+import (
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestReplay_ReproducesARecordedSchedule(t *testing.T) {
+	t.Parallel()
+
+	rec := &Recording{}
+	original := newInsomniac()
+	err := NewRetrier(
+		WithMaxAttempts(6),
+		WithStrategy(Exponential(2*time.Second, 0)),
+		WithJitter(FullJitter),
+		WithRecording(rec),
+		WithSleepFunc(original.sleep),
+	).Do(func(r *Retrier) error {
+		if r.AttemptCount() == 2 {
+			r.SetNextInterval(7 * time.Second)
+		}
+		return errDummy
+	})
+	assert.ErrorIs(t, err, errDummy)
+	assert.DeepEqual(t, original.sleepIntervals, rec.Intervals(), DurationExact())
+	assert.Equal(t, 7*time.Second, rec.Intervals()[2])
+
+	intervals, err := ParseIntervals(rec.String())
+	assert.NilError(t, err)
+
+	replayed := newInsomniac()
+	err = NewRetrier(
+		WithMaxAttempts(len(intervals)+1),
+		WithStrategy(Replay(intervals...)),
+		WithSleepFunc(replayed.sleep),
+	).Do(func(r *Retrier) error { return errDummy })
+	assert.ErrorIs(t, err, errDummy)
+	assert.DeepEqual(t, original.sleepIntervals, replayed.sleepIntervals, DurationExact())
+}
+
+func TestReplay_RepeatsTheLastIntervalOnceItRunsOut(t *testing.T) {
+	t.Parallel()
+
+	r := NewRetrier(WithMaxAttempts(5), WithStrategy(Replay(time.Second, 3*time.Second)))
+	assert.DeepEqual(t, []time.Duration{time.Second, 3 * time.Second, 3 * time.Second, 3 * time.Second}, r.PlannedIntervals(10), DurationExact())
+	assert.Equal(t, "replay(2 intervals), up to 5 attempts", r.Describe())
+}
+
+func TestReplay_PanicsWithoutValidIntervals(t *testing.T) {
+	t.Parallel()
+
+	for name, intervals := range map[string][]time.Duration{
+		"none":     nil,
+		"negative": {time.Second, -time.Second},
+	} {
+		intervals := intervals
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			defer func() {
+				assert.Assert(t, recover() != nil)
+			}()
+
+			Replay(intervals...)
+		})
+	}
+}
+
+func TestReplay_CopiesItsIntervals(t *testing.T) {
+	t.Parallel()
+
+	intervals := []time.Duration{time.Second, 2 * time.Second}
+	strategy, _ := Replay(intervals...)
+	intervals[0] = time.Hour
+
+	r := NewRetrier(WithMaxAttempts(3), WithStrategy(strategy, replayStrategy))
+	assert.Equal(t, time.Second, r.calculateNextInterval())
+}
+
+func TestParseIntervals(t *testing.T) {
+	t.Parallel()
+
+	intervals, err := ParseIntervals("1.204s, 2m3.000000001s,0s")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []time.Duration{1204 * time.Millisecond, 2*time.Minute + 3*time.Second + 1, 0}, intervals, DurationExact())
+
+	intervals, err = ParseIntervals("")
+	assert.NilError(t, err)
+	assert.Equal(t, len(intervals), 0)
+
+	_, err = ParseIntervals("1s,soon")
+	assert.ErrorContains(t, err, "parsing interval 1")
+}
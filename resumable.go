@@ -0,0 +1,46 @@
+package roko
+
+import "context"
+
+// Resumable runs an iteration that can pick up where it left off, like a scan of a large table, or a list operation
+// that returns a continuation token, against an API that might fail partway through. scan is called with the token to
+// resume from (starting with token), and returns the items it got and the token to continue from. When it returns an
+// error, any items it returned are still yielded, and its token (if it returned one) is kept, so the retry carries on
+// from the latest checkpoint rather than starting again from the beginning. When it returns no error, scan is called
+// again with the new token, until it returns an empty token to say the iteration is finished.
+//
+// Unlike Paginate, which gives each page its own retrier, the whole iteration shares r, so r's attempts are a budget
+// for the number of failures across all of it. Resumable returns the latest token along with the error if the
+// iteration doesn't finish, so that it can be saved and resumed later, or "" once it has. If yield returns an error,
+// Resumable stops straight away and returns it.
+// (Note this is not a method of Retrier, since methods can't be generic.)
+func Resumable[T any](ctx context.Context, r *Retrier, token string, scan func(r *Retrier, token string) (items []T, next string, err error), yield func(T) error) (string, error) {
+	var yieldErr error
+	err := r.run(ctx, func(r *Retrier) error {
+		for {
+			items, next, err := scan(r, token)
+
+			for _, item := range items {
+				if yieldErr = yield(item); yieldErr != nil {
+					return Unrecoverable(yieldErr)
+				}
+			}
+
+			if next != "" {
+				token = next
+			}
+			if err != nil {
+				return err
+			}
+			if next == "" {
+				token = ""
+				return nil
+			}
+		}
+	}, nil)
+
+	if yieldErr != nil {
+		return token, yieldErr
+	}
+	return token, err
+}
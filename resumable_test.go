@@ -0,0 +1,92 @@
+package roko
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+// scanFrom returns up to 3 numbers from the range [token, 10), failing after the first one whenever fail is true
+func scanFrom(token string, fail bool) ([]int, string, error) {
+	start := 0
+	if token != "" {
+		start, _ = strconv.Atoi(token)
+	}
+
+	items := []int{}
+	for i := start; i < 10 && i < start+3; i++ {
+		items = append(items, i)
+		if fail {
+			return items, strconv.Itoa(i + 1), errDummy
+		}
+	}
+
+	next := ""
+	if start+3 < 10 {
+		next = strconv.Itoa(start + 3)
+	}
+	return items, next, nil
+}
+
+func TestResumable_RetriesFromTheLatestToken(t *testing.T) {
+	t.Parallel()
+
+	calls := 0
+	got := []int{}
+	token, err := Resumable(context.Background(), NewRetrier(
+		WithMaxAttempts(5),
+		WithStrategy(Constant(time.Millisecond)),
+		WithSleepFunc(dummySleep),
+	), "", func(r *Retrier, token string) ([]int, string, error) {
+		calls++
+		return scanFrom(token, calls == 2 || calls == 3)
+	}, func(i int) error {
+		got = append(got, i)
+		return nil
+	})
+
+	assert.NilError(t, err)
+	assert.Equal(t, "", token)
+	assert.DeepEqual(t, []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}, got) // Nothing was read twice
+}
+
+func TestResumable_WhenTheRetrierGivesUp_ReturnsTheLatestToken(t *testing.T) {
+	t.Parallel()
+
+	token, err := Resumable(context.Background(), NewRetrier(
+		WithMaxAttempts(2),
+		WithStrategy(Constant(time.Millisecond)),
+		WithSleepFunc(dummySleep),
+	), "4", func(r *Retrier, token string) ([]int, string, error) {
+		return scanFrom(token, true)
+	}, func(i int) error {
+		return nil
+	})
+
+	assert.ErrorIs(t, err, errDummy)
+	assert.Equal(t, "6", token)
+}
+
+func TestResumable_WhenYieldFails_StopsWithoutRetrying(t *testing.T) {
+	t.Parallel()
+
+	errFull := errors.New("disk full")
+	calls := 0
+	_, err := Resumable(context.Background(), NewRetrier(
+		WithMaxAttempts(5),
+		WithStrategy(Constant(time.Millisecond)),
+		WithSleepFunc(dummySleep),
+	), "", func(r *Retrier, token string) ([]int, string, error) {
+		calls++
+		return scanFrom(token, false)
+	}, func(i int) error {
+		return errFull
+	})
+
+	assert.Equal(t, errFull, err)
+	assert.Equal(t, 1, calls)
+}
@@ -2,41 +2,165 @@ package roko
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"math"
 	"math/rand"
+	"strings"
+	"sync"
 	"time"
 )
 
-var defaultRandom = rand.New(rand.NewSource(time.Now().UnixNano()))
-
 const defaultJitterInterval = 1000 * time.Millisecond
 
+// ErrUnrecoverable can be returned (or wrapped) by a callback to tell the retrier that the operation can't succeed, and
+// that it shouldn't try again. It has the same effect as calling r.Break() before returning the error
+var ErrUnrecoverable = errors.New("roko: unrecoverable error")
+
+// ErrNoAttemptsRemaining is returned by Do and DoWithContext when the retrier's budget was already used up before the
+// loop could make an attempt - for example, when the retrier has been shared between goroutines, and the other loops
+// have made all of the allowed attempts between them
+var ErrNoAttemptsRemaining = errors.New("roko: retrier has no attempts remaining")
+
+// ErrUnbounded is returned by Do and DoWithContext when a retrier created with RequireBound would retry forever, with
+// nothing to stop it
+var ErrUnbounded = errors.New("roko: retrier is unbounded - it tries forever, and has no total sleep limit or context deadline")
+
+// Unrecoverable wraps err so that the retrier won't try again after it's returned by a callback. The returned error
+// matches both err and ErrUnrecoverable when checked using errors.Is
+func Unrecoverable(err error) error {
+	return unrecoverableError{err: err}
+}
+
+type unrecoverableError struct{ err error }
+
+func (e unrecoverableError) Error() string        { return e.err.Error() }
+func (e unrecoverableError) Unwrap() error        { return e.err }
+func (e unrecoverableError) Is(target error) bool { return target == ErrUnrecoverable }
+
+// InterruptedError is returned by DoWithContext when the context is done while the retrier is waiting between attempts.
+// It matches both the context's error and the error from the last attempt when checked using errors.Is, and unwraps to
+// the context's error. No attempt is made after the wait is interrupted, so it doesn't count towards the attempt count
+// or stats, and only the time actually spent waiting counts towards the total sleep
+type InterruptedError struct {
+	Err   error // The error returned by the last attempt
+	Cause error // The context's error
+}
+
+func (e *InterruptedError) Error() string {
+	return fmt.Sprintf("interrupted while waiting to retry: %v (last error: %v)", e.Cause, e.Err)
+}
+
+func (e *InterruptedError) Unwrap() error { return e.Cause }
+
+func (e *InterruptedError) Is(target error) bool { return errors.Is(e.Err, target) }
+
+// Retrier retries an operation according to its configuration - see NewRetrier.
+//
+// A single Retrier can safely be shared between goroutines, each running their own Do or DoWithContext loop. When it is,
+// the loops share one budget: the attempt count (and the total sleep, see WithMaxTotalSleep) is the total across all of
+// the loops, so a retrier with WithMaxAttempts(10) will make 10 attempts between all of them, and calling Break stops
+// all of them. Note that NextInterval, SetNextInterval and Context are also shared, and reflect whichever loop used
+// them most recently - loops that need their own intervals or contexts should use their own retriers.
 type Retrier struct {
+	mu sync.Mutex
+
 	maxAttempts  int
 	attemptCount int
+	attempts     int
+	inFlight     int
 	jitter       bool
 	jitterRange  jitterRange
+	jitterMode   JitterMode
 	forever      bool
+	noRetry      bool
 	rand         *rand.Rand
+	seed         int64
+	seeded       bool
 
 	breakNext bool
 	sleepFunc func(time.Duration)
+	trigger   *Trigger
+
+	maxTotalSleep      time.Duration
+	truncateFinalSleep bool
+	totalSleep         time.Duration
+	giveUpAt           time.Time
+	requireBound       bool
 
 	intervalCalculator Strategy
 	strategyType       string
 	nextInterval       time.Duration
+	overridden         bool
+	jitterOverrides    bool
+	quantum            time.Duration
+	minInterval        time.Duration
+	intervalCap        time.Duration // The longest interval, set by WithMaxInterval (not to be confused with maxInterval)
+	stopOnNegative     bool
+	blackouts          []blackoutWindow
+	businessHours      *BusinessHours
+	backpressure       *Backpressure
+	budget             *Budget
+
+	ctx           context.Context
+	splitDeadline bool
+	nestedPolicy  NestedPolicy
+	onNested      func(outer *Retrier)
+
+	stats          Stats
+	name           string
+	onAttempt      []func(AttemptEvent)
+	noDefaultHooks bool
+	softFail       func(SoftFailure)
+	traceExtractor func(ctx context.Context) (traceID, spanID string)
+	idempotencyKey func() string
+	zeroOnFailure  bool
+	clock          Clock
+	onClockJump    func(ClockJump)
+	until          *clockTime
+	lastError      error
+	lastErrorAt    time.Time
+	lastSuccessAt  time.Time
+	nextAttemptAt  time.Time
 }
 
+// Interface is the set of Retrier methods used by code that runs operations with a retrier. Accepting an Interface
+// rather than a *Retrier lets tests substitute a fake, such as the ones in the rokotest package
+type Interface interface {
+	Do(callback func(*Retrier) error) error
+	DoWithContext(ctx context.Context, callback func(*Retrier) error) error
+	Break()
+	SetNextInterval(d time.Duration)
+	AttemptCount() int
+}
+
+var _ Interface = (*Retrier)(nil)
+
 type jitterRange struct{ min, max time.Duration }
 
 type Strategy func(*Retrier) time.Duration
 
+// The strategy constructors in this package return a name of the form "kind(parameters)", so that a retrier can
+// describe the strategy it's using (see Retrier.Describe), while still being able to tell what kind of strategy it is
 const (
-	constantStrategy    = "constant"
-	exponentialStrategy = "exponential"
+	constantStrategy             = "constant"
+	linearStrategy               = "linear"
+	exponentialStrategy          = "exponential"
+	exponentialSubsecondStrategy = "exponential-subsecond"
+	funcStrategy                 = "func"
+	cronStrategy                 = "cron"
+	replayStrategy               = "replay"
+	scheduleStrategy             = "schedule"
+	cycleStrategy                = "cycle"
+	repeatStrategy               = "repeat"
 )
 
+// strategyKind returns the kind of strategy from its name, without any parameters
+func strategyKind(strategyType string) string {
+	kind, _, _ := strings.Cut(strategyType, "(")
+	return kind
+}
+
 // Constant returns a strategy that always returns the same value, the interval passed in as an arg to the function
 // Semantically, when this is used with a roko.Retrier, it means that the retrier will always wait the given
 // duration before retrying
@@ -47,11 +171,52 @@ func Constant(interval time.Duration) (Strategy, string) {
 
 	return func(r *Retrier) time.Duration {
 		return interval + r.Jitter()
-	}, constantStrategy
+	}, fmt.Sprintf("%s(%s)", constantStrategy, interval)
+}
+
+// Linear returns a strategy whose intervals grow by the same amount after every attempt: initial, then initial+step,
+// initial+2*step, and so on. It's for when waits should grow predictably, but more gently than an exponential strategy
+// lets them
+func Linear(initial, step time.Duration) (Strategy, string) {
+	if initial < 0 || step < 0 {
+		panic("linear retry strategies must have a positive initial interval and step")
+	}
+
+	return func(r *Retrier) time.Duration {
+		growth := saturatingDuration(float64(step)*float64(r.AttemptCount()), 1)
+		return saturatingAdd(initial, growth, r.Jitter())
+	}, fmt.Sprintf("%s(%s, %s)", linearStrategy, initial, step)
+}
+
+// maxInterval is the longest interval the exponential strategies will return. Once an exponential strategy's interval
+// grows past it - which a long-running TryForever loop can get to - the strategy keeps returning maxInterval, rather
+// than overflowing time.Duration and returning a negative interval
+const maxInterval = time.Duration(math.MaxInt64)
+
+// saturatingDuration returns n units as a duration, truncating n to a whole number of units, or maxInterval if it's too
+// long to be represented
+func saturatingDuration(n float64, unit time.Duration) time.Duration {
+	if math.IsNaN(n) || n*float64(unit) >= float64(maxInterval) {
+		return maxInterval
+	}
+	return time.Duration(n) * unit
+}
+
+// saturatingAdd returns the sum of the intervals, or maxInterval if it's too long to be represented
+func saturatingAdd(intervals ...time.Duration) time.Duration {
+	var sum time.Duration
+	for _, d := range intervals {
+		if d > 0 && sum > maxInterval-d {
+			return maxInterval
+		}
+		sum += d
+	}
+	return sum
 }
 
 // Exponential returns a strategy that increases expontially based on the number of attempts the retrier has made
-// It uses the calculation: adjustment + (base ** attempts) + jitter
+// It uses the calculation: adjustment + (base ** attempts) + jitter, saturating at the
+// longest interval a time.Duration can hold
 func Exponential(base, adjustment time.Duration) (Strategy, string) {
 	if base < 1*time.Second {
 		panic("exponential retry strategies must have a base of at least 1 second")
@@ -59,11 +224,11 @@ func Exponential(base, adjustment time.Duration) (Strategy, string) {
 
 	return func(r *Retrier) time.Duration {
 		baseSeconds := int(base / time.Second)
-		exponentSeconds := math.Pow(float64(baseSeconds), float64(r.attemptCount))
-		exponent := time.Duration(exponentSeconds) * time.Second
+		exponentSeconds := math.Pow(float64(baseSeconds), float64(r.AttemptCount()))
+		exponent := saturatingDuration(exponentSeconds, time.Second)
 
-		return adjustment + exponent + r.Jitter()
-	}, exponentialStrategy
+		return saturatingAdd(adjustment, exponent, r.Jitter())
+	}, fmt.Sprintf("%s(%s, %s)", exponentialStrategy, base, adjustment)
 }
 
 // ExponentialSubsecond is an exponential backoff using milliseconds as a base unit,
@@ -80,16 +245,34 @@ func Exponential(base, adjustment time.Duration) (Strategy, string) {
 //	100ms → 133ms → 177ms → 237ms → 316ms → 421ms → 562ms → 749ms → 1000ms
 //	1.0s  → 1.5s  → 2.4s  → 3.7s  → 5.6s  → 8.7s  → 13.3s → 20.6s → 31.6s
 //	5s    → 9s    → 14s   → 25s   → 42s   → 72s   → 120s  → 208s  → 354s
+//
+// As with Exponential, the interval saturates at the longest interval a time.Duration can hold.
 func ExponentialSubsecond(initial time.Duration) (Strategy, string) {
 	if initial < 1*time.Millisecond {
 		panic("ExponentialSubsecond retry strategies must have an initial delay of at least 1 millisecond")
 	}
 
 	return func(r *Retrier) time.Duration {
-		result := math.Pow(float64(initial/time.Millisecond), float64(r.attemptCount)/16+1.0)
+		result := math.Pow(float64(initial/time.Millisecond), float64(r.AttemptCount())/16+1.0)
 
-		return time.Duration(result)*time.Millisecond + r.Jitter()
-	}, exponentialStrategy
+		return saturatingAdd(saturatingDuration(result, time.Millisecond), r.Jitter())
+	}, fmt.Sprintf("%s(%s)", exponentialSubsecondStrategy, initial)
+}
+
+// Func returns a strategy that calls f with the number of attempts the retrier has made so far (starting at 0) to get
+// the interval to wait before the next attempt, then adds jitter. It's an escape hatch for expressing intervals that
+// the other strategies can't, without having to deal with the retrier itself:
+//
+//	roko.WithStrategy(roko.Func(func(attempt int) time.Duration {
+//		return time.Duration(attempt*attempt) * time.Second
+//	}))
+//
+// Negative intervals from f are treated as 0 (or end the loop, with StopOnNegativeInterval), and intervals too long to
+// add jitter to saturate at the longest interval a time.Duration can hold, rather than overflowing
+func Func(f func(attempt int) time.Duration) (Strategy, string) {
+	return func(r *Retrier) time.Duration {
+		return saturatingAdd(r.clampNegative(f(r.AttemptCount())), r.Jitter())
+	}, funcStrategy
 }
 
 type retrierOpt func(*Retrier)
@@ -101,9 +284,36 @@ func WithMaxAttempts(maxAttempts int) retrierOpt {
 	}
 }
 
+// WithRand sets the source of randomness the retrier uses for jitter. By default, it uses the math/rand package's. The
+// retrier uses rand from whichever goroutines it runs on, so it mustn't be shared with anything else that uses it
+// concurrently. See also WithSeed
 func WithRand(rand *rand.Rand) retrierOpt {
 	return func(r *Retrier) {
 		r.rand = rand
+		r.seeded = false
+	}
+}
+
+// WithMaxTotalSleep causes the retrier to give up when waiting for the next interval would take the total amount of time
+// it has spent waiting between attempts past d. Only the time spent waiting is counted, not the time spent performing
+// the operation itself. This is useful when the intervals vary (for example, when using SetNextInterval), and so the
+// maximum attempt count doesn't translate to a predictable length of time
+func WithMaxTotalSleep(d time.Duration) retrierOpt {
+	if d <= 0 {
+		panic("max total sleep must be positive")
+	}
+
+	return func(r *Retrier) {
+		r.maxTotalSleep = d
+	}
+}
+
+// TruncateFinalSleep makes a retrier with a WithMaxTotalSleep limit shorten the interval that would take it past the
+// limit to exactly the time it has left, so that it makes one last attempt right at the limit rather than giving up
+// early. Intervals set using SetNextInterval aren't shortened
+func TruncateFinalSleep() retrierOpt {
+	return func(r *Retrier) {
+		r.truncateFinalSleep = true
 	}
 }
 
@@ -119,10 +329,22 @@ func WithStrategy(strategy Strategy, strategyType string) retrierOpt {
 // The idea here is to avoid thundering herds - retries that are in parallel will happen at slightly different times when
 // jitter is enabled, whereas if jitter is disabled, all the retries might happen at the same time, causing further load
 // on the system that we're tryung to do something with
-func WithJitter() retrierOpt {
+//
+// Optionally, a JitterMode (FullJitter, EqualJitter, DecorrelatedJitter or PlusMinus) can be passed to choose a
+// different jitter algorithm. Modes are applied to the whole interval calculated by the strategy, rather than adding
+// up to a second on top of it. Any mode can be given a lower bound using its AtLeast method
+func WithJitter(mode ...JitterMode) retrierOpt {
+	if len(mode) > 1 {
+		panic("WithJitter accepts at most one jitter mode")
+	}
+
 	return func(r *Retrier) {
 		r.jitter = true
 		r.jitterRange = jitterRange{min: 0, max: defaultJitterInterval}
+		r.jitterMode = JitterMode{}
+		if len(mode) == 1 {
+			r.jitterMode = mode[0]
+		}
 	}
 }
 
@@ -138,6 +360,7 @@ func WithJitterRange(min, max time.Duration) retrierOpt {
 
 	return func(r *Retrier) {
 		r.jitter = true
+		r.jitterMode = JitterMode{}
 		r.jitterRange = jitterRange{
 			min: min,
 			max: max,
@@ -145,6 +368,25 @@ func WithJitterRange(min, max time.Duration) retrierOpt {
 	}
 }
 
+// RequireBound is a safety check for retriers that might accidentally retry forever. With it, Do and DoWithContext
+// return ErrUnbounded without making any attempts if the retrier tries forever (see TryForever), has no limit set by
+// WithMaxTotalSleep or UntilClock, and the context it's run with has no deadline
+func RequireBound() retrierOpt {
+	return func(r *Retrier) {
+		r.requireBound = true
+	}
+}
+
+// NoRetry causes the retrier to make a single attempt, and never retry. It lets code that accepts a retrier be called by
+// code that doesn't want any retrying, without needing a separate code path. A retrier using NoRetry doesn't need a
+// strategy, as it never waits. It takes precedence over TryForever and WithMaxAttempts, whichever order they're given
+// in, so that a caller can opt out of the retrying a library sets up by default
+func NoRetry() retrierOpt {
+	return func(r *Retrier) {
+		r.noRetry = true
+	}
+}
+
 // TryForever causes the retrier to to never give up retrying, until either the operation succeeds, or the operation
 // calls retrier.Break()
 func TryForever() retrierOpt {
@@ -164,14 +406,23 @@ func WithSleepFunc(f func(time.Duration)) retrierOpt {
 // NewRetrier creates a new instance of the Retrier struct. Pass in retrierOpt functions to customise the behaviour of
 // the retrier
 func NewRetrier(opts ...retrierOpt) *Retrier {
-	r := &Retrier{
-		rand: defaultRandom,
-	}
+	r := &Retrier{}
 
 	for _, o := range opts {
 		o(r)
 	}
 
+	// NoRetry is applied once all of the options have been, so that it wins over the attempt limits they set
+	if r.noRetry {
+		r.forever = false
+		r.maxAttempts = 1
+	}
+
+	// This is worked out once all of the options have been applied, so that it uses the retrier's clock
+	if r.until != nil {
+		r.giveUpAt = nextClock(r.now(), r.until.hour, r.until.min, r.until.loc)
+	}
+
 	// We use panics here rather than returning an error because all of these are logical issues caused by the programmer,
 	// they should never occur in normal running, and can't be logically recovered from
 	if r.maxAttempts == 0 && !r.forever {
@@ -182,9 +433,21 @@ func NewRetrier(opts ...retrierOpt) *Retrier {
 		panic("retriers must have a positive max attempt count")
 	}
 
+	if r.forever && r.intervalCalculator == nil {
+		panic("retriers that run forever must have a strategy")
+	}
+
+	if r.intervalCap > 0 && r.intervalCap < r.minInterval {
+		panic("retriers can't have a max interval shorter than their min interval")
+	}
+
+	if r.truncateFinalSleep && r.maxTotalSleep == 0 {
+		panic("retriers can only truncate their final sleep if they have a max total sleep")
+	}
+
 	oldJitter := r.jitter
 	r.jitter = false // Temporarily turn off jitter while we check if the interval is 0
-	if r.forever && r.strategyType == constantStrategy && r.intervalCalculator(r) == 0 {
+	if r.forever && strategyKind(r.strategyType) == constantStrategy && r.intervalCalculator(r) == 0 {
 		panic("retriers using the constant strategy that run forever must have an interval")
 	}
 	r.jitter = oldJitter // and now set it back to what it was previously
@@ -194,40 +457,80 @@ func NewRetrier(opts ...retrierOpt) *Retrier {
 
 // Jitter returns a duration in the interval in the range [0, r.jitterRange.max - r.jitterRange.min). When no jitter range
 // is defined, the default range is [0, 1 second). The jitter is recalculated for each retry.
-// If jitter is disabled, this method will always return 0.
+// If jitter is disabled, or a JitterMode is in use (in which case the mode jitters the whole interval instead), this
+// method will always return 0.
 func (r *Retrier) Jitter() time.Duration {
-	if !r.jitter {
+	if !r.jitter || r.jitterMode.isSet() {
 		return 0
 	}
 
 	min, max := float64(r.jitterRange.min), float64(r.jitterRange.max)
-	return time.Duration(min + (max-min)*rand.Float64())
+	return time.Duration(min + (max-min)*r.randFloat64())
 }
 
 // MarkAttempt increments the attempt count for the retrier. This affects ShouldGiveUp, and also affects the retry interval
 // for Exponential retry strategy
 func (r *Retrier) MarkAttempt() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 	r.attemptCount += 1
 }
 
 // Break causes the Retrier to stop retrying after it completes the next retry cycle
 func (r *Retrier) Break() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 	r.breakNext = true
 }
 
 // SetNextInterval overrides the strategy for the interval before the next try
+// By default, the interval is used exactly as given, without any jitter. This is usually what you want when the interval
+// comes from somewhere else, like a server's Retry-After header, as jitter could push the wait past what the server asked
+// for. To have jitter applied to overridden intervals as well, use WithJitteredOverrides
 func (r *Retrier) SetNextInterval(d time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 	r.nextInterval = d
+	r.overridden = true
+}
+
+// setCalculatedInterval sets the next interval to one calculated by the retrier's strategy
+func (r *Retrier) setCalculatedInterval(d time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.nextInterval = d
+	r.overridden = false
 }
 
 // ShouldGiveUp returns whether the retrier should stop trying do do the thing it's been asked to do
-// It returns true if the retry count is greater than r.maxAttempts, or if r.Break() has been called
+// It returns true if the retry count is greater than r.maxAttempts, if r.Break() has been called, or if waiting for the
+// next interval would exceed the limit set by WithMaxTotalSleep, take it past the time set by UntilClock, or leave no
+// room for another attempt in its Budget
 // It returns false if the retrier is supposed to try forever
 func (r *Retrier) ShouldGiveUp() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.shouldGiveUp()
+}
+
+// shouldGiveUp is ShouldGiveUp, for callers that already hold r.mu
+func (r *Retrier) shouldGiveUp() bool {
 	if r.breakNext {
 		return true
 	}
 
+	if r.maxTotalSleep > 0 && saturatingAdd(r.totalSleep, r.nextInterval) > r.maxTotalSleep {
+		return true
+	}
+
+	if !r.giveUpAt.IsZero() && r.now().Add(r.nextInterval).After(r.giveUpAt) {
+		return true
+	}
+
+	if r.budget != nil && !r.budget.allows(r.now(), r.nextInterval) {
+		return true
+	}
+
 	if r.forever {
 		return false
 	}
@@ -237,10 +540,15 @@ func (r *Retrier) ShouldGiveUp() bool {
 
 // NextInterval returns the length of time that the retrier will wait before the next retry
 func (r *Retrier) NextInterval() time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 	return r.nextInterval
 }
 
 func (r *Retrier) String() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
 	str := fmt.Sprintf("Attempt %d/", r.attemptCount+1) // +1 because we increment the attempt count after the callback, which is the only useful place to call Retrier.String()
 
 	if r.forever {
@@ -262,10 +570,39 @@ func (r *Retrier) String() string {
 	return str
 }
 
+// AttemptCount returns the number of failed attempts the retrier has made so far. When the retrier is shared between
+// goroutines, this is the total across all of them
 func (r *Retrier) AttemptCount() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 	return r.attemptCount
 }
 
+// Attempts returns the number of times the retrier has called its callback, including the final, successful call if
+// there was one. Unlike AttemptCount, which only counts failed attempts (and is what strategies use to calculate
+// intervals), this is the total number of attempts made, which makes it the number to report once Do has returned.
+// When the retrier is shared between goroutines, this is the total across all of them
+func (r *Retrier) Attempts() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.attempts
+}
+
+// Context returns the context that the retrier's current DoWithContext loop is running with. It's derived from the
+// context passed to DoWithContext, and records that code using it is running inside a retry loop - passing it to a
+// nested retrier's DoWithContext lets that retrier detect the nesting (see WithNestedPolicy). During an attempt, it may
+// also carry a per-attempt deadline (see WithSplitDeadline).
+// Outside of a loop, Context returns context.Background()
+func (r *Retrier) Context() context.Context {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.ctx == nil {
+		return context.Background()
+	}
+	return r.ctx
+}
+
 // Do is the core loop of a Retrier. It defines the operation that the Retrier will attempt to perform, retrying it if necessary
 // Calling retrier.Do(someFunc) will cause the Retrier to attempt to call the function, and if it returns an error,
 // retry it using the settings provided to it.
@@ -275,82 +612,385 @@ func (r *Retrier) Do(callback func(*Retrier) error) error {
 
 // DoWithContext is a context-aware variant of Do.
 func (r *Retrier) DoWithContext(ctx context.Context, callback func(*Retrier) error) error {
-	for {
-		// Calculate the next interval before we do work - this way, the calls to r.NextInterval() in the callback will be
-		// accurate and include the calculated jitter, if present
-		r.nextInterval = r.intervalCalculator(r)
+	var attempts []AttemptEvent
+	var record func(AttemptEvent)
+	if r.softFail != nil {
+		record = func(e AttemptEvent) { attempts = append(attempts, e) }
+	}
 
-		// Perform the action the user has requested we retry
-		err := callback(r)
-		if err == nil {
-			return nil
-		}
+	err := r.run(ctx, callback, record)
+	if err != nil && r.softFail != nil && !errors.Is(err, ErrUnbounded) {
+		r.softFail(SoftFailure{Name: r.name, Err: err, Attempts: attempts})
+		return nil
+	}
+	return err
+}
 
-		r.MarkAttempt()
+// run is DoWithContext without soft failing (see WithSoftFail), for the helpers in this package that need to know
+// whether the loop really succeeded, such as DoFunc and Prepared
+func (r *Retrier) run(ctx context.Context, callback func(*Retrier) error, record func(AttemptEvent)) error {
+	err := r.doWithContext(ctx, callback, record)
+	if err != nil && r.name != "" {
+		err = &NamedError{Name: r.name, Err: err}
+	}
+	return err
+}
+
+// doWithContext runs the retry loop. If record isn't nil, it's called with each attempt's event, after the retrier's
+// hooks
+func (r *Retrier) doWithContext(ctx context.Context, callback func(*Retrier) error, record func(AttemptEvent)) error {
+	if r.requireBound && r.isUnbounded(ctx) {
+		return ErrUnbounded
+	}
 
-		// If the last callback called r.Break(), or if we've hit our call limit, bail out and return the last error we got
-		if r.ShouldGiveUp() {
+	l := r.newLoop(ctx, record)
+	for {
+		if err := r.waitForPressure(l.ctx); err != nil {
+			if l.lastErr != nil {
+				err = &InterruptedError{Err: l.lastErr, Cause: err}
+			}
+			r.mu.Lock()
+			r.recordLoop(l.info.Attempt, err, false)
+			r.mu.Unlock()
 			return err
 		}
 
-		if err := r.sleepOrDone(ctx, r.nextInterval); err != nil {
+		interval, kicked, done, err := l.step(callback)
+		if done {
+			return err
+		}
+
+		sleepStart := r.now()
+		err = r.sleepUntil(l.ctx, interval, kicked)
+		sleepEnd := r.now()
+		l.slept = sleepEnd.Sub(sleepStart)
+		if errors.Is(err, errKicked) {
+			r.mu.Lock()
+			if l.slept < interval {
+				r.totalSleep -= interval - l.slept
+			}
+			r.mu.Unlock()
+			err = nil
+		} else if err == nil {
+			err = r.checkClockJump(l.ctx, l.slept, sleepEnd.Round(0).Sub(sleepStart.Round(0)), sleepEnd)
+		}
+		if err != nil {
+			err = &InterruptedError{Err: l.lastErr, Cause: err}
+
+			r.mu.Lock()
+			if l.slept < interval {
+				// Only count the part of the wait that actually happened
+				r.totalSleep -= interval - l.slept
+			}
+			r.recordLoop(l.info.Attempt, err, false)
+			r.nextAttemptAt = time.Time{}
+			r.mu.Unlock()
 			return err
 		}
 	}
 }
 
+// loop is the state of a single retry loop. Loops that wait between attempts themselves (doWithContext) and ones that
+// leave the waiting to something else (such as a Queue) make their attempts with step, so that every attempt is counted,
+// reported and drawn from the retrier's limits in the same way
+type loop struct {
+	r      *Retrier
+	ctx    context.Context
+	info   AttemptInfo
+	record func(AttemptEvent)
+
+	traceID, spanID string
+
+	// maxAttempts is the most attempts the loop can make, on top of the retrier's own limits, or 0 for no limit beyond
+	// them. It's set by the retrier's NestedPolicy
+	maxAttempts int
+
+	lastErr error
+	slept   time.Duration // How long the loop waited before the attempt it's about to make
+}
+
+// newLoop starts a loop using r, run with ctx. If record isn't nil, it's called with each attempt's event, after the
+// retrier's hooks
+func (r *Retrier) newLoop(ctx context.Context, record func(AttemptEvent)) *loop {
+	l := &loop{r: r, record: record}
+	l.ctx, l.maxAttempts = r.enterLoop(ctx)
+	l.info = newLoopInfo(r)
+	if r.traceExtractor != nil {
+		l.traceID, l.spanID = r.traceExtractor(l.ctx)
+	}
+	return l
+}
+
+// outOfAttempts returns whether the loop has made as many attempts as its own limit allows
+func (l *loop) outOfAttempts() bool {
+	return l.maxAttempts > 0 && l.info.Attempt >= l.maxAttempts
+}
+
+// newEvent returns the event for the loop's attempt'th attempt, which started at start and returned err
+func (l *loop) newEvent(attempt int, start time.Time, err error) AttemptEvent {
+	return AttemptEvent{
+		Name:     l.r.name,
+		ID:       l.info.ID,
+		Attempt:  attempt,
+		Start:    start,
+		Duration: l.r.since(start),
+		Err:      err,
+		TraceID:  l.traceID,
+		SpanID:   l.spanID,
+	}
+}
+
+// emit passes e to the retrier's hooks, and then to the loop's record func
+func (l *loop) emit(e AttemptEvent) {
+	l.r.emit(e)
+	if l.record != nil {
+		l.record(e)
+	}
+}
+
+// step makes the loop's next attempt, unless the retrier has run out of attempts, and records how it went. It returns
+// whether the loop is over, along with its error if it is, or how long to wait before the next attempt if it isn't.
+// kicked is closed if the retrier's trigger is kicked during the attempt or after it, as a sign to stop waiting
+func (l *loop) step(callback func(*Retrier) error) (interval time.Duration, kicked <-chan struct{}, done bool, err error) {
+	r := l.r
+
+	// Reserve this attempt, so that loops sharing the retrier can't make more attempts between them than it allows
+	if err := l.startAttempt(); err != nil {
+		if l.lastErr == nil {
+			l.lastErr = err
+		}
+		r.mu.Lock()
+		r.recordLoop(l.info.Attempt, l.lastErr, !r.breakNext)
+		r.mu.Unlock()
+		return 0, nil, true, l.lastErr
+	}
+
+	// Calculate the next interval before we do work - this way, the calls to r.NextInterval() in the callback will be
+	// accurate and include the calculated jitter, if present
+	r.setCalculatedInterval(r.calculateNextInterval())
+
+	// Perform the action the user has requested we retry
+	l.info.Attempt += 1
+	// A kick that comes during the attempt counts too, since it means whatever was wrong may have been fixed since
+	// the attempt started
+	kicked = r.kickedCh()
+	cancel := r.startAttemptContext(l.ctx, l.info)
+	start := r.now()
+	err = callback(r)
+	event := l.newEvent(l.info.Attempt, start, err)
+	event.Slept = l.slept
+	cancel()
+
+	if err != nil {
+		r.realignInterval()
+	}
+	if err != nil && r.jitterOverrides {
+		r.jitterOverriddenInterval()
+	}
+	if err != nil {
+		r.applyBlackouts()
+	}
+
+	r.mu.Lock()
+	r.finishAttempt(event)
+	if err == nil {
+		r.recordLoop(l.info.Attempt, nil, false)
+		r.mu.Unlock()
+		event.Final = true
+		l.emit(event)
+		return 0, nil, true, nil
+	}
+
+	l.lastErr = err
+
+	// If the last callback called r.Break(), or if we've hit our call limit, bail out and return the last error we got
+	giveUp := r.shouldGiveUp() || l.outOfAttempts()
+	interval = r.nextInterval
+	if giveUp {
+		r.recordLoop(l.info.Attempt, err, !r.breakNext)
+		if r.budget != nil {
+			r.budget.giveUp(r.now(), interval)
+		}
+	} else if interval > 0 {
+		// Count the sleep now, rather than after it's done, so that other loops sharing this retrier see it straight away
+		r.totalSleep += interval
+		r.recordSleep(interval)
+		r.nextAttemptAt = r.now().Add(interval)
+	}
+	r.mu.Unlock()
+
+	event.Final = giveUp
+	if !giveUp {
+		event.NextInterval = interval
+	}
+	l.emit(event)
+
+	if giveUp {
+		return 0, nil, true, err
+	}
+	return interval, kicked, false, nil
+}
+
+// startAttempt reserves an attempt for the loop, as Retrier.startAttempt does, unless the loop has run out of attempts
+// of its own
+func (l *loop) startAttempt() error {
+	if l.outOfAttempts() {
+		return ErrNoAttemptsRemaining
+	}
+	return l.r.startAttempt()
+}
+
+// finishAttempt records the outcome of an attempt that was reserved with startAttempt. r.mu must be held
+func (r *Retrier) finishAttempt(e AttemptEvent) {
+	r.inFlight -= 1
+	r.recordCallback(e.Duration)
+
+	if e.Err == nil {
+		r.lastSuccessAt = r.now()
+		if r.budget != nil {
+			r.budget.finish(r.lastSuccessAt, false)
+		}
+		return
+	}
+
+	r.attemptCount += 1
+	r.lastError, r.lastErrorAt = e.Err, r.now()
+	if r.budget != nil {
+		r.budget.finish(r.lastErrorAt, true)
+	}
+	if errors.Is(e.Err, ErrUnrecoverable) {
+		r.breakNext = true
+	}
+}
+
+// isUnbounded returns whether the retrier would keep retrying forever when run with ctx, if the operation never succeeds
+func (r *Retrier) isUnbounded(ctx context.Context) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	_, hasDeadline := ctx.Deadline()
+	return r.forever && r.maxTotalSleep == 0 && r.giveUpAt.IsZero() && !hasDeadline
+}
+
+// startAttempt reserves an attempt for a loop that's about to call its callback, drawing it from the retrier's budget,
+// if it has one. It returns ErrNoAttemptsRemaining if the retrier's attempts have all been used up, including by
+// attempts that other loops currently have in flight, or ErrBudgetExhausted if the budget has run out
+func (r *Retrier) startAttempt() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.breakNext || (!r.forever && r.attemptCount+r.inFlight >= r.maxAttempts) {
+		return ErrNoAttemptsRemaining
+	}
+	if r.budget != nil && !r.budget.draw(r.now()) {
+		return ErrBudgetExhausted
+	}
+
+	r.inFlight += 1
+	r.attempts += 1
+	r.nextAttemptAt = time.Time{}
+	return nil
+}
+
+// WithZeroValueOnFailure makes DoFunc, DoFunc2 and DoFunc3 return zero values along with the error when none of the
+// calls succeeded, rather than the values returned by the last call. Functions that return partial data alongside
+// their errors can otherwise leak it to callers that don't expect any
+func WithZeroValueOnFailure() retrierOpt {
+	return func(r *Retrier) {
+		r.zeroOnFailure = true
+	}
+}
+
 // DoFunc is a helper for retrying callback functions that return a value or an
 // error. It returns the last value returned by a call to callback, and reports
-// an error if none of the calls succeeded.
+// an error if none of the calls succeeded. If the retrier was created with
+// WithZeroValueOnFailure, the value returned with an error is always the zero value.
+// WithSoftFail doesn't apply to DoFunc, which always reports the error, since
+// there isn't a value to return if none of the calls succeeded.
 // (Note this is not a method of Retrier, since methods can't be generic.)
 func DoFunc[T any](ctx context.Context, r *Retrier, callback func(*Retrier) (T, error)) (T, error) {
 	var t T
-	err := r.DoWithContext(ctx, func(rt *Retrier) error {
+	err := r.run(ctx, func(rt *Retrier) error {
 		var err error
 		t, err = callback(rt)
 		return err
-	})
+	}, nil)
+	if err != nil && r.zeroOnFailure {
+		var zero T
+		return zero, err
+	}
 	return t, err
 }
 
 // DoFunc2 is a helper for retrying callback functions that return two value or
 // an error. It returns the last values returned by a call to callback, and
-// reports an error if none of the calls succeeded.
+// reports an error if none of the calls succeeded. As with DoFunc, the values
+// returned with an error are zero values if the retrier was created with
+// WithZeroValueOnFailure.
 // (Note this is not a method of Retrier, since methods can't be generic.)
 func DoFunc2[T1, T2 any](ctx context.Context, r *Retrier, callback func(*Retrier) (T1, T2, error)) (T1, T2, error) {
 	var t1 T1
 	var t2 T2
-	err := r.DoWithContext(ctx, func(rt *Retrier) error {
+	err := r.run(ctx, func(rt *Retrier) error {
 		var err error
 		t1, t2, err = callback(rt)
 		return err
-	})
+	}, nil)
+	if err != nil && r.zeroOnFailure {
+		var zero1 T1
+		var zero2 T2
+		return zero1, zero2, err
+	}
 	return t1, t2, err
 }
 
 // DoFunc3 is a helper for retrying callback functions that return 3 values or
 // an error. It returns the last values returned by a call to callback, and
-// reports an error if none of the calls succeeded.
+// reports an error if none of the calls succeeded. As with DoFunc, the values
+// returned with an error are zero values if the retrier was created with
+// WithZeroValueOnFailure.
 // (Note this is not a method of Retrier, since methods can't be generic.)
 func DoFunc3[T1, T2, T3 any](ctx context.Context, r *Retrier, callback func(*Retrier) (T1, T2, T3, error)) (T1, T2, T3, error) {
 	var t1 T1
 	var t2 T2
 	var t3 T3
-	err := r.DoWithContext(ctx, func(rt *Retrier) error {
+	err := r.run(ctx, func(rt *Retrier) error {
 		var err error
 		t1, t2, t3, err = callback(rt)
 		return err
-	})
+	}, nil)
+	if err != nil && r.zeroOnFailure {
+		var zero1 T1
+		var zero2 T2
+		var zero3 T3
+		return zero1, zero2, zero3, err
+	}
 	return t1, t2, t3, err
 }
 
 func (r *Retrier) sleepOrDone(ctx context.Context, nextInterval time.Duration) error {
+	return r.sleepUntil(ctx, nextInterval, nil)
+}
+
+// errKicked is what sleepUntil returns when it's woken by a kick
+var errKicked = errors.New("roko: woken by a trigger")
+
+// sleepUntil sleeps for nextInterval, unless ctx is done or kicked is closed first, in which case it returns ctx's error
+// or errKicked
+func (r *Retrier) sleepUntil(ctx context.Context, nextInterval time.Duration, kicked <-chan struct{}) error {
 	if r.sleepFunc == nil {
+		if nextInterval <= 0 {
+			// Don't bother with a timer (and the trip through the scheduler that comes with it) when there's no wait
+			return ctx.Err()
+		}
+
 		t := time.NewTimer(nextInterval)
 		defer t.Stop()
 		select {
 		case <-t.C:
 			return nil
+		case <-kicked:
+			return errKicked
 		case <-ctx.Done():
 			return ctx.Err()
 		}
@@ -364,6 +1004,8 @@ func (r *Retrier) sleepOrDone(ctx context.Context, nextInterval time.Duration) e
 	select {
 	case <-sleepCh:
 		return nil
+	case <-kicked:
+		return errKicked
 	case <-ctx.Done():
 		return ctx.Err()
 	}
@@ -3,7 +3,10 @@ package roko
 import (
 	"context"
 	"errors"
+	"math"
 	"regexp"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -226,6 +229,93 @@ func Test_WhenJitterCausesNegativeInterval_ItDoesntWait(t *testing.T) {
 	assert.Check(t, after.Sub(before) < 1*time.Millisecond)
 }
 
+func TestNextInterval_LinearStrategy(t *testing.T) {
+	t.Parallel()
+
+	insomniac := newInsomniac()
+	err := NewRetrier(
+		WithStrategy(Linear(time.Second, 2*time.Second)),
+		WithMaxAttempts(5),
+		WithSleepFunc(insomniac.sleep),
+	).Do(func(_ *Retrier) error { return errDummy })
+	assert.ErrorIs(t, err, errDummy)
+
+	assert.DeepEqual(t,
+		[]time.Duration{
+			1 * time.Second,
+			3 * time.Second,
+			5 * time.Second,
+			7 * time.Second,
+		},
+		insomniac.sleepIntervals,
+		DurationExact(),
+	)
+}
+
+func TestNextInterval_LinearStrategy_WithJitter(t *testing.T) {
+	t.Parallel()
+
+	insomniac := newInsomniac()
+	err := NewRetrier(
+		WithStrategy(Linear(5*time.Second, time.Second)),
+		WithJitter(),
+		WithMaxAttempts(4),
+		WithSleepFunc(insomniac.sleep),
+	).Do(func(_ *Retrier) error { return errDummy })
+	assert.ErrorIs(t, err, errDummy)
+
+	assert.DeepEqual(t,
+		[]time.Duration{
+			5 * time.Second,
+			6 * time.Second,
+			7 * time.Second,
+		},
+		insomniac.sleepIntervals,
+		opt.DurationWithThreshold(defaultJitterInterval),
+	)
+}
+
+func TestLinear_SaturatesRatherThanOverflowing(t *testing.T) {
+	t.Parallel()
+
+	r := NewRetrier(WithStrategy(Linear(time.Second, maxInterval/2)), TryForever())
+	r.attemptCount = 10
+	assert.Equal(t, maxInterval, r.calculateNextInterval())
+}
+
+func TestFunc_SaturatesRatherThanOverflowing(t *testing.T) {
+	t.Parallel()
+
+	r := NewRetrier(
+		WithStrategy(Func(func(int) time.Duration { return math.MaxInt64 })),
+		WithJitter(),
+		TryForever(),
+	)
+	assert.Equal(t, maxInterval, r.calculateNextInterval())
+}
+
+func TestFunc_TreatsNegativeIntervalsAsZero(t *testing.T) {
+	t.Parallel()
+
+	// Jitter is added to 0, rather than being cancelled out by the negative interval
+	r := NewRetrier(
+		WithStrategy(Func(func(int) time.Duration { return -time.Hour })),
+		WithJitterRange(time.Second, 2*time.Second),
+		TryForever(),
+	)
+	for i := 0; i < 100; i++ {
+		interval := r.calculateNextInterval()
+		assert.Check(t, interval >= time.Second && interval < 2*time.Second, "interval: %s", interval)
+	}
+}
+
+func TestLinear_PanicsOnNegativeIntervals(t *testing.T) {
+	t.Parallel()
+	defer func() { assert.Assert(t, recover() != nil) }()
+
+	Linear(time.Second, -time.Second)
+}
+
 func TestNextInterval_ExponentialStrategy(t *testing.T) {
 	t.Parallel()
 
@@ -429,6 +519,33 @@ func TestNextInterval_ExponentialSubsecondStrategy_WithJitter(t *testing.T) {
 	}, insomniac.sleepIntervals, opt.DurationWithThreshold(defaultJitterInterval))
 }
 
+func TestNextInterval_ExponentialStrategies_SaturateRatherThanOverflowing(t *testing.T) {
+	t.Parallel()
+
+	strategies := map[string]func() (Strategy, string){
+		"exponential":                 func() (Strategy, string) { return Exponential(2*time.Second, 0) },
+		"exponential with adjustment": func() (Strategy, string) { return Exponential(10*time.Second, time.Hour) },
+		"exponential subsecond":       func() (Strategy, string) { return ExponentialSubsecond(5 * time.Second) },
+	}
+
+	for name, strategy := range strategies {
+		strategy := strategy
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			r := NewRetrier(TryForever(), WithStrategy(strategy()), WithJitterRange(0, time.Second))
+			previous := time.Duration(0)
+			for i := 0; i < 2000; i++ {
+				interval := r.calculateNextInterval()
+				assert.Assert(t, interval >= previous, "interval went from %s to %s after %d attempts", previous, interval, i)
+				previous = interval
+				r.MarkAttempt()
+			}
+			assert.Equal(t, maxInterval, previous)
+		})
+	}
+}
+
 func TestString_WithFiniteAttemptCount(t *testing.T) {
 	t.Parallel()
 
@@ -641,3 +758,477 @@ func TestSetNextInterval_Interval(t *testing.T) {
 		4 * time.Second, // manual
 	}, insomniac.sleepIntervals, DurationExact())
 }
+
+func TestDo_WhenCallbackReturnsErrUnrecoverable_StopsRetrying(t *testing.T) {
+	t.Parallel()
+
+	callcount := 0
+	err := NewRetrier(
+		WithStrategy(Constant(1*time.Second)),
+		WithMaxAttempts(10),
+		WithSleepFunc(dummySleep),
+	).Do(func(_ *Retrier) error {
+		callcount += 1
+		if callcount == 3 {
+			return Unrecoverable(errDummy)
+		}
+		return errDummy
+	})
+	assert.ErrorIs(t, err, errDummy)
+	assert.ErrorIs(t, err, ErrUnrecoverable)
+	assert.Equal(t, "this makes it retry", err.Error())
+
+	assert.Equal(t, 3, callcount)
+}
+
+func TestShouldGiveUp_WithMaxTotalSleep(t *testing.T) {
+	t.Parallel()
+
+	insomniac := newInsomniac()
+	callcount := 0
+	err := NewRetrier(
+		WithStrategy(Exponential(2*time.Second, 0)),
+		TryForever(),
+		WithMaxTotalSleep(20*time.Second),
+		WithSleepFunc(insomniac.sleep),
+	).Do(func(_ *Retrier) error {
+		callcount += 1
+		return errDummy
+	})
+	assert.ErrorIs(t, err, errDummy)
+
+	// 1 + 2 + 4 + 8 = 15 seconds, and waiting another 16 seconds would take us past 20
+	assert.DeepEqual(t, []time.Duration{
+		1 * time.Second,
+		2 * time.Second,
+		4 * time.Second,
+		8 * time.Second,
+	}, insomniac.sleepIntervals, DurationExact())
+	assert.Equal(t, 5, callcount)
+}
+
+func TestShouldGiveUp_WithMaxTotalSleep_CountsSetNextInterval(t *testing.T) {
+	t.Parallel()
+
+	insomniac := newInsomniac()
+	err := NewRetrier(
+		WithStrategy(Constant(1*time.Second)),
+		WithMaxAttempts(100),
+		WithMaxTotalSleep(1*time.Minute),
+		WithSleepFunc(insomniac.sleep),
+	).Do(func(r *Retrier) error {
+		r.SetNextInterval(25 * time.Second)
+		return errDummy
+	})
+	assert.ErrorIs(t, err, errDummy)
+
+	assert.DeepEqual(t, []time.Duration{
+		25 * time.Second,
+		25 * time.Second,
+	}, insomniac.sleepIntervals, DurationExact())
+}
+
+func TestShouldGiveUp_WithMaxTotalSleep_TruncatesTheFinalSleep(t *testing.T) {
+	t.Parallel()
+
+	insomniac := newInsomniac()
+	callcount := 0
+	err := NewRetrier(
+		WithStrategy(Exponential(2*time.Second, 0)),
+		TryForever(),
+		WithMaxTotalSleep(20*time.Second),
+		TruncateFinalSleep(),
+		WithSleepFunc(insomniac.sleep),
+	).Do(func(_ *Retrier) error {
+		callcount += 1
+		return errDummy
+	})
+	assert.ErrorIs(t, err, errDummy)
+
+	// 1 + 2 + 4 + 8 = 15 seconds, and then the 16 second wait is cut down to the 5 seconds that are left
+	assert.DeepEqual(t, []time.Duration{
+		1 * time.Second,
+		2 * time.Second,
+		4 * time.Second,
+		8 * time.Second,
+		5 * time.Second,
+	}, insomniac.sleepIntervals, DurationExact())
+	assert.Equal(t, 6, callcount)
+}
+
+func TestTruncateFinalSleep_CountsSleepFromEarlierLoops(t *testing.T) {
+	t.Parallel()
+
+	insomniac := newInsomniac()
+	r := NewRetrier(
+		WithStrategy(Constant(4*time.Second)),
+		TryForever(),
+		WithMaxTotalSleep(10*time.Second),
+		TruncateFinalSleep(),
+		WithSleepFunc(insomniac.sleep),
+	)
+
+	// The first loop waits once, and then succeeds
+	err := r.Do(func(r *Retrier) error {
+		if r.AttemptCount() == 0 {
+			return errDummy
+		}
+		return nil
+	})
+	assert.NilError(t, err)
+
+	// So the second only has 6 seconds left: one full wait, and then one cut down to the 2 seconds that remain
+	err = r.Do(func(*Retrier) error { return errDummy })
+	assert.ErrorIs(t, err, errDummy)
+
+	assert.DeepEqual(t, []time.Duration{
+		4 * time.Second,
+		4 * time.Second,
+		2 * time.Second,
+	}, insomniac.sleepIntervals, DurationExact())
+}
+
+func TestTruncateFinalSleep_WhenSharedBetweenGoroutines_StaysWithinTheLimit(t *testing.T) {
+	t.Parallel()
+
+	r := NewRetrier(
+		WithStrategy(Constant(3*time.Millisecond)),
+		TryForever(),
+		WithMaxTotalSleep(20*time.Millisecond),
+		TruncateFinalSleep(),
+	)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = r.Do(func(*Retrier) error { return errDummy })
+		}()
+	}
+	wg.Wait()
+
+	assert.Assert(t, r.Stats().TotalSleep <= 20*time.Millisecond, "slept for %s", r.Stats().TotalSleep)
+}
+
+func TestTruncateFinalSleep_PanicsWithoutMaxTotalSleep(t *testing.T) {
+	t.Parallel()
+	defer func() { assert.Assert(t, recover() != nil) }()
+
+	NewRetrier(WithMaxAttempts(3), WithStrategy(Constant(time.Second)), TruncateFinalSleep())
+}
+
+func TestDo_WhenSharedBetweenGoroutines_SharesTheAttemptBudget(t *testing.T) {
+	t.Parallel()
+
+	var calls int32
+	r := NewRetrier(
+		WithStrategy(Constant(1*time.Millisecond)),
+		WithMaxAttempts(20),
+	)
+
+	var wg sync.WaitGroup
+	errs := make([]error, 8)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = r.Do(func(*Retrier) error {
+				atomic.AddInt32(&calls, 1)
+				return errDummy
+			})
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(20), atomic.LoadInt32(&calls))
+	assert.Equal(t, 20, r.AttemptCount())
+	for _, err := range errs {
+		assert.Check(t, errors.Is(err, errDummy) || errors.Is(err, ErrNoAttemptsRemaining), "unexpected error: %v", err)
+	}
+}
+
+func TestDo_WhenSharedBetweenGoroutines_BreakStopsEveryLoop(t *testing.T) {
+	t.Parallel()
+
+	var calls int32
+	r := NewRetrier(
+		WithStrategy(Constant(1*time.Millisecond)),
+		TryForever(),
+	)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = r.Do(func(r *Retrier) error {
+				if atomic.AddInt32(&calls, 1) == 10 {
+					r.Break()
+				}
+				return errDummy
+			})
+		}()
+	}
+	wg.Wait()
+
+	assert.Check(t, r.ShouldGiveUp())
+}
+
+func TestDo_WhenRetrierIsAlreadyExhausted_ReturnsErrNoAttemptsRemaining(t *testing.T) {
+	t.Parallel()
+
+	r := NewRetrier(
+		WithStrategy(Constant(1*time.Second)),
+		WithMaxAttempts(2),
+		WithSleepFunc(dummySleep),
+	)
+	err := r.Do(func(*Retrier) error { return errDummy })
+	assert.ErrorIs(t, err, errDummy)
+
+	called := false
+	err = r.Do(func(*Retrier) error {
+		called = true
+		return nil
+	})
+	assert.ErrorIs(t, err, ErrNoAttemptsRemaining)
+	assert.Check(t, !called)
+}
+
+func TestAttempts_WhenOperationSucceeds_IncludesTheSuccessfulAttempt(t *testing.T) {
+	t.Parallel()
+
+	r := NewRetrier(
+		WithStrategy(Constant(1*time.Second)),
+		WithMaxAttempts(5),
+		WithSleepFunc(dummySleep),
+	)
+
+	callcount := 0
+	err := r.Do(func(*Retrier) error {
+		callcount += 1
+		if callcount == 3 {
+			return nil
+		}
+		return errDummy
+	})
+	assert.NilError(t, err)
+
+	assert.Equal(t, 3, r.Attempts())
+	assert.Equal(t, 2, r.AttemptCount())
+}
+
+func TestAttempts_WhenRetrierGivesUp_IsTheMaxAttempts(t *testing.T) {
+	t.Parallel()
+
+	r := NewRetrier(
+		WithStrategy(Constant(1*time.Second)),
+		WithMaxAttempts(5),
+		WithSleepFunc(dummySleep),
+	)
+
+	err := r.Do(func(*Retrier) error { return errDummy })
+	assert.ErrorIs(t, err, errDummy)
+
+	assert.Equal(t, 5, r.Attempts())
+}
+
+func TestNoRetry_MakesASingleAttempt(t *testing.T) {
+	t.Parallel()
+
+	insomniac := newInsomniac()
+	r := NewRetrier(NoRetry(), WithSleepFunc(insomniac.sleep))
+
+	callcount := 0
+	err := r.Do(func(r *Retrier) error {
+		callcount += 1
+		assert.Equal(t, "Attempt 1/1", r.String())
+		return errDummy
+	})
+	assert.ErrorIs(t, err, errDummy)
+
+	assert.Equal(t, 1, callcount)
+	assert.Equal(t, 0, len(insomniac.sleepIntervals))
+}
+
+func TestNoRetry_OverridesOtherLimits(t *testing.T) {
+	t.Parallel()
+
+	for name, opts := range map[string][]retrierOpt{
+		"after TryForever":       {TryForever(), NoRetry()},
+		"before TryForever":      {NoRetry(), TryForever()},
+		"after WithMaxAttempts":  {WithMaxAttempts(5), NoRetry()},
+		"before WithMaxAttempts": {NoRetry(), WithMaxAttempts(5)},
+	} {
+		opts := opts
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			callcount := 0
+			err := NewRetrier(
+				append(opts, WithStrategy(Constant(1*time.Second)), WithSleepFunc(dummySleep))...,
+			).Do(func(*Retrier) error {
+				callcount += 1
+				return errDummy
+			})
+			assert.ErrorIs(t, err, errDummy)
+
+			assert.Equal(t, 1, callcount)
+		})
+	}
+}
+
+func TestNextInterval_FuncStrategy(t *testing.T) {
+	t.Parallel()
+
+	insomniac := newInsomniac()
+	err := NewRetrier(
+		WithStrategy(Func(func(attempt int) time.Duration {
+			return time.Duration(attempt*attempt) * time.Second
+		})),
+		WithMaxAttempts(5),
+		WithSleepFunc(insomniac.sleep),
+	).Do(func(_ *Retrier) error { return errDummy })
+	assert.ErrorIs(t, err, errDummy)
+
+	assert.DeepEqual(t, []time.Duration{
+		0 * time.Second,
+		1 * time.Second,
+		4 * time.Second,
+		9 * time.Second,
+	}, insomniac.sleepIntervals, DurationExact())
+}
+
+func TestNextInterval_FuncStrategy_WithJitter(t *testing.T) {
+	t.Parallel()
+
+	insomniac := newInsomniac()
+	err := NewRetrier(
+		WithStrategy(Func(func(int) time.Duration { return 3 * time.Second })),
+		WithJitter(),
+		WithMaxAttempts(100),
+		WithSleepFunc(insomniac.sleep),
+	).Do(func(_ *Retrier) error { return errDummy })
+	assert.ErrorIs(t, err, errDummy)
+
+	for _, interval := range insomniac.sleepIntervals {
+		assert.Check(t, interval >= 3*time.Second && interval < 4*time.Second, "interval: %s", interval)
+	}
+}
+
+func TestRequireBound_WhenUnbounded_ReturnsErrUnbounded(t *testing.T) {
+	t.Parallel()
+
+	called := false
+	err := NewRetrier(
+		WithStrategy(Constant(1*time.Second)),
+		TryForever(),
+		RequireBound(),
+	).Do(func(*Retrier) error {
+		called = true
+		return nil
+	})
+	assert.ErrorIs(t, err, ErrUnbounded)
+	assert.Check(t, !called)
+}
+
+func TestRequireBound_WhenBounded_Runs(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Hour)
+	defer cancel()
+
+	retriers := map[string]*Retrier{
+		"max attempts": NewRetrier(WithStrategy(Constant(1*time.Second)), WithMaxAttempts(3), RequireBound()),
+		"max total sleep": NewRetrier(
+			WithStrategy(Constant(1*time.Second)),
+			TryForever(),
+			WithMaxTotalSleep(1*time.Minute),
+			RequireBound(),
+		),
+	}
+
+	for name, r := range retriers {
+		err := r.Do(func(*Retrier) error { return nil })
+		assert.NilError(t, err, name)
+	}
+
+	err := NewRetrier(
+		WithStrategy(Constant(1*time.Second)),
+		TryForever(),
+		RequireBound(),
+	).DoWithContext(ctx, func(*Retrier) error { return nil })
+	assert.NilError(t, err, "context deadline")
+}
+
+func TestDoFunc_OnFailure_ReturnsTheLastValues(t *testing.T) {
+	t.Parallel()
+
+	calls := 0
+	newRetrier := func(opts ...retrierOpt) *Retrier {
+		return NewRetrier(append([]retrierOpt{WithMaxAttempts(2), WithStrategy(Constant(time.Second)), WithSleepFunc(dummySleep)}, opts...)...)
+	}
+	partial := func(r *Retrier) (int, error) {
+		calls++
+		return calls, errDummy
+	}
+
+	n, err := DoFunc(context.Background(), newRetrier(), partial)
+	assert.ErrorIs(t, err, errDummy)
+	assert.Equal(t, 2, n)
+
+	n, err = DoFunc(context.Background(), newRetrier(WithZeroValueOnFailure()), partial)
+	assert.ErrorIs(t, err, errDummy)
+	assert.Equal(t, 0, n)
+
+	a, b, err := DoFunc2(context.Background(), newRetrier(WithZeroValueOnFailure()), func(r *Retrier) (string, []byte, error) {
+		return "partial", []byte("data"), errDummy
+	})
+	assert.ErrorIs(t, err, errDummy)
+	assert.Equal(t, "", a)
+	assert.Assert(t, b == nil)
+
+	x, y, z, err := DoFunc3(context.Background(), newRetrier(WithZeroValueOnFailure()), func(r *Retrier) (int, int, int, error) {
+		return 1, 2, 3, errDummy
+	})
+	assert.ErrorIs(t, err, errDummy)
+	assert.Equal(t, [3]int{0, 0, 0}, [3]int{x, y, z})
+}
+
+func TestDoFunc_WithZeroValueOnFailure_OnSuccess_ReturnsTheValues(t *testing.T) {
+	t.Parallel()
+
+	a, b, err := DoFunc2(context.Background(), NewRetrier(NoRetry(), WithZeroValueOnFailure()), func(r *Retrier) (string, int, error) {
+		return "ok", 42, nil
+	})
+	assert.NilError(t, err)
+	assert.Equal(t, "ok", a)
+	assert.Equal(t, 42, b)
+}
+
+func TestDoWithContext_WhenCancelledWhileWaiting_ReturnsAnInterruptedError(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r := NewRetrier(WithMaxAttempts(3), WithStrategy(Constant(time.Hour)), WithMaxTotalSleep(10*time.Hour))
+
+	err := r.DoWithContext(ctx, func(r *Retrier) error {
+		cancel()
+		return errDummy
+	})
+
+	var interrupted *InterruptedError
+	assert.Assert(t, errors.As(err, &interrupted))
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.ErrorIs(t, err, errDummy)
+	assert.Equal(t, errDummy, interrupted.Err)
+	assert.ErrorContains(t, err, "interrupted while waiting to retry: context canceled (last error: this makes it retry)")
+
+	// Only the one attempt was made, and the hour's wait didn't happen
+	assert.Equal(t, 1, r.AttemptCount())
+	assert.Equal(t, 1, r.Attempts())
+	s := r.Stats()
+	assert.Equal(t, 1, s.Attempts)
+	assert.DeepEqual(t, map[int]int{1: 1}, s.AttemptsPerLoop)
+	assert.Assert(t, s.TotalSleep < time.Second)
+}
@@ -0,0 +1,43 @@
+package roko
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParseRetryAfter parses the value of an HTTP Retry-After header, which is either a number of seconds or an HTTP date,
+// into the duration to wait from now. ok is false if the value is empty or malformed. Dates in the past give a duration
+// of 0, and numbers of seconds too large for a time.Duration saturate at the longest interval one can hold. The result
+// is suitable for passing to SetNextInterval
+func ParseRetryAfter(value string, now time.Time) (d time.Duration, ok bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+
+	// ParseInt returns the largest int64 along with ErrRange when the number is too large for one, which saturates below
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err == nil || errors.Is(err, strconv.ErrRange) {
+		if seconds < 0 {
+			return 0, false
+		}
+		if seconds > int64(maxInterval/time.Second) {
+			return maxInterval, true
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	// http.ParseTime accepts all three of the date formats HTTP allows, not just the preferred RFC 1123 one
+	at, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+
+	if d = at.Sub(now); d < 0 {
+		d = 0
+	}
+	return d, true
+}
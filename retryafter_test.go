@@ -0,0 +1,45 @@
+package roko
+
+import (
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2022, time.June, 1, 12, 0, 0, 0, time.UTC)
+
+	cases := map[string]struct {
+		value  string
+		want   time.Duration
+		wantOK bool
+	}{
+		"seconds":        {value: "120", want: 2 * time.Minute, wantOK: true},
+		"zero seconds":   {value: "0", want: 0, wantOK: true},
+		"padded":         {value: " 5 ", want: 5 * time.Second, wantOK: true},
+		"date in future": {value: "Wed, 01 Jun 2022 12:00:30 GMT", want: 30 * time.Second, wantOK: true},
+		"date in past":   {value: "Wed, 01 Jun 2022 11:00:00 GMT", want: 0, wantOK: true},
+		"RFC 850 date":   {value: "Wednesday, 01-Jun-22 12:01:00 GMT", want: time.Minute, wantOK: true},
+		"ANSI C date":    {value: "Wed Jun  1 12:00:10 2022", want: 10 * time.Second, wantOK: true},
+		"huge seconds":   {value: "9223372036854775807", want: maxInterval, wantOK: true},
+		"overflowing":    {value: "99999999999999999999999", want: maxInterval, wantOK: true},
+		"huge negative":  {value: "-99999999999999999999999", wantOK: false},
+		"empty":          {value: "", wantOK: false},
+		"negative":       {value: "-5", wantOK: false},
+		"garbage":        {value: "soon", wantOK: false},
+	}
+
+	for name, tc := range cases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got, ok := ParseRetryAfter(tc.value, now)
+			assert.Equal(t, tc.wantOK, ok)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
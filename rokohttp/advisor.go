@@ -0,0 +1,254 @@
+package rokohttp
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/buildkite/roko"
+)
+
+const (
+	// defaultAdvisorSteps is how many of the schedule's intervals an Advisor escalates through, if WithSteps isn't used
+	defaultAdvisorSteps = 16
+
+	// defaultForgetAfter is how long an Advisor remembers a client that hasn't been throttled, if WithForgetAfter isn't
+	// used
+	defaultForgetAfter = 10 * time.Minute
+)
+
+// Advisor is the server side of Retry-After: it keeps track of the clients that a server has throttled, and works out
+// how long to tell each one to wait before retrying, escalating with each throttled request along a schedule taken from
+// a roko retrier's strategy. Clients are identified by a key, such as their IP address or API token:
+//
+//	advisor := rokohttp.NewAdvisor(roko.NewRetrier(
+//		roko.TryForever(),
+//		roko.WithStrategy(roko.Exponential(2*time.Second, 0)),
+//	))
+//	http.Handle("/api/", advisor.Middleware(rokohttp.RemoteIP)(api))
+//
+// A client's Nth throttled request is told to wait for the Nth interval of the schedule, without jitter (see
+// roko.Retrier.PlannedIntervals), staying at the last interval once they run out. A client that goes long enough
+// without being throttled (see WithForgetAfter) starts again from the beginning of the schedule. An Advisor is safe to
+// use concurrently
+type Advisor struct {
+	mu          sync.Mutex
+	schedule    []time.Duration
+	clients     map[string]*clientState
+	forgetAfter time.Duration
+	clock       roko.Clock
+	lastSweep   time.Time
+}
+
+// clientState is what an Advisor remembers about a client
+type clientState struct {
+	throttles int       // How many of the client's requests have been throttled since it was last forgotten
+	last      time.Time // When the client was last throttled
+	until     time.Time // When the client was last told it could retry
+}
+
+type advisorOpt func(*advisorConfig)
+
+type advisorConfig struct {
+	steps       int
+	forgetAfter time.Duration
+	clock       roko.Clock
+}
+
+// WithSteps sets how many of the schedule's intervals the advisor escalates through before it stops escalating. The
+// default is 16
+func WithSteps(n int) advisorOpt {
+	if n <= 0 {
+		panic("advisors must have at least one step")
+	}
+
+	return func(c *advisorConfig) {
+		c.steps = n
+	}
+}
+
+// WithForgetAfter sets how long a client has to go without being throttled before the advisor forgets about it, and
+// starts its escalation again from the beginning. The default is 10 minutes
+func WithForgetAfter(d time.Duration) advisorOpt {
+	if d <= 0 {
+		panic("advisors must remember clients for a positive duration")
+	}
+
+	return func(c *advisorConfig) {
+		c.forgetAfter = d
+	}
+}
+
+// WithClock sets the clock the advisor uses to tell the time, for use in tests (see roko.SimulatedClock)
+func WithClock(clock roko.Clock) advisorOpt {
+	return func(c *advisorConfig) {
+		c.clock = clock
+	}
+}
+
+// NewAdvisor returns an advisor that escalates along the intervals that schedule's strategy would wait between
+// attempts. schedule is only used to calculate the intervals, and isn't run. NewAdvisor panics if schedule wouldn't
+// wait at all between attempts (because it only makes one, for example)
+func NewAdvisor(schedule *roko.Retrier, opts ...advisorOpt) *Advisor {
+	c := advisorConfig{steps: defaultAdvisorSteps, forgetAfter: defaultForgetAfter, clock: roko.SystemClock}
+	for _, o := range opts {
+		o(&c)
+	}
+
+	intervals := schedule.PlannedIntervals(c.steps)
+	if len(intervals) == 0 {
+		panic("advisors need a schedule with at least one interval")
+	}
+
+	return &Advisor{
+		schedule:    intervals,
+		clients:     map[string]*clientState{},
+		forgetAfter: c.forgetAfter,
+		clock:       c.clock,
+	}
+}
+
+// Throttle records that a request from the client with the given key has been throttled, and returns how long the
+// client should be told to wait before retrying
+func (a *Advisor) Throttle(key string) time.Duration {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := a.clock.Now()
+	a.sweep(now)
+
+	s := a.clients[key]
+	if s == nil || now.Sub(s.last) > a.forgetAfter {
+		s = &clientState{}
+		a.clients[key] = s
+	}
+
+	step := s.throttles
+	if step >= len(a.schedule) {
+		step = len(a.schedule) - 1
+	}
+	wait := a.schedule[step]
+
+	s.throttles++
+	s.last = now
+	s.until = now.Add(wait)
+	return wait
+}
+
+// Remaining returns how much longer the client with the given key was last told to wait, or false if it isn't being
+// made to wait
+func (a *Advisor) Remaining(key string) (time.Duration, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	s := a.clients[key]
+	if s == nil {
+		return 0, false
+	}
+
+	remaining := s.until.Sub(a.clock.Now())
+	if remaining <= 0 {
+		return 0, false
+	}
+	return remaining, true
+}
+
+// Forget forgets about the client with the given key, so that the next time it's throttled, its escalation starts
+// again from the beginning
+func (a *Advisor) Forget(key string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.clients, key)
+}
+
+// sweep forgets about the clients that haven't been throttled for long enough, at most once per forgetAfter, so that
+// clients that go away don't build up forever. a.mu must be held
+func (a *Advisor) sweep(now time.Time) {
+	if now.Sub(a.lastSweep) < a.forgetAfter {
+		return
+	}
+	a.lastSweep = now
+
+	for key, s := range a.clients {
+		if now.Sub(s.last) > a.forgetAfter {
+			delete(a.clients, key)
+		}
+	}
+}
+
+// Middleware returns HTTP middleware that sets a Retry-After header on throttled responses, using key to identify the
+// client each request came from. A response is throttled if the handler it wraps responds with 429 Too Many Requests
+// or 503 Service Unavailable without setting Retry-After itself. A client that comes back before the time it was told
+// to wait is over is throttled again straight away, with a 429 response, without the request reaching the handler
+func (a *Advisor) Middleware(key func(*http.Request) string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			k := key(req)
+
+			if _, waiting := a.Remaining(k); waiting {
+				setRetryAfter(w.Header(), a.Throttle(k))
+				http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+				return
+			}
+
+			next.ServeHTTP(&throttlingWriter{ResponseWriter: w, advisor: a, key: k}, req)
+		})
+	}
+}
+
+// throttlingWriter sets Retry-After on throttled responses
+type throttlingWriter struct {
+	http.ResponseWriter
+	advisor     *Advisor
+	key         string
+	wroteHeader bool
+}
+
+func (w *throttlingWriter) WriteHeader(code int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		if (code == http.StatusTooManyRequests || code == http.StatusServiceUnavailable) && w.Header().Get("Retry-After") == "" {
+			setRetryAfter(w.Header(), w.advisor.Throttle(w.key))
+		}
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *throttlingWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap returns the underlying ResponseWriter, for http.ResponseController
+func (w *throttlingWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// setRetryAfter sets the Retry-After header to d, rounded up to a whole number of seconds (since that's all the header
+// can express), and at least one second. It rounds up by hand rather than adding a second first, which would overflow
+// for the saturated intervals an exponential schedule gets to
+func setRetryAfter(h http.Header, d time.Duration) {
+	seconds := int64(d / time.Second)
+	if d%time.Second > 0 {
+		seconds++
+	}
+	if seconds < 1 {
+		seconds = 1
+	}
+	h.Set("Retry-After", strconv.FormatInt(seconds, 10))
+}
+
+// RemoteIP is a key func for Advisor.Middleware that identifies clients by the IP address they connected from. Behind
+// a proxy or load balancer, that's the proxy's address, so a key func that reads the header the proxy sets (such as
+// X-Forwarded-For) should be used instead
+func RemoteIP(req *http.Request) string {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,184 @@
+package rokohttp
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/buildkite/roko"
+	"gotest.tools/v3/assert"
+)
+
+var advisorEpoch = time.Date(2024, time.January, 1, 9, 0, 0, 0, time.UTC)
+
+func newTestAdvisor(clock roko.Clock, opts ...advisorOpt) *Advisor {
+	schedule := roko.NewRetrier(roko.TryForever(), roko.WithStrategy(roko.Exponential(2*time.Second, 0)))
+	return NewAdvisor(schedule, append([]advisorOpt{WithClock(clock), WithSteps(4)}, opts...)...)
+}
+
+func TestAdvisor_Escalates(t *testing.T) {
+	t.Parallel()
+
+	clock := roko.NewSimulatedClock(advisorEpoch)
+	a := newTestAdvisor(clock)
+
+	var waits []time.Duration
+	for i := 0; i < 6; i++ {
+		waits = append(waits, a.Throttle("client"))
+		clock.Advance(time.Minute)
+	}
+
+	// Four steps of the exponential schedule, and then the last one again
+	assert.DeepEqual(t, []time.Duration{
+		time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 8 * time.Second, 8 * time.Second,
+	}, waits)
+
+	// Other clients have their own escalations
+	assert.Equal(t, time.Second, a.Throttle("other"))
+}
+
+func TestAdvisor_ForgetsClients(t *testing.T) {
+	t.Parallel()
+
+	clock := roko.NewSimulatedClock(advisorEpoch)
+	a := newTestAdvisor(clock, WithForgetAfter(time.Hour))
+
+	a.Throttle("client")
+	a.Throttle("client")
+	clock.Advance(2 * time.Hour)
+	assert.Equal(t, time.Second, a.Throttle("client"))
+
+	a.Throttle("client")
+	a.Forget("client")
+	assert.Equal(t, time.Second, a.Throttle("client"))
+}
+
+func TestAdvisor_Remaining(t *testing.T) {
+	t.Parallel()
+
+	clock := roko.NewSimulatedClock(advisorEpoch)
+	a := newTestAdvisor(clock)
+
+	_, waiting := a.Remaining("client")
+	assert.Assert(t, !waiting)
+
+	a.Throttle("client")
+	a.Throttle("client")
+	clock.Advance(500 * time.Millisecond)
+	remaining, waiting := a.Remaining("client")
+	assert.Assert(t, waiting)
+	assert.Equal(t, 1500*time.Millisecond, remaining)
+
+	clock.Advance(2 * time.Second)
+	_, waiting = a.Remaining("client")
+	assert.Assert(t, !waiting)
+}
+
+func TestAdvisor_Middleware(t *testing.T) {
+	t.Parallel()
+
+	clock := roko.NewSimulatedClock(advisorEpoch)
+	a := newTestAdvisor(clock)
+
+	overloaded := true
+	calls := 0
+	handler := a.Middleware(RemoteIP)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if overloaded {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+
+	get := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := get()
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Equal(t, "1", rec.Header().Get("Retry-After"))
+	assert.Equal(t, 1, calls)
+
+	// Coming back too soon is throttled without reaching the handler, and escalates
+	rec = get()
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "2", rec.Header().Get("Retry-After"))
+	assert.Equal(t, 1, calls)
+
+	// Once the client has waited, and the server has recovered, its requests go through as normal
+	clock.Advance(2 * time.Second)
+	overloaded = false
+	rec = get()
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "", rec.Header().Get("Retry-After"))
+	assert.Equal(t, "ok", rec.Body.String())
+	assert.Equal(t, 2, calls)
+}
+
+func TestAdvisor_MiddlewareKeepsTheHandlersRetryAfter(t *testing.T) {
+	t.Parallel()
+
+	a := newTestAdvisor(roko.NewSimulatedClock(advisorEpoch))
+	handler := a.Middleware(RemoteIP)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "120")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, "120", rec.Header().Get("Retry-After"))
+
+	_, waiting := a.Remaining(RemoteIP(httptest.NewRequest(http.MethodGet, "/", nil)))
+	assert.Assert(t, !waiting)
+}
+
+func TestNewAdvisor_PanicsWithoutAnyIntervals(t *testing.T) {
+	t.Parallel()
+	defer func() { assert.Assert(t, recover() != nil) }()
+
+	NewAdvisor(roko.NewRetrier(roko.WithMaxAttempts(1), roko.WithStrategy(roko.Constant(time.Second))))
+}
+
+func TestSetRetryAfter_RoundsUp(t *testing.T) {
+	t.Parallel()
+
+	for d, want := range map[time.Duration]string{
+		0:                       "1",
+		100 * time.Millisecond:  "1",
+		time.Second:             "1",
+		1500 * time.Millisecond: "2",
+		time.Minute:             "60",
+		math.MaxInt64:           "9223372037",
+	} {
+		h := http.Header{}
+		setRetryAfter(h, d)
+		assert.Equal(t, want, h.Get("Retry-After"), "%s", d)
+	}
+}
+
+func TestAdvisor_Middleware_WithASaturatedSchedule(t *testing.T) {
+	t.Parallel()
+
+	// Enough steps for the exponential strategy to saturate at the longest interval a time.Duration can hold
+	schedule := roko.NewRetrier(roko.TryForever(), roko.WithStrategy(roko.Exponential(2*time.Second, 0)))
+	a := NewAdvisor(schedule, WithClock(roko.NewSimulatedClock(advisorEpoch)), WithSteps(70))
+	for i := 0; i < 69; i++ {
+		a.Throttle("192.0.2.1")
+	}
+
+	handler := a.Middleware(RemoteIP)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "9223372037", rec.Header().Get("Retry-After"))
+}
@@ -0,0 +1,120 @@
+// Package rokohttp contains HTTP helpers for roko retriers, and for the servers that they retry requests to.
+package rokohttp
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/buildkite/roko"
+)
+
+// StateHandler is an http.Handler that shows the current state of a set of retriers as JSON, so that someone debugging
+// a stuck process can see what it's retrying, what went wrong last time, and how long it's going to wait before trying
+// again. Retriers are added to it with Register. For example:
+//
+//	states := rokohttp.NewStateHandler()
+//	states.Register("artifact-upload", uploadRetrier)
+//	http.Handle("/debug/retriers", states)
+//
+// A StateHandler is safe to use concurrently
+type StateHandler struct {
+	mu       sync.Mutex
+	retriers map[string]*roko.Retrier
+}
+
+var _ http.Handler = (*StateHandler)(nil)
+
+// NewStateHandler returns a StateHandler with no retriers registered
+func NewStateHandler() *StateHandler {
+	return &StateHandler{retriers: map[string]*roko.Retrier{}}
+}
+
+// Register adds a retrier to the handler, under the given name. Registering another retrier with the same name replaces
+// the first one
+func (h *StateHandler) Register(name string, r *roko.Retrier) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.retriers[name] = r
+}
+
+// Unregister removes the retrier with the given name from the handler
+func (h *StateHandler) Unregister(name string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.retriers, name)
+}
+
+// retrierState is the JSON representation of a retrier's state
+type retrierState struct {
+	Name          string     `json:"name"`
+	Policy        string     `json:"policy"`
+	Attempts      int        `json:"attempts"`
+	InFlight      int        `json:"in_flight"`
+	Waiting       bool       `json:"waiting"`
+	NextAttemptAt *time.Time `json:"next_attempt_at,omitempty"`
+	NextAttemptIn string     `json:"next_attempt_in,omitempty"`
+	LastError     string     `json:"last_error,omitempty"`
+	LastErrorAt   *time.Time `json:"last_error_at,omitempty"`
+	LastSuccessAt *time.Time `json:"last_success_at,omitempty"`
+}
+
+// ServeHTTP responds with a JSON array describing each of the registered retriers, sorted by name
+func (h *StateHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	h.mu.Lock()
+	names := make([]string, 0, len(h.retriers))
+	retriers := make(map[string]*roko.Retrier, len(h.retriers))
+	for name, r := range h.retriers {
+		names = append(names, name)
+		retriers[name] = r
+	}
+	h.mu.Unlock()
+
+	sort.Strings(names)
+
+	now := time.Now()
+	states := make([]retrierState, 0, len(names))
+	for _, name := range names {
+		r := retriers[name]
+		s := r.State()
+
+		state := retrierState{
+			Name:          name,
+			Policy:        r.Describe(),
+			Attempts:      s.AttemptCount,
+			InFlight:      s.InFlight,
+			Waiting:       s.Waiting(),
+			NextAttemptAt: optionalTime(s.NextAttemptAt),
+			LastErrorAt:   optionalTime(s.LastErrorAt),
+			LastSuccessAt: optionalTime(s.LastSuccessAt),
+		}
+		if s.Waiting() {
+			state.NextAttemptIn = s.NextAttemptAt.Sub(now).Round(time.Millisecond).String()
+		}
+		if s.LastError != nil {
+			state.LastError = s.LastError.Error()
+		}
+		states = append(states, state)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(states)
+}
+
+// optionalTime returns nil for the zero time, so that it's left out of the JSON
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
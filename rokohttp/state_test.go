@@ -0,0 +1,62 @@
+package rokohttp
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/buildkite/roko"
+	"gotest.tools/v3/assert"
+)
+
+func TestStateHandler(t *testing.T) {
+	t.Parallel()
+
+	idle := roko.NewRetrier(roko.WithMaxAttempts(3), roko.WithStrategy(roko.Constant(time.Second)))
+
+	failed := roko.NewRetrier(
+		roko.WithMaxAttempts(2),
+		roko.WithStrategy(roko.Constant(time.Second)),
+		roko.WithSleepFunc(func(time.Duration) {}),
+	)
+	_ = failed.Do(func(r *roko.Retrier) error { return errors.New("connection refused") })
+
+	h := NewStateHandler()
+	h.Register("upload", failed)
+	h.Register("fetch", idle)
+	h.Register("gone", idle)
+	h.Unregister("gone")
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/retriers", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+
+	var states []retrierState
+	assert.NilError(t, json.Unmarshal(rec.Body.Bytes(), &states))
+	assert.Equal(t, 2, len(states))
+
+	assert.Equal(t, "fetch", states[0].Name)
+	assert.Equal(t, "constant(1s), up to 3 attempts", states[0].Policy)
+	assert.Equal(t, 0, states[0].Attempts)
+	assert.Assert(t, states[0].LastErrorAt == nil)
+
+	assert.Equal(t, "upload", states[1].Name)
+	assert.Equal(t, 2, states[1].Attempts)
+	assert.Equal(t, "connection refused", states[1].LastError)
+	assert.Assert(t, states[1].LastErrorAt != nil)
+	assert.Assert(t, !states[1].Waiting)
+	assert.Assert(t, states[1].NextAttemptAt == nil)
+}
+
+func TestStateHandler_OnlyAllowsGet(t *testing.T) {
+	t.Parallel()
+
+	rec := httptest.NewRecorder()
+	NewStateHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/debug/retriers", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
@@ -0,0 +1,222 @@
+package rokotest
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/buildkite/roko"
+)
+
+// Fault is something that goes wrong with a call to a function wrapped by a FaultInjector. A fault can combine latency
+// with an error or a panic, as a call that times out would. The zero Fault doesn't do anything, so the call goes through
+// as normal.
+type Fault struct {
+	Latency time.Duration // How long to wait before the call (or before failing it)
+	Err     error         // The error to fail the call with, instead of calling the function
+	Panic   interface{}   // What to panic with, instead of calling the function
+
+	// AfterCall makes the fault happen after calling the function, rather than instead of it, as when a request reaches
+	// a server and succeeds, but the response is lost. It's for checking that retrying a call that failed that way is
+	// safe.
+	AfterCall bool
+}
+
+func (f Fault) isZero() bool {
+	return f.Latency == 0 && f.Err == nil && f.Panic == nil
+}
+
+// FaultInjector wraps functions, injecting faults into calls to them, so that retry policies and error classifiers can
+// be tested against realistic failures without breaking real dependencies:
+//
+//	errUnavailable := errors.New("503 Service Unavailable")
+//	faults := rokotest.NewFaultInjector(
+//		rokotest.WithFaults(rokotest.Fault{Err: errUnavailable}, rokotest.Fault{Latency: 2 * time.Second}),
+//		rokotest.WithRandomFaults(0.1, rokotest.Fault{Err: errUnavailable}),
+//		rokotest.WithSeed(1),
+//	)
+//	get := faults.Wrap(client.Get)
+//
+// Calls are first given each of the faults from WithFaults in turn, and once they've run out, each call has a chance
+// of getting one of the faults from WithRandomFaults. Wrapped functions can be shared between FaultInjector's wrappers,
+// in which case the script and the randomness are shared between them too. It's safe to use concurrently.
+type FaultInjector struct {
+	mu          sync.Mutex
+	script      []Fault
+	probability float64
+	random      []Fault
+	rand        *rand.Rand
+	sleep       func(ctx context.Context, d time.Duration) error
+
+	calls    int
+	injected int
+}
+
+type injectorOpt func(*FaultInjector)
+
+// WithFaults sets the faults to inject into the first calls, one per call, in order. Use the zero Fault to let a call
+// through
+func WithFaults(faults ...Fault) injectorOpt {
+	return func(i *FaultInjector) {
+		i.script = append([]Fault(nil), faults...)
+	}
+}
+
+// WithRandomFaults gives each call after the scripted faults have run out a probability chance (between 0 and 1) of
+// getting one of faults, chosen at random
+func WithRandomFaults(probability float64, faults ...Fault) injectorOpt {
+	if probability < 0 || probability > 1 {
+		panic("fault probabilities must be between 0 and 1")
+	}
+	if len(faults) == 0 {
+		panic("random faults need at least one fault to choose from")
+	}
+
+	return func(i *FaultInjector) {
+		i.probability = probability
+		i.random = append([]Fault(nil), faults...)
+	}
+}
+
+// WithSeed seeds the randomness used by WithRandomFaults, so that the same calls get the same faults every time
+func WithSeed(seed int64) injectorOpt {
+	return func(i *FaultInjector) {
+		i.rand = rand.New(rand.NewSource(seed))
+	}
+}
+
+// WithSleepFunc sets the function used to wait for a fault's latency, instead of actually waiting. Passing a
+// roko.SimulatedClock's Advance method lets faults and a retrier using roko.WithSimulation share the same simulated
+// time.
+func WithSleepFunc(f func(time.Duration)) injectorOpt {
+	return func(i *FaultInjector) {
+		i.sleep = func(_ context.Context, d time.Duration) error {
+			f(d)
+			return nil
+		}
+	}
+}
+
+// NewFaultInjector returns a fault injector. With no options, it doesn't inject any faults
+func NewFaultInjector(opts ...injectorOpt) *FaultInjector {
+	i := &FaultInjector{sleep: sleep}
+	for _, o := range opts {
+		o(i)
+	}
+	if i.rand == nil {
+		i.rand = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+	return i
+}
+
+// sleep waits for d, or until ctx is done
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// next returns the fault for the next call
+func (i *FaultInjector) next() Fault {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	var f Fault
+	switch {
+	case i.calls < len(i.script):
+		f = i.script[i.calls]
+	case len(i.random) > 0 && i.rand.Float64() < i.probability:
+		f = i.random[i.rand.Intn(len(i.random))]
+	}
+
+	i.calls++
+	if !f.isZero() {
+		i.injected++
+	}
+	return f
+}
+
+// call calls f, with the next fault injected
+func (i *FaultInjector) call(ctx context.Context, f func() error) error {
+	fault := i.next()
+
+	if fault.Latency > 0 {
+		if err := i.sleep(ctx, fault.Latency); err != nil {
+			return err
+		}
+	}
+
+	var err error
+	if fault.AfterCall {
+		err = f()
+	}
+
+	switch {
+	case fault.Panic != nil:
+		panic(fault.Panic)
+	case fault.Err != nil:
+		return fault.Err
+	case fault.AfterCall:
+		return err
+	default:
+		return f()
+	}
+}
+
+// Wrap returns a function that calls f, with faults injected
+func (i *FaultInjector) Wrap(f func(ctx context.Context) error) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		return i.call(ctx, func() error { return f(ctx) })
+	}
+}
+
+// WrapCallback returns a retrier callback that calls callback, with faults injected, so that a retry loop can be tested
+// as it is:
+//
+//	err := r.Do(faults.WrapCallback(func(r *roko.Retrier) error {
+//		return upload(r.Context())
+//	}))
+func (i *FaultInjector) WrapCallback(callback func(*roko.Retrier) error) func(*roko.Retrier) error {
+	return func(r *roko.Retrier) error {
+		return i.call(r.Context(), func() error { return callback(r) })
+	}
+}
+
+// WrapFunc returns a function that calls f, with faults injected. Calls that fault return the zero value of T
+// (Note this is not a method of FaultInjector, since methods can't be generic.)
+func WrapFunc[T any](i *FaultInjector, f func(ctx context.Context) (T, error)) func(ctx context.Context) (T, error) {
+	return func(ctx context.Context) (T, error) {
+		var result T
+		err := i.call(ctx, func() error {
+			var err error
+			result, err = f(ctx)
+			return err
+		})
+		if err != nil {
+			var zero T
+			return zero, err
+		}
+		return result, nil
+	}
+}
+
+// Calls returns the number of calls made to the injector's wrapped functions
+func (i *FaultInjector) Calls() int {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.calls
+}
+
+// Injected returns the number of calls that had a fault injected into them
+func (i *FaultInjector) Injected() int {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.injected
+}
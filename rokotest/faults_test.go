@@ -0,0 +1,136 @@
+package rokotest
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/buildkite/roko"
+	"gotest.tools/v3/assert"
+)
+
+func TestFaultInjector_ScriptedFaults(t *testing.T) {
+	t.Parallel()
+
+	var slept []time.Duration
+	faults := NewFaultInjector(
+		WithFaults(Fault{Err: errDummy}, Fault{}, Fault{Latency: time.Second}),
+		WithSleepFunc(func(d time.Duration) { slept = append(slept, d) }),
+	)
+
+	calls := 0
+	f := faults.Wrap(func(context.Context) error {
+		calls++
+		return nil
+	})
+
+	ctx := context.Background()
+	assert.ErrorIs(t, f(ctx), errDummy)
+	assert.Equal(t, 0, calls)
+	assert.NilError(t, f(ctx))
+	assert.NilError(t, f(ctx))
+	assert.Equal(t, 2, calls)
+	assert.DeepEqual(t, []time.Duration{time.Second}, slept)
+
+	// Once the script runs out, calls go straight through
+	assert.NilError(t, f(ctx))
+	assert.Equal(t, 3, calls)
+	assert.Equal(t, 4, faults.Calls())
+	assert.Equal(t, 2, faults.Injected())
+}
+
+func TestFaultInjector_AfterCall(t *testing.T) {
+	t.Parallel()
+
+	faults := NewFaultInjector(WithFaults(Fault{Err: errDummy, AfterCall: true}))
+
+	calls := 0
+	f := faults.Wrap(func(context.Context) error {
+		calls++
+		return nil
+	})
+
+	assert.ErrorIs(t, f(context.Background()), errDummy)
+	assert.Equal(t, 1, calls)
+}
+
+func TestFaultInjector_Panics(t *testing.T) {
+	t.Parallel()
+
+	faults := NewFaultInjector(WithFaults(Fault{Panic: "boom"}))
+	f := faults.Wrap(func(context.Context) error { return nil })
+
+	defer func() { assert.Equal(t, "boom", recover()) }()
+	_ = f(context.Background())
+}
+
+func TestFaultInjector_LatencyRespectsTheContext(t *testing.T) {
+	t.Parallel()
+
+	faults := NewFaultInjector(WithFaults(Fault{Latency: time.Hour}))
+	f := faults.Wrap(func(context.Context) error { return nil })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, f(ctx), context.DeadlineExceeded)
+}
+
+func TestFaultInjector_RandomFaults(t *testing.T) {
+	t.Parallel()
+
+	run := func(seed int64) []bool {
+		faults := NewFaultInjector(WithRandomFaults(0.25, Fault{Err: errDummy}), WithSeed(seed))
+		f := faults.Wrap(func(context.Context) error { return nil })
+
+		failed := make([]bool, 0, 10000)
+		for i := 0; i < 10000; i++ {
+			failed = append(failed, f(context.Background()) != nil)
+		}
+
+		rate := float64(faults.Injected()) / float64(faults.Calls())
+		assert.Check(t, math.Abs(rate-0.25) < 0.02, "fault rate %f", rate)
+		return failed
+	}
+
+	assert.DeepEqual(t, run(1), run(1))
+}
+
+func TestFaultInjector_WithARetrier(t *testing.T) {
+	t.Parallel()
+
+	errThrottled := errors.New("429 Too Many Requests")
+	faults := NewFaultInjector(WithFaults(Fault{Err: errThrottled}, Fault{Err: errThrottled}))
+
+	r := roko.NewRetrier(roko.WithMaxAttempts(3), roko.WithSleepFunc(func(time.Duration) {}))
+	err := r.Do(faults.WrapCallback(func(*roko.Retrier) error { return nil }))
+
+	assert.NilError(t, err)
+	assert.Equal(t, 3, faults.Calls())
+	assert.Equal(t, 2, r.AttemptCount())
+}
+
+func TestWrapFunc(t *testing.T) {
+	t.Parallel()
+
+	faults := NewFaultInjector(WithFaults(Fault{Err: errDummy}, Fault{Err: errDummy, AfterCall: true}))
+	get := WrapFunc(faults, func(context.Context) (int, error) { return 42, nil })
+
+	for i := 0; i < 2; i++ {
+		n, err := get(context.Background())
+		assert.ErrorIs(t, err, errDummy)
+		assert.Equal(t, 0, n)
+	}
+
+	n, err := get(context.Background())
+	assert.NilError(t, err)
+	assert.Equal(t, 42, n)
+}
+
+func TestWithRandomFaults_PanicsOnBadProbabilities(t *testing.T) {
+	t.Parallel()
+	defer func() { assert.Assert(t, recover() != nil) }()
+
+	WithRandomFaults(1.5, Fault{Err: errDummy})
+}
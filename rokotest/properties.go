@@ -0,0 +1,91 @@
+package rokotest
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/buildkite/roko"
+)
+
+// The Check functions verify properties of a retry schedule, for use in property and fuzz tests of strategies,
+// particularly custom ones built with roko.Func. Each returns nil if the property holds, or an error describing the
+// first interval that breaks it, so that they can be used with any assertion library, or with t.Fatal.
+//
+// The intervals they check can come from a retrier's PlannedIntervals (which leaves out jitter), or from a retrier run
+// with a SleepRecorder (which includes it).
+
+// CheckNonDecreasing checks that each interval is at least as long as the one before it
+func CheckNonDecreasing(intervals []time.Duration) error {
+	for i := 1; i < len(intervals); i++ {
+		if intervals[i] < intervals[i-1] {
+			return fmt.Errorf("interval %d (%s) is shorter than interval %d (%s)", i, intervals[i], i-1, intervals[i-1])
+		}
+	}
+	return nil
+}
+
+// CheckBounded checks that every interval is between zero and max, inclusive
+func CheckBounded(intervals []time.Duration, max time.Duration) error {
+	for i, interval := range intervals {
+		if interval < 0 || interval > max {
+			return fmt.Errorf("interval %d (%s) is outside [0s, %s]", i, interval, max)
+		}
+	}
+	return nil
+}
+
+// CheckTotalWithin checks that the intervals add up to no more than budget
+func CheckTotalWithin(intervals []time.Duration, budget time.Duration) error {
+	total := time.Duration(0)
+	for i, interval := range intervals {
+		if interval > budget-total {
+			return fmt.Errorf("total of the first %d intervals is more than %s", i+1, budget)
+		}
+		total += interval
+	}
+	return nil
+}
+
+// CheckTerminatesWithin checks that r gives up within its next attempts attempts, if every one of them fails. It only
+// looks at the limits that can be known in advance - the maximum attempt count, and WithMaxTotalSleep - rather than
+// making any attempts, so r isn't affected
+func CheckTerminatesWithin(r *roko.Retrier, attempts int) error {
+	if planned := r.PlannedIntervals(attempts); len(planned) >= attempts {
+		return fmt.Errorf("retrier is still retrying after %d attempts", attempts)
+	}
+	return nil
+}
+
+// SleepRecorder records the intervals a retrier sleeps for, without sleeping. Pass its Sleep method to WithSleepFunc to
+// get a retrier's real, jittered intervals for the Check functions:
+//
+//	rec := &rokotest.SleepRecorder{}
+//	r := roko.NewRetrier(
+//		roko.WithMaxAttempts(10),
+//		roko.WithStrategy(roko.Func(backoff)),
+//		roko.WithJitter(),
+//		roko.WithSleepFunc(rec.Sleep),
+//	)
+//	_ = r.Do(func(*roko.Retrier) error { return errors.New("always fails") })
+//	err := rokotest.CheckBounded(rec.Intervals(), time.Minute)
+//
+// It's safe to use concurrently
+type SleepRecorder struct {
+	mu        sync.Mutex
+	intervals []time.Duration
+}
+
+// Sleep records d, and returns immediately
+func (s *SleepRecorder) Sleep(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.intervals = append(s.intervals, d)
+}
+
+// Intervals returns the intervals that Sleep has been called with, in order
+func (s *SleepRecorder) Intervals() []time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]time.Duration(nil), s.intervals...)
+}
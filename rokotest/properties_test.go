@@ -0,0 +1,63 @@
+package rokotest
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/buildkite/roko"
+	"gotest.tools/v3/assert"
+)
+
+func TestCheckNonDecreasing(t *testing.T) {
+	t.Parallel()
+
+	assert.NilError(t, CheckNonDecreasing(nil))
+	assert.NilError(t, CheckNonDecreasing([]time.Duration{time.Second, time.Second, 2 * time.Second}))
+	assert.Error(t, CheckNonDecreasing([]time.Duration{time.Second, 3 * time.Second, 2 * time.Second}), "interval 2 (2s) is shorter than interval 1 (3s)")
+}
+
+func TestCheckBounded(t *testing.T) {
+	t.Parallel()
+
+	assert.NilError(t, CheckBounded([]time.Duration{0, time.Minute}, time.Minute))
+	assert.Error(t, CheckBounded([]time.Duration{time.Second, 2 * time.Minute}, time.Minute), "interval 1 (2m0s) is outside [0s, 1m0s]")
+	assert.Error(t, CheckBounded([]time.Duration{-time.Second}, time.Minute), "interval 0 (-1s) is outside [0s, 1m0s]")
+}
+
+func TestCheckTotalWithin(t *testing.T) {
+	t.Parallel()
+
+	assert.NilError(t, CheckTotalWithin([]time.Duration{time.Second, 2 * time.Second}, 3*time.Second))
+	assert.Error(t, CheckTotalWithin([]time.Duration{time.Second, 2 * time.Second, time.Second}, 3*time.Second), "total of the first 3 intervals is more than 3s")
+}
+
+func TestCheckTerminatesWithin(t *testing.T) {
+	t.Parallel()
+
+	bounded := roko.NewRetrier(roko.WithMaxAttempts(5), roko.WithStrategy(roko.Constant(time.Second)))
+	assert.NilError(t, CheckTerminatesWithin(bounded, 5))
+	assert.Error(t, CheckTerminatesWithin(bounded, 4), "retrier is still retrying after 4 attempts")
+
+	budgeted := roko.NewRetrier(roko.TryForever(), roko.WithStrategy(roko.Constant(time.Second)), roko.WithMaxTotalSleep(10*time.Second))
+	assert.NilError(t, CheckTerminatesWithin(budgeted, 100))
+
+	forever := roko.NewRetrier(roko.TryForever(), roko.WithStrategy(roko.Constant(time.Second)))
+	assert.ErrorContains(t, CheckTerminatesWithin(forever, 100), "still retrying")
+}
+
+func TestSleepRecorder_RecordsJitteredIntervals(t *testing.T) {
+	t.Parallel()
+
+	rec := &SleepRecorder{}
+	err := roko.NewRetrier(
+		roko.WithMaxAttempts(20),
+		roko.WithStrategy(roko.Exponential(2*time.Second, 0)),
+		roko.WithJitter(roko.EqualJitter),
+		roko.WithSleepFunc(rec.Sleep),
+	).Do(func(*roko.Retrier) error { return errors.New("always fails") })
+	assert.ErrorContains(t, err, "always fails")
+
+	assert.Equal(t, len(rec.Intervals()), 19)
+	assert.NilError(t, CheckBounded(rec.Intervals(), 1<<19*time.Second))
+}
@@ -0,0 +1,141 @@
+// Package rokotest provides fakes for testing code that uses roko retriers, helpers for checking the properties of
+// retry schedules, table-driven Scenarios for testing the schedule a retry policy follows, and a FaultInjector for
+// testing retry policies and error classifiers against scripted or random failures.
+package rokotest
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/buildkite/roko"
+)
+
+// Retrier is a fake roko.Interface, for testing code that accepts a retrier. Each call to Do or DoWithContext calls the
+// callback exactly once, without sleeping, and then returns the next result from the retrier's script rather than the
+// callback's result. Once the script runs out, Do and DoWithContext return nil.
+//
+// Retrier records how it was used, so that tests can make assertions about it - including calls to Break and
+// SetNextInterval made by a callback on the *roko.Retrier it's passed, as well as calls made on the fake itself. Only the
+// last interval a callback sets is recorded, as that's the one a real retrier would use. It's safe to use concurrently.
+type Retrier struct {
+	mu sync.Mutex
+
+	script        []error
+	calls         int
+	attempts      int
+	breaks        int
+	nextIntervals []time.Duration
+}
+
+var _ roko.Interface = (*Retrier)(nil)
+
+// unsetInterval is what the retrier passed to a callback has as its next interval until the callback sets one
+const unsetInterval = time.Duration(math.MinInt64)
+
+// AlwaysSucceed returns a fake retrier whose Do and DoWithContext always return nil
+func AlwaysSucceed() *Retrier {
+	return Scripted()
+}
+
+// FailNTimes returns a fake retrier whose first n calls to Do or DoWithContext return err, after which they return nil
+func FailNTimes(n int, err error) *Retrier {
+	script := make([]error, n)
+	for i := range script {
+		script[i] = err
+	}
+	return Scripted(script...)
+}
+
+// Scripted returns a fake retrier whose calls to Do and DoWithContext return each of the given results in turn. A nil
+// entry in the script is a success. Once the script runs out, Do and DoWithContext return nil
+func Scripted(results ...error) *Retrier {
+	return &Retrier{script: results}
+}
+
+// Do calls callback once, and returns the next scripted result
+func (r *Retrier) Do(callback func(*roko.Retrier) error) error {
+	return r.DoWithContext(context.Background(), callback)
+}
+
+// DoWithContext calls callback once, and returns the next scripted result. If ctx is already done, it returns the
+// context's error without calling callback
+func (r *Retrier) DoWithContext(ctx context.Context, callback func(*roko.Retrier) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// The callback is given a real retrier, so watch it for calls to Break and SetNextInterval. Presetting the next
+	// interval to a value no caller would pass shows whether the callback set it
+	inner := roko.NewRetrier(roko.NoRetry())
+	inner.SetNextInterval(unsetInterval)
+	gaveUp := inner.ShouldGiveUp()
+
+	_ = callback(inner)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.calls++
+	if inner.ShouldGiveUp() && !gaveUp {
+		r.breaks++
+	}
+	if d := inner.NextInterval(); d != unsetInterval {
+		r.nextIntervals = append(r.nextIntervals, d)
+	}
+
+	var err error
+	if len(r.script) > 0 {
+		err, r.script = r.script[0], r.script[1:]
+	}
+
+	if err != nil {
+		r.attempts++
+	}
+
+	return err
+}
+
+// Break records that Break was called
+func (r *Retrier) Break() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.breaks++
+}
+
+// SetNextInterval records the interval it was called with
+func (r *Retrier) SetNextInterval(d time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.nextIntervals = append(r.nextIntervals, d)
+}
+
+// AttemptCount returns the number of calls to Do or DoWithContext that have returned an error
+func (r *Retrier) AttemptCount() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.attempts
+}
+
+// Calls returns the number of times Do or DoWithContext has called its callback
+func (r *Retrier) Calls() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.calls
+}
+
+// Breaks returns the number of times Break has been called, either on the fake or by a callback
+func (r *Retrier) Breaks() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.breaks
+}
+
+// NextIntervals returns the intervals that SetNextInterval has been called with, either on the fake or by a callback,
+// in order
+func (r *Retrier) NextIntervals() []time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]time.Duration(nil), r.nextIntervals...)
+}
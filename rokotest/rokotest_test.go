@@ -0,0 +1,111 @@
+package rokotest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/buildkite/roko"
+	"gotest.tools/v3/assert"
+)
+
+var errDummy = errors.New("scripted failure")
+
+// fetch is a stand-in for code under test that accepts a retrier
+func fetch(r roko.Interface) (int, error) {
+	calls := 0
+	err := r.Do(func(*roko.Retrier) error {
+		calls++
+		return nil
+	})
+	return calls, err
+}
+
+func TestAlwaysSucceed(t *testing.T) {
+	t.Parallel()
+
+	r := AlwaysSucceed()
+	for i := 0; i < 3; i++ {
+		calls, err := fetch(r)
+		assert.NilError(t, err)
+		assert.Equal(t, 1, calls)
+	}
+
+	assert.Equal(t, 3, r.Calls())
+	assert.Equal(t, 0, r.AttemptCount())
+}
+
+func TestFailNTimes(t *testing.T) {
+	t.Parallel()
+
+	r := FailNTimes(2, errDummy)
+
+	_, err := fetch(r)
+	assert.ErrorIs(t, err, errDummy)
+	_, err = fetch(r)
+	assert.ErrorIs(t, err, errDummy)
+	_, err = fetch(r)
+	assert.NilError(t, err)
+
+	assert.Equal(t, 3, r.Calls())
+	assert.Equal(t, 2, r.AttemptCount())
+}
+
+func TestScripted(t *testing.T) {
+	t.Parallel()
+
+	errOther := errors.New("other")
+	r := Scripted(nil, errDummy, errOther)
+
+	assert.NilError(t, r.Do(func(*roko.Retrier) error { return nil }))
+	assert.ErrorIs(t, r.Do(func(*roko.Retrier) error { return nil }), errDummy)
+	assert.ErrorIs(t, r.Do(func(*roko.Retrier) error { return nil }), errOther)
+	assert.NilError(t, r.Do(func(*roko.Retrier) error { return errDummy })) // the script overrides the callback
+}
+
+func TestRetrier_RecordsBreaksAndIntervals(t *testing.T) {
+	t.Parallel()
+
+	r := AlwaysSucceed()
+	r.Break()
+	r.SetNextInterval(5 * time.Second)
+	r.SetNextInterval(0)
+
+	assert.Equal(t, 1, r.Breaks())
+	assert.DeepEqual(t, []time.Duration{5 * time.Second, 0}, r.NextIntervals())
+}
+
+func TestRetrier_RecordsBreaksAndIntervalsFromCallbacks(t *testing.T) {
+	t.Parallel()
+
+	r := Scripted(errDummy, errDummy)
+
+	// Code under test usually calls these on the retrier its callback is passed, rather than on the retrier itself
+	_ = r.Do(func(rr *roko.Retrier) error {
+		rr.SetNextInterval(time.Second)
+		rr.SetNextInterval(3 * time.Second)
+		return errDummy
+	})
+	_ = r.Do(func(rr *roko.Retrier) error {
+		rr.SetNextInterval(0)
+		rr.Break()
+		return errDummy
+	})
+	_ = r.Do(func(*roko.Retrier) error { return nil })
+
+	assert.Equal(t, 1, r.Breaks())
+	assert.DeepEqual(t, []time.Duration{3 * time.Second, 0}, r.NextIntervals())
+}
+
+func TestDoWithContext_WhenContextIsDone_DoesNotCallCallback(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	r := AlwaysSucceed()
+	err := r.DoWithContext(ctx, func(*roko.Retrier) error { return nil })
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 0, r.Calls())
+}
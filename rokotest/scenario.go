@@ -0,0 +1,220 @@
+package rokotest
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/buildkite/roko"
+)
+
+// scenarioEpoch is when every scenario's simulated clock starts
+var scenarioEpoch = time.Date(2024, time.January, 1, 9, 0, 0, 0, time.UTC)
+
+// Outcome is the scripted result of one attempt in a Scenario
+type Outcome struct {
+	Err        error         // The error the attempt fails with, or nil if it succeeds
+	StatusCode int           // The HTTP status of the attempt's response, if it has one
+	RetryAfter time.Duration // How long the response's Retry-After header asks the retrier to wait, if it has one
+}
+
+// Succeed returns an outcome for an attempt that succeeds
+func Succeed() Outcome {
+	return Outcome{StatusCode: http.StatusOK}
+}
+
+// Fail returns an outcome for an attempt that fails with err
+func Fail(err error) Outcome {
+	return Outcome{Err: err}
+}
+
+// FailStatus returns an outcome for an attempt that gets an HTTP response with the given (unsuccessful) status code,
+// and a Retry-After header if retryAfter is more than zero, such as FailStatus(429, 5*time.Second)
+func FailStatus(code int, retryAfter time.Duration) Outcome {
+	return Outcome{
+		Err:        &StatusError{StatusCode: code},
+		StatusCode: code,
+		RetryAfter: retryAfter,
+	}
+}
+
+// StatusError is the error of an outcome from FailStatus
+type StatusError struct {
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%d %s", e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+// Is reports whether target is a StatusError with the same status code, so that a scenario's WantErr can be written as
+// &rokotest.StatusError{StatusCode: 404}
+func (e *StatusError) Is(target error) bool {
+	t, ok := target.(*StatusError)
+	return ok && t.StatusCode == e.StatusCode
+}
+
+// Apply is what an attempt does with its outcome in a Scenario without an Attempt func: it passes the outcome's
+// Retry-After on to r with SetNextInterval, and returns its error
+func (o Outcome) Apply(r *roko.Retrier) error {
+	if o.Err != nil && o.RetryAfter > 0 {
+		r.SetNextInterval(o.RetryAfter)
+	}
+	return o.Err
+}
+
+// Response returns a fake HTTP response with the outcome's status code (or 200 OK, if it doesn't have one) and
+// Retry-After header, for scenarios that test HTTP error classifiers
+func (o Outcome) Response() *http.Response {
+	code := o.StatusCode
+	if code == 0 {
+		code = http.StatusOK
+	}
+
+	resp := &http.Response{
+		Status:     fmt.Sprintf("%d %s", code, http.StatusText(code)),
+		StatusCode: code,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     http.Header{},
+		Body:       http.NoBody,
+	}
+	if o.RetryAfter > 0 {
+		resp.Header.Set("Retry-After", strconv.Itoa(int((o.RetryAfter+time.Second-1)/time.Second)))
+	}
+	return resp
+}
+
+// Scenario is a table-driven test of a retry policy: a script of attempt outcomes, and the schedule that the policy
+// should follow given them. The retrier runs in a simulation (see roko.WithSimulation), so scenarios run instantly:
+//
+//	rokotest.RunScenarios(t, []rokotest.Scenario{{
+//		Name:    "honours Retry-After",
+//		Options: []func(*roko.Retrier){roko.WithMaxAttempts(3), roko.WithStrategy(roko.Constant(time.Second))},
+//		Outcomes: []rokotest.Outcome{
+//			rokotest.Fail(io.ErrUnexpectedEOF),
+//			rokotest.FailStatus(429, 5*time.Second),
+//			rokotest.Succeed(),
+//		},
+//		WantIntervals: []time.Duration{time.Second, 5 * time.Second},
+//	}})
+//
+// Every outcome in the script must be used, and the retrier must not make any attempts that aren't in it.
+type Scenario struct {
+	Name string
+
+	// Options are the retrier options for the policy under test. The retrier is given a simulated clock after them, so
+	// they shouldn't include WithClock, WithSleepFunc or WithSimulation
+	Options []func(*roko.Retrier)
+	Seed    int64 // The seed for the retrier's jitter
+
+	Outcomes []Outcome
+
+	// Attempt is the code under test, which is called for each attempt with its outcome, and returns the error the
+	// attempt fails with. It can, for example, pass the outcome's Response to an HTTP error classifier. If it's nil, each
+	// attempt calls the outcome's Apply method
+	Attempt func(r *roko.Retrier, o Outcome) error
+
+	WantIntervals []time.Duration // The waits the retrier should make between attempts, in order
+	WantErr       error           // What the retrier's final error should match with errors.Is, or nil if it should succeed
+}
+
+// ScenarioResult is what happened when a Scenario ran
+type ScenarioResult struct {
+	Attempts  int             // The number of attempts the retrier made
+	Intervals []time.Duration // The waits between them
+	Elapsed   time.Duration   // The total simulated time the scenario took
+	Err       error           // The retrier's final error
+}
+
+// Play runs the scenario, without checking its result against what it wants
+func (s Scenario) Play() ScenarioResult {
+	var result ScenarioResult
+
+	clock := roko.NewSimulatedClock(scenarioEpoch)
+	opts := s.Options
+	r := roko.NewRetrier(
+		func(r *roko.Retrier) {
+			for _, o := range opts {
+				o(r)
+			}
+		},
+		roko.WithSimulation(s.Seed, clock),
+		roko.WithSleepFunc(func(d time.Duration) {
+			result.Intervals = append(result.Intervals, d)
+			clock.Advance(d)
+		}),
+	)
+
+	attempt := s.Attempt
+	if attempt == nil {
+		attempt = func(r *roko.Retrier, o Outcome) error { return o.Apply(r) }
+	}
+
+	result.Err = r.Do(func(r *roko.Retrier) error {
+		result.Attempts++
+		if result.Attempts > len(s.Outcomes) {
+			return roko.Unrecoverable(errUnscripted)
+		}
+		return attempt(r, s.Outcomes[result.Attempts-1])
+	})
+	result.Elapsed = clock.Now().Sub(scenarioEpoch)
+	return result
+}
+
+// errUnscripted is what an attempt fails with when the scenario's script has run out
+var errUnscripted = errors.New("rokotest: the retrier made more attempts than the scenario has outcomes")
+
+// Check returns an error describing how result differs from what the scenario wants, or nil if it doesn't
+func (s Scenario) Check(result ScenarioResult) error {
+	switch {
+	case result.Attempts > len(s.Outcomes):
+		return fmt.Errorf("made %d attempts, but only %d outcomes are scripted", result.Attempts, len(s.Outcomes))
+	case result.Attempts < len(s.Outcomes):
+		return fmt.Errorf("made %d attempts, but %d outcomes are scripted (final error: %v)", result.Attempts, len(s.Outcomes), result.Err)
+	}
+
+	if len(result.Intervals) != len(s.WantIntervals) {
+		return fmt.Errorf("waited %v between attempts, want %v", result.Intervals, s.WantIntervals)
+	}
+	for i := range result.Intervals {
+		if result.Intervals[i] != s.WantIntervals[i] {
+			return fmt.Errorf("waited %v between attempts, want %v", result.Intervals, s.WantIntervals)
+		}
+	}
+
+	switch {
+	case s.WantErr == nil && result.Err != nil:
+		return fmt.Errorf("failed with %q, want success", result.Err)
+	case s.WantErr != nil && result.Err == nil:
+		return fmt.Errorf("succeeded, want an error matching %q", s.WantErr)
+	case s.WantErr != nil && !errors.Is(result.Err, s.WantErr):
+		return fmt.Errorf("failed with %q, want an error matching %q", result.Err, s.WantErr)
+	}
+	return nil
+}
+
+// Run plays the scenario, and fails t if the result isn't what the scenario wants
+func (s Scenario) Run(t testing.TB) {
+	t.Helper()
+
+	if err := s.Check(s.Play()); err != nil {
+		t.Error(err)
+	}
+}
+
+// RunScenarios runs each of the scenarios as a subtest of t, named after the scenario
+func RunScenarios(t *testing.T, scenarios []Scenario) {
+	t.Helper()
+
+	for _, s := range scenarios {
+		s := s
+		t.Run(s.Name, func(t *testing.T) {
+			s.Run(t)
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package rokotest
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/buildkite/roko"
+	"gotest.tools/v3/assert"
+)
+
+func TestRunScenarios(t *testing.T) {
+	t.Parallel()
+
+	constant := []func(*roko.Retrier){roko.WithMaxAttempts(3), roko.WithStrategy(roko.Constant(time.Second))}
+
+	RunScenarios(t, []Scenario{
+		{
+			Name:     "succeeds first time",
+			Options:  constant,
+			Outcomes: []Outcome{Succeed()},
+		},
+		{
+			Name:          "honours Retry-After",
+			Options:       constant,
+			Outcomes:      []Outcome{Fail(io.ErrUnexpectedEOF), FailStatus(http.StatusTooManyRequests, 5*time.Second), Succeed()},
+			WantIntervals: []time.Duration{time.Second, 5 * time.Second},
+		},
+		{
+			Name:          "gives up",
+			Options:       constant,
+			Outcomes:      []Outcome{Fail(errDummy), Fail(errDummy), Fail(errDummy)},
+			WantIntervals: []time.Duration{time.Second, time.Second},
+			WantErr:       errDummy,
+		},
+		{
+			Name:    "doesn't retry client errors",
+			Options: constant,
+			Attempt: func(r *roko.Retrier, o Outcome) error {
+				resp := o.Response()
+				if resp.StatusCode >= 400 && resp.StatusCode < 500 {
+					r.Break()
+				}
+				return o.Apply(r)
+			},
+			Outcomes: []Outcome{FailStatus(http.StatusNotFound, 0)},
+			WantErr:  &StatusError{StatusCode: http.StatusNotFound},
+		},
+	})
+}
+
+func TestScenario_Check(t *testing.T) {
+	t.Parallel()
+
+	s := Scenario{
+		Options:       []func(*roko.Retrier){roko.WithMaxAttempts(2), roko.WithStrategy(roko.Constant(time.Second))},
+		Outcomes:      []Outcome{Fail(errDummy), Fail(errDummy)},
+		WantIntervals: []time.Duration{time.Second},
+		WantErr:       errDummy,
+	}
+	result := s.Play()
+	assert.NilError(t, s.Check(result))
+	assert.Equal(t, 2, result.Attempts)
+	assert.Equal(t, time.Second, result.Elapsed)
+
+	cases := []struct {
+		name   string
+		change func(*Scenario)
+		want   string
+	}{
+		{"too few outcomes", func(s *Scenario) { s.Outcomes = s.Outcomes[:1] }, "made 2 attempts, but only 1 outcomes are scripted"},
+		{"too many outcomes", func(s *Scenario) { s.Outcomes = append(s.Outcomes, Succeed()) }, "made 2 attempts, but 3 outcomes are scripted"},
+		{"different intervals", func(s *Scenario) { s.WantIntervals = []time.Duration{2 * time.Second} }, "waited [1s] between attempts, want [2s]"},
+		{"unexpected failure", func(s *Scenario) { s.WantErr = nil }, "want success"},
+		{"different error", func(s *Scenario) { s.WantErr = io.EOF }, "want an error matching"},
+	}
+
+	for _, tc := range cases {
+		s := s
+		s.Outcomes = append([]Outcome(nil), s.Outcomes...)
+		tc.change(&s)
+
+		err := s.Check(s.Play())
+		assert.Check(t, err != nil && strings.Contains(err.Error(), tc.want), "%s: %v", tc.name, err)
+	}
+}
+
+func TestScenario_JitterIsRepeatable(t *testing.T) {
+	t.Parallel()
+
+	s := Scenario{
+		Options: []func(*roko.Retrier){
+			roko.WithMaxAttempts(4),
+			roko.WithStrategy(roko.Exponential(2*time.Second, 0)),
+			roko.WithJitter(roko.FullJitter),
+		},
+		Seed:     3,
+		Outcomes: []Outcome{Fail(errDummy), Fail(errDummy), Fail(errDummy), Succeed()},
+	}
+
+	first := s.Play()
+	assert.NilError(t, first.Err)
+	assert.Equal(t, 3, len(first.Intervals))
+
+	// Once a jittered schedule has been seen, a scenario can pin it down
+	s.WantIntervals = first.Intervals
+	s.Run(t)
+}
+
+func TestOutcome_Response(t *testing.T) {
+	t.Parallel()
+
+	resp := FailStatus(http.StatusServiceUnavailable, 1500*time.Millisecond).Response()
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	assert.Equal(t, "503 Service Unavailable", resp.Status)
+	assert.Equal(t, "2", resp.Header.Get("Retry-After"))
+
+	assert.Equal(t, http.StatusOK, Fail(errDummy).Response().StatusCode)
+
+	var statusErr *StatusError
+	assert.Assert(t, errors.As(FailStatus(http.StatusTooManyRequests, 0).Err, &statusErr))
+	assert.Equal(t, "429 Too Many Requests", statusErr.Error())
+}
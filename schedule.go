@@ -0,0 +1,84 @@
+package roko
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Schedule returns a strategy that waits for each of the given intervals in turn, for hand-tuned schedules that don't
+// follow a formula - such as ones read from a config file with ParseIntervals:
+//
+//	intervals, err := roko.ParseIntervals("1s, 5s, 30s, 5m")
+//	...
+//	roko.WithStrategy(roko.Schedule(intervals...))
+//
+// Once the intervals run out, it keeps waiting for the last one. Unlike Replay, jitter is added to each interval, so a
+// schedule can be combined with WithJitter and anything else that adjusts intervals. Schedule panics if it's given no
+// intervals, or a negative one
+func Schedule(intervals ...time.Duration) (Strategy, string) {
+	intervals = checkIntervals(scheduleStrategy, intervals)
+	return func(r *Retrier) time.Duration {
+		attempt := r.AttemptCount()
+		if attempt >= len(intervals) {
+			attempt = len(intervals) - 1
+		}
+		return saturatingAdd(intervals[attempt], r.Jitter())
+	}, fmt.Sprintf("%s(%s)", scheduleStrategy, formatIntervals(intervals))
+}
+
+// Cycle returns a strategy that waits for each of the given intervals in turn, as Schedule does, but starts again from
+// the first interval once they run out, rather than staying at the last one, for as long as the retrier keeps trying. To
+// go through the intervals a fixed number of times, use Repeat. Cycle panics if it's given no intervals, or a negative
+// one
+func Cycle(intervals ...time.Duration) (Strategy, string) {
+	intervals = checkIntervals(cycleStrategy, intervals)
+	return func(r *Retrier) time.Duration {
+		return saturatingAdd(intervals[r.AttemptCount()%len(intervals)], r.Jitter())
+	}, fmt.Sprintf("%s(%s)", cycleStrategy, formatIntervals(intervals))
+}
+
+// Repeat returns a strategy that goes through the given intervals n times, as Cycle does, and then gives up, by calling
+// Break before the attempt that follows the last interval. Combined with Schedule's intervals, that expresses patterns
+// like "1s, 5s, 30s, three times over". The retrier's own limits still apply, so it should usually be used with
+// TryForever, or with an attempt limit no lower than n*len(intervals) + 1. Repeat panics if n isn't positive, or if it's
+// given no intervals, or a negative one
+func Repeat(n int, intervals ...time.Duration) (Strategy, string) {
+	if n <= 0 {
+		panic(repeatStrategy + " retry strategies must repeat their intervals at least once")
+	}
+
+	intervals = checkIntervals(repeatStrategy, intervals)
+	return func(r *Retrier) time.Duration {
+		attempt := r.AttemptCount()
+		if attempt >= n*len(intervals) {
+			r.Break()
+			return 0
+		}
+		return saturatingAdd(intervals[attempt%len(intervals)], r.Jitter())
+	}, fmt.Sprintf("%s(%d, %s)", repeatStrategy, n, formatIntervals(intervals))
+}
+
+// checkIntervals panics if intervals is empty or has a negative interval in it, and otherwise returns a copy of it, so
+// that the caller can't change a strategy's intervals after the fact
+func checkIntervals(kind string, intervals []time.Duration) []time.Duration {
+	if len(intervals) == 0 {
+		panic(kind + " retry strategies must have at least one interval")
+	}
+	for _, interval := range intervals {
+		if interval < 0 {
+			panic(kind + " retry strategies can't have negative intervals")
+		}
+	}
+
+	return append([]time.Duration(nil), intervals...)
+}
+
+// formatIntervals formats intervals as a comma-separated list, for strategy names
+func formatIntervals(intervals []time.Duration) string {
+	parts := make([]string, len(intervals))
+	for i, interval := range intervals {
+		parts[i] = interval.String()
+	}
+	return strings.Join(parts, ", ")
+}
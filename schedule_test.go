@@ -0,0 +1,208 @@
+package roko
+
+import (
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/opt"
+)
+
+func TestSchedule_WaitsForEachIntervalThenTheLast(t *testing.T) {
+	t.Parallel()
+
+	intervals, err := ParseIntervals("1s, 5s, 30s, 5m")
+	assert.NilError(t, err)
+
+	insomniac := newInsomniac()
+	err = NewRetrier(
+		WithMaxAttempts(7),
+		WithStrategy(Schedule(intervals...)),
+		WithSleepFunc(insomniac.sleep),
+	).Do(func(*Retrier) error { return errDummy })
+	assert.ErrorIs(t, err, errDummy)
+
+	assert.DeepEqual(t,
+		[]time.Duration{
+			time.Second,
+			5 * time.Second,
+			30 * time.Second,
+			5 * time.Minute,
+			5 * time.Minute,
+			5 * time.Minute,
+		},
+		insomniac.sleepIntervals,
+		DurationExact(),
+	)
+}
+
+func TestSchedule_WithJitter(t *testing.T) {
+	t.Parallel()
+
+	insomniac := newInsomniac()
+	err := NewRetrier(
+		WithMaxAttempts(4),
+		WithStrategy(Schedule(5*time.Second, 10*time.Second)),
+		WithJitter(),
+		WithSleepFunc(insomniac.sleep),
+	).Do(func(*Retrier) error { return errDummy })
+	assert.ErrorIs(t, err, errDummy)
+
+	assert.DeepEqual(t,
+		[]time.Duration{5 * time.Second, 10 * time.Second, 10 * time.Second},
+		insomniac.sleepIntervals,
+		opt.DurationWithThreshold(defaultJitterInterval),
+	)
+}
+
+func TestSchedule_Describe(t *testing.T) {
+	t.Parallel()
+
+	r := NewRetrier(WithMaxAttempts(4), WithStrategy(Schedule(time.Second, 90*time.Second)))
+	assert.Equal(t, "schedule(1s, 1m30s), up to 4 attempts", r.Describe())
+}
+
+func TestSchedule_PanicsWithoutValidIntervals(t *testing.T) {
+	t.Parallel()
+
+	for name, intervals := range map[string][]time.Duration{
+		"none":     nil,
+		"negative": {time.Second, -time.Second},
+	} {
+		intervals := intervals
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			defer func() { assert.Assert(t, recover() != nil) }()
+
+			Schedule(intervals...)
+		})
+	}
+}
+
+func TestCycle_StartsAgainOnceTheIntervalsRunOut(t *testing.T) {
+	t.Parallel()
+
+	// Going through the intervals twice, then giving up
+	intervals := []time.Duration{time.Second, 5 * time.Second, 30 * time.Second}
+	insomniac := newInsomniac()
+	r := NewRetrier(
+		WithMaxAttempts(2*len(intervals)+1),
+		WithStrategy(Cycle(intervals...)),
+		WithSleepFunc(insomniac.sleep),
+	)
+	err := r.Do(func(*Retrier) error { return errDummy })
+	assert.ErrorIs(t, err, errDummy)
+
+	assert.DeepEqual(t,
+		[]time.Duration{
+			time.Second,
+			5 * time.Second,
+			30 * time.Second,
+			time.Second,
+			5 * time.Second,
+			30 * time.Second,
+		},
+		insomniac.sleepIntervals,
+		DurationExact(),
+	)
+	assert.Equal(t, "cycle(1s, 5s, 30s), up to 7 attempts", r.Describe())
+}
+
+func TestCycle_PanicsWithoutValidIntervals(t *testing.T) {
+	t.Parallel()
+
+	for name, intervals := range map[string][]time.Duration{
+		"none":     nil,
+		"negative": {-time.Second},
+	} {
+		intervals := intervals
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			defer func() { assert.Assert(t, recover() != nil) }()
+
+			Cycle(intervals...)
+		})
+	}
+}
+
+func TestRepeat_GivesUpAfterGoingThroughTheIntervalsNTimes(t *testing.T) {
+	t.Parallel()
+
+	intervals := []time.Duration{time.Second, 5 * time.Second, 30 * time.Second}
+	insomniac := newInsomniac()
+	calls := 0
+	r := NewRetrier(
+		TryForever(),
+		WithStrategy(Repeat(2, intervals...)),
+		WithSleepFunc(insomniac.sleep),
+	)
+	err := r.Do(func(*Retrier) error {
+		calls++
+		return errDummy
+	})
+	assert.ErrorIs(t, err, errDummy)
+
+	assert.DeepEqual(t,
+		[]time.Duration{
+			time.Second,
+			5 * time.Second,
+			30 * time.Second,
+			time.Second,
+			5 * time.Second,
+			30 * time.Second,
+		},
+		insomniac.sleepIntervals,
+		DurationExact(),
+	)
+	assert.Equal(t, 2*len(intervals)+1, calls)
+	assert.Equal(t, "repeat(2, 1s, 5s, 30s), forever", r.Describe())
+}
+
+func TestRepeat_CanSucceedOnTheLastAttempt(t *testing.T) {
+	t.Parallel()
+
+	err := NewRetrier(
+		TryForever(),
+		WithStrategy(Repeat(1, time.Second, 2*time.Second)),
+		WithSleepFunc(dummySleep),
+	).Do(func(r *Retrier) error {
+		if r.AttemptCount() < 2 {
+			return errDummy
+		}
+		return nil
+	})
+	assert.NilError(t, err)
+}
+
+func TestRepeat_PlannedIntervals(t *testing.T) {
+	t.Parallel()
+
+	r := NewRetrier(TryForever(), WithStrategy(Repeat(2, time.Second, 5*time.Second)))
+	assert.DeepEqual(t,
+		[]time.Duration{time.Second, 5 * time.Second, time.Second, 5 * time.Second},
+		r.PlannedIntervals(10),
+		DurationExact(),
+	)
+	assert.Equal(t, 12*time.Second, r.EstimateTotal(100))
+}
+
+func TestRepeat_PanicsWithoutValidArguments(t *testing.T) {
+	t.Parallel()
+
+	for name, tc := range map[string]struct {
+		n         int
+		intervals []time.Duration
+	}{
+		"no repeats": {n: 0, intervals: []time.Duration{time.Second}},
+		"none":       {n: 2},
+		"negative":   {n: 2, intervals: []time.Duration{-time.Second}},
+	} {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			defer func() { assert.Assert(t, recover() != nil) }()
+
+			Repeat(tc.n, tc.intervals...)
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package roko
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// WithSeed makes the retrier's jitter come from a source of randomness seeded with seed, so that the same seed always
+// produces the same sequence of jittered intervals. The seed is included in Describe, so that as long as a retrier's
+// description is logged, its schedule can be reproduced afterwards by passing the same seed to WithSimulation.
+//
+// To get different jitter from each retrier (which is the point of jitter), pick a different seed for each one, for
+// example with time.Now().UnixNano()
+func WithSeed(seed int64) retrierOpt {
+	return func(r *Retrier) {
+		r.rand = rand.New(rand.NewSource(seed))
+		r.seed = seed
+		r.seeded = true
+	}
+}
+
+// WithSimulation runs the retrier in a simulation: its jitter comes from seed (as with WithSeed), and its time comes
+// from clock, which it advances instead of sleeping. Every wait, and every option that depends on the time of day
+// (UntilClock, WithBlackout, WithBusinessHours and Cron), is then driven by the seed and the clock's starting time
+// alone, so a retry schedule can be replayed exactly, and instantly:
+//
+//	clock := roko.NewSimulatedClock(start)
+//	r := roko.NewRetrier(
+//		roko.WithMaxAttempts(10),
+//		roko.WithStrategy(roko.Exponential(2*time.Second, 0)),
+//		roko.WithJitter(roko.FullJitter),
+//		roko.WithSimulation(seed, clock),
+//	)
+//
+// The operation itself is still run for real, so to reproduce a schedule exactly it should fail (or call
+// SetNextInterval) the same way it did originally. WithSimulation should come after any WithClock or WithSleepFunc
+// options, which it replaces
+func WithSimulation(seed int64, clock *SimulatedClock) retrierOpt {
+	return func(r *Retrier) {
+		WithSeed(seed)(r)
+		r.clock = clock
+		r.sleepFunc = clock.Advance
+	}
+}
+
+// SimulatedClock is a Clock that only moves when it's advanced, for use with WithSimulation. It's safe to use
+// concurrently
+type SimulatedClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// NewSimulatedClock returns a simulated clock that starts at start
+func NewSimulatedClock(start time.Time) *SimulatedClock {
+	return &SimulatedClock{now: start.Round(0)}
+}
+
+// Now returns the clock's current time
+func (c *SimulatedClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+// Advance moves the clock forward by d. Negative durations are ignored, so that the clock never goes backwards
+func (c *SimulatedClock) Advance(d time.Duration) {
+	if d <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+}
@@ -0,0 +1,107 @@
+package roko
+
+import (
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+var simulationStart = time.Date(2024, time.March, 4, 9, 0, 0, 0, time.UTC)
+
+// simulate runs a retrier that always fails in a simulation, returning how long after the start each retry was made, and
+// the time it gave up
+func simulate(t *testing.T, seed int64, opts ...retrierOpt) ([]time.Duration, time.Time) {
+	t.Helper()
+
+	clock := NewSimulatedClock(simulationStart)
+	var retries []time.Duration
+	opts = append(opts, WithSimulation(seed, clock))
+
+	err := NewRetrier(opts...).Do(func(r *Retrier) error {
+		if r.AttemptCount() > 0 {
+			retries = append(retries, clock.Now().Sub(simulationStart))
+		}
+		return errDummy
+	})
+	assert.ErrorIs(t, err, errDummy)
+
+	return retries, clock.Now()
+}
+
+func TestWithSimulation_ReproducesTheScheduleFromTheSeed(t *testing.T) {
+	t.Parallel()
+
+	opts := []retrierOpt{WithMaxAttempts(10), WithStrategy(Exponential(2*time.Second, 0)), WithJitter(FullJitter)}
+
+	first, firstEnd := simulate(t, 42, opts...)
+	second, secondEnd := simulate(t, 42, opts...)
+	other, _ := simulate(t, 43, opts...)
+
+	assert.Equal(t, len(first), 9)
+	assert.DeepEqual(t, first, second, DurationExact())
+	assert.Equal(t, firstEnd, secondEnd)
+	assert.Assert(t, firstEnd.After(simulationStart))
+	assert.Check(t, !durationsEqual(first, other), "different seeds gave the same schedule: %v", first)
+}
+
+func TestWithSimulation_DrivesTimeOfDayOptions(t *testing.T) {
+	t.Parallel()
+
+	opts := []retrierOpt{TryForever(), WithStrategy(Constant(10 * time.Minute)), WithJitter(), UntilClock(10, 0, time.UTC)}
+
+	first, end := simulate(t, 7, opts...)
+	second, _ := simulate(t, 7, opts...)
+
+	assert.DeepEqual(t, first, second, DurationExact())
+	assert.Equal(t, len(first), 5)
+	assert.Assert(t, end.Before(time.Date(2024, time.March, 4, 10, 0, 0, 0, time.UTC)))
+}
+
+func TestWithSeed_IsIncludedInTheDescription(t *testing.T) {
+	t.Parallel()
+
+	r := NewRetrier(WithMaxAttempts(3), WithStrategy(Constant(time.Second)), WithJitter(), WithSeed(1234))
+	assert.Equal(t, "constant(1s), jitter [0s, 1s), seed 1234, up to 3 attempts", r.Describe())
+}
+
+func TestWithSeed_GivesTheSameJitter(t *testing.T) {
+	t.Parallel()
+
+	jitter := func() []time.Duration {
+		insomniac := newInsomniac()
+		err := NewRetrier(
+			WithMaxAttempts(20),
+			WithStrategy(Constant(time.Second)),
+			WithJitter(),
+			WithSeed(99),
+			WithSleepFunc(insomniac.sleep),
+		).Do(func(r *Retrier) error { return errDummy })
+		assert.ErrorIs(t, err, errDummy)
+		return insomniac.sleepIntervals
+	}
+
+	assert.DeepEqual(t, jitter(), jitter(), DurationExact())
+}
+
+func TestSimulatedClock_OnlyMovesForwards(t *testing.T) {
+	t.Parallel()
+
+	clock := NewSimulatedClock(simulationStart)
+	clock.Advance(time.Minute)
+	clock.Advance(-time.Hour)
+
+	assert.Equal(t, simulationStart.Add(time.Minute), clock.Now())
+}
+
+func durationsEqual(a, b []time.Duration) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,194 @@
+// Package smtpretry helps to deliver email over SMTP, retrying the way mail servers do. SMTP says which failures are
+// worth retrying: 4xx replies are temporary (a greylisting server, a full mailbox, a server that's too busy), and 5xx
+// replies are permanent (no such user, a message the server won't accept). Connection errors are treated as temporary.
+//
+// Since a single message can go to several recipients, and each can be accepted or refused separately, Send keeps
+// track of every recipient, retrying only the ones that haven't been delivered to or refused for good.
+package smtpretry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/smtp"
+	"net/textproto"
+	"time"
+
+	"github.com/buildkite/roko"
+)
+
+// IsPermanent reports whether err is a permanent SMTP failure: a 5xx reply from the server. Every other error,
+// including 4xx replies and connection errors, might succeed if the command is tried again later
+func IsPermanent(err error) bool {
+	var perr *textproto.Error
+	return errors.As(err, &perr) && perr.Code >= 500 && perr.Code <= 599
+}
+
+// Classify turns an error from an SMTP command into one suitable for returning from a retrier's callback. Permanent
+// failures (see IsPermanent) are wrapped with roko.Unrecoverable, and all other errors are returned as they are
+func Classify(err error) error {
+	if IsPermanent(err) {
+		return roko.Unrecoverable(err)
+	}
+	return err
+}
+
+// Message is an email to deliver
+type Message struct {
+	From string   // The envelope sender
+	To   []string // The envelope recipients
+	Data []byte   // The message, with its headers, as it's sent after the DATA command
+}
+
+// Result is the outcome of delivering a message to one of its recipients
+type Result struct {
+	Recipient string
+	Attempts  int   // How many times delivery to the recipient was attempted
+	Err       error // Why the message wasn't delivered to the recipient, or nil if it was
+}
+
+// Delivered reports whether the message was delivered to the recipient
+func (r Result) Delivered() bool {
+	return r.Err == nil
+}
+
+// Send delivers msg to each of its recipients, retrying with r. For each attempt, it connects to the server with dial,
+// which should also do anything else the server needs before a message can be sent, such as STARTTLS and AUTH.
+//
+// Recipients that the server refuses with a permanent failure are given up on, and the rest are retried until they're
+// delivered, or r gives up. If the whole transaction fails (when the server refuses the sender, or the message itself),
+// the failure applies to every recipient that hadn't been delivered to yet.
+//
+// Send returns a result for every recipient, in the same order as msg.To, and an error if the message wasn't delivered
+// to all of them
+func Send(ctx context.Context, r *roko.Retrier, dial func(ctx context.Context) (*smtp.Client, error), msg Message) ([]Result, error) {
+	results := make([]Result, len(msg.To))
+	pending := make([]int, len(msg.To))
+	for i, to := range msg.To {
+		results[i] = Result{Recipient: to}
+		pending[i] = i
+	}
+
+	err := r.DoWithContext(ctx, func(r *roko.Retrier) error {
+		for _, i := range pending {
+			results[i].Attempts++
+		}
+
+		var err error
+		pending, err = deliver(r.Context(), dial, msg, results, pending)
+		if err != nil {
+			return err
+		}
+		if len(pending) > 0 {
+			return results[pending[0]].Err
+		}
+		return nil
+	})
+
+	// Anything that's still pending is stuck with the last error it got, or the retrier's, if it never got one (because
+	// the context was cancelled before the first attempt)
+	for _, i := range pending {
+		if results[i].Err == nil {
+			results[i].Err = err
+		}
+	}
+
+	failed := 0
+	var firstErr error
+	for _, res := range results {
+		if !res.Delivered() {
+			if failed == 0 {
+				firstErr = res.Err
+			}
+			failed++
+		}
+	}
+	if failed > 0 {
+		return results, fmt.Errorf("message not delivered to %d of %d recipients: %w", failed, len(results), firstErr)
+	}
+	return results, nil
+}
+
+// deliver makes one attempt at delivering msg to the recipients in pending, recording what happens to each of them in
+// results, and returns the ones that are still pending. It returns an error only if the attempt failed for every
+// recipient that was pending, and the error is Unrecoverable if it was permanent
+func deliver(ctx context.Context, dial func(ctx context.Context) (*smtp.Client, error), msg Message, results []Result, pending []int) ([]int, error) {
+	// fail records err against each of the recipients, and returns the ones that can still be retried
+	fail := func(recipients []int, err error) []int {
+		var retry []int
+		for _, i := range recipients {
+			results[i].Err = err
+			if !IsPermanent(err) {
+				retry = append(retry, i)
+			}
+		}
+		return retry
+	}
+
+	c, err := dial(ctx)
+	if err != nil {
+		return fail(pending, err), Classify(err)
+	}
+	defer c.Close()
+
+	if err := c.Mail(msg.From); err != nil {
+		return fail(pending, err), Classify(err)
+	}
+
+	var accepted, retry []int
+	for _, i := range pending {
+		if err := c.Rcpt(results[i].Recipient); err != nil {
+			retry = append(retry, fail([]int{i}, err)...)
+			continue
+		}
+		accepted = append(accepted, i)
+	}
+	if len(accepted) == 0 {
+		return retry, nil
+	}
+
+	if err := sendData(c, msg.Data); err != nil {
+		return append(retry, fail(accepted, err)...), Classify(err)
+	}
+	for _, i := range accepted {
+		results[i].Err = nil
+	}
+
+	// The message has been accepted by now, so a failure to say goodbye doesn't matter
+	_ = c.Quit()
+	return retry, nil
+}
+
+func sendData(c *smtp.Client, data []byte) error {
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+// NewRetrier returns a retrier with the long tail of retries that mail servers use. It waits 5 minutes before the first
+// retry (long enough to get past most greylisting), then 15 and 30 minutes, and then an hour between each retry after
+// that, each shortened by up to a tenth for jitter. It gives up once it's spent 4 days waiting, as RFC 5321 suggests
+func NewRetrier() *roko.Retrier {
+	return roko.NewRetrier(
+		roko.TryForever(),
+		roko.WithMaxTotalSleep(4*24*time.Hour),
+		roko.WithStrategy(roko.Func(interval)),
+		roko.WithJitter(roko.EqualJitter.AtLeast(0.9)),
+	)
+}
+
+// intervals are the waits before the first few retries. The wait before every retry after those is the last of them
+var intervals = []time.Duration{5 * time.Minute, 15 * time.Minute, 30 * time.Minute, time.Hour}
+
+func interval(attempt int) time.Duration {
+	if attempt >= len(intervals) {
+		return intervals[len(intervals)-1]
+	}
+	return intervals[attempt]
+}
@@ -0,0 +1,258 @@
+package smtpretry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/buildkite/roko"
+	"gotest.tools/v3/assert"
+)
+
+func TestClassify(t *testing.T) {
+	t.Parallel()
+
+	temporary := &textproto.Error{Code: 451, Msg: "try again later"}
+	assert.Check(t, !IsPermanent(temporary))
+	assert.Check(t, !errors.Is(Classify(temporary), roko.ErrUnrecoverable))
+
+	assert.Check(t, !IsPermanent(io.EOF))
+	assert.Check(t, !errors.Is(Classify(io.EOF), roko.ErrUnrecoverable))
+
+	permanent := fmt.Errorf("rcpt: %w", &textproto.Error{Code: 550, Msg: "no such user"})
+	assert.Check(t, IsPermanent(permanent))
+	assert.Check(t, errors.Is(Classify(permanent), roko.ErrUnrecoverable))
+
+	assert.NilError(t, Classify(nil))
+}
+
+func TestNewRetrier_HasALongTail(t *testing.T) {
+	t.Parallel()
+
+	assert.DeepEqual(t, []time.Duration{
+		5 * time.Minute,
+		15 * time.Minute,
+		30 * time.Minute,
+		time.Hour,
+		time.Hour,
+	}, []time.Duration{interval(0), interval(1), interval(2), interval(3), interval(4)})
+}
+
+// fakeServer is an SMTP server that replies to RCPT and DATA commands as its funcs say, given the number of the
+// connection (starting at 1) they were sent on
+type fakeServer struct {
+	ln   net.Listener
+	rcpt func(conn int, to string) string
+	data func(conn int) string
+
+	mu        sync.Mutex
+	conns     int
+	delivered [][]string // The recipients of each message the server accepted
+}
+
+func newFakeServer(t *testing.T, rcpt func(conn int, to string) string, data func(conn int) string) *fakeServer {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NilError(t, err)
+	t.Cleanup(func() { ln.Close() })
+
+	s := &fakeServer{ln: ln, rcpt: rcpt, data: data}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.conns++
+			n := s.conns
+			s.mu.Unlock()
+			go s.serve(conn, n)
+		}
+	}()
+	return s
+}
+
+func (s *fakeServer) serve(conn net.Conn, n int) {
+	defer conn.Close()
+	tp := textproto.NewConn(conn)
+
+	var recipients []string
+	_ = tp.PrintfLine("220 localhost fake ESMTP")
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+
+		cmd := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+		switch cmd {
+		case "EHLO", "HELO", "MAIL", "RSET", "NOOP":
+			_ = tp.PrintfLine("250 OK")
+
+		case "RCPT":
+			to := strings.Trim(strings.TrimPrefix(line[len("RCPT "):], "TO:"), "<>")
+			reply := s.rcpt(n, to)
+			if strings.HasPrefix(reply, "250") {
+				recipients = append(recipients, to)
+			}
+			_ = tp.PrintfLine("%s", reply)
+
+		case "DATA":
+			_ = tp.PrintfLine("354 Go ahead")
+			if _, err := tp.ReadDotBytes(); err != nil {
+				return
+			}
+			reply := s.data(n)
+			if strings.HasPrefix(reply, "250") {
+				s.mu.Lock()
+				s.delivered = append(s.delivered, recipients)
+				s.mu.Unlock()
+			}
+			_ = tp.PrintfLine("%s", reply)
+
+		case "QUIT":
+			_ = tp.PrintfLine("221 Bye")
+			return
+
+		default:
+			_ = tp.PrintfLine("502 Command not implemented")
+		}
+	}
+}
+
+func (s *fakeServer) dial(ctx context.Context) (*smtp.Client, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", s.ln.Addr().String())
+	if err != nil {
+		return nil, err
+	}
+	return smtp.NewClient(conn, "localhost")
+}
+
+func (s *fakeServer) deliveries() [][]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.delivered
+}
+
+func accept(int) string { return "250 OK" }
+
+func newTestRetrier() *roko.Retrier {
+	return roko.NewRetrier(
+		roko.WithMaxAttempts(3),
+		roko.WithStrategy(roko.Constant(time.Minute)),
+		roko.WithSleepFunc(func(time.Duration) {}),
+	)
+}
+
+var msg = Message{
+	From: "roko@example.com",
+	To:   []string{"a@example.com", "b@example.com", "c@example.com"},
+	Data: []byte("Subject: Hello\r\n\r\nHello!\r\n"),
+}
+
+func TestSend_TracksEachRecipient(t *testing.T) {
+	t.Parallel()
+
+	srv := newFakeServer(t, func(conn int, to string) string {
+		switch {
+		case to == "b@example.com" && conn == 1:
+			return "451 4.2.1 Mailbox busy, try again later"
+		case to == "c@example.com":
+			return "550 5.1.1 No such user"
+		default:
+			return "250 OK"
+		}
+	}, accept)
+
+	results, err := Send(context.Background(), newTestRetrier(), srv.dial, msg)
+	assert.ErrorContains(t, err, "message not delivered to 1 of 3 recipients: 550")
+
+	assert.Equal(t, 3, len(results))
+	assert.Check(t, results[0].Delivered())
+	assert.Equal(t, 1, results[0].Attempts)
+	assert.Check(t, results[1].Delivered())
+	assert.Equal(t, 2, results[1].Attempts)
+	assert.Check(t, !results[2].Delivered())
+	assert.Check(t, IsPermanent(results[2].Err))
+	assert.Equal(t, 1, results[2].Attempts)
+
+	assert.DeepEqual(t, [][]string{{"a@example.com"}, {"b@example.com"}}, srv.deliveries())
+}
+
+func TestSend_RetriesTheMessageWhenItsRefusedTemporarily(t *testing.T) {
+	t.Parallel()
+
+	srv := newFakeServer(t, func(int, string) string { return "250 OK" }, func(conn int) string {
+		if conn == 1 {
+			return "452 4.3.1 Insufficient system storage"
+		}
+		return "250 OK"
+	})
+
+	results, err := Send(context.Background(), newTestRetrier(), srv.dial, msg)
+	assert.NilError(t, err)
+	for _, res := range results {
+		assert.Check(t, res.Delivered())
+		assert.Equal(t, 2, res.Attempts)
+	}
+	assert.DeepEqual(t, [][]string{msg.To}, srv.deliveries())
+}
+
+func TestSend_GivesUpOnMessagesThatAreRefusedPermanently(t *testing.T) {
+	t.Parallel()
+
+	srv := newFakeServer(t, func(int, string) string { return "250 OK" }, func(int) string {
+		return "554 5.7.1 Message rejected as spam"
+	})
+
+	results, err := Send(context.Background(), newTestRetrier(), srv.dial, msg)
+	assert.ErrorContains(t, err, "message not delivered to 3 of 3 recipients: 554")
+	for _, res := range results {
+		assert.Check(t, IsPermanent(res.Err))
+		assert.Equal(t, 1, res.Attempts)
+	}
+}
+
+func TestSend_GivesUpWhenTheRetrierDoes(t *testing.T) {
+	t.Parallel()
+
+	srv := newFakeServer(t, func(int, string) string {
+		return "450 4.7.1 Greylisted, try again later"
+	}, accept)
+
+	results, err := Send(context.Background(), newTestRetrier(), srv.dial, msg)
+	assert.ErrorContains(t, err, "message not delivered to 3 of 3 recipients: 450")
+	for _, res := range results {
+		assert.Check(t, !res.Delivered())
+		assert.Equal(t, 3, res.Attempts)
+	}
+	assert.Equal(t, 0, len(srv.deliveries()))
+}
+
+func TestSend_RetriesConnectionErrors(t *testing.T) {
+	t.Parallel()
+
+	srv := newFakeServer(t, func(int, string) string { return "250 OK" }, accept)
+
+	dials := 0
+	results, err := Send(context.Background(), newTestRetrier(), func(ctx context.Context) (*smtp.Client, error) {
+		dials++
+		if dials == 1 {
+			return nil, errors.New("connection refused")
+		}
+		return srv.dial(ctx)
+	}, msg)
+
+	assert.NilError(t, err)
+	assert.Equal(t, 2, dials)
+	assert.Equal(t, 2, results[0].Attempts)
+}
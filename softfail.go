@@ -0,0 +1,48 @@
+package roko
+
+// SoftFailure describes a loop that failed on a retrier created with WithSoftFail
+type SoftFailure struct {
+	Name string // The name of the retrier, if it has one - see WithName
+
+	// Err is the error that Do or DoWithContext would have returned, had the retrier not been soft-failing
+	Err error
+
+	// Attempts are the events for each of the attempts the loop made, in order, as passed to WithOnAttempt hooks. There
+	// aren't any if the loop was interrupted before its first attempt
+	Attempts []AttemptEvent
+}
+
+// Summary returns a compact summary of the failed loop's attempts - see Summarize
+func (f SoftFailure) Summary() string {
+	return Summarize(f.Attempts)
+}
+
+// WithSoftFail makes the retrier's Do and DoWithContext loops return nil even when they fail, calling f with the
+// failure instead, for best-effort work whose failure shouldn't fail its caller, such as uploading telemetry:
+//
+//	r := roko.NewRetrier(
+//		roko.WithMaxAttempts(3),
+//		roko.WithStrategy(roko.Exponential(time.Second, 0)),
+//		roko.WithSoftFail(func(f roko.SoftFailure) {
+//			log.Printf("giving up on uploading metrics: %v (%s)", f.Err, f.Summary())
+//		}),
+//	)
+//
+// f is called from the goroutine that ran the loop, once the loop is over, whether it gave up, was stopped with an
+// unrecoverable error or Break, or was interrupted by its context. The one error that's still returned is
+// ErrUnbounded, since it means the retrier was misconfigured rather than that the work failed.
+//
+// Soft failing only applies to Do and DoWithContext. DoFunc and its relatives, and the helpers that need to know
+// whether the work succeeded (such as Prepared, Value, Acquire, Pipeline and ociretry.DoLayers), always return the
+// loop's error, and don't call f, since carrying on as if the work had succeeded would mean using a result that doesn't
+// exist. Wrappers that are built on DoWithContext do soft fail, though - these include gitretry.Do and the wrappers
+// generated by rokogen
+func WithSoftFail(f func(SoftFailure)) retrierOpt {
+	if f == nil {
+		panic("soft fail funcs must not be nil")
+	}
+
+	return func(r *Retrier) {
+		r.softFail = f
+	}
+}
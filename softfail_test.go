@@ -0,0 +1,115 @@
+package roko
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestWithSoftFail_WhenTheRetrierGivesUp(t *testing.T) {
+	t.Parallel()
+
+	errUpload := errors.New("upload failed")
+	var failures []SoftFailure
+	r := NewRetrier(
+		WithName("telemetry"),
+		WithMaxAttempts(3),
+		WithStrategy(Constant(time.Second)),
+		WithSleepFunc(func(time.Duration) {}),
+		WithSoftFail(func(f SoftFailure) { failures = append(failures, f) }),
+	)
+
+	err := r.Do(func(*Retrier) error { return errUpload })
+
+	assert.NilError(t, err)
+	assert.Equal(t, 1, len(failures))
+	f := failures[0]
+	assert.Equal(t, "telemetry", f.Name)
+	assert.ErrorIs(t, f.Err, errUpload)
+	assert.Equal(t, 3, len(f.Attempts))
+	assert.Assert(t, f.Attempts[2].Final)
+	assert.Assert(t, strings.HasPrefix(f.Summary(), "3 attempts"), f.Summary())
+}
+
+func TestWithSoftFail_WhenTheLoopSucceeds(t *testing.T) {
+	t.Parallel()
+
+	called := false
+	r := NewRetrier(
+		WithMaxAttempts(3),
+		WithSoftFail(func(SoftFailure) { called = true }),
+	)
+
+	assert.NilError(t, r.Do(func(*Retrier) error { return nil }))
+	assert.Assert(t, !called)
+}
+
+func TestWithSoftFail_Unrecoverable(t *testing.T) {
+	t.Parallel()
+
+	var failure SoftFailure
+	r := NewRetrier(
+		WithMaxAttempts(3),
+		WithStrategy(Constant(time.Second)),
+		WithSoftFail(func(f SoftFailure) { failure = f }),
+	)
+
+	err := r.Do(func(*Retrier) error { return Unrecoverable(errors.New("bad request")) })
+	assert.NilError(t, err)
+	assert.ErrorIs(t, failure.Err, ErrUnrecoverable)
+	assert.Equal(t, 1, len(failure.Attempts))
+}
+
+func TestWithSoftFail_Interrupted(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	var failure SoftFailure
+	r := NewRetrier(
+		WithMaxAttempts(3),
+		WithStrategy(Constant(time.Hour)),
+		WithSoftFail(func(f SoftFailure) { failure = f }),
+	)
+
+	err := r.DoWithContext(ctx, func(*Retrier) error { return errors.New("failed") })
+	assert.NilError(t, err)
+	assert.ErrorIs(t, failure.Err, context.DeadlineExceeded)
+}
+
+func TestWithSoftFail_StillReturnsErrUnbounded(t *testing.T) {
+	t.Parallel()
+
+	r := NewRetrier(
+		TryForever(),
+		WithStrategy(Constant(time.Second)),
+		RequireBound(),
+		WithSoftFail(func(SoftFailure) { t.Error("unexpected soft failure") }),
+	)
+
+	assert.ErrorIs(t, r.Do(func(*Retrier) error { return nil }), ErrUnbounded)
+}
+
+func TestWithSoftFail_DoesntApplyToDoFunc(t *testing.T) {
+	t.Parallel()
+
+	r := NewRetrier(
+		WithName("lookup"),
+		WithMaxAttempts(2),
+		WithStrategy(Constant(time.Second)),
+		WithSleepFunc(func(time.Duration) {}),
+		WithSoftFail(func(SoftFailure) { t.Error("DoFunc shouldn't soft fail") }),
+	)
+
+	_, err := DoFunc(context.Background(), r, func(*Retrier) (int, error) { return 1, errDummy })
+	assert.ErrorIs(t, err, errDummy)
+
+	var named *NamedError
+	assert.Assert(t, errors.As(err, &named))
+	assert.Equal(t, "lookup", named.Name)
+}
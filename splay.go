@@ -0,0 +1,36 @@
+package roko
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+// Splay returns a random delay between zero and max, for spreading out work that a whole fleet would otherwise start at
+// the same moment - when every agent is restarted by the same deploy, for example. Unlike jitter, which varies each wait
+// in a retry loop, a splay is meant to be applied once, before the first attempt (see SplayedStart). A max of zero or
+// less gives no delay
+func Splay(max time.Duration) time.Duration {
+	if max <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(max)))
+}
+
+// SplayedStart waits for a random delay of up to max (see Splay), for use before starting periodic or retried work. It
+// returns ctx's error if ctx is done before the delay is over, and nil otherwise
+func SplayedStart(ctx context.Context, max time.Duration) error {
+	d := Splay(max)
+	if d == 0 {
+		return ctx.Err()
+	}
+
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
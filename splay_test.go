@@ -0,0 +1,42 @@
+package roko
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestSplay_IsWithinRange(t *testing.T) {
+	t.Parallel()
+
+	seen := map[time.Duration]bool{}
+	for i := 0; i < 1000; i++ {
+		d := Splay(time.Second)
+		assert.Assert(t, d >= 0 && d < time.Second, "splay out of range: %s", d)
+		seen[d] = true
+	}
+	assert.Assert(t, len(seen) > 1, "splay is always %v", seen)
+
+	assert.Equal(t, time.Duration(0), Splay(0))
+	assert.Equal(t, time.Duration(0), Splay(-time.Second))
+}
+
+func TestSplayedStart_Waits(t *testing.T) {
+	t.Parallel()
+
+	start := time.Now()
+	assert.NilError(t, SplayedStart(context.Background(), 20*time.Millisecond))
+	assert.Assert(t, time.Since(start) < time.Second)
+}
+
+func TestSplayedStart_WhenContextIsCancelled_ReturnsItsError(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	assert.ErrorIs(t, SplayedStart(ctx, time.Hour), context.Canceled)
+	assert.ErrorIs(t, SplayedStart(ctx, 0), context.Canceled)
+}
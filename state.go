@@ -0,0 +1,39 @@
+package roko
+
+import "time"
+
+// State is a snapshot of what a retrier is doing right now, for showing to people trying to work out why something is
+// taking so long. See Retrier.State
+type State struct {
+	AttemptCount int // The number of failed attempts so far, as returned by AttemptCount
+	InFlight     int // The number of attempts currently running
+
+	// NextAttemptAt is when the retrier will make its next attempt, if it's waiting to make one. It's the zero time if
+	// the retrier isn't waiting
+	NextAttemptAt time.Time
+
+	LastError     error     // The error from the most recent failed attempt, or nil if no attempts have failed
+	LastErrorAt   time.Time // When the most recent attempt failed
+	LastSuccessAt time.Time // When the most recent attempt succeeded, or the zero time if none have
+}
+
+// Waiting returns whether the retrier is waiting to make another attempt
+func (s State) Waiting() bool {
+	return !s.NextAttemptAt.IsZero()
+}
+
+// State returns a snapshot of the retrier's current state. When the retrier is shared between goroutines, the state
+// describes whichever of their loops updated it most recently
+func (r *Retrier) State() State {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return State{
+		AttemptCount:  r.attemptCount,
+		InFlight:      r.inFlight,
+		NextAttemptAt: r.nextAttemptAt,
+		LastError:     r.lastError,
+		LastErrorAt:   r.lastErrorAt,
+		LastSuccessAt: r.lastSuccessAt,
+	}
+}
@@ -0,0 +1,70 @@
+package roko
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestState_NewRetrier(t *testing.T) {
+	t.Parallel()
+
+	s := NewRetrier(WithMaxAttempts(3), WithStrategy(Constant(time.Second))).State()
+	assert.DeepEqual(t, State{}, s)
+	assert.Assert(t, !s.Waiting())
+}
+
+func TestState_WhileWaiting(t *testing.T) {
+	t.Parallel()
+
+	r := NewRetrier(WithMaxAttempts(3), WithStrategy(Constant(time.Hour)))
+	ctx, cancel := context.WithCancel(context.Background())
+
+	states := make(chan State, 1)
+	go func() {
+		// Give the loop a moment to start sleeping, then look at what it's doing
+		for !r.State().Waiting() {
+			time.Sleep(time.Millisecond)
+		}
+		states <- r.State()
+		cancel()
+	}()
+
+	before := time.Now()
+	err := r.DoWithContext(ctx, func(r *Retrier) error { return errDummy })
+	assert.ErrorIs(t, err, context.Canceled)
+
+	s := <-states
+	assert.Equal(t, 1, s.AttemptCount)
+	assert.Equal(t, 0, s.InFlight)
+	assert.ErrorIs(t, s.LastError, errDummy)
+	assert.Assert(t, !s.LastErrorAt.Before(before))
+	assert.Assert(t, s.NextAttemptAt.Sub(before) >= time.Hour)
+	assert.Assert(t, s.LastSuccessAt.IsZero())
+
+	// Once the loop has stopped, it's not waiting any more
+	assert.Assert(t, !r.State().Waiting())
+}
+
+func TestState_AfterSuccess(t *testing.T) {
+	t.Parallel()
+
+	r := NewRetrier(WithMaxAttempts(3), WithStrategy(Constant(time.Second)), WithSleepFunc(dummySleep))
+
+	calls := 0
+	err := r.Do(func(r *Retrier) error {
+		calls++
+		if calls == 1 {
+			return errDummy
+		}
+		return nil
+	})
+	assert.NilError(t, err)
+
+	s := r.State()
+	assert.Assert(t, !s.Waiting())
+	assert.ErrorIs(t, s.LastError, errDummy)
+	assert.Assert(t, !s.LastSuccessAt.Before(s.LastErrorAt))
+}
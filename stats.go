@@ -0,0 +1,125 @@
+package roko
+
+import "time"
+
+// sleepBuckets are the bounds of the buckets in Stats.Sleeps and Stats.Callbacks
+var sleepBuckets = []time.Duration{
+	10 * time.Millisecond,
+	100 * time.Millisecond,
+	1 * time.Second,
+	10 * time.Second,
+	1 * time.Minute,
+	10 * time.Minute,
+}
+
+// Stats are counters accumulated by a retrier over every Do or DoWithContext loop it's run, for giving small programs
+// some visibility into their retrying without needing a metrics library. See Retrier.Stats
+type Stats struct {
+	Loops       int // The number of loops that have finished
+	Successes   int // The number of loops that finished with the operation succeeding
+	Failures    int // The number of loops that finished with an error, for any reason
+	Exhaustions int // The number of failed loops that ran out of attempts, or hit the limit set by WithMaxTotalSleep
+
+	Attempts     int           // The total number of attempts made by every loop, including ones still running
+	TotalSleep   time.Duration // The total time spent waiting between attempts
+	CallbackTime time.Duration // The total time spent in callbacks, performing the operation being retried
+
+	// AttemptsPerLoop counts the finished loops by the number of attempts they made - AttemptsPerLoop[3] is the number
+	// of loops that made 3 attempts
+	AttemptsPerLoop map[int]int
+
+	// Sleeps counts every wait between attempts by its length
+	Sleeps DurationHistogram
+
+	// Callbacks counts every finished attempt by how long its callback took
+	Callbacks DurationHistogram
+}
+
+// DurationHistogram counts durations into buckets. Counts[i] is the number of durations that were no longer than
+// Bounds[i] (and longer than Bounds[i-1]). Counts has one more element than Bounds, for the durations that were longer
+// than all of them
+type DurationHistogram struct {
+	Bounds []time.Duration
+	Counts []int
+}
+
+func newDurationHistogram(bounds []time.Duration) DurationHistogram {
+	return DurationHistogram{Bounds: append([]time.Duration(nil), bounds...), Counts: make([]int, len(bounds)+1)}
+}
+
+func (h *DurationHistogram) observe(d time.Duration) {
+	for i, bound := range h.Bounds {
+		if d <= bound {
+			h.Counts[i]++
+			return
+		}
+	}
+	h.Counts[len(h.Bounds)]++
+}
+
+// cloneOrNew returns a copy of the histogram, or a new empty histogram if nothing has been recorded in it yet
+func (h DurationHistogram) cloneOrNew() DurationHistogram {
+	if h.Counts == nil {
+		return newDurationHistogram(sleepBuckets)
+	}
+
+	return DurationHistogram{
+		Bounds: append([]time.Duration(nil), h.Bounds...),
+		Counts: append([]int(nil), h.Counts...),
+	}
+}
+
+// Stats returns a snapshot of the retrier's stats, accumulated over every loop that's been run with it. When the
+// retrier is shared between goroutines, they include all of their loops
+func (r *Retrier) Stats() Stats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s := r.stats
+	s.Attempts = r.attempts
+	s.TotalSleep = r.totalSleep
+	s.AttemptsPerLoop = make(map[int]int, len(r.stats.AttemptsPerLoop))
+	for k, v := range r.stats.AttemptsPerLoop {
+		s.AttemptsPerLoop[k] = v
+	}
+	s.Sleeps = r.stats.Sleeps.cloneOrNew()
+	s.Callbacks = r.stats.Callbacks.cloneOrNew()
+
+	return s
+}
+
+// recordLoop records the outcome of a finished loop that made the given number of attempts. exhausted is whether the
+// loop failed because it ran out of attempts or sleep budget. r.mu must be held
+func (r *Retrier) recordLoop(attempts int, err error, exhausted bool) {
+	r.stats.Loops++
+	if err == nil {
+		r.stats.Successes++
+	} else {
+		r.stats.Failures++
+		if exhausted {
+			r.stats.Exhaustions++
+		}
+	}
+
+	if r.stats.AttemptsPerLoop == nil {
+		r.stats.AttemptsPerLoop = map[int]int{}
+	}
+	r.stats.AttemptsPerLoop[attempts]++
+}
+
+// recordSleep records a wait between attempts. r.mu must be held
+func (r *Retrier) recordSleep(d time.Duration) {
+	if r.stats.Sleeps.Counts == nil {
+		r.stats.Sleeps = newDurationHistogram(sleepBuckets)
+	}
+	r.stats.Sleeps.observe(d)
+}
+
+// recordCallback records how long an attempt's callback took. r.mu must be held
+func (r *Retrier) recordCallback(d time.Duration) {
+	r.stats.CallbackTime += d
+	if r.stats.Callbacks.Counts == nil {
+		r.stats.Callbacks = newDurationHistogram(sleepBuckets)
+	}
+	r.stats.Callbacks.observe(d)
+}
@@ -0,0 +1,129 @@
+package roko
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestStats_NewRetrier(t *testing.T) {
+	t.Parallel()
+
+	s := NewRetrier(WithMaxAttempts(3), WithStrategy(Constant(time.Second))).Stats()
+
+	assert.Equal(t, 0, s.Loops)
+	assert.Equal(t, 0, s.Attempts)
+	assert.DeepEqual(t, map[int]int{}, s.AttemptsPerLoop)
+	assert.DeepEqual(t, sleepBuckets, s.Sleeps.Bounds)
+	assert.DeepEqual(t, make([]int, len(sleepBuckets)+1), s.Sleeps.Counts)
+}
+
+func TestStats_AccumulateOverLoops(t *testing.T) {
+	t.Parallel()
+
+	r := NewRetrier(
+		WithMaxAttempts(10),
+		WithStrategy(Constant(5*time.Second)),
+		WithSleepFunc(dummySleep),
+	)
+
+	// Succeeds on the third attempt
+	calls := 0
+	err := r.Do(func(r *Retrier) error {
+		calls++
+		if calls < 3 {
+			return errDummy
+		}
+		return nil
+	})
+	assert.NilError(t, err)
+
+	// Gives up straight away
+	err = r.Do(func(r *Retrier) error {
+		return Unrecoverable(errDummy)
+	})
+	assert.ErrorIs(t, err, errDummy)
+
+	s := r.Stats()
+	assert.Equal(t, 2, s.Loops)
+	assert.Equal(t, 1, s.Successes)
+	assert.Equal(t, 1, s.Failures)
+	assert.Equal(t, 0, s.Exhaustions)
+	assert.Equal(t, 4, s.Attempts)
+	assert.Equal(t, 10*time.Second, s.TotalSleep)
+	assert.DeepEqual(t, map[int]int{3: 1, 1: 1}, s.AttemptsPerLoop)
+	assert.DeepEqual(t, []int{0, 0, 0, 2, 0, 0, 0}, s.Sleeps.Counts)
+	assert.DeepEqual(t, []int{4, 0, 0, 0, 0, 0, 0}, s.Callbacks.Counts)
+}
+
+func TestStats_CountsExhaustions(t *testing.T) {
+	t.Parallel()
+
+	r := NewRetrier(
+		WithMaxAttempts(3),
+		WithStrategy(Constant(50*time.Millisecond)),
+		WithSleepFunc(dummySleep),
+	)
+
+	err := r.Do(func(r *Retrier) error { return errDummy })
+	assert.ErrorIs(t, err, errDummy)
+
+	// The retrier's attempts have all been used, so this loop is exhausted before it starts
+	err = r.Do(func(r *Retrier) error { return nil })
+	assert.ErrorIs(t, err, ErrNoAttemptsRemaining)
+
+	s := r.Stats()
+	assert.Equal(t, 2, s.Loops)
+	assert.Equal(t, 2, s.Failures)
+	assert.Equal(t, 2, s.Exhaustions)
+	assert.DeepEqual(t, map[int]int{3: 1, 0: 1}, s.AttemptsPerLoop)
+	assert.DeepEqual(t, []int{0, 2, 0, 0, 0, 0, 0}, s.Sleeps.Counts)
+}
+
+func TestStats_CountsCancelledLoopsAsFailures(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r := NewRetrier(WithMaxAttempts(3), WithStrategy(Constant(time.Hour)))
+
+	err := r.DoWithContext(ctx, func(r *Retrier) error {
+		cancel()
+		return errDummy
+	})
+	assert.Check(t, errors.Is(err, context.Canceled))
+
+	s := r.Stats()
+	assert.Equal(t, 1, s.Failures)
+	assert.Equal(t, 0, s.Exhaustions)
+}
+
+func TestStats_MeasuresCallbackTime(t *testing.T) {
+	t.Parallel()
+
+	r := NewRetrier(WithMaxAttempts(2), WithStrategy(Constant(time.Second)), WithSleepFunc(dummySleep))
+	_ = r.Do(func(r *Retrier) error {
+		time.Sleep(20 * time.Millisecond)
+		return errDummy
+	})
+
+	s := r.Stats()
+	assert.Assert(t, s.CallbackTime >= 40*time.Millisecond, s.CallbackTime)
+	assert.DeepEqual(t, []int{0, 2, 0, 0, 0, 0, 0}, s.Callbacks.Counts)
+}
+
+func TestStats_ReturnsACopy(t *testing.T) {
+	t.Parallel()
+
+	r := NewRetrier(WithMaxAttempts(3), WithStrategy(Constant(time.Second)), WithSleepFunc(dummySleep))
+	_ = r.Do(func(r *Retrier) error { return nil })
+
+	s := r.Stats()
+	s.AttemptsPerLoop[1] = 100
+	s.Sleeps.Counts[0] = 100
+
+	assert.DeepEqual(t, map[int]int{1: 1}, r.Stats().AttemptsPerLoop)
+	assert.Equal(t, 0, r.Stats().Sleeps.Counts[0])
+}
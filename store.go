@@ -0,0 +1,165 @@
+package roko
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RetryState is the state of a pending retry, as persisted in a Store so that it can be resumed after the process
+// restarts
+type RetryState struct {
+	Key         string    // Identifies the retry. It's up to the caller to make sure keys are unique
+	Attempts    int       // How many attempts have failed so far
+	NextAttempt time.Time // When the next attempt is due. The zero time means as soon as possible
+	LastError   string    // The error from the most recent failed attempt, if there's been one
+	Data        []byte    // Whatever the caller needs to recreate the operation being retried
+}
+
+// Store persists the state of pending retries. Implementations must be safe to use concurrently
+type Store interface {
+	// Save creates or updates the state with the given state's key
+	Save(ctx context.Context, state RetryState) error
+
+	// Load returns all of the states in the store
+	Load(ctx context.Context) ([]RetryState, error)
+
+	// Delete removes the state with the given key. Deleting a key that isn't in the store isn't an error
+	Delete(ctx context.Context, key string) error
+}
+
+// MemoryStore is a Store that keeps retry states in memory. It doesn't survive restarts, so it's mostly useful in tests
+type MemoryStore struct {
+	mu     sync.Mutex
+	states map[string]RetryState
+}
+
+var _ Store = (*MemoryStore)(nil)
+
+// NewMemoryStore returns an empty MemoryStore
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{states: map[string]RetryState{}}
+}
+
+func (s *MemoryStore) Save(_ context.Context, state RetryState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state.Data = append([]byte(nil), state.Data...)
+	s.states[state.Key] = state
+	return nil
+}
+
+func (s *MemoryStore) Load(_ context.Context) ([]RetryState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	states := make([]RetryState, 0, len(s.states))
+	for _, state := range s.states {
+		state.Data = append([]byte(nil), state.Data...)
+		states = append(states, state)
+	}
+	return states, nil
+}
+
+func (s *MemoryStore) Delete(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.states, key)
+	return nil
+}
+
+// FileStore is a Store that keeps each retry state in its own JSON file in a directory. Files are replaced atomically
+// when they're saved, so a crash partway through saving leaves the previous state intact
+type FileStore struct {
+	dir string
+}
+
+var _ Store = (*FileStore)(nil)
+
+// NewFileStore returns a FileStore that keeps its files in dir, creating it if it doesn't exist
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+// path returns the path of the file for key. Keys can contain anything, so they're hashed to get a safe filename
+func (s *FileStore) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(s.dir, hex.EncodeToString(sum[:])+".json")
+}
+
+func (s *FileStore) Save(_ context.Context, state RetryState) error {
+	b, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(s.dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // Fails harmlessly once the file has been renamed
+
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), s.path(state.Key))
+}
+
+func (s *FileStore) Load(_ context.Context) ([]RetryState, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	states := []RetryState{}
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+
+		b, err := os.ReadFile(filepath.Join(s.dir, e.Name()))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue // Deleted since we listed the directory
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var state RetryState
+		if err := json.Unmarshal(b, &state); err != nil {
+			return nil, err
+		}
+		states = append(states, state)
+	}
+	return states, nil
+}
+
+func (s *FileStore) Delete(_ context.Context, key string) error {
+	err := os.Remove(s.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,72 @@
+package roko
+
+import (
+	"context"
+	"sort"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func testStore(t *testing.T, s Store) {
+	t.Helper()
+	ctx := context.Background()
+
+	states, err := s.Load(ctx)
+	assert.NilError(t, err)
+	assert.Equal(t, 0, len(states))
+
+	next := time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
+	assert.NilError(t, s.Save(ctx, RetryState{Key: "a/b", Data: []byte("payload")}))
+	assert.NilError(t, s.Save(ctx, RetryState{Key: "c", Attempts: 1}))
+	assert.NilError(t, s.Save(ctx, RetryState{Key: "a/b", Attempts: 2, NextAttempt: next, LastError: "nope", Data: []byte("payload")}))
+
+	states, err = s.Load(ctx)
+	assert.NilError(t, err)
+	sort.Slice(states, func(i, j int) bool { return states[i].Key < states[j].Key })
+	assert.Equal(t, 2, len(states))
+	assert.Equal(t, "a/b", states[0].Key)
+	assert.Equal(t, 2, states[0].Attempts)
+	assert.Assert(t, states[0].NextAttempt.Equal(next))
+	assert.Equal(t, "nope", states[0].LastError)
+	assert.Equal(t, "payload", string(states[0].Data))
+	assert.Equal(t, "c", states[1].Key)
+
+	assert.NilError(t, s.Delete(ctx, "a/b"))
+	assert.NilError(t, s.Delete(ctx, "not there"))
+
+	states, err = s.Load(ctx)
+	assert.NilError(t, err)
+	assert.Equal(t, 1, len(states))
+	assert.Equal(t, "c", states[0].Key)
+}
+
+func TestMemoryStore(t *testing.T) {
+	t.Parallel()
+	testStore(t, NewMemoryStore())
+}
+
+func TestFileStore(t *testing.T) {
+	t.Parallel()
+
+	s, err := NewFileStore(t.TempDir())
+	assert.NilError(t, err)
+	testStore(t, s)
+}
+
+func TestFileStore_SurvivesBeingReopened(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	s, err := NewFileStore(dir)
+	assert.NilError(t, err)
+	assert.NilError(t, s.Save(context.Background(), RetryState{Key: "upload", Attempts: 3}))
+
+	s, err = NewFileStore(dir)
+	assert.NilError(t, err)
+	states, err := s.Load(context.Background())
+	assert.NilError(t, err)
+	assert.Equal(t, 1, len(states))
+	assert.Equal(t, 3, states[0].Attempts)
+}
@@ -0,0 +1,75 @@
+package roko
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+// Stream pulls values from a producer one at a time, retrying each pull separately, so that a stream of values gets
+// retry semantics per value rather than for the whole stream. It's used like a bufio.Scanner:
+//
+//	s := roko.NewStream(ctx, newRetrier, func(r *roko.Retrier) (Event, error) {
+//		return sub.Next(r.Context())
+//	})
+//	for s.Next() {
+//		handle(s.Value())
+//	}
+//	if err := s.Err(); err != nil {
+//		// ...
+//	}
+//
+// A Stream isn't safe to use concurrently
+type Stream[T any] struct {
+	ctx        context.Context
+	newRetrier func() *Retrier
+	next       func(r *Retrier) (T, error)
+
+	value T
+	err   error
+	done  bool
+}
+
+// NewStream returns a stream that gets its values from next. Each call to Next retries next with a new retrier from
+// newRetrier, until it returns a value, or io.EOF to say that there are no more values. io.EOF is never retried
+func NewStream[T any](ctx context.Context, newRetrier func() *Retrier, next func(r *Retrier) (T, error)) *Stream[T] {
+	return &Stream[T]{ctx: ctx, newRetrier: newRetrier, next: next}
+}
+
+// Next pulls the next value from the producer, retrying it if it fails, and returns whether there is one. Once it
+// returns false, the stream is over, and Err says why
+func (s *Stream[T]) Next() bool {
+	if s.done {
+		return false
+	}
+
+	value, err := DoFunc(s.ctx, s.newRetrier(), func(r *Retrier) (T, error) {
+		v, err := s.next(r)
+		if errors.Is(err, io.EOF) {
+			r.Break()
+		}
+		return v, err
+	})
+	if err != nil {
+		var zero T
+		s.value, s.done = zero, true
+		if !errors.Is(err, io.EOF) {
+			s.err = err
+		}
+		return false
+	}
+
+	s.value = value
+	return true
+}
+
+// Value returns the value pulled by the most recent call to Next
+func (s *Stream[T]) Value() T {
+	return s.value
+}
+
+// Err returns the error that ended the stream: the last error from the producer when a pull's retrier gave up, or nil
+// if the producer said there were no more values
+func (s *Stream[T]) Err() error {
+	return s.err
+}
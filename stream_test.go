@@ -0,0 +1,66 @@
+package roko
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func newStreamRetrier() *Retrier {
+	return NewRetrier(WithMaxAttempts(3), WithStrategy(Constant(time.Millisecond)), WithSleepFunc(dummySleep))
+}
+
+func TestStream_RetriesEachPull(t *testing.T) {
+	t.Parallel()
+
+	values := []int{1, 2, 3}
+	calls := 0
+	s := NewStream(context.Background(), newStreamRetrier, func(r *Retrier) (int, error) {
+		calls++
+		if len(values) == 0 {
+			return 0, io.EOF
+		}
+		// Every value fails twice before it's produced, which a retrier for the whole stream couldn't survive
+		if r.AttemptCount() < 2 {
+			return 0, errDummy
+		}
+		v := values[0]
+		values = values[1:]
+		return v, nil
+	})
+
+	got := []int{}
+	for s.Next() {
+		got = append(got, s.Value())
+	}
+
+	assert.NilError(t, s.Err())
+	assert.DeepEqual(t, []int{1, 2, 3}, got)
+	assert.Equal(t, 10, calls) // 3 attempts for each value, and one for the end of the stream
+	assert.Assert(t, !s.Next())
+}
+
+func TestStream_WhenAPullGivesUp_StopsWithTheError(t *testing.T) {
+	t.Parallel()
+
+	n := 0
+	s := NewStream(context.Background(), newStreamRetrier, func(r *Retrier) (int, error) {
+		if n == 2 {
+			return 0, errDummy
+		}
+		n++
+		return n, nil
+	})
+
+	got := []int{}
+	for s.Next() {
+		got = append(got, s.Value())
+	}
+
+	assert.ErrorIs(t, s.Err(), errDummy)
+	assert.DeepEqual(t, []int{1, 2}, got)
+	assert.Equal(t, 0, s.Value())
+}
@@ -0,0 +1,115 @@
+package roko
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// summaryMaxAttempts is the most attempts Summarize lists individually. Past that, it lists the first and last few,
+	// eliding the ones in the middle
+	summaryMaxAttempts  = 8
+	summaryEdgeAttempts = 3
+
+	// summaryMaxErrorLen is the longest an error message can be in a summary before it's truncated
+	summaryMaxErrorLen = 40
+)
+
+// History collects the attempts made by a retrier, for summarising later. Pass its Record method to WithOnAttempt:
+//
+//	var h roko.History
+//	err := roko.NewRetrier(roko.WithOnAttempt(h.Record), ...).Do(...)
+//	log.Println(h.Summary())
+//
+// A History is safe to use concurrently
+type History struct {
+	mu     sync.Mutex
+	events []AttemptEvent
+}
+
+// Record adds an attempt to the history
+func (h *History) Record(e AttemptEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.events = append(h.events, e)
+}
+
+// Events returns the attempts in the history, in the order they were recorded
+func (h *History) Events() []AttemptEvent {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]AttemptEvent(nil), h.events...)
+}
+
+// Summary returns a summary of the attempts in the history - see Summarize
+func (h *History) Summary() string {
+	return Summarize(h.Events())
+}
+
+// Summarize returns a compact, human-readable summary of a series of attempts, suitable for including in logs, like:
+//
+//	5 attempts over 42s: t+0 fail(timeout), t+1s fail(timeout), t+3s fail(connection refused), t+11s fail(timeout), t+42s ok
+//
+// Each attempt is shown with its start time relative to the first attempt. Long series of attempts have the ones in
+// the middle elided, and long error messages are truncated
+func Summarize(events []AttemptEvent) string {
+	if len(events) == 0 {
+		return "no attempts"
+	}
+
+	first := events[0].Start
+	over := events[len(events)-1].Start.Sub(first)
+
+	parts := make([]string, 0, len(events))
+	for i, e := range events {
+		if len(events) > summaryMaxAttempts && i >= summaryEdgeAttempts && i < len(events)-summaryEdgeAttempts {
+			if i == summaryEdgeAttempts {
+				parts = append(parts, fmt.Sprintf("… (%d more)", len(events)-2*summaryEdgeAttempts))
+			}
+			continue
+		}
+		parts = append(parts, fmt.Sprintf("t+%s %s", summaryDuration(e.Start.Sub(first)), summaryOutcome(e.Err)))
+	}
+
+	noun := "attempts"
+	if len(events) == 1 {
+		noun = "attempt"
+	}
+	return fmt.Sprintf("%d %s over %s: %s", len(events), noun, summaryDuration(over), strings.Join(parts, ", "))
+}
+
+// summaryDuration formats d with a precision that suits its size
+func summaryDuration(d time.Duration) string {
+	switch {
+	case d <= 0:
+		return "0"
+	case d < time.Second:
+		return d.Round(time.Millisecond).String()
+	case d < time.Minute:
+		return d.Round(100 * time.Millisecond).String()
+	default:
+		return d.Round(time.Second).String()
+	}
+}
+
+// summaryOutcome gives a short description of an attempt's result
+func summaryOutcome(err error) string {
+	switch {
+	case err == nil:
+		return "ok"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "fail(timeout)"
+	case errors.Is(err, context.Canceled):
+		return "fail(cancelled)"
+	}
+
+	msg := err.Error()
+	if len(msg) > summaryMaxErrorLen {
+		msg = msg[:summaryMaxErrorLen-1] + "…"
+	}
+	return fmt.Sprintf("fail(%s)", msg)
+}
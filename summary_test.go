@@ -0,0 +1,81 @@
+package roko
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestSummarize(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2022, time.June, 1, 12, 0, 0, 0, time.UTC)
+	at := func(d time.Duration, err error) AttemptEvent {
+		return AttemptEvent{Start: start.Add(d), Err: err}
+	}
+
+	assert.Equal(t, "no attempts", Summarize(nil))
+	assert.Equal(t, "1 attempt over 0: t+0 ok", Summarize([]AttemptEvent{at(0, nil)}))
+
+	assert.Equal(t,
+		"5 attempts over 42s: t+0 fail(timeout), t+1s fail(timeout), t+3.5s fail(connection refused), t+11s fail(cancelled), t+42s ok",
+		Summarize([]AttemptEvent{
+			at(0, context.DeadlineExceeded),
+			at(time.Second, fmt.Errorf("fetching: %w", context.DeadlineExceeded)),
+			at(3500*time.Millisecond, errors.New("connection refused")),
+			at(11*time.Second, context.Canceled),
+			at(42*time.Second, nil),
+		}),
+	)
+}
+
+func TestSummarize_TruncatesLongErrors(t *testing.T) {
+	t.Parallel()
+
+	summary := Summarize([]AttemptEvent{{Err: errors.New(strings.Repeat("x", 100))}})
+	assert.Equal(t, "1 attempt over 0: t+0 fail("+strings.Repeat("x", 39)+"…)", summary)
+}
+
+func TestSummarize_ElidesTheMiddleOfLongHistories(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2022, time.June, 1, 12, 0, 0, 0, time.UTC)
+	events := []AttemptEvent{}
+	for i := 0; i < 20; i++ {
+		events = append(events, AttemptEvent{Start: start.Add(time.Duration(i) * 500 * time.Millisecond), Err: errDummy})
+	}
+
+	assert.Equal(t,
+		"20 attempts over 9.5s: t+0 fail(this makes it retry), t+500ms fail(this makes it retry), t+1s fail(this makes it retry), … (14 more), t+8.5s fail(this makes it retry), t+9s fail(this makes it retry), t+9.5s fail(this makes it retry)",
+		Summarize(events),
+	)
+}
+
+func TestHistory_RecordsAttemptsFromARetrier(t *testing.T) {
+	t.Parallel()
+
+	var h History
+	calls := 0
+	err := NewRetrier(
+		WithMaxAttempts(3),
+		WithStrategy(Constant(time.Second)),
+		WithSleepFunc(dummySleep),
+		WithOnAttempt(h.Record),
+	).Do(func(r *Retrier) error {
+		calls++
+		if calls == 1 {
+			return errDummy
+		}
+		return nil
+	})
+	assert.NilError(t, err)
+
+	assert.Equal(t, 2, len(h.Events()))
+	assert.Assert(t, strings.HasPrefix(h.Summary(), "2 attempts over "))
+	assert.Assert(t, strings.HasSuffix(h.Summary(), " ok"))
+}
@@ -0,0 +1,200 @@
+// Package tokensource caches short-lived credentials, such as OIDC tokens, fetching them with retries and refreshing
+// them in the background before they expire, so that callers almost never have to wait for a fetch.
+package tokensource
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/buildkite/roko"
+)
+
+// ErrClosed is returned by GetToken after the source has been closed
+var ErrClosed = errors.New("token source is closed")
+
+const (
+	defaultRefreshAhead  = 1 * time.Minute
+	defaultRefreshJitter = 30 * time.Second
+)
+
+// Token is a credential, and the time after which it's no longer valid. A token with a zero Expiry never expires
+type Token struct {
+	Value  string
+	Expiry time.Time
+}
+
+func (t Token) valid(now time.Time) bool {
+	return t.Value != "" && (t.Expiry.IsZero() || now.Before(t.Expiry))
+}
+
+// Source fetches a token when it's first needed, and then keeps it fresh by fetching a new one shortly before it
+// expires. Fetches are made using a retrier, and only one fetch happens at a time, however many goroutines are asking
+// for the token. A Source is safe to use concurrently
+type Source struct {
+	fetch         func(context.Context) (Token, error)
+	newRetrier    func() *roko.Retrier
+	refreshAhead  time.Duration
+	refreshJitter time.Duration
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu       sync.Mutex
+	token    Token
+	fetching chan struct{} // non-nil while a fetch is in progress, and closed when it finishes
+	fetchErr error         // the error from the most recent fetch
+	timer    *time.Timer
+}
+
+type sourceOpt func(*Source)
+
+// WithRefreshAhead sets how long before a token expires the source starts fetching a new one. The default is 1 minute.
+// If a token's lifetime is shorter than this, the new one is fetched halfway through the token's lifetime instead
+func WithRefreshAhead(d time.Duration) sourceOpt {
+	if d < 0 {
+		panic("refresh ahead duration must not be negative")
+	}
+
+	return func(s *Source) {
+		s.refreshAhead = d
+	}
+}
+
+// WithRefreshJitter sets the maximum random amount of time added to the refresh ahead duration, so that many processes
+// that got their tokens at the same time don't all refresh them at once. The default is 30 seconds
+func WithRefreshJitter(d time.Duration) sourceOpt {
+	if d < 0 {
+		panic("refresh jitter must not be negative")
+	}
+
+	return func(s *Source) {
+		s.refreshJitter = d
+	}
+}
+
+// New returns a Source that gets tokens by calling fetch, retrying it with a new retrier from newRetrier for each
+// fetch. The context passed to fetch isn't tied to any caller of GetToken, as the fetch is shared between all of them;
+// it's cancelled when the Source is closed
+func New(fetch func(context.Context) (Token, error), newRetrier func() *roko.Retrier, opts ...sourceOpt) *Source {
+	s := &Source{
+		fetch:         fetch,
+		newRetrier:    newRetrier,
+		refreshAhead:  defaultRefreshAhead,
+		refreshJitter: defaultRefreshJitter,
+	}
+	for _, o := range opts {
+		o(s)
+	}
+
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	return s
+}
+
+// GetToken returns a valid token, fetching one if the source doesn't have one. When the token has been refreshed in the
+// background, this returns straight away. If a background refresh fails, GetToken keeps returning the old token until
+// it expires, and then tries fetching a new one again
+func (s *Source) GetToken(ctx context.Context) (Token, error) {
+	s.mu.Lock()
+	if s.ctx.Err() != nil {
+		s.mu.Unlock()
+		return Token{}, ErrClosed
+	}
+	if s.token.valid(time.Now()) {
+		defer s.mu.Unlock()
+		return s.token, nil
+	}
+	done := s.startFetch()
+	s.mu.Unlock()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return Token{}, ctx.Err()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token.valid(time.Now()) {
+		return s.token, nil
+	}
+	if s.fetchErr != nil {
+		return Token{}, s.fetchErr
+	}
+	return Token{}, ErrClosed
+}
+
+// Close stops the source from refreshing its token, and cancels any fetch in progress
+func (s *Source) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.cancel()
+	if s.timer != nil {
+		s.timer.Stop()
+	}
+}
+
+// startFetch starts fetching a new token, if a fetch isn't already in progress, and returns a channel that's closed
+// when the fetch finishes. s.mu must be held
+func (s *Source) startFetch() chan struct{} {
+	if s.fetching != nil {
+		return s.fetching
+	}
+
+	done := make(chan struct{})
+	s.fetching = done
+
+	go func() {
+		defer close(done)
+
+		token, err := roko.DoFunc(s.ctx, s.newRetrier(), func(r *roko.Retrier) (Token, error) {
+			return s.fetch(r.Context())
+		})
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		s.fetching = nil
+		s.fetchErr = err
+		if err != nil || s.ctx.Err() != nil {
+			return
+		}
+
+		s.token = token
+		s.scheduleRefresh(token)
+	}()
+
+	return done
+}
+
+// scheduleRefresh sets a timer to fetch a new token shortly before token expires. s.mu must be held
+func (s *Source) scheduleRefresh(token Token) {
+	if token.Expiry.IsZero() {
+		return
+	}
+
+	lifetime := time.Until(token.Expiry)
+	delay := lifetime - s.refreshAhead
+	if s.refreshJitter > 0 {
+		delay -= time.Duration(rand.Int63n(int64(s.refreshJitter)))
+	}
+	if delay < 0 {
+		delay = lifetime / 2
+	}
+
+	if s.timer != nil {
+		s.timer.Stop()
+	}
+	s.timer = time.AfterFunc(delay, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		if s.ctx.Err() == nil {
+			s.startFetch()
+		}
+	})
+}
@@ -0,0 +1,118 @@
+package tokensource
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/buildkite/roko"
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/poll"
+)
+
+var errFetch = errors.New("token endpoint unavailable")
+
+func newTestRetrier() *roko.Retrier {
+	return roko.NewRetrier(
+		roko.WithMaxAttempts(3),
+		roko.WithStrategy(roko.Constant(time.Second)),
+		roko.WithSleepFunc(func(time.Duration) {}),
+	)
+}
+
+func TestGetToken_FetchesOnceAndCaches(t *testing.T) {
+	t.Parallel()
+
+	var fetches int32
+	s := New(func(ctx context.Context) (Token, error) {
+		n := atomic.AddInt32(&fetches, 1)
+		return Token{Value: fmt.Sprintf("token-%d", n), Expiry: time.Now().Add(time.Hour)}, nil
+	}, newTestRetrier)
+	defer s.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			token, err := s.GetToken(context.Background())
+			assert.Check(t, err)
+			assert.Check(t, token.Value == "token-1")
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&fetches))
+}
+
+func TestGetToken_RetriesFailedFetches(t *testing.T) {
+	t.Parallel()
+
+	var fetches int32
+	s := New(func(ctx context.Context) (Token, error) {
+		if atomic.AddInt32(&fetches, 1) < 3 {
+			return Token{}, errFetch
+		}
+		return Token{Value: "token", Expiry: time.Now().Add(time.Hour)}, nil
+	}, newTestRetrier)
+	defer s.Close()
+
+	token, err := s.GetToken(context.Background())
+	assert.NilError(t, err)
+	assert.Equal(t, "token", token.Value)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&fetches))
+}
+
+func TestGetToken_WhenFetchKeepsFailing_ReturnsTheError(t *testing.T) {
+	t.Parallel()
+
+	s := New(func(ctx context.Context) (Token, error) {
+		return Token{}, errFetch
+	}, newTestRetrier)
+	defer s.Close()
+
+	_, err := s.GetToken(context.Background())
+	assert.ErrorIs(t, err, errFetch)
+}
+
+func TestSource_RefreshesAheadOfExpiry(t *testing.T) {
+	t.Parallel()
+
+	var fetches int32
+	s := New(func(ctx context.Context) (Token, error) {
+		n := atomic.AddInt32(&fetches, 1)
+		return Token{Value: fmt.Sprintf("token-%d", n), Expiry: time.Now().Add(time.Hour)}, nil
+	}, newTestRetrier, WithRefreshAhead(time.Hour-50*time.Millisecond), WithRefreshJitter(0))
+	defer s.Close()
+
+	token, err := s.GetToken(context.Background())
+	assert.NilError(t, err)
+	assert.Equal(t, "token-1", token.Value)
+
+	poll.WaitOn(t, func(poll.LogT) poll.Result {
+		if atomic.LoadInt32(&fetches) >= 2 {
+			return poll.Success()
+		}
+		return poll.Continue("waiting for the token to be refreshed")
+	}, poll.WithTimeout(5*time.Second), poll.WithDelay(10*time.Millisecond))
+
+	token, err = s.GetToken(context.Background())
+	assert.NilError(t, err)
+	assert.Assert(t, token.Value != "token-1")
+}
+
+func TestGetToken_AfterClose_ReturnsErrClosed(t *testing.T) {
+	t.Parallel()
+
+	s := New(func(ctx context.Context) (Token, error) {
+		return Token{Value: "token"}, nil
+	}, newTestRetrier)
+	s.Close()
+
+	_, err := s.GetToken(context.Background())
+	assert.ErrorIs(t, err, ErrClosed)
+}
@@ -0,0 +1,141 @@
+// Package transfer retries large file transfers over protocols that can resume them, such as SFTP and FTP, so that a
+// connection dropping partway through a transfer only means sending the rest of the file, rather than all of it again.
+//
+// It doesn't depend on any particular client library. Downloads need a way to open the remote file from an offset, and
+// uploads need a way to find out how much of the file the server has stored, and to send the rest from there. With
+// github.com/pkg/sftp, for example:
+//
+//	func openAt(ctx context.Context, offset int64) (io.ReadCloser, error) {
+//		f, err := client.Open(path)
+//		if err != nil {
+//			return nil, err
+//		}
+//		if _, err := f.Seek(offset, io.SeekStart); err != nil {
+//			f.Close()
+//			return nil, err
+//		}
+//		return f, nil
+//	}
+//
+// and with github.com/jlaffaye/ftp, which uses the FTP REST command to resume:
+//
+//	func openAt(ctx context.Context, offset int64) (io.ReadCloser, error) {
+//		return conn.RetrFrom(path, uint64(offset))
+//	}
+//
+//	func stored(ctx context.Context) (int64, error) {
+//		return conn.FileSize(path)
+//	}
+//
+//	func send(ctx context.Context, offset int64, r io.Reader) error {
+//		return conn.StorFrom(path, r, uint64(offset))
+//	}
+//
+// Since a connection that's dropped usually needs to be made again, these are often closures over a client that
+// reconnects when it needs to.
+package transfer
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/buildkite/roko"
+)
+
+// Download copies a remote file to dst, retrying with r. For each attempt, it calls openAt with the number of bytes
+// written to dst so far, and openAt should return the rest of the file from there. The whole transfer shares r, so its
+// attempts are a budget for the number of interruptions across all of it.
+//
+// Errors writing to dst are returned straight away, without retrying, since they're local problems, like a full disk,
+// that retrying won't fix. Download returns the number of bytes written to dst, which is the offset to resume from if
+// the download is picked up again later
+func Download(ctx context.Context, r *roko.Retrier, dst io.Writer, openAt func(ctx context.Context, offset int64) (io.ReadCloser, error)) (int64, error) {
+	w := &countingWriter{w: dst}
+	err := r.DoWithContext(ctx, func(r *roko.Retrier) error {
+		src, err := openAt(r.Context(), w.n)
+		if err != nil {
+			return err
+		}
+		defer src.Close()
+
+		if _, err := io.Copy(w, src); err != nil {
+			if w.err != nil {
+				return roko.Unrecoverable(w.err)
+			}
+			return err
+		}
+		return nil
+	})
+	return w.n, err
+}
+
+// Upload sends size bytes of src to a remote file, retrying with r. The first attempt calls send with an offset of 0.
+// Each retry calls stored to find out how many bytes of the file the server has confirmed storing (which should be 0 if
+// the file doesn't exist), and then calls send with that offset and the rest of src, which send should append to the
+// remote file from there. The whole transfer shares r, so its attempts are a budget for the number of interruptions
+// across all of it.
+//
+// Errors reading from src are returned straight away, without retrying, since they're local problems that retrying
+// won't fix. So is the server claiming to have stored more of the file than there is, since appending to it wouldn't
+// leave it with the right contents
+func Upload(ctx context.Context, r *roko.Retrier, src io.ReaderAt, size int64, stored func(ctx context.Context) (int64, error), send func(ctx context.Context, offset int64, data io.Reader) error) error {
+	first := true
+	return r.DoWithContext(ctx, func(r *roko.Retrier) error {
+		var offset int64
+		if !first {
+			var err error
+			if offset, err = stored(r.Context()); err != nil {
+				return err
+			}
+		}
+		first = false
+
+		switch {
+		case offset > size:
+			return roko.Unrecoverable(fmt.Errorf("server has stored %d bytes of a %d byte file", offset, size))
+		case offset == size && offset > 0:
+			// The last attempt sent everything, but failed before it heard back that it had
+			return nil
+		}
+
+		rd := &trackingReader{r: io.NewSectionReader(src, offset, size-offset)}
+		if err := send(r.Context(), offset, rd); err != nil {
+			if rd.err != nil {
+				return roko.Unrecoverable(rd.err)
+			}
+			return err
+		}
+		return nil
+	})
+}
+
+// countingWriter counts the bytes written to w, and keeps the error from writing to it, if there was one
+type countingWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	if err != nil {
+		c.err = err
+	}
+	return n, err
+}
+
+// trackingReader keeps the error from reading from r, if there was one
+type trackingReader struct {
+	r   io.Reader
+	err error
+}
+
+func (t *trackingReader) Read(p []byte) (int, error) {
+	n, err := t.r.Read(p)
+	if err != nil && err != io.EOF {
+		t.err = err
+	}
+	return n, err
+}
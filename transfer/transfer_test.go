@@ -0,0 +1,197 @@
+package transfer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/buildkite/roko"
+	"gotest.tools/v3/assert"
+)
+
+var errDropped = errors.New("connection reset by peer")
+
+func newTestRetrier() *roko.Retrier {
+	return roko.NewRetrier(
+		roko.WithMaxAttempts(5),
+		roko.WithStrategy(roko.Constant(time.Second)),
+		roko.WithSleepFunc(func(time.Duration) {}),
+	)
+}
+
+// flakyReader reads from r, but fails with errDropped after limit bytes
+type flakyReader struct {
+	r     io.Reader
+	limit int
+}
+
+func (f *flakyReader) Read(p []byte) (int, error) {
+	if f.limit <= 0 {
+		return 0, errDropped
+	}
+	if len(p) > f.limit {
+		p = p[:f.limit]
+	}
+	n, err := f.r.Read(p)
+	f.limit -= n
+	return n, err
+}
+
+func TestDownload_ResumesFromTheBytesWritten(t *testing.T) {
+	t.Parallel()
+
+	remote := strings.Repeat("0123456789", 10)
+
+	var offsets []int64
+	var dst bytes.Buffer
+	n, err := Download(context.Background(), newTestRetrier(), &dst, func(_ context.Context, offset int64) (io.ReadCloser, error) {
+		offsets = append(offsets, offset)
+		r := strings.NewReader(remote[offset:])
+		if len(offsets) < 3 {
+			return io.NopCloser(&flakyReader{r: r, limit: 30}), nil
+		}
+		return io.NopCloser(r), nil
+	})
+
+	assert.NilError(t, err)
+	assert.Equal(t, int64(100), n)
+	assert.Equal(t, remote, dst.String())
+	assert.DeepEqual(t, []int64{0, 30, 60}, offsets)
+}
+
+func TestDownload_ReturnsHowFarItGot(t *testing.T) {
+	t.Parallel()
+
+	var dst bytes.Buffer
+	n, err := Download(context.Background(), newTestRetrier(), &dst, func(_ context.Context, offset int64) (io.ReadCloser, error) {
+		if offset >= 20 {
+			return nil, errDropped
+		}
+		return io.NopCloser(&flakyReader{r: strings.NewReader("abcdefghijklmnopqrstuvwxyz"[offset:]), limit: 10}), nil
+	})
+
+	assert.ErrorIs(t, err, errDropped)
+	assert.Equal(t, int64(20), n)
+	assert.Equal(t, "abcdefghijklmnopqrst", dst.String())
+}
+
+type fullDisk struct{}
+
+var errNoSpace = errors.New("no space left on device")
+
+func (fullDisk) Write(p []byte) (int, error) {
+	return 0, errNoSpace
+}
+
+func TestDownload_DoesntRetryWriteErrors(t *testing.T) {
+	t.Parallel()
+
+	opens := 0
+	_, err := Download(context.Background(), newTestRetrier(), fullDisk{}, func(context.Context, int64) (io.ReadCloser, error) {
+		opens++
+		return io.NopCloser(strings.NewReader("data")), nil
+	})
+
+	assert.ErrorIs(t, err, errNoSpace)
+	assert.ErrorIs(t, err, roko.ErrUnrecoverable)
+	assert.Equal(t, 1, opens)
+}
+
+// fakeServer stores uploaded data, keeping whatever it received before a connection dropped
+type fakeServer struct {
+	data    []byte
+	offsets []int64
+}
+
+func (s *fakeServer) stored(context.Context) (int64, error) {
+	return int64(len(s.data)), nil
+}
+
+// send stores what it's sent, failing after limit bytes if limit isn't 0
+func (s *fakeServer) send(limit int) func(context.Context, int64, io.Reader) error {
+	return func(_ context.Context, offset int64, r io.Reader) error {
+		s.offsets = append(s.offsets, offset)
+		s.data = s.data[:offset]
+
+		if limit > 0 {
+			r = &flakyReader{r: r, limit: limit}
+		}
+		b, err := io.ReadAll(r)
+		s.data = append(s.data, b...)
+		return err
+	}
+}
+
+func TestUpload_ResumesFromWhatTheServerStored(t *testing.T) {
+	t.Parallel()
+
+	src := strings.Repeat("abcdefghij", 10)
+	srv := &fakeServer{}
+
+	sends := 0
+	err := Upload(context.Background(), newTestRetrier(), strings.NewReader(src), int64(len(src)), srv.stored, func(ctx context.Context, offset int64, r io.Reader) error {
+		sends++
+		if sends < 3 {
+			return srv.send(40)(ctx, offset, r)
+		}
+		return srv.send(0)(ctx, offset, r)
+	})
+
+	assert.NilError(t, err)
+	assert.Equal(t, src, string(srv.data))
+	assert.DeepEqual(t, []int64{0, 40, 80}, srv.offsets)
+}
+
+func TestUpload_FinishesIfTheServerStoredEverything(t *testing.T) {
+	t.Parallel()
+
+	src := "all of it"
+	srv := &fakeServer{}
+	sends := 0
+	err := Upload(context.Background(), newTestRetrier(), strings.NewReader(src), int64(len(src)), srv.stored, func(ctx context.Context, offset int64, r io.Reader) error {
+		sends++
+		if err := srv.send(0)(ctx, offset, r); err != nil {
+			return err
+		}
+		return errDropped // The whole file got there, but the reply didn't
+	})
+
+	assert.NilError(t, err)
+	assert.Equal(t, 1, sends)
+	assert.Equal(t, src, string(srv.data))
+}
+
+func TestUpload_GivesUpIfTheServerHasTooMuch(t *testing.T) {
+	t.Parallel()
+
+	srv := &fakeServer{data: []byte("this is not the file you're looking for")}
+	err := Upload(context.Background(), newTestRetrier(), strings.NewReader("short"), 5, srv.stored, func(context.Context, int64, io.Reader) error {
+		return errDropped
+	})
+
+	assert.ErrorIs(t, err, roko.ErrUnrecoverable)
+	assert.ErrorContains(t, err, "server has stored 39 bytes of a 5 byte file")
+}
+
+type brokenDisk struct{}
+
+var errIO = errors.New("input/output error")
+
+func (brokenDisk) ReadAt([]byte, int64) (int, error) {
+	return 0, errIO
+}
+
+func TestUpload_DoesntRetryReadErrors(t *testing.T) {
+	t.Parallel()
+
+	srv := &fakeServer{}
+	err := Upload(context.Background(), newTestRetrier(), brokenDisk{}, 100, srv.stored, srv.send(0))
+
+	assert.ErrorIs(t, err, errIO)
+	assert.ErrorIs(t, err, roko.ErrUnrecoverable)
+	assert.Equal(t, 1, len(srv.offsets))
+}
@@ -0,0 +1,91 @@
+package roko
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Trigger wakes retry loops early, so that they retry straight away rather than waiting out the rest of their backoff
+// - when a health check reports that a dependency has recovered, for example:
+//
+//	recovered := roko.NewTrigger()
+//	r := roko.NewRetrier(
+//		roko.TryForever(),
+//		roko.WithStrategy(roko.Exponential(2*time.Second, 0)),
+//		roko.WithTrigger(recovered),
+//	)
+//
+//	// Elsewhere, once the dependency is healthy again
+//	recovered.Kick()
+//
+// A kick only cuts waits short: it doesn't give loops any more attempts than they'd otherwise have. A single Trigger
+// can be shared between any number of retriers, and the zero Trigger is ready to use. It's safe to use concurrently
+type Trigger struct {
+	mu     sync.Mutex
+	kicked chan struct{}
+}
+
+// NewTrigger returns a new trigger
+func NewTrigger() *Trigger {
+	return &Trigger{}
+}
+
+// Kick wakes every loop that's waiting to retry with the trigger, along with any loop that's in the middle of an
+// attempt, which then retries straight away if the attempt fails
+func (t *Trigger) Kick() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.kicked != nil {
+		close(t.kicked)
+	}
+	t.kicked = make(chan struct{})
+}
+
+// kickedCh returns a channel that's closed the next time the trigger is kicked
+func (t *Trigger) kickedCh() <-chan struct{} {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.kicked == nil {
+		t.kicked = make(chan struct{})
+	}
+	return t.kicked
+}
+
+// Sleep waits for d, or until the trigger is kicked, for loops that wait between attempts without a Retrier. It
+// returns ctx's error if ctx is done first, and nil otherwise
+func (t *Trigger) Sleep(ctx context.Context, d time.Duration) error {
+	kicked := t.kickedCh()
+	if d <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-kicked:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// WithTrigger lets t wake the retrier's loops early, so that they retry straight away. See Trigger
+func WithTrigger(t *Trigger) retrierOpt {
+	return func(r *Retrier) {
+		r.trigger = t
+	}
+}
+
+// kickedCh returns a channel that's closed when the retrier's trigger is next kicked, or nil (which never is) if it
+// doesn't have one
+func (r *Retrier) kickedCh() <-chan struct{} {
+	if r.trigger == nil {
+		return nil
+	}
+	return r.trigger.kickedCh()
+}
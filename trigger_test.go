@@ -0,0 +1,143 @@
+package roko
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestWithTrigger_KickWakesTheLoop(t *testing.T) {
+	t.Parallel()
+
+	trigger := NewTrigger()
+	r := NewRetrier(
+		WithMaxAttempts(2),
+		WithStrategy(Constant(time.Hour)),
+		WithTrigger(trigger),
+	)
+
+	waiting := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		done <- r.Do(func(r *Retrier) error {
+			if r.AttemptCount() == 0 {
+				close(waiting)
+				return errors.New("not yet")
+			}
+			return nil
+		})
+	}()
+
+	<-waiting
+	// Keep kicking until the loop wakes, since the first kick may come before it's started waiting, while the attempt is
+	// still returning
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	timeout := time.After(10 * time.Second)
+	for {
+		select {
+		case err := <-done:
+			assert.NilError(t, err)
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			assert.Assert(t, r.totalSleep < time.Minute, "total sleep %s", r.totalSleep)
+			return
+		case <-ticker.C:
+			trigger.Kick()
+		case <-timeout:
+			t.Fatal("the loop wasn't woken")
+		}
+	}
+}
+
+func TestWithTrigger_KickDuringAnAttemptSkipsTheWait(t *testing.T) {
+	t.Parallel()
+
+	var trigger Trigger
+	r := NewRetrier(
+		WithMaxAttempts(3),
+		WithStrategy(Constant(time.Hour)),
+		WithTrigger(&trigger),
+	)
+
+	start := time.Now()
+	err := r.Do(func(r *Retrier) error {
+		if r.AttemptCount() == 0 {
+			trigger.Kick()
+			return errors.New("not yet")
+		}
+		return nil
+	})
+
+	assert.NilError(t, err)
+	assert.Assert(t, time.Since(start) < time.Minute)
+	assert.Equal(t, 2, r.Attempts())
+}
+
+func TestWithTrigger_KicksDontAddAttempts(t *testing.T) {
+	t.Parallel()
+
+	trigger := NewTrigger()
+	r := NewRetrier(
+		WithMaxAttempts(2),
+		WithStrategy(Constant(time.Hour)),
+		WithTrigger(trigger),
+	)
+
+	errFailed := errors.New("failed")
+	err := r.Do(func(r *Retrier) error {
+		trigger.Kick()
+		return errFailed
+	})
+
+	assert.ErrorIs(t, err, errFailed)
+	assert.Equal(t, 2, r.AttemptCount())
+}
+
+func TestWithTrigger_WithoutAKick_Waits(t *testing.T) {
+	t.Parallel()
+
+	var slept []time.Duration
+	r := NewRetrier(
+		WithMaxAttempts(2),
+		WithStrategy(Constant(time.Second)),
+		WithTrigger(NewTrigger()),
+		WithSleepFunc(func(d time.Duration) { slept = append(slept, d) }),
+	)
+
+	_ = r.Do(func(*Retrier) error { return errors.New("failed") })
+	assert.DeepEqual(t, []time.Duration{time.Second}, slept)
+}
+
+func TestTrigger_Sleep(t *testing.T) {
+	t.Parallel()
+
+	trigger := NewTrigger()
+	assert.NilError(t, trigger.Sleep(context.Background(), time.Millisecond))
+
+	// As above, keep kicking until the sleep is woken, in case the first kick comes before it starts
+	stop := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(10 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				trigger.Kick()
+			case <-stop:
+				return
+			}
+		}
+	}()
+	start := time.Now()
+	assert.NilError(t, trigger.Sleep(context.Background(), time.Hour))
+	close(stop)
+	assert.Assert(t, time.Since(start) < time.Minute)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.ErrorIs(t, trigger.Sleep(ctx, time.Hour), context.Canceled)
+}
@@ -0,0 +1,39 @@
+package roko
+
+import "time"
+
+// UntilClock makes the retrier give up at the next time the clock reads hour:min in loc - "keep trying until 09:00" -
+// rolling over to tomorrow if that time has already passed today. The time is worked out when the retrier is created,
+// using its clock (see WithClock). Like WithMaxTotalSleep, the retrier gives up as soon as waiting for the next interval
+// would take it past the time, rather than sleeping until then, and so it bounds retriers that try forever as far as
+// RequireBound is concerned. If loc is nil, the local time zone is used
+func UntilClock(hour, min int, loc *time.Location) retrierOpt {
+	if hour < 0 || hour > 23 || min < 0 || min > 59 {
+		panic("UntilClock needs an hour between 0 and 23, and a minute between 0 and 59")
+	}
+	if loc == nil {
+		loc = time.Local
+	}
+
+	return func(r *Retrier) {
+		r.until = &clockTime{hour: hour, min: min, loc: loc}
+	}
+}
+
+// clockTime is a time of day, as given to UntilClock
+type clockTime struct {
+	hour, min int
+	loc       *time.Location
+}
+
+// nextClock returns the first time after now that the clock reads hour:min in loc
+func nextClock(now time.Time, hour, min int, loc *time.Location) time.Time {
+	local := now.In(loc)
+	y, m, d := local.Date()
+
+	t := time.Date(y, m, d, hour, min, 0, 0, loc)
+	if !t.After(now) {
+		t = time.Date(y, m, d+1, hour, min, 0, 0, loc)
+	}
+	return t
+}
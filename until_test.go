@@ -0,0 +1,82 @@
+package roko
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestNextClock(t *testing.T) {
+	t.Parallel()
+
+	sydney := time.FixedZone("AEST", 10*60*60)
+
+	testCases := []struct {
+		name string
+		now  time.Time
+		loc  *time.Location
+		want time.Time
+	}{
+		{"later today", time.Date(2022, 6, 15, 7, 30, 0, 0, time.UTC), time.UTC, time.Date(2022, 6, 15, 9, 0, 0, 0, time.UTC)},
+		{"exactly now", time.Date(2022, 6, 15, 9, 0, 0, 0, time.UTC), time.UTC, time.Date(2022, 6, 16, 9, 0, 0, 0, time.UTC)},
+		{"tomorrow", time.Date(2022, 6, 15, 10, 0, 0, 0, time.UTC), time.UTC, time.Date(2022, 6, 16, 9, 0, 0, 0, time.UTC)},
+		{"end of the month", time.Date(2022, 6, 30, 23, 0, 0, 0, time.UTC), time.UTC, time.Date(2022, 7, 1, 9, 0, 0, 0, time.UTC)},
+		{"another time zone", time.Date(2022, 6, 15, 0, 0, 0, 0, time.UTC), sydney, time.Date(2022, 6, 15, 23, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Assert(t, nextClock(tc.now, 9, 0, tc.loc).Equal(tc.want))
+		})
+	}
+}
+
+func TestUntilClock_GivesUpBeforeWaitingPastTheTime(t *testing.T) {
+	t.Parallel()
+
+	r := NewRetrier(TryForever(), WithStrategy(Constant(time.Millisecond)), WithSleepFunc(dummySleep))
+	r.giveUpAt = time.Now().Add(50 * time.Millisecond)
+
+	err := r.Do(func(r *Retrier) error {
+		r.SetNextInterval(time.Duration(r.AttemptCount()) * 10 * time.Millisecond)
+		return errDummy
+	})
+
+	assert.ErrorIs(t, err, errDummy)
+	assert.Assert(t, time.Now().Before(r.giveUpAt))
+}
+
+func TestUntilClock_BoundsRetriersThatTryForever(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now().UTC()
+	r := NewRetrier(TryForever(), RequireBound(), WithStrategy(Constant(time.Hour)), UntilClock(now.Hour(), now.Minute(), time.UTC))
+
+	// The clock has already read now's hour and minute, so the retrier gives up this time tomorrow
+	assert.Assert(t, time.Until(r.giveUpAt) > 23*time.Hour)
+	assert.Assert(t, !r.isUnbounded(context.Background()))
+}
+
+func TestUntilClock_WithInvalidTime_Panics(t *testing.T) {
+	t.Parallel()
+
+	defer func() {
+		assert.Assert(t, recover() != nil)
+	}()
+
+	UntilClock(24, 0, time.UTC)
+}
+
+func TestUntilClock_WithoutALocation_UsesLocalTime(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2024, time.January, 1, 7, 0, 0, 0, time.Local)
+	r := NewRetrier(TryForever(), WithStrategy(Constant(time.Minute)), WithClock(NewSimulatedClock(now)), UntilClock(9, 0, nil))
+
+	assert.Assert(t, r.giveUpAt.Equal(time.Date(2024, time.January, 1, 9, 0, 0, 0, time.Local)))
+	assert.NilError(t, r.Do(func(*Retrier) error { return nil }))
+}
@@ -0,0 +1,165 @@
+package roko
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrValueClosed is returned by Value.Get after the value has been closed
+var ErrValueClosed = errors.New("roko: value is closed")
+
+// Value caches the result of a fetch function for a TTL - for feature flags, signing keys, config and so on. Fetches are
+// retried using a retrier, and only one happens at a time, however many goroutines are asking for the value. A Value is
+// safe to use concurrently
+type Value[T any] struct {
+	fetch      func(context.Context) (T, error)
+	ttl        time.Duration
+	newRetrier func() *Retrier
+	maxStale   time.Duration
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu        sync.Mutex
+	value     T
+	fetchedAt time.Time     // zero until the first successful fetch
+	fetching  chan struct{} // non-nil while a fetch is in progress, and closed when it finishes
+	fetchErr  error         // the error from the most recent fetch
+}
+
+type valueOpt func(*valueConfig)
+
+type valueConfig struct {
+	maxStale time.Duration
+}
+
+// WithServeStale lets a value keep serving its last fetched value for up to d after it expires, while a new one is
+// fetched in the background, or if fetching a new one fails. Without it, Get waits for the new value once the old one
+// has expired
+func WithServeStale(d time.Duration) valueOpt {
+	if d < 0 {
+		panic("max staleness must not be negative")
+	}
+
+	return func(c *valueConfig) {
+		c.maxStale = d
+	}
+}
+
+// NewValue returns a Value that gets its value by calling fetch, and caches it for ttl. Each fetch is retried with a new
+// retrier from newRetrier. The context passed to fetch isn't tied to any caller of Get, as the fetch is shared between
+// all of them; it's cancelled when the value is closed
+func NewValue[T any](fetch func(ctx context.Context) (T, error), ttl time.Duration, newRetrier func() *Retrier, opts ...valueOpt) *Value[T] {
+	if ttl <= 0 {
+		panic("values must have a positive TTL")
+	}
+
+	c := &valueConfig{}
+	for _, o := range opts {
+		o(c)
+	}
+
+	v := &Value[T]{
+		fetch:      fetch,
+		ttl:        ttl,
+		newRetrier: newRetrier,
+		maxStale:   c.maxStale,
+	}
+	v.ctx, v.cancel = context.WithCancel(context.Background())
+	return v
+}
+
+// Get returns the cached value if it hasn't expired. Once it has, Get starts fetching a new one in the background, and
+// either waits for it, or (with WithServeStale) returns the stale value straight away. If the fetch fails, Get returns
+// its error, unless there's a stale value it's allowed to serve instead
+func (v *Value[T]) Get(ctx context.Context) (T, error) {
+	var zero T
+
+	v.mu.Lock()
+	if v.ctx.Err() != nil {
+		v.mu.Unlock()
+		return zero, ErrValueClosed
+	}
+
+	now := time.Now()
+	if v.fresh(now) {
+		defer v.mu.Unlock()
+		return v.value, nil
+	}
+
+	done := v.startFetch()
+	if v.servable(now) {
+		defer v.mu.Unlock()
+		return v.value, nil
+	}
+	v.mu.Unlock()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return zero, ctx.Err()
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	now = time.Now()
+	switch {
+	case v.fresh(now), v.servable(now):
+		return v.value, nil
+	case v.fetchErr != nil:
+		return zero, v.fetchErr
+	default:
+		return zero, ErrValueClosed
+	}
+}
+
+// Close stops any fetch in progress. Get returns ErrValueClosed once the value has been closed
+func (v *Value[T]) Close() {
+	v.cancel()
+}
+
+// fresh returns whether the cached value hasn't expired at now. v.mu must be held
+func (v *Value[T]) fresh(now time.Time) bool {
+	return !v.fetchedAt.IsZero() && now.Before(v.fetchedAt.Add(v.ttl))
+}
+
+// servable returns whether the cached value can be served at now, even though it's expired. v.mu must be held
+func (v *Value[T]) servable(now time.Time) bool {
+	return !v.fetchedAt.IsZero() && v.maxStale > 0 && now.Before(v.fetchedAt.Add(v.ttl+v.maxStale))
+}
+
+// startFetch starts fetching a new value, if a fetch isn't already in progress, and returns a channel that's closed
+// when the fetch finishes. v.mu must be held
+func (v *Value[T]) startFetch() chan struct{} {
+	if v.fetching != nil {
+		return v.fetching
+	}
+
+	done := make(chan struct{})
+	v.fetching = done
+
+	go func() {
+		defer close(done)
+
+		value, err := DoFunc(v.ctx, v.newRetrier(), func(r *Retrier) (T, error) {
+			return v.fetch(r.Context())
+		})
+
+		v.mu.Lock()
+		defer v.mu.Unlock()
+
+		v.fetching = nil
+		v.fetchErr = err
+		if err != nil || v.ctx.Err() != nil {
+			return
+		}
+
+		v.value = value
+		v.fetchedAt = time.Now()
+	}()
+
+	return done
+}
@@ -0,0 +1,132 @@
+package roko
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func newValueRetrier() *Retrier {
+	return NewRetrier(WithMaxAttempts(3), WithStrategy(Constant(time.Millisecond)))
+}
+
+func TestValue_CachesForTheTTL(t *testing.T) {
+	t.Parallel()
+
+	var fetches int32
+	v := NewValue(func(ctx context.Context) (int32, error) {
+		return atomic.AddInt32(&fetches, 1), nil
+	}, time.Hour, newValueRetrier)
+	defer v.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			n, err := v.Get(context.Background())
+			assert.Check(t, err)
+			assert.Check(t, n == 1)
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&fetches))
+}
+
+func TestValue_RetriesFetches(t *testing.T) {
+	t.Parallel()
+
+	var fetches int32
+	v := NewValue(func(ctx context.Context) (string, error) {
+		if atomic.AddInt32(&fetches, 1) < 3 {
+			return "", errDummy
+		}
+		return "flags", nil
+	}, time.Hour, newValueRetrier)
+	defer v.Close()
+
+	s, err := v.Get(context.Background())
+	assert.NilError(t, err)
+	assert.Equal(t, "flags", s)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&fetches))
+}
+
+func TestValue_AfterTheTTL_FetchesAgain(t *testing.T) {
+	t.Parallel()
+
+	var fetches int32
+	v := NewValue(func(ctx context.Context) (int32, error) {
+		return atomic.AddInt32(&fetches, 1), nil
+	}, 20*time.Millisecond, newValueRetrier)
+	defer v.Close()
+
+	n, err := v.Get(context.Background())
+	assert.NilError(t, err)
+	assert.Equal(t, int32(1), n)
+
+	time.Sleep(30 * time.Millisecond)
+
+	n, err = v.Get(context.Background())
+	assert.NilError(t, err)
+	assert.Equal(t, int32(2), n)
+}
+
+func TestValue_WithServeStale_ServesTheOldValueWhenRefreshingFails(t *testing.T) {
+	t.Parallel()
+
+	var fetches int32
+	v := NewValue(func(ctx context.Context) (string, error) {
+		if atomic.AddInt32(&fetches, 1) == 1 {
+			return "signing key", nil
+		}
+		return "", errDummy
+	}, 20*time.Millisecond, newValueRetrier, WithServeStale(time.Hour))
+	defer v.Close()
+
+	_, err := v.Get(context.Background())
+	assert.NilError(t, err)
+	time.Sleep(30 * time.Millisecond)
+
+	for i := 0; i < 3; i++ {
+		s, err := v.Get(context.Background())
+		assert.NilError(t, err)
+		assert.Equal(t, "signing key", s)
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Assert(t, atomic.LoadInt32(&fetches) > 1)
+}
+
+func TestValue_WithoutServeStale_ReturnsTheFetchError(t *testing.T) {
+	t.Parallel()
+
+	var fetches int32
+	v := NewValue(func(ctx context.Context) (string, error) {
+		if atomic.AddInt32(&fetches, 1) == 1 {
+			return "config", nil
+		}
+		return "", errDummy
+	}, 20*time.Millisecond, newValueRetrier)
+	defer v.Close()
+
+	_, err := v.Get(context.Background())
+	assert.NilError(t, err)
+	time.Sleep(30 * time.Millisecond)
+
+	_, err = v.Get(context.Background())
+	assert.ErrorIs(t, err, errDummy)
+}
+
+func TestValue_AfterClose_ReturnsErrValueClosed(t *testing.T) {
+	t.Parallel()
+
+	v := NewValue(func(ctx context.Context) (int, error) { return 1, nil }, time.Hour, newValueRetrier)
+	v.Close()
+
+	_, err := v.Get(context.Background())
+	assert.ErrorIs(t, err, ErrValueClosed)
+}
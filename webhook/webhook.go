@@ -0,0 +1,271 @@
+// Package webhook delivers outbound webhooks, retrying failed deliveries according to a roko retrier. Pending
+// deliveries are persisted to a roko.Store as they're retried, so that a process that restarts can pick up where it left
+// off, rather than losing them or starting their retry schedules again from scratch.
+package webhook
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/buildkite/roko"
+)
+
+// ErrNotStarted is returned by Enqueue when the manager hasn't been started
+var ErrNotStarted = errors.New("webhook manager hasn't been started")
+
+// Delivery is a single webhook that's waiting to be delivered
+type Delivery struct {
+	ID      string // Identifies the delivery. Enqueue generates one if it's empty
+	URL     string // Where to deliver the payload to. The manager doesn't use it itself, it's there for the deliver func
+	Payload []byte
+
+	Attempts    int       // How many attempts have been made to deliver it so far
+	NextAttempt time.Time // When the next attempt is due. The zero time means as soon as possible
+	LastError   string    // The error from the most recent attempt, if there's been one
+}
+
+// payload is the part of a delivery that's kept in its roko.RetryState's Data
+type payload struct {
+	URL     string `json:"url"`
+	Payload []byte `json:"payload"`
+}
+
+// retryState converts d into the retry state it's stored as
+func (d Delivery) retryState() (roko.RetryState, error) {
+	data, err := json.Marshal(payload{URL: d.URL, Payload: d.Payload})
+	if err != nil {
+		return roko.RetryState{}, err
+	}
+
+	return roko.RetryState{
+		Key:         d.ID,
+		Attempts:    d.Attempts,
+		NextAttempt: d.NextAttempt,
+		LastError:   d.LastError,
+		Data:        data,
+	}, nil
+}
+
+// deliveryFromState converts a retry state loaded from a store back into the delivery it was saved from
+func deliveryFromState(state roko.RetryState) (Delivery, error) {
+	var p payload
+	if err := json.Unmarshal(state.Data, &p); err != nil {
+		return Delivery{}, fmt.Errorf("decoding delivery %q: %w", state.Key, err)
+	}
+
+	return Delivery{
+		ID:          state.Key,
+		URL:         p.URL,
+		Payload:     p.Payload,
+		Attempts:    state.Attempts,
+		NextAttempt: state.NextAttempt,
+		LastError:   state.LastError,
+	}, nil
+}
+
+// Manager delivers webhooks, retrying them as needed, and keeping its store up to date with the ones that are pending
+type Manager struct {
+	store      roko.Store
+	deliver    func(*roko.Retrier, Delivery) error
+	newRetrier func() *roko.Retrier
+	onFailed   func(Delivery, error)
+	onSaveErr  func(Delivery, error)
+
+	mu  sync.Mutex
+	ctx context.Context
+	wg  sync.WaitGroup
+}
+
+type managerOpt func(*Manager)
+
+// WithOnFailed sets a function that's called when a delivery is given up on, after its retrier has run out of attempts
+// or the delivery failed with an unrecoverable error. The delivery has already been removed from the store when it's
+// called
+func WithOnFailed(f func(Delivery, error)) managerOpt {
+	return func(m *Manager) {
+		m.onFailed = f
+	}
+}
+
+// WithOnStoreError sets a function that's called when the manager can't update the store while retrying a delivery.
+// The delivery carries on being retried in memory, but if the process restarts, it'll be resumed from the last state
+// that was saved successfully
+func WithOnStoreError(f func(Delivery, error)) managerOpt {
+	return func(m *Manager) {
+		m.onSaveErr = f
+	}
+}
+
+// NewManager returns a manager that persists pending deliveries to store (for example, a roko.FileStore), and delivers
+// them by calling deliver with the delivery's retrier, which it gets from newRetrier. As with any other retry loop, deliver should use r.Context() for
+// the request, can call r.SetNextInterval to honour a Retry-After header, and can return an error wrapped with
+// roko.Unrecoverable (for example, when the receiver responds with 410 Gone) to give up on the delivery straight away
+func NewManager(store roko.Store, deliver func(r *roko.Retrier, d Delivery) error, newRetrier func() *roko.Retrier, opts ...managerOpt) *Manager {
+	m := &Manager{
+		store:      store,
+		deliver:    deliver,
+		newRetrier: newRetrier,
+		onFailed:   func(Delivery, error) {},
+		onSaveErr:  func(Delivery, error) {},
+	}
+	for _, o := range opts {
+		o(m)
+	}
+	return m
+}
+
+// Start resumes the deliveries that were pending in the store, and lets the manager accept new ones. Deliveries are made
+// in the background until ctx is cancelled. Deliveries that are still pending when that happens stay in the store, to be
+// resumed by the next call to Start, possibly in another process
+func (m *Manager) Start(ctx context.Context) error {
+	states, err := m.store.Load(ctx)
+	if err != nil {
+		return err
+	}
+
+	pending := make([]Delivery, 0, len(states))
+	for _, state := range states {
+		d, err := deliveryFromState(state)
+		if err != nil {
+			return err
+		}
+		pending = append(pending, d)
+	}
+
+	m.mu.Lock()
+	m.ctx = ctx
+	m.mu.Unlock()
+
+	// Resume the deliveries in the order they were due, so that the oldest ones go out first
+	sort.SliceStable(pending, func(i, j int) bool {
+		return pending[i].NextAttempt.Before(pending[j].NextAttempt)
+	})
+	for _, d := range pending {
+		m.run(ctx, d)
+	}
+
+	return nil
+}
+
+// Enqueue saves a new delivery to the store, and starts delivering it. It returns the delivery's ID
+func (m *Manager) Enqueue(ctx context.Context, d Delivery) (string, error) {
+	m.mu.Lock()
+	runCtx := m.ctx
+	m.mu.Unlock()
+
+	if runCtx == nil {
+		return "", ErrNotStarted
+	}
+
+	if d.ID == "" {
+		d.ID = newID()
+	}
+	d.Attempts = 0
+	d.NextAttempt = time.Time{}
+	d.LastError = ""
+
+	if err := m.save(ctx, d); err != nil {
+		return "", err
+	}
+
+	m.run(runCtx, d)
+	return d.ID, nil
+}
+
+// Wait waits for all of the manager's deliveries to finish - either by being delivered, by being given up on, or by
+// the context passed to Start being cancelled
+func (m *Manager) Wait() {
+	m.wg.Wait()
+}
+
+// run delivers d in the background, resuming its retry schedule from where it was saved
+func (m *Manager) run(ctx context.Context, d Delivery) {
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+
+		r := m.newRetrier()
+
+		// Bring the retrier up to the point the delivery got to, so that its strategy picks up the schedule from there,
+		// and it doesn't get a fresh set of attempts every time the process restarts
+		for i := 0; i < d.Attempts; i++ {
+			r.MarkAttempt()
+		}
+		if r.ShouldGiveUp() {
+			m.fail(ctx, d, errors.New(d.LastError))
+			return
+		}
+
+		if wait := time.Until(d.NextAttempt); wait > 0 {
+			t := time.NewTimer(wait)
+			defer t.Stop()
+			select {
+			case <-t.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		err := r.DoWithContext(ctx, func(r *roko.Retrier) error {
+			err := m.deliver(r, d)
+			if err == nil {
+				return nil
+			}
+
+			// Record how far the delivery got, so that it can be resumed from here. The retrier has already worked out
+			// the interval before the next attempt (or been told it by SetNextInterval), so we can save when it's due
+			d.Attempts++
+			d.NextAttempt = time.Now().Add(r.NextInterval())
+			d.LastError = err.Error()
+			if saveErr := m.save(r.Context(), d); saveErr != nil {
+				m.onSaveErr(d, saveErr)
+			}
+
+			return err
+		})
+
+		switch {
+		case err == nil:
+			if err := m.store.Delete(context.Background(), d.ID); err != nil {
+				m.onSaveErr(d, err)
+			}
+		case ctx.Err() != nil:
+			// Leave the delivery in the store, to be resumed later
+		default:
+			m.fail(ctx, d, err)
+		}
+	}()
+}
+
+// save creates or updates d in the store
+func (m *Manager) save(ctx context.Context, d Delivery) error {
+	state, err := d.retryState()
+	if err != nil {
+		return err
+	}
+	return m.store.Save(ctx, state)
+}
+
+// fail removes a delivery that's been given up on from the store, and reports it
+func (m *Manager) fail(ctx context.Context, d Delivery, err error) {
+	if err := m.store.Delete(ctx, d.ID); err != nil {
+		m.onSaveErr(d, err)
+	}
+	m.onFailed(d, err)
+}
+
+// newID returns a random ID for a delivery
+func newID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
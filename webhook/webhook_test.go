@@ -0,0 +1,241 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/buildkite/roko"
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/poll"
+)
+
+var errUnavailable = errors.New("503 Service Unavailable")
+
+func newTestRetrier() *roko.Retrier {
+	return roko.NewRetrier(
+		roko.WithMaxAttempts(3),
+		roko.WithStrategy(roko.Constant(time.Second)),
+		roko.WithSleepFunc(func(time.Duration) {}),
+	)
+}
+
+// receiver is a fake webhook receiver, that fails the first few deliveries of each webhook
+type receiver struct {
+	mu       sync.Mutex
+	failures int
+	attempts map[string]int
+}
+
+func newReceiver(failures int) *receiver {
+	return &receiver{failures: failures, attempts: map[string]int{}}
+}
+
+func (rc *receiver) deliver(r *roko.Retrier, d Delivery) error {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	rc.attempts[d.ID]++
+	if rc.attempts[d.ID] <= rc.failures {
+		return errUnavailable
+	}
+	return nil
+}
+
+func (rc *receiver) attemptsFor(id string) int {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return rc.attempts[id]
+}
+
+func TestManager_DeliversAndRemovesFromStore(t *testing.T) {
+	t.Parallel()
+
+	store := roko.NewMemoryStore()
+	rc := newReceiver(2)
+	m := NewManager(store, rc.deliver, newTestRetrier)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	assert.NilError(t, m.Start(ctx))
+
+	id, err := m.Enqueue(ctx, Delivery{URL: "https://example.com/hook", Payload: []byte(`{"event":"build.finished"}`)})
+	assert.NilError(t, err)
+	assert.Assert(t, id != "")
+
+	m.Wait()
+
+	assert.Equal(t, 3, rc.attemptsFor(id))
+	pending, err := store.Load(ctx)
+	assert.NilError(t, err)
+	assert.Equal(t, 0, len(pending))
+}
+
+func TestManager_WhenDeliveryKeepsFailing_GivesUp(t *testing.T) {
+	t.Parallel()
+
+	var failed []Delivery
+	var failedErr error
+
+	store := roko.NewMemoryStore()
+	rc := newReceiver(100)
+	m := NewManager(store, rc.deliver, newTestRetrier, WithOnFailed(func(d Delivery, err error) {
+		failed = append(failed, d)
+		failedErr = err
+	}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	assert.NilError(t, m.Start(ctx))
+
+	id, err := m.Enqueue(ctx, Delivery{ID: "hook-1"})
+	assert.NilError(t, err)
+	assert.Equal(t, "hook-1", id)
+
+	m.Wait()
+
+	assert.Equal(t, 3, rc.attemptsFor(id))
+	assert.Equal(t, 1, len(failed))
+	assert.Equal(t, 3, failed[0].Attempts)
+	assert.ErrorIs(t, failedErr, errUnavailable)
+
+	pending, err := store.Load(ctx)
+	assert.NilError(t, err)
+	assert.Equal(t, 0, len(pending))
+}
+
+func TestManager_ResumesPendingDeliveriesFromTheStore(t *testing.T) {
+	t.Parallel()
+
+	store := roko.NewMemoryStore()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// This delivery has already used 2 of its 3 attempts in a previous process, so it only gets one more
+	state, err := Delivery{ID: "resumed", Attempts: 2, NextAttempt: time.Now(), LastError: "timeout"}.retryState()
+	assert.NilError(t, err)
+	assert.NilError(t, store.Save(ctx, state))
+
+	var failed []string
+	rc := newReceiver(100)
+	m := NewManager(store, rc.deliver, newTestRetrier, WithOnFailed(func(d Delivery, err error) {
+		failed = append(failed, d.ID)
+	}))
+	assert.NilError(t, m.Start(ctx))
+	m.Wait()
+
+	assert.Equal(t, 1, rc.attemptsFor("resumed"))
+	assert.DeepEqual(t, []string{"resumed"}, failed)
+}
+
+func TestManager_WhenStopped_LeavesPendingDeliveriesInTheStore(t *testing.T) {
+	t.Parallel()
+
+	store := roko.NewMemoryStore()
+	rc := newReceiver(100)
+	m := NewManager(store, rc.deliver, func() *roko.Retrier {
+		return roko.NewRetrier(roko.WithMaxAttempts(3), roko.WithStrategy(roko.Constant(time.Hour)))
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	assert.NilError(t, m.Start(ctx))
+
+	id, err := m.Enqueue(ctx, Delivery{})
+	assert.NilError(t, err)
+
+	poll.WaitOn(t, func(poll.LogT) poll.Result {
+		if rc.attemptsFor(id) == 1 {
+			return poll.Success()
+		}
+		return poll.Continue("waiting for the first attempt")
+	}, poll.WithTimeout(5*time.Second), poll.WithDelay(time.Millisecond))
+
+	cancel()
+	m.Wait()
+
+	pending, err := store.Load(context.Background())
+	assert.NilError(t, err)
+	assert.Equal(t, 1, len(pending))
+	assert.Equal(t, id, pending[0].Key)
+	assert.Equal(t, 1, pending[0].Attempts)
+	assert.Equal(t, "503 Service Unavailable", pending[0].LastError)
+	assert.Assert(t, time.Until(pending[0].NextAttempt) > 59*time.Minute)
+}
+
+func TestManager_EnqueueBeforeStart_ReturnsErrNotStarted(t *testing.T) {
+	t.Parallel()
+
+	m := NewManager(roko.NewMemoryStore(), newReceiver(0).deliver, newTestRetrier)
+	_, err := m.Enqueue(context.Background(), Delivery{})
+	assert.ErrorIs(t, err, ErrNotStarted)
+}
+
+func TestManager_ResumesDeliveriesFromAFileStore(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// The first process makes one attempt, and then stops while it's waiting for the next
+	store, err := roko.NewFileStore(dir)
+	assert.NilError(t, err)
+	rc := newReceiver(1)
+	first := NewManager(store, rc.deliver, func() *roko.Retrier {
+		return roko.NewRetrier(roko.WithMaxAttempts(3), roko.WithStrategy(roko.Constant(time.Hour)))
+	})
+	firstCtx, stop := context.WithCancel(ctx)
+	assert.NilError(t, first.Start(firstCtx))
+	id, err := first.Enqueue(ctx, Delivery{URL: "https://example.com/hook", Payload: []byte(`{"event":"build.finished"}`)})
+	assert.NilError(t, err)
+
+	poll.WaitOn(t, func(poll.LogT) poll.Result {
+		if states, _ := store.Load(ctx); len(states) == 1 && states[0].Attempts == 1 {
+			return poll.Success()
+		}
+		return poll.Continue("waiting for the first attempt to be saved")
+	}, poll.WithTimeout(5*time.Second), poll.WithDelay(time.Millisecond))
+	stop()
+	first.Wait()
+
+	// The second picks it up from the files the first left behind
+	store, err = roko.NewFileStore(dir)
+	assert.NilError(t, err)
+	var delivered []Delivery
+	second := NewManager(store, func(r *roko.Retrier, d Delivery) error {
+		delivered = append(delivered, d)
+		return nil
+	}, newTestRetrier)
+
+	// The saved NextAttempt is an hour away, so bring it forward rather than waiting for it
+	states, err := store.Load(ctx)
+	assert.NilError(t, err)
+	states[0].NextAttempt = time.Time{}
+	assert.NilError(t, store.Save(ctx, states[0]))
+
+	assert.NilError(t, second.Start(ctx))
+	second.Wait()
+
+	assert.Equal(t, 1, len(delivered))
+	assert.Equal(t, id, delivered[0].ID)
+	assert.Equal(t, "https://example.com/hook", delivered[0].URL)
+	assert.Equal(t, `{"event":"build.finished"}`, string(delivered[0].Payload))
+	assert.Equal(t, 1, delivered[0].Attempts)
+
+	states, err = store.Load(ctx)
+	assert.NilError(t, err)
+	assert.Equal(t, 0, len(states))
+}
+
+func TestManager_Start_WhenAStateCantBeDecoded_ReturnsAnError(t *testing.T) {
+	t.Parallel()
+
+	store := roko.NewMemoryStore()
+	assert.NilError(t, store.Save(context.Background(), roko.RetryState{Key: "broken", Data: []byte("not json")}))
+
+	m := NewManager(store, newReceiver(0).deliver, newTestRetrier)
+	err := m.Start(context.Background())
+	assert.ErrorContains(t, err, `decoding delivery "broken"`)
+}
@@ -0,0 +1,21 @@
+package roko
+
+import "context"
+
+// Wrap returns a version of f that retries it, so that a function can be decorated once, when it's set up, rather than
+// wrapping every call to it in Do:
+//
+//	getUser := roko.Wrap(newRetrier, client.GetUser)
+//	user, err := getUser(ctx)
+//
+// Retriers keep track of the attempts they've made, so each call to the returned function gets a new one from
+// newRetrier. f is passed the context for each attempt, as returned by r.Context(). The returned function has the same
+// signature as f, so it can be wrapped in turn by other middleware.
+// (Note this is not a method of Retrier, since methods can't be generic.)
+func Wrap[T any](newRetrier func() *Retrier, f func(ctx context.Context) (T, error)) func(ctx context.Context) (T, error) {
+	return func(ctx context.Context) (T, error) {
+		return DoFunc(ctx, newRetrier(), func(r *Retrier) (T, error) {
+			return f(r.Context())
+		})
+	}
+}
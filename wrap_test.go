@@ -0,0 +1,48 @@
+package roko
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestWrap_RetriesEachCall(t *testing.T) {
+	t.Parallel()
+
+	calls := 0
+	f := Wrap(func() *Retrier {
+		return NewRetrier(WithMaxAttempts(3), WithStrategy(Constant(time.Millisecond)), WithSleepFunc(dummySleep))
+	}, func(ctx context.Context) (int, error) {
+		calls++
+		_, ok := AttemptFromContext(ctx)
+		assert.Check(t, ok)
+		if calls%2 == 1 {
+			return 0, errDummy
+		}
+		return calls, nil
+	})
+
+	// Each call has its own retrier, so the second call gets its full three attempts too
+	n, err := f(context.Background())
+	assert.NilError(t, err)
+	assert.Equal(t, 2, n)
+
+	n, err = f(context.Background())
+	assert.NilError(t, err)
+	assert.Equal(t, 4, n)
+}
+
+func TestWrap_WhenTheRetrierGivesUp_ReturnsTheError(t *testing.T) {
+	t.Parallel()
+
+	f := Wrap(func() *Retrier {
+		return NewRetrier(WithMaxAttempts(2), WithStrategy(Constant(time.Millisecond)), WithSleepFunc(dummySleep))
+	}, func(ctx context.Context) (string, error) {
+		return "", errDummy
+	})
+
+	_, err := f(context.Background())
+	assert.ErrorIs(t, err, errDummy)
+}